@@ -0,0 +1,46 @@
+package phpserialize
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/kamiaka/go-phpserialize/internal/decoding"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// IsClosure reports whether v is a closure serialized by opis/closure,
+// Laravel's SerializableClosure or SuperClosure.
+//
+// Unmarshal keeps such closures opaque: they decode to Values created by
+// php.Raw that hold their original bytes, so that Marshal writes them back
+// byte for byte as long as they are not accessed, and IsClosure does not
+// decode them.
+func IsClosure(v *php.Value) bool {
+	if r := v.Raw(); r != nil {
+		name, ok := className(r.Bytes())
+		return ok && decoding.ClosureClasses[strings.ToLower(name)]
+	}
+	if v.IsNil() || v.Type() != php.TypeObject {
+		return false
+	}
+	return decoding.ClosureClasses[strings.ToLower(v.Object().Name)]
+}
+
+// className returns the class name in the header of the O: or C: object
+// serialized at the head of data, such as `O:8:"stdClass":0:{}`.
+func className(data []byte) (string, bool) {
+	if len(data) < 2 || data[0] != 'O' && data[0] != 'C' || data[1] != ':' {
+		return "", false
+	}
+	i := bytes.IndexByte(data[2:], ':')
+	if i < 0 {
+		return "", false
+	}
+	n, err := strconv.Atoi(string(data[2 : 2+i]))
+	start := 2 + i + 1 // the opening quote
+	if err != nil || n < 0 || n > len(data)-start-2 || data[start] != '"' || data[start+1+n] != '"' {
+		return "", false
+	}
+	return string(data[start+1 : start+1+n]), true
+}
@@ -0,0 +1,42 @@
+package phpserialize_test
+
+import (
+	"strings"
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestClosureRoundTrip(t *testing.T) {
+	opis := `C:32:"Opis\Closure\SerializableClosure":78:{a:2:{s:3:"use";a:0:{}s:8:"function";s:33:"function () { return 0.1 + 0.2; }";}}`
+	laravel := `O:47:"Laravel\SerializableClosure\SerializableClosure":1:{s:12:"serializable";O:46:"Laravel\SerializableClosure\Serializers\Signed":2:{s:12:"serializable";s:4:"x\0y";s:4:"hash";d:0.10000000000000001;}}`
+	data := `a:3:{s:5:"count";i:1;s:4:"opis";` + opis + `s:7:"laravel";` + laravel + `}`
+
+	v, err := phpserialize.Unmarshal([]byte(data))
+	if err != nil {
+		t.Fatalf("Unmarshal(%s) returns error: %v", data, err)
+	}
+	for _, name := range []string{"opis", "laravel"} {
+		if c := v.IndexByName(name); !phpserialize.IsClosure(c) || c.Raw() == nil {
+			t.Errorf("%s closure is not kept opaque", name)
+		}
+	}
+	if phpserialize.IsClosure(v.IndexByName("count")) {
+		t.Errorf("IsClosure(i:1;) == true")
+	}
+
+	v.Array()[0].Value = php.Int(2)
+	got, err := phpserialize.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal(...) returns error: %v", err)
+	}
+	if want := strings.Replace(data, "i:1;", "i:2;", 1); string(got) != want {
+		t.Errorf("Marshal(...) == %s\nwant: %s", got, want)
+	}
+
+	c := v.IndexByName("opis")
+	if name := c.Object().Name; name != `Opis\Closure\SerializableClosure` || !phpserialize.IsClosure(c) {
+		t.Errorf("decoded closure has class %q", name)
+	}
+}
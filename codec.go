@@ -0,0 +1,160 @@
+package phpserialize
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// Item flags used by PHP's memcached extension. The low four bits hold the
+// type of the stored value.
+const (
+	MemcachedString     uint32 = 0
+	MemcachedLong       uint32 = 1
+	MemcachedDouble     uint32 = 2
+	MemcachedBool       uint32 = 3
+	MemcachedSerialized uint32 = 4
+	MemcachedTypeMask   uint32 = 0xf
+
+	MemcachedCompressed uint32 = 1 << 4 // the value is compressed
+	MemcachedZlib       uint32 = 1 << 5 // compressed with zlib
+	MemcachedFastLZ     uint32 = 1 << 6 // compressed with FastLZ
+)
+
+// A Codec encodes and decodes cache values as PHP serialized data, so that Go
+// and PHP applications can share a cache. Its Marshal and Unmarshal methods
+// match the functions expected by Go cache clients such as go-redis/cache,
+// and MarshalItem and UnmarshalItem follow the item flags of PHP's memcached
+// extension for memcache clients.
+type Codec struct {
+	opts options
+}
+
+// NewCodec returns a new Codec that applies opts when encoding and decoding.
+func NewCodec(opts ...Option) *Codec {
+	return &Codec{opts: newOptions(opts)}
+}
+
+// Marshal returns the PHP serialized data of v.
+func (c *Codec) Marshal(v interface{}) ([]byte, error) {
+	return marshal(v, c.opts)
+}
+
+// Unmarshal decodes the PHP serialized data and stores the result in the
+// value pointed to by v, following the rules of UnmarshalInto.
+func (c *Codec) Unmarshal(data []byte, v interface{}) error {
+	return unmarshalData(data, v, c.opts)
+}
+
+// MarshalItem returns the value and flags PHP's memcached extension stores
+// for v: strings, integers, floats and bools are stored as text with their
+// type in the flags, and other values are serialized.
+func (c *Codec) MarshalItem(v interface{}) (value []byte, flags uint32, err error) {
+	switch x := v.(type) {
+	case []byte:
+		return x, MemcachedString, nil
+	case *php.Value:
+		switch x.Type() {
+		case php.TypeString:
+			return []byte(x.String()), MemcachedString, nil
+		case php.TypeInt:
+			return []byte(strconv.FormatInt(x.Int(), 10)), MemcachedLong, nil
+		case php.TypeFloat:
+			return []byte(dumpFloat(x.Float())), MemcachedDouble, nil
+		case php.TypeBool:
+			return []byte(phpBoolString(x.Bool())), MemcachedBool, nil
+		}
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.String:
+			return []byte(rv.String()), MemcachedString, nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return []byte(strconv.FormatInt(rv.Int(), 10)), MemcachedLong, nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if rv.Uint() <= math.MaxInt64 {
+				return []byte(strconv.FormatUint(rv.Uint(), 10)), MemcachedLong, nil
+			}
+		case reflect.Float32, reflect.Float64:
+			return []byte(dumpFloat(rv.Float())), MemcachedDouble, nil
+		case reflect.Bool:
+			return []byte(phpBoolString(rv.Bool())), MemcachedBool, nil
+		}
+	}
+	value, err = c.Marshal(v)
+	return value, MemcachedSerialized, err
+}
+
+// UnmarshalItem decodes a value stored by PHP's memcached extension with the
+// given flags and stores the result in the value pointed to by v, following
+// the rules of UnmarshalInto. Values compressed with zlib are decompressed;
+// FastLZ compression and the igbinary, JSON and msgpack serializers are not
+// supported.
+func (c *Codec) UnmarshalItem(value []byte, flags uint32, v interface{}) error {
+	if flags&MemcachedCompressed != 0 {
+		if flags&MemcachedFastLZ != 0 {
+			return fmt.Errorf("php serialize: unsupported memcached compression in flags %#x", flags)
+		}
+		var err error
+		if value, err = memcachedInflate(value, c.opts.maxInputBytes); err != nil {
+			return err
+		}
+	}
+	var pv *php.Value
+	switch t := flags & MemcachedTypeMask; t {
+	case MemcachedString:
+		pv = php.String(string(value))
+	case MemcachedLong:
+		i, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("php serialize: invalid memcached long %q", value)
+		}
+		pv = php.Int(int(i))
+	case MemcachedDouble:
+		f, err := strconv.ParseFloat(string(value), 64)
+		if err != nil {
+			return fmt.Errorf("php serialize: invalid memcached double %q", value)
+		}
+		pv = php.Float(f)
+	case MemcachedBool:
+		pv = php.Bool(string(value) == "1")
+	case MemcachedSerialized:
+		return c.Unmarshal(value, v)
+	default:
+		return fmt.Errorf("php serialize: unsupported memcached value type %d", t)
+	}
+	return unmarshalValue(pv, v, c.opts)
+}
+
+// memcachedInflate decompresses a zlib-compressed memcached value, which is
+// preceded by its uncompressed length as a 4-byte little-endian integer. The
+// value fails with ErrTooLarge if it exceeds max bytes, unless max is 0.
+func memcachedInflate(value []byte, max int64) ([]byte, error) {
+	if len(value) < 4 {
+		return nil, fmt.Errorf("php serialize: compressed memcached value too short")
+	}
+	n := binary.LittleEndian.Uint32(value)
+	if max > 0 && int64(n) > max {
+		return nil, tooLargeError("decompressed data exceeds %d bytes", max)
+	}
+	r, err := zlib.NewReader(bytes.NewReader(value[4:]))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	bs, err := io.ReadAll(io.LimitReader(r, int64(n)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(bs) != int(n) {
+		return nil, fmt.Errorf("php serialize: compressed memcached value has %d bytes, want %d", len(bs), n)
+	}
+	return bs, nil
+}
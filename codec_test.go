@@ -0,0 +1,92 @@
+package phpserialize_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestCodec(t *testing.T) {
+	c := phpserialize.NewCodec(phpserialize.WithMaxDepth(2))
+	bs, err := c.Marshal(map[string]int{"a": 1})
+	if err != nil {
+		t.Fatalf("Marshal(...) returns error: %v", err)
+	}
+	var m map[string]int
+	if err := c.Unmarshal(bs, &m); err != nil {
+		t.Fatalf("Unmarshal(%q) returns error: %v", bs, err)
+	}
+	if !reflect.DeepEqual(m, map[string]int{"a": 1}) {
+		t.Errorf("Unmarshal(%q) sets %v, want: %v", bs, m, map[string]int{"a": 1})
+	}
+	if err := c.Unmarshal([]byte(`a:1:{i:0;a:1:{i:0;a:0:{}}}`), new(interface{})); err == nil {
+		t.Errorf("Unmarshal(too deep) returns no error")
+	}
+}
+
+func TestCodecItem(t *testing.T) {
+	cases := []struct {
+		v     interface{}
+		value string
+		flags uint32
+		ptr   interface{}
+	}{
+		{v: "abc", value: "abc", flags: phpserialize.MemcachedString, ptr: new(string)},
+		{v: 42, value: "42", flags: phpserialize.MemcachedLong, ptr: new(int)},
+		{v: 1.5, value: "1.5", flags: phpserialize.MemcachedDouble, ptr: new(float64)},
+		{v: true, value: "1", flags: phpserialize.MemcachedBool, ptr: new(bool)},
+		{v: false, value: "", flags: phpserialize.MemcachedBool, ptr: new(bool)},
+		{v: []string{"x"}, value: `a:1:{i:0;s:1:"x";}`, flags: phpserialize.MemcachedSerialized, ptr: new([]string)},
+	}
+	c := phpserialize.NewCodec()
+	for i, tc := range cases {
+		value, flags, err := c.MarshalItem(tc.v)
+		if err != nil {
+			t.Errorf("#%d: MarshalItem(%#v) returns error: %v", i, tc.v, err)
+			continue
+		}
+		if string(value) != tc.value || flags != tc.flags {
+			t.Errorf("#%d: MarshalItem(%#v) == %q, %d, want: %q, %d", i, tc.v, value, flags, tc.value, tc.flags)
+		}
+		if err := c.UnmarshalItem([]byte(tc.value), tc.flags, tc.ptr); err != nil {
+			t.Errorf("#%d: UnmarshalItem(%q, %d) returns error: %v", i, tc.value, tc.flags, err)
+			continue
+		}
+		if got := reflect.ValueOf(tc.ptr).Elem().Interface(); !reflect.DeepEqual(got, tc.v) {
+			t.Errorf("#%d: UnmarshalItem(%q, %d) sets %#v, want: %#v", i, tc.value, tc.flags, got, tc.v)
+		}
+	}
+
+	data := []byte(`s:5:"hello";`)
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	var v *php.Value
+	flags := phpserialize.MemcachedSerialized | phpserialize.MemcachedCompressed | phpserialize.MemcachedZlib
+	if err := c.UnmarshalItem(buf.Bytes(), flags, &v); err != nil {
+		t.Fatalf("UnmarshalItem(compressed) returns error: %v", err)
+	}
+	if got := v.StringOr(""); got != "hello" {
+		t.Errorf("UnmarshalItem(compressed) sets %q, want: %q", got, "hello")
+	}
+
+	limited := phpserialize.NewCodec(phpserialize.WithMaxInputBytes(10))
+	if err := limited.UnmarshalItem(buf.Bytes(), flags, &v); !errors.Is(err, phpserialize.ErrTooLarge) {
+		t.Errorf("UnmarshalItem(compressed) with WithMaxInputBytes(10) returns error: %v, want: %v", err, phpserialize.ErrTooLarge)
+	}
+
+	if err := c.UnmarshalItem([]byte("x"), 6, new(interface{})); err == nil {
+		t.Errorf("UnmarshalItem(json) returns no error")
+	}
+	if err := c.UnmarshalItem([]byte("x"), phpserialize.MemcachedLong, new(int)); err == nil {
+		t.Errorf("UnmarshalItem(invalid long) returns no error")
+	}
+}
@@ -0,0 +1,64 @@
+package phpserialize
+
+import "strconv"
+
+// Feature identifies a PHP serialize construct beyond the basic N, b, i, d,
+// s, a and O tokens, which are always supported.
+type Feature uint
+
+// Features
+const (
+	// FeatureReferences is the R: and r: reference tokens.
+	FeatureReferences Feature = iota
+	// FeatureCustomSerialized is the C: token written by classes
+	// implementing Serializable.
+	FeatureCustomSerialized
+	// FeatureEnums is the E: token for PHP 8.1 enum cases.
+	FeatureEnums
+	// FeatureEscapedStrings is the S: token with escaped string bodies.
+	FeatureEscapedStrings
+	// FeatureFloatPrecision is float formatting identical to PHP's
+	// serialize() with the default serialize_precision.
+	FeatureFloatPrecision
+)
+
+var featureNames = []string{
+	FeatureReferences:       "references",
+	FeatureCustomSerialized: "custom serialized",
+	FeatureEnums:            "enums",
+	FeatureEscapedStrings:   "escaped strings",
+	FeatureFloatPrecision:   "float precision",
+}
+
+func (f Feature) String() string {
+	if int(f) < len(featureNames) {
+		return featureNames[f]
+	}
+	return "feature" + strconv.Itoa(int(f))
+}
+
+// supportedFeatures lists the features that both Marshal and Unmarshal
+// round-trip faithfully.
+var supportedFeatures = map[Feature]bool{
+	FeatureCustomSerialized: true,
+	FeatureEscapedStrings:   true,
+	FeatureFloatPrecision:   true,
+}
+
+// Supported reports whether this build can decode and re-encode f without
+// losing information.
+func (f Feature) Supported() bool {
+	return supportedFeatures[f]
+}
+
+// Compatibility returns the features this build supports, in Feature order,
+// so services can detect at runtime which payloads they can round-trip.
+func Compatibility() []Feature {
+	var fs []Feature
+	for f := Feature(0); int(f) < len(featureNames); f++ {
+		if f.Supported() {
+			fs = append(fs, f)
+		}
+	}
+	return fs
+}
@@ -0,0 +1,18 @@
+package phpserialize_test
+
+import (
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+)
+
+func TestCompatibility(t *testing.T) {
+	for _, f := range phpserialize.Compatibility() {
+		if !f.Supported() {
+			t.Errorf("Compatibility() contains unsupported feature %v", f)
+		}
+	}
+	if phpserialize.FeatureReferences.Supported() {
+		t.Errorf("%v.Supported() == true, want: false", phpserialize.FeatureReferences)
+	}
+}
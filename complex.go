@@ -0,0 +1,99 @@
+package phpserialize
+
+import (
+	"reflect"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// ComplexEncoding is the policy for encoding complex numbers, for which PHP
+// has no type.
+type ComplexEncoding uint
+
+// Complex encodings
+const (
+	// ComplexError fails with an UnsupportedTypeError.
+	ComplexError ComplexEncoding = iota
+	// ComplexList encodes complex numbers as lists of their real and
+	// imaginary parts, [re, im].
+	ComplexList
+	// ComplexAssoc encodes complex numbers as arrays with the keys "re" and
+	// "im".
+	ComplexAssoc
+	// ComplexObject encodes complex numbers as objects with the public
+	// properties re and im, of class Complex unless a class name mapper
+	// names their type.
+	ComplexObject
+)
+
+func (e *encodeState) writeComplex(v reflect.Value) {
+	c := v.Complex()
+	switch e.complexEncoding {
+	case ComplexList:
+		e.writeArrayHeader(2)
+		e.writeInt(0)
+		e.writeFloat(real(c))
+		e.writeInt(1)
+		e.writeFloat(imag(c))
+	case ComplexAssoc:
+		e.writeArrayHeader(2)
+		e.writeString("re")
+		e.writeFloat(real(c))
+		e.writeString("im")
+		e.writeFloat(imag(c))
+	case ComplexObject:
+		class := "Complex"
+		if e.classNameMapper != nil {
+			class = e.classNameMapper(v.Type())
+		}
+		e.writeObjectHeader(class, 2)
+		e.writeString("re")
+		e.writeFloat(real(c))
+		e.writeString("im")
+		e.writeFloat(imag(c))
+	default:
+		raiseError(&UnsupportedTypeError{v.Type()})
+	}
+	e.writeEnd()
+}
+
+// assignComplex decodes a number, or any of the forms of ComplexEncoding,
+// into the complex v.
+func (a *assignState) assignComplex(src *php.Value, v reflect.Value) {
+	var re, im *php.Value
+	switch src.Type() {
+	case php.TypeInt, php.TypeFloat:
+		re, im = src, php.Int(0)
+	case php.TypeArray:
+		if re, im = src.IndexByInt(0), src.IndexByInt(1); re == nil {
+			re, im = src.IndexByName("re"), src.IndexByName("im")
+		}
+		if len(src.Array()) != 2 {
+			re = nil
+		}
+	case php.TypeObject:
+		obj := src.Object()
+		if f, g := obj.Field("re"), obj.Field("im"); f != nil && g != nil && len(obj.Fields) == 2 {
+			re, im = f.Value, g.Value
+		}
+	}
+	x, okx := complexPart(re)
+	y, oky := complexPart(im)
+	if !okx || !oky {
+		a.assignError(src, v.Type())
+	}
+	v.SetComplex(complex(x, y))
+}
+
+func complexPart(v *php.Value) (float64, bool) {
+	if v == nil {
+		return 0, false
+	}
+	switch v.Type() {
+	case php.TypeInt:
+		return float64(v.Int()), true
+	case php.TypeFloat:
+		return v.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,62 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+)
+
+func TestComplexEncoding(t *testing.T) {
+	v := complex(1.5, -2)
+	cases := []struct {
+		ce   phpserialize.ComplexEncoding
+		want string
+	}{
+		{ce: phpserialize.ComplexList, want: `a:2:{i:0;d:1.5;i:1;d:-2;}`},
+		{ce: phpserialize.ComplexAssoc, want: `a:2:{s:2:"re";d:1.5;s:2:"im";d:-2;}`},
+		{ce: phpserialize.ComplexObject, want: `O:7:"Complex":2:{s:2:"re";d:1.5;s:2:"im";d:-2;}`},
+	}
+	for i, c := range cases {
+		got, err := phpserialize.MarshalWithOptions(v, phpserialize.WithComplexEncoding(c.ce))
+		if err != nil {
+			t.Errorf("#%d: MarshalWithOptions(%v) returns error: %v", i, v, err)
+			continue
+		}
+		if string(got) != c.want {
+			t.Errorf("#%d: MarshalWithOptions(%v) == %s, want: %s", i, v, got, c.want)
+		}
+		var back complex128
+		if err := phpserialize.UnmarshalInto(got, &back); err != nil || back != v {
+			t.Errorf("#%d: UnmarshalInto(%s) == %v, %v, want: %v", i, got, back, err, v)
+		}
+	}
+
+	var ute *phpserialize.UnsupportedTypeError
+	if _, err := phpserialize.Marshal(v); !errors.As(err, &ute) {
+		t.Errorf("Marshal(%v) returns error: %v, want: UnsupportedTypeError", v, err)
+	}
+}
+
+func TestUnmarshalComplex(t *testing.T) {
+	cases := []struct {
+		data string
+		want complex64
+		ok   bool
+	}{
+		{data: `i:3;`, want: 3, ok: true},
+		{data: `a:2:{i:0;i:1;i:1;d:0.5;}`, want: complex(1, 0.5), ok: true},
+		{data: `a:1:{i:0;i:1;}`},
+		{data: `a:3:{i:0;i:1;i:1;i:2;i:2;i:3;}`},
+		{data: `O:1:"C":2:{s:2:"im";i:1;s:2:"re";i:2;}`, want: complex(2, 1), ok: true},
+		{data: `s:1:"1";`},
+	}
+	for i, c := range cases {
+		var got complex64
+		err := phpserialize.UnmarshalInto([]byte(c.data), &got)
+		if (err == nil) != c.ok || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("#%d: UnmarshalInto(%s) == %v, %v, want: %v, ok: %v", i, c.data, got, err, c.want, c.ok)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package phpserialize
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+)
+
+// Compression is a compression format of serialized data.
+type Compression int
+
+// Compression formats. Zlib is the format of PHP's gzcompress and Gzip the
+// format of gzencode.
+const (
+	Zlib Compression = iota
+	Gzip
+)
+
+// A CompressedCodec is a Codec for PHP serialized data compressed as PHP
+// applications often store large cache entries, like
+// gzcompress(serialize($v)). It compresses the data it encodes, and
+// decompresses the data it decodes if it starts with zlib or gzip magic
+// bytes, passing uncompressed data through.
+type CompressedCodec struct {
+	codec       Codec
+	compression Compression
+}
+
+// NewCompressedCodec returns a new CompressedCodec that compresses in the
+// format c and applies opts when encoding and decoding. WithMaxInputBytes
+// limits the size of decompressed data.
+func NewCompressedCodec(c Compression, opts ...Option) *CompressedCodec {
+	return &CompressedCodec{
+		codec:       Codec{opts: newOptions(opts)},
+		compression: c,
+	}
+}
+
+// Marshal returns the compressed PHP serialized data of v.
+func (c *CompressedCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch c.compression {
+	case Zlib:
+		w = zlib.NewWriter(&buf)
+	case Gzip:
+		w = gzip.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("php serialize: unknown compression %d", c.compression)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decompresses data if it is compressed with zlib or gzip, decodes
+// the PHP serialized data and stores the result in the value pointed to by
+// v, following the rules of UnmarshalInto.
+func (c *CompressedCodec) Unmarshal(data []byte, v interface{}) error {
+	data, err := decompress(data, c.codec.opts.maxInputBytes)
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(data, v)
+}
+
+// decompress returns data decompressed if it starts with the magic bytes of
+// gzip or zlib, or data itself otherwise. The decompressed data fails with
+// ErrTooLarge if it exceeds max bytes, unless max is 0.
+func decompress(data []byte, max int64) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch {
+	case len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b:
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	case len(data) >= 2 && data[0]&0x0f == 8 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0:
+		// deflate with a valid header checksum; no serialized value starts
+		// with such a byte
+		r, err = zlib.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("php serialize: cannot decompress data: %w", err)
+	}
+	defer r.Close()
+
+	var src io.Reader = r
+	if max > 0 {
+		src = io.LimitReader(r, max+1)
+	}
+	bs, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("php serialize: cannot decompress data: %w", err)
+	}
+	if max > 0 && int64(len(bs)) > max {
+		return nil, tooLargeError("decompressed data exceeds %d bytes", max)
+	}
+	return bs, nil
+}
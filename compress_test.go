@@ -0,0 +1,62 @@
+package phpserialize_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+)
+
+func TestCompressedCodec(t *testing.T) {
+	want := map[string]string{"key": strings.Repeat("value", 100)}
+	for _, compression := range []phpserialize.Compression{phpserialize.Zlib, phpserialize.Gzip} {
+		c := phpserialize.NewCompressedCodec(compression)
+		bs, err := c.Marshal(want)
+		if err != nil {
+			t.Fatalf("%d: Marshal(...) returns error: %v", compression, err)
+		}
+		if len(bs) > 100 {
+			t.Errorf("%d: Marshal(...) returns %d bytes, want them compressed", compression, len(bs))
+		}
+		var got map[string]string
+		if err := c.Unmarshal(bs, &got); err != nil {
+			t.Fatalf("%d: Unmarshal(...) returns error: %v", compression, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: Unmarshal(...) sets %v, want: %v", compression, got, want)
+		}
+	}
+}
+
+func TestCompressedCodecUnmarshal(t *testing.T) {
+	// gzcompress(serialize([1, 2]))
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte(`a:2:{i:0;i:1;i:1;i:2;}`))
+	w.Close()
+
+	c := phpserialize.NewCompressedCodec(phpserialize.Gzip)
+	for _, data := range [][]byte{buf.Bytes(), []byte(`a:2:{i:0;i:1;i:1;i:2;}`)} {
+		var got []int
+		if err := c.Unmarshal(data, &got); err != nil {
+			t.Errorf("Unmarshal(%q) returns error: %v", data, err)
+			continue
+		}
+		if want := []int{1, 2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Unmarshal(%q) sets %v, want: %v", data, got, want)
+		}
+	}
+
+	if err := c.Unmarshal(buf.Bytes()[:buf.Len()-4], new(interface{})); err == nil {
+		t.Errorf("Unmarshal(truncated) returns no error")
+	}
+
+	c = phpserialize.NewCompressedCodec(phpserialize.Zlib, phpserialize.WithMaxInputBytes(10))
+	if err := c.Unmarshal(buf.Bytes(), new(interface{})); !errors.Is(err, phpserialize.ErrTooLarge) {
+		t.Errorf("Unmarshal(...) with WithMaxInputBytes(10) returns error: %v, want: %v", err, phpserialize.ErrTooLarge)
+	}
+}
@@ -0,0 +1,23 @@
+package phpserialize
+
+import (
+	"context"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// UnmarshalContext is like UnmarshalWithOptions but stops with an error
+// wrapping ctx.Err() when ctx is done before data has been decoded. ctx is
+// checked periodically while reading arrays and objects, so that decoding a
+// huge value can be abandoned.
+func UnmarshalContext(ctx context.Context, data []byte, opts ...Option) (*php.Value, error) {
+	return unmarshal(ctx, data, newOptions(opts))
+}
+
+// DecodeContext is like Decode but stops with an error wrapping ctx.Err()
+// when ctx is done before the value has been decoded, checking ctx while
+// reading arrays and objects and before reading more input. The value a
+// cancelled call was reading is read again by the next call.
+func (dec *Decoder) DecodeContext(ctx context.Context) (*php.Value, error) {
+	return dec.decode(ctx)
+}
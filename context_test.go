@@ -0,0 +1,49 @@
+package phpserialize_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+)
+
+func hugeList(n int) string {
+	var b strings.Builder
+	b.WriteString("a:" + strconv.Itoa(n) + ":{")
+	for i := 0; i < n; i++ {
+		b.WriteString("i:" + strconv.Itoa(i) + ";N;")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func TestUnmarshalContext(t *testing.T) {
+	data := hugeList(5000)
+	if _, err := phpserialize.UnmarshalContext(context.Background(), []byte(data)); err != nil {
+		t.Fatalf("UnmarshalContext returns error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := phpserialize.UnmarshalContext(ctx, []byte(data)); !errors.Is(err, context.Canceled) {
+		t.Errorf("UnmarshalContext returns error: %v, want: %v", err, context.Canceled)
+	}
+	if _, err := phpserialize.UnmarshalContext(ctx, []byte(`a:1:{i:0;N;}`)); err != nil {
+		t.Errorf("UnmarshalContext of a small value returns error: %v", err)
+	}
+}
+
+func TestDecoderDecodeContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dec := phpserialize.NewDecoder(strings.NewReader(hugeList(5000)))
+	if _, err := dec.DecodeContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("DecodeContext returns error: %v, want: %v", err, context.Canceled)
+	}
+	if _, err := dec.Decode(); err != nil {
+		t.Errorf("Decode after a cancelled DecodeContext returns error: %v", err)
+	}
+}
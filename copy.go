@@ -0,0 +1,118 @@
+package phpserialize
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/kamiaka/go-phpserialize/internal/decoding"
+)
+
+// copyBufferSize is the size of the reads of CopyValid.
+const copyBufferSize = 32 * 1024
+
+// CopyValid copies the PHP serialized values read from src to dst, checking
+// their syntax as they stream through, and returns the number of bytes
+// written. Values are checked without building arrays and objects, and
+// bytes are written once they are checked, so memory usage is bounded by
+// the size of the reads whatever the size of the values. Copying stops at
+// the end of src or at the first value that is malformed or exceeds the
+// limits of opts. The bytes of that value checked before the error have
+// been written, so dst then ends with an incomplete value.
+func CopyValid(dst io.Writer, src io.Reader, opts ...Option) (written int64, err error) {
+	c := newValidCopier(dst, newOptions(opts))
+	buf := make([]byte, copyBufferSize)
+	var read int64
+	for {
+		n, rerr := src.Read(buf)
+		data := buf[:n]
+		if max := c.opts.maxInputBytes; max > 0 && read+int64(n) > max {
+			data = data[:max-read]
+			rerr = tooLargeError("input exceeds limit of %d bytes", max)
+		}
+		read += int64(len(data))
+		serr := c.scan(data)
+		if err := c.flush(); err != nil {
+			return c.written, err
+		}
+		if serr != nil {
+			return c.written, serr
+		}
+		if rerr == io.EOF {
+			if !c.s.Started() {
+				return c.written, nil
+			}
+			if lr, ok := src.(*io.LimitedReader); ok && lr.N <= 0 {
+				return c.written, tooLargeError("value cut off by the read limit")
+			}
+			return c.written, fmt.Errorf("php serialize: %w in value, position: %d", io.ErrUnexpectedEOF, c.scanned)
+		}
+		if rerr != nil {
+			return c.written, rerr
+		}
+	}
+}
+
+// A validCopier checks the values copied by CopyValid.
+type validCopier struct {
+	opts    options
+	dst     io.Writer
+	s       *decoding.Scanner // scanner of the value being read
+	scanned int               // bytes of the value being read scanned so far
+	pending []byte            // bytes scanned but not written yet
+	valid   int               // bytes of pending known to be valid
+	written int64
+}
+
+func newValidCopier(dst io.Writer, opts options) *validCopier {
+	c := &validCopier{opts: opts, dst: dst}
+	c.reset()
+	return c
+}
+
+// reset starts the scan of the next value.
+func (c *validCopier) reset() {
+	o := c.opts.decodeOptions()
+	o.MaxBytes = 0 // checked by scan without scanning beyond the limit
+	c.s = &decoding.Scanner{Options: o, Check: true}
+	c.scanned = 0
+}
+
+// scan checks data, which follows the bytes scanned before.
+func (c *validCopier) scan(data []byte) error {
+	for len(data) > 0 {
+		chunk, limited := data, false
+		if max := c.opts.maxBytes; max > 0 && c.scanned+len(chunk) > max {
+			chunk, limited = chunk[:max-c.scanned], true
+		}
+		n, done, err := c.s.Scan(chunk)
+		c.pending = append(c.pending, chunk[:n]...)
+		data = data[n:]
+		c.scanned += n
+		if done {
+			c.valid = len(c.pending)
+			c.reset()
+			continue
+		}
+		// the bytes not checked yet are at the end of pending
+		c.valid = len(c.pending) - (c.scanned - c.s.Checked())
+		if err != nil {
+			return err
+		}
+		if limited {
+			return tooLargeError("value size exceeds limit of %d bytes", c.opts.maxBytes)
+		}
+	}
+	return nil
+}
+
+// flush writes the bytes known to be valid.
+func (c *validCopier) flush() error {
+	if c.valid == 0 {
+		return nil
+	}
+	n, err := writeAll(c.dst, c.pending[:c.valid])
+	c.written += int64(n)
+	c.pending = append(c.pending[:0], c.pending[c.valid:]...)
+	c.valid = 0
+	return err
+}
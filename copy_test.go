@@ -0,0 +1,172 @@
+package phpserialize_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+)
+
+func TestCopyValid(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+		err  bool
+	}{
+		{src: ``, want: ``},
+		{src: `i:1;`, want: `i:1;`},
+		{
+			src:  `a:2:{i:0;s:3:"a;b";s:1:"k";O:8:"stdClass":1:{s:1:"x";d:-1.5E+30;}}b:1;N;C:3:"Foo":2:{ab}`,
+			want: `a:2:{i:0;s:3:"a;b";s:1:"k";O:8:"stdClass":1:{s:1:"x";d:-1.5E+30;}}b:1;N;C:3:"Foo":2:{ab}`,
+		},
+		{src: `i:1;i:x;i:3;`, want: `i:1;i:`, err: true},
+		{src: `i:1;b:2;`, want: `i:1;b:`, err: true},
+		{src: `a:1:{d:1.5;i:0;}`, want: `a:1:{`, err: true},
+		{src: `a:1:{a:0:{}i:0;}`, want: `a:1:{`, err: true},
+		{src: `a:2:{i:0;i:1;}`, want: `a:2:{i:0;i:1;`, err: true},
+		{src: `s:5:"abc";`, want: `s:5:"abc";`, err: true},
+		{src: `i:1;a:1:{i:0;`, want: `i:1;a:1:{i:0;`, err: true},
+	}
+	for i, tc := range cases {
+		var dst bytes.Buffer
+		n, err := phpserialize.CopyValid(&dst, iotest.OneByteReader(strings.NewReader(tc.src)))
+		if (err != nil) != tc.err {
+			t.Errorf("#%d: CopyValid(%s) returns error: %v, want error: %v", i, tc.src, err, tc.err)
+		}
+		if got := dst.String(); got != tc.want || n != int64(len(tc.want)) {
+			t.Errorf("#%d: CopyValid(%s) == %d and writes %s, want: %d and %s", i, tc.src, n, got, len(tc.want), tc.want)
+		}
+	}
+}
+
+func TestCopyValidMatchesUnmarshal(t *testing.T) {
+	// the Scanner of CopyValid and the decoder share the grammar, and so
+	// accept the same values
+	srcs := []string{
+		`N;`, `n;`, `N:`, `b:1;`, `b:2;`, `b:;`, `i:-12;`, `i:+7;`, `i:1.5;`, `i:99999999999999999999;`,
+		`d:1.5;`, `d:-1.5E+30;`, `d:NAN;`, `d:-INF;`, `d:x;`, `s:3:"abc";`, `s:-1:"";`, `s:3:"ab";`,
+		`s:1:"a"`, `s:1:"a":`, `S:3:"\61bc";`, `S:1:"\6x";`, `a:0:{}`, `a:-1:{}`, `a:1:{i:0;N;}`,
+		`a:1:{s:1:"k";b:0;}`, `a:1:{N;i:0;}`, `a:1:{i:0;N;`, `a:1:{i:0;N;]`, `O:3:"Foo":0:{}`,
+		`O:3:"Foo":1:{s:1:"a";i:1;}`, `O:3:"Foo":1:s:1:"a";i:1;}`, `O:3:"Fo":0:{}`, `C:3:"Foo":2:{ab}`,
+		`C:3:"Foo":-1:{}`, `C:3:"Foo":3:{ab}`, `x:1;`,
+		"i:" + strings.Repeat("0", 63) + "1;", "i:" + strings.Repeat("0", 64) + "1;",
+		"d:" + strings.Repeat("0", 64) + "1.5;", "s:" + strings.Repeat("0", 64) + `1:"a";`,
+	}
+	for _, src := range srcs {
+		_, uerr := phpserialize.Unmarshal([]byte(src))
+		_, cerr := phpserialize.CopyValid(io.Discard, strings.NewReader(src))
+		if (uerr != nil) != (cerr != nil) {
+			t.Errorf("Unmarshal(%s) returns error: %v, but CopyValid returns error: %v", src, uerr, cerr)
+		}
+	}
+}
+
+func TestCopyValidOptions(t *testing.T) {
+	src := `a:1:{i:0;a:1:{i:0;a:0:{}}}`
+	var dst bytes.Buffer
+	_, err := phpserialize.CopyValid(&dst, strings.NewReader(src), phpserialize.WithMaxDepth(2))
+	if want := `a:1:{i:0;a:1:{i:0;a:0:`; err == nil || dst.String() != want {
+		t.Errorf("CopyValid(...) with WithMaxDepth(2) writes %s and returns error: %v, want %s and an error", dst.String(), err, want)
+	}
+
+	dst.Reset()
+	_, err = phpserialize.CopyValid(&dst, strings.NewReader(`s:3:"abc";s:4:"abcd";`), phpserialize.WithMaxBytes(10))
+	if want := `s:3:"abc";s:4:"abcd"`; !errors.Is(err, phpserialize.ErrTooLarge) || dst.String() != want {
+		t.Errorf("CopyValid(...) with WithMaxBytes(10) writes %s and returns error: %v, want %s and %v", dst.String(), err, want, phpserialize.ErrTooLarge)
+	}
+
+	dst.Reset()
+	_, err = phpserialize.CopyValid(&dst, strings.NewReader(`i:1;i:2;`), phpserialize.WithMaxInputBytes(6))
+	if want := `i:1;i:`; !errors.Is(err, phpserialize.ErrTooLarge) || dst.String() != want {
+		t.Errorf("CopyValid(...) with WithMaxInputBytes(6) writes %s and returns error: %v, want %s and %v", dst.String(), err, want, phpserialize.ErrTooLarge)
+	}
+
+	dst.Reset()
+	_, err = phpserialize.CopyValid(&dst, &io.LimitedReader{R: strings.NewReader(`i:1;i:2;`), N: 6})
+	if want := `i:1;i:`; !errors.Is(err, phpserialize.ErrTooLarge) || dst.String() != want {
+		t.Errorf("CopyValid(LimitedReader, ...) writes %s and returns error: %v, want %s and %v", dst.String(), err, want, phpserialize.ErrTooLarge)
+	}
+
+	dst.Reset()
+	_, err = phpserialize.CopyValid(&dst, strings.NewReader(`a:1:{i:0;N;;};;`), phpserialize.WithLenient())
+	if want := `a:1:{i:0;N;;};;`; err != nil || dst.String() != want {
+		t.Errorf("CopyValid(...) with WithLenient() writes %s and returns error: %v, want %s", dst.String(), err, want)
+	}
+
+	_, err = phpserialize.CopyValid(io.Discard, strings.NewReader(`i:1;a:1:{i:0;`))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("CopyValid(truncated value) returns error: %v, want: %v", err, io.ErrUnexpectedEOF)
+	}
+
+	errWrite := errors.New("write failed")
+	if _, err := phpserialize.CopyValid(errorWriter{errWrite}, strings.NewReader(src)); !errors.Is(err, errWrite) {
+		t.Errorf("CopyValid(failing writer, ...) returns error: %v, want: %v", err, errWrite)
+	}
+}
+
+// sizeWriter discards the bytes written to it, counting them and recording
+// the largest write.
+type sizeWriter struct {
+	n, max int
+}
+
+func (w *sizeWriter) Write(p []byte) (int, error) {
+	w.n += len(p)
+	if len(p) > w.max {
+		w.max = len(p)
+	}
+	return len(p), nil
+}
+
+func TestCopyValidLargeValue(t *testing.T) {
+	// a large value is checked once as it streams through, and written as
+	// it is checked rather than buffered whole
+	const n = 1 << 18
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "a:%d:{", n)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, `i:%d;s:20:"%020d";`, i, i)
+	}
+	buf.WriteString("}")
+
+	var w sizeWriter
+	written, err := phpserialize.CopyValid(&w, chunkReader{bytes.NewReader(buf.Bytes()), 4096})
+	if err != nil {
+		t.Fatalf("CopyValid(...) returns error: %v", err)
+	}
+	if written != int64(buf.Len()) || w.n != buf.Len() {
+		t.Errorf("CopyValid(...) == %d and writes %d bytes, want: %d", written, w.n, buf.Len())
+	}
+	if w.max > 4096+64 {
+		t.Errorf("CopyValid(...) writes up to %d bytes at once, want them written as they are read", w.max)
+	}
+}
+
+// digitReader reads digits without end.
+type digitReader struct{}
+
+func (digitReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = '1'
+	}
+	return len(p), nil
+}
+
+func TestCopyValidLongNumber(t *testing.T) {
+	// an unterminated number fails once it is longer than any valid one,
+	// rather than being buffered until the end of the input
+	var dst bytes.Buffer
+	digits := &io.LimitedReader{R: digitReader{}, N: 1 << 30}
+	n, err := phpserialize.CopyValid(&dst, io.MultiReader(strings.NewReader("i:1;i:"), digits))
+	if err == nil || n != 6 || dst.String() != "i:1;i:" {
+		t.Errorf("CopyValid(...) == %d, %v and writes %s, want: 6, an error and i:1;i:", n, err, dst.String())
+	}
+	if read := 1<<30 - digits.N; read > 1<<20 {
+		t.Errorf("CopyValid(...) reads %d digits before failing", read)
+	}
+}
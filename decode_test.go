@@ -1,20 +1,20 @@
 package phpserialize_test
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math"
-	"reflect"
+	"io"
+	"runtime/debug"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	phpserialize "github.com/kamiaka/go-phpserialize"
 	"github.com/kamiaka/go-phpserialize/php"
 )
 
-func isNaN(v *php.Value) bool {
-	return v != nil && v.Type() == php.TypeFloat && math.IsNaN(v.Float())
-}
-
 func TestUnmarshal(t *testing.T) {
 	cases := []struct {
 		bs         []byte
@@ -73,6 +73,26 @@ func TestUnmarshal(t *testing.T) {
 			bs:   []byte("a:3:{i:0;i:1;i:1;i:2;i:2;i:3;}"),
 			want: php.Append(php.Array(), php.Int(1), php.Int(2), php.Int(3)),
 		},
+		{
+			bs: []byte(`O:3:"Foo":3:{s:1:"a";i:42;s:2:"*b";s:3:"aaa";s:6:"` + "\x00Foo\x00c" + `";b:1;}`),
+			want: php.Object(
+				"Foo",
+				php.Field("a", php.Int(42), php.VisibilityPublic),
+				php.Field("b", php.String("aaa"), php.VisibilityProtected),
+				php.Field("c", php.Bool(true), php.VisibilityPrivate),
+			),
+		},
+		{
+			bs: []byte(`O:3:"Foo":1:{s:4:"` + "\x00*\x00b" + `";s:3:"aaa";}`),
+			want: php.Object(
+				"Foo",
+				php.Field("b", php.String("aaa"), php.VisibilityProtected),
+			),
+		},
+		{
+			bs:         []byte(`O:3:"Foo":1:{s:2:"` + "\x00c" + `";b:1;}`),
+			wantsError: true,
+		},
 	}
 	for i, tc := range cases {
 		got, err := phpserialize.Unmarshal(tc.bs)
@@ -84,7 +104,7 @@ func TestUnmarshal(t *testing.T) {
 		}
 		if tc.wantsError {
 			t.Errorf("#%d: Unmarshal(...) wants error but no error occurred, return %#v", i, got)
-		} else if !reflect.DeepEqual(tc.want, got) && !(isNaN(tc.want) && isNaN(got)) {
+		} else if !php.Equal(tc.want, got) {
 			t.Errorf("#%d: Unmarshal(...) == %#v, wants: %#v", i, got, tc.want)
 			g, _ := json.Marshal(got)
 			w, _ := json.Marshal(tc.want)
@@ -93,6 +113,74 @@ func TestUnmarshal(t *testing.T) {
 	}
 }
 
+func TestUnmarshalObjectBraces(t *testing.T) {
+	// the braces around the fields and the semicolons after their names
+	// must be read, so that a value following an object is read as itself
+	cases := []struct {
+		bs         string
+		want       *php.Value
+		wantsError bool
+	}{
+		{
+			bs: `a:2:{i:0;O:3:"Foo":1:{s:1:"a";i:1;}i:1;O:3:"Bar":0:{}}`,
+			want: php.List(
+				php.Object("Foo", php.PubField("a", php.Int(1))),
+				php.Object("Bar"),
+			),
+		},
+		{
+			bs:   `O:3:"Foo":1:{s:1:"a";O:3:"Bar":1:{s:1:"b";N;}}`,
+			want: php.Object("Foo", php.PubField("a", php.Object("Bar", php.PubField("b", php.Null())))),
+		},
+		{bs: `O:3:"Foo":1:s:1:"a";i:1;}`, wantsError: true},
+		{bs: `O:3:"Foo":1:{s:1:"a"i:1;}`, wantsError: true},
+		{bs: `O:3:"Foo":1:{s:1:"a";i:1;`, wantsError: true},
+		{bs: `O:3:"Foo":1:{s:1:"a";i:1;]`, wantsError: true},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.Unmarshal([]byte(tc.bs))
+		if tc.wantsError {
+			if err == nil {
+				t.Errorf("#%d: Unmarshal(%s) wants error but no error occurred, return %#v", i, tc.bs, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: Unmarshal(%s) returns error: %v", i, tc.bs, err)
+		} else if !php.Equal(tc.want, got) {
+			t.Errorf("#%d: Unmarshal(%s) == %#v, wants: %#v", i, tc.bs, got, tc.want)
+		}
+	}
+}
+
+func TestDecoderObjectBraces(t *testing.T) {
+	// a value following an object in a stream starts after its closing brace
+	data := `O:3:"Foo":1:{s:1:"a";i:1;}O:3:"Bar":0:{}i:2;`
+	want := []*php.Value{
+		php.Object("Foo", php.PubField("a", php.Int(1))),
+		php.Object("Bar"),
+		php.Int(2),
+	}
+	dec := phpserialize.NewDecoder(strings.NewReader(data))
+	for i, w := range want {
+		got, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("#%d: Decode() returns error: %v", i, err)
+		}
+		if !php.Equal(w, got) {
+			t.Errorf("#%d: Decode() == %#v, want: %#v", i, got, w)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("Decode() at the end returns %v, want: %v", err, io.EOF)
+	}
+
+	var buf bytes.Buffer
+	if _, err := phpserialize.CopyValid(&buf, strings.NewReader(`O:3:"Foo":1:{s:1:"a"i:1;}`)); err == nil {
+		t.Errorf("CopyValid(...) of an object without a semicolon after a field name returns no error")
+	}
+}
+
 func ExampleUnmarshal() {
 	s := `a:2:{i:0;s:1:"a";i:1;s:3:"bbb";}`
 	arr, _ := phpserialize.Unmarshal([]byte(s))
@@ -142,3 +230,761 @@ func ExampleUnmarshal_panic() {
 	// 42
 	// php: call of php.Value.Int on null Value
 }
+
+func TestUnmarshalWithOptions(t *testing.T) {
+	nested := []byte(`a:1:{i:0;a:1:{i:0;a:1:{i:0;i:1;}}}`)
+	cases := []struct {
+		bs         []byte
+		opts       []phpserialize.Option
+		wantsError bool
+	}{
+		{bs: nested, opts: nil},
+		{bs: nested, opts: []phpserialize.Option{phpserialize.WithMaxDepth(3)}},
+		{bs: nested, opts: []phpserialize.Option{phpserialize.WithMaxDepth(2)}, wantsError: true},
+		{bs: nested, opts: []phpserialize.Option{phpserialize.WithMaxBytes(10)}, wantsError: true},
+		{bs: nested, opts: []phpserialize.Option{phpserialize.WithMaxInputBytes(int64(len(nested)))}},
+		{bs: nested, opts: []phpserialize.Option{phpserialize.WithMaxInputBytes(10)}, wantsError: true},
+		{bs: nested, opts: []phpserialize.Option{phpserialize.WithMaxElements(2)}, wantsError: true},
+		{bs: []byte(`a:2000000000:{}`), opts: []phpserialize.Option{phpserialize.WithProfile(phpserialize.ProfileUntrustedInput)}, wantsError: true},
+		{
+			bs: nested,
+			opts: []phpserialize.Option{
+				phpserialize.WithProfile(phpserialize.ProfileUntrustedInput),
+				phpserialize.WithMaxDepth(1),
+			},
+			wantsError: true,
+		},
+		{
+			bs: nested,
+			opts: []phpserialize.Option{
+				phpserialize.WithMaxDepth(1),
+				phpserialize.WithProfile(phpserialize.ProfileTrustedBulk),
+			},
+		},
+	}
+	for i, tc := range cases {
+		_, err := phpserialize.UnmarshalWithOptions(tc.bs, tc.opts...)
+		if err != nil && !tc.wantsError {
+			t.Errorf("#%d: UnmarshalWithOptions(...) returns error: %v", i, err)
+		} else if err == nil && tc.wantsError {
+			t.Errorf("#%d: UnmarshalWithOptions(...) wants error but no error occurred", i)
+		}
+	}
+}
+
+func TestUnmarshalWithProfile(t *testing.T) {
+	dupKeys := []byte(`a:2:{i:0;i:1;i:0;i:2;}`)
+	bigInt := []byte(`i:99999999999999999999;`)
+	str := []byte(fmt.Sprintf(`s:%d:"%s";`, 600<<10, strings.Repeat("x", 600<<10)))
+	cases := []struct {
+		bs         []byte
+		profile    phpserialize.Profile
+		wantsError bool
+	}{
+		{bs: dupKeys, profile: phpserialize.ProfileUntrustedInput, wantsError: true},
+		{bs: dupKeys, profile: phpserialize.ProfileLowMemory, wantsError: true},
+		{bs: dupKeys, profile: phpserialize.ProfileTrustedBulk},
+		{bs: bigInt, profile: phpserialize.ProfileUntrustedInput, wantsError: true},
+		{bs: bigInt, profile: phpserialize.ProfileLowMemory, wantsError: true},
+		{bs: bigInt, profile: phpserialize.ProfileTrustedBulk},
+		{bs: str, profile: phpserialize.ProfileUntrustedInput},
+		{bs: str, profile: phpserialize.ProfileLowMemory, wantsError: true},
+		{bs: str, profile: phpserialize.ProfileTrustedBulk},
+	}
+	for i, tc := range cases {
+		_, err := phpserialize.UnmarshalWithOptions(tc.bs, phpserialize.WithStrictKeys(), phpserialize.WithProfile(tc.profile))
+		if err != nil && !tc.wantsError {
+			t.Errorf("#%d: UnmarshalWithOptions(...) returns error: %v", i, err)
+		} else if err == nil && tc.wantsError {
+			t.Errorf("#%d: UnmarshalWithOptions(...) wants error but no error occurred", i)
+		}
+	}
+
+	obj := []byte(`O:4:"Evil":1:{s:1:"a";i:1;}`)
+	for _, p := range []phpserialize.Profile{phpserialize.ProfileUntrustedInput, phpserialize.ProfileLowMemory} {
+		v, err := phpserialize.UnmarshalWithOptions(obj, phpserialize.WithProfile(p))
+		if err != nil {
+			t.Fatalf("UnmarshalWithOptions(...) returns error: %v", err)
+		}
+		if name, ok := v.Object().IncompleteClassName(); !ok || name != "Evil" {
+			t.Errorf("profile %d: IncompleteClassName() == %q, %v, want: Evil, true", p, name, ok)
+		}
+	}
+	v, err := phpserialize.UnmarshalWithOptions(obj, phpserialize.WithAllowedClasses(), phpserialize.WithProfile(phpserialize.ProfileTrustedBulk))
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions(...) returns error: %v", err)
+	}
+	if _, ok := v.Object().IncompleteClassName(); ok || v.Object().Name != "Evil" {
+		t.Errorf("object %s, want: Evil", v.Object().Name)
+	}
+}
+
+func TestUnmarshalWithLexemes(t *testing.T) {
+	data := []byte(`a:3:{i:0;d:0.10000000000000001;i:1;d:1.0E+25;i:2;i:+7;}`)
+	v, err := phpserialize.UnmarshalWithOptions(data, phpserialize.WithLexemes())
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions(...) returns error: %v", err)
+	}
+	if got := v.AtIndex(1).Lexeme(); got != "1.0E+25" {
+		t.Errorf("Lexeme() == %q, want: %q", got, "1.0E+25")
+	}
+	got, err := phpserialize.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal(...) returns error: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("Marshal(...) == %s, want: %s", got, data)
+	}
+}
+
+func TestUnmarshalWithInt32(t *testing.T) {
+	cases := []struct {
+		data string
+		want *php.Value
+	}{
+		{data: "i:2147483647;", want: php.Int(2147483647)},
+		{data: "i:-2147483648;", want: php.Int(-2147483648)},
+		{data: "i:2147483648;", want: php.Float(2147483648)},
+		{data: "i:-2147483649;", want: php.Float(-2147483649)},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.UnmarshalWithOptions([]byte(tc.data), phpserialize.WithInt32())
+		if err != nil {
+			t.Fatalf("#%d: UnmarshalWithOptions(%s) returns error: %v", i, tc.data, err)
+		}
+		if !php.Equal(got, tc.want) {
+			t.Errorf("#%d: UnmarshalWithOptions(%s) == %#v, want: %#v", i, tc.data, got, tc.want)
+		}
+	}
+
+	if _, err := phpserialize.UnmarshalWithOptions([]byte("a:1:{i:4294967296;i:1;}"), phpserialize.WithInt32()); err == nil {
+		t.Errorf("UnmarshalWithOptions(...) with out of range key returns no error")
+	}
+}
+
+func TestUnmarshalIntOverflow(t *testing.T) {
+	data := []byte("i:99999999999999999999;")
+	got, err := phpserialize.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal(%s) returns error: %v", data, err)
+	}
+	if want := php.Float(1e20); !php.Equal(got, want) {
+		t.Errorf("Unmarshal(%s) == %#v, want: %#v", data, got, want)
+	}
+
+	if _, err := phpserialize.UnmarshalWithOptions(data, phpserialize.WithStrictInts()); err == nil {
+		t.Errorf("UnmarshalWithOptions(%s, WithStrictInts()) returns no error", data)
+	}
+}
+
+func TestUnmarshalWithLenient(t *testing.T) {
+	cases := []struct {
+		data string
+		want *php.Value
+	}{
+		{data: "I:1;", want: php.Int(1)},
+		{data: "n;", want: php.Null()},
+		{data: "i:1;;", want: php.Int(1)},
+		{data: "s:1:\"a\";\n", want: php.String("a")},
+		{data: `A:1:{i:0;B:1;;}`, want: php.Array(php.Element(php.Int(0), php.Bool(true)))},
+		{data: `o:3:"Foo":1:{s:1:"a";D:1.5;;}`, want: php.Object("Foo", php.PubField("a", php.Float(1.5)))},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.UnmarshalWithOptions([]byte(tc.data), phpserialize.WithLenient())
+		if err != nil {
+			t.Fatalf("#%d: UnmarshalWithOptions(%q) returns error: %v", i, tc.data, err)
+		}
+		if !php.Equal(got, tc.want) {
+			t.Errorf("#%d: UnmarshalWithOptions(%q) == %#v, want: %#v", i, tc.data, got, tc.want)
+		}
+		if _, err := phpserialize.Unmarshal([]byte(tc.data)); err == nil {
+			t.Errorf("#%d: Unmarshal(%q) returns no error", i, tc.data)
+		}
+	}
+}
+
+func TestUnmarshalPartial(t *testing.T) {
+	data := []byte(`i:1;s:1:"a";N;`)
+	want := []*php.Value{php.Int(1), php.String("a"), php.Null()}
+	for i, w := range want {
+		v, rest, err := phpserialize.UnmarshalPartial(data)
+		if err != nil {
+			t.Fatalf("#%d: UnmarshalPartial(%s) returns error: %v", i, data, err)
+		}
+		if !php.Equal(v, w) {
+			t.Errorf("#%d: UnmarshalPartial(%s) == %#v, want: %#v", i, data, v, w)
+		}
+		data = rest
+	}
+	if len(data) != 0 {
+		t.Errorf("UnmarshalPartial(...) leaves %s, want empty", data)
+	}
+
+	if _, rest, err := phpserialize.UnmarshalPartial([]byte("i:1")); err == nil || string(rest) != "i:1" {
+		t.Errorf("UnmarshalPartial(i:1) == %s, %v, want: i:1 and error", rest, err)
+	}
+}
+
+func TestUnmarshalWithLazy(t *testing.T) {
+	data := []byte(`a:2:{s:1:"a";a:1:{i:0;s:1:"x";}s:1:"b";O:3:"Foo":1:{s:1:"c";i:1;}}`)
+	v, err := phpserialize.UnmarshalWithOptions(data, phpserialize.WithLazy())
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions(...) returns error: %v", err)
+	}
+	a := v.Array()[0].Value
+	if r := a.Raw(); r == nil || string(r.Bytes()) != `a:1:{i:0;s:1:"x";}` {
+		t.Errorf("element Raw() == %v, want raw bytes", r)
+	}
+	if got := v.At("b").At("c").IntOr(0); got != 1 {
+		t.Errorf(`At("b").At("c").IntOr(0) == %d, want: 1`, got)
+	}
+	if a.Raw() == nil {
+		t.Errorf("accessing b decodes a")
+	}
+	got, err := phpserialize.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal(...) returns error: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("Marshal(...) == %s, want: %s", got, data)
+	}
+
+	v, err = phpserialize.UnmarshalWithOptions([]byte(`a:1:{i:0;i:x;}`), phpserialize.WithLazy())
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions(...) returns error: %v", err)
+	}
+	if err := v.AtIndex(0).Load(); err == nil {
+		t.Errorf("Load() of invalid element returns no error")
+	}
+}
+
+func TestUnmarshalWithArena(t *testing.T) {
+	data := []byte(`a:3:{i:0;s:1:"a";i:1;O:3:"Foo":1:{s:1:"b";d:1.5;}i:2;N;}`)
+	want, err := phpserialize.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal(...) returns error: %v", err)
+	}
+	got, err := phpserialize.UnmarshalWithOptions(data, phpserialize.WithArena())
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions(...) returns error: %v", err)
+	}
+	if !php.Equal(got, want) {
+		t.Errorf("UnmarshalWithOptions(...) == %#v, want: %#v", got, want)
+	}
+}
+
+func TestUnmarshalWithInterning(t *testing.T) {
+	data := []byte(`a:6:{i:0;i:7;i:1;i:7;i:2;b:1;i:3;s:0:"";i:4;N;i:5;a:2:{i:0;i:5000;i:1;i:5000;}}`)
+	want, err := phpserialize.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal(...) returns error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		got, err := phpserialize.UnmarshalWithOptions(data, phpserialize.WithInterning(), phpserialize.WithArena())
+		if err != nil {
+			t.Fatalf("UnmarshalWithOptions(...) returns error: %v", err)
+		}
+		if !php.Equal(got, want) {
+			t.Errorf("UnmarshalWithOptions(...) == %#v, want: %#v", got, want)
+		}
+		if got.AtIndex(0) != got.AtIndex(1) || got.Keys()[1] != got.AtIndex(5).Keys()[1] {
+			t.Errorf("UnmarshalWithOptions(...) does not share small ints")
+		}
+		if big := got.AtIndex(5); big.AtIndex(0) == big.AtIndex(1) {
+			t.Errorf("UnmarshalWithOptions(...) shares large ints")
+		}
+	}
+
+	type T struct {
+		A []int `php:"a"`
+	}
+	_, err = phpserialize.Decode[T]([]byte(`a:1:{s:1:"a";a:2:{i:0;i:1;i:1;s:1:"x";}}`), phpserialize.WithInterning())
+	var te *phpserialize.UnmarshalTypeError
+	if !errors.As(err, &te) || te.Offset != 30 {
+		t.Errorf("Decode[T](...) returns %v, want: UnmarshalTypeError at offset 30", err)
+	}
+}
+
+func TestUnmarshalWithStringBytes(t *testing.T) {
+	data := []byte("a:3:{s:1:\"k\";s:4:\"\x1f\x8b\xff\x00\";s:1:\"3\";S:2:\"\\00a\";i:4;s:0:\"\";}")
+	want, err := phpserialize.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal(...) returns error: %v", err)
+	}
+	got, err := phpserialize.UnmarshalWithOptions(data, phpserialize.WithStringBytes())
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions(...) returns error: %v", err)
+	}
+	if !php.Equal(got, want) {
+		t.Errorf("UnmarshalWithOptions(...) == %#v, want: %#v", got, want)
+	}
+	if bs := got.IndexByName("k").Bytes(); &bs[0] != &data[18] {
+		t.Errorf("Bytes() of a decoded string does not share the data")
+	}
+	if got := got.IndexByInt(3).Bytes(); string(got) != "\x00a" {
+		t.Errorf("Bytes() of a decoded S: string == %q, want: %q", got, "\x00a")
+	}
+	if !got.Has("k") || got.Index(got.IndexByName("k")) != nil {
+		t.Errorf("keys of UnmarshalWithOptions(...) are not usable")
+	}
+
+	dec := phpserialize.NewDecoder(iotest.OneByteReader(strings.NewReader(`s:3:"abc";s:3:"def";`)), phpserialize.WithStringBytes())
+	first, err := dec.Decode()
+	if err != nil {
+		t.Fatalf("Decode() returns error: %v", err)
+	}
+	if _, err := dec.Decode(); err != nil {
+		t.Fatalf("Decode() returns error: %v", err)
+	}
+	if got := first.String(); got != "abc" {
+		t.Errorf("Decode() == %q after the next Decode(), want: %q", got, "abc")
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	var buf bytes.Buffer
+	buf.WriteString("a:1000:{")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&buf, `i:%d;a:2:{s:2:"id";i:%d;s:4:"name";s:3:"foo";}`, i, i)
+	}
+	buf.WriteString("}")
+	data := buf.Bytes()
+
+	for _, bc := range []struct {
+		name string
+		opts []phpserialize.Option
+	}{
+		{name: "default"},
+		{name: "arena", opts: []phpserialize.Option{phpserialize.WithArena()}},
+		{name: "interning", opts: []phpserialize.Option{phpserialize.WithInterning()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := phpserialize.UnmarshalWithOptions(data, bc.opts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestUnmarshalForgedCounts(t *testing.T) {
+	cases := []string{
+		`a:2000000000:{i:0;i:0;}`,
+		`O:3:"Foo":2000000000:{}`,
+		`a:-1:{}`,
+		`s:-1:"";`,
+		`a:1:{i:0;a:1000000:{}}`,
+	}
+	for i, data := range cases {
+		if _, err := phpserialize.Unmarshal([]byte(data)); err == nil {
+			t.Errorf("#%d: Unmarshal(%s) returns no error", i, data)
+		}
+		if _, err := phpserialize.UnmarshalWithOptions([]byte(data), phpserialize.WithLazy()); err == nil {
+			t.Errorf("#%d: UnmarshalWithOptions(%s, WithLazy()) returns no error", i, data)
+		}
+	}
+}
+
+func TestUnmarshalStringLengthOverflow(t *testing.T) {
+	cases := []string{
+		`s:9223372036854775806:"abc";`,
+		`s:9223372036854775807:"abc";`,
+		`a:1:{i:0;s:9223372036854775806:"abc";}`,
+	}
+	for i, data := range cases {
+		if _, err := phpserialize.Unmarshal([]byte(data)); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("#%d: Unmarshal(%s) returns error: %v, want: %v", i, data, err, io.ErrUnexpectedEOF)
+		}
+		if _, err := phpserialize.UnmarshalWithOptions([]byte(data), phpserialize.WithLazy()); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("#%d: UnmarshalWithOptions(%s, WithLazy()) returns error: %v, want: %v", i, data, err, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+func TestUnmarshalObjectsAsArrays(t *testing.T) {
+	data := []byte(`a:2:{i:0;O:8:"stdClass":2:{s:1:"a";i:1;s:1:"7";b:1;}i:1;O:3:"Foo":1:{s:6:"` + "\x00Foo\x00p" + `";N;}}`)
+	stdClass := php.Array(
+		php.Element(php.String("a"), php.Int(1)),
+		php.Element(php.Int(7), php.Bool(true)),
+	)
+	cases := []struct {
+		opts []phpserialize.Option
+		opt  phpserialize.Option
+		want *php.Value
+	}{
+		{
+			opt: phpserialize.WithStdClassAsArray(),
+			want: php.Append(php.Array(),
+				stdClass,
+				php.Object("Foo", php.PrivField("p", php.Null())),
+			),
+		},
+		{
+			opt: phpserialize.WithObjectsAsArrays(),
+			want: php.Append(php.Array(),
+				stdClass,
+				php.Array(php.Element(php.String("\x00Foo\x00p"), php.Null())),
+			),
+		},
+		{
+			opts: []phpserialize.Option{phpserialize.WithClassKey("__class")},
+			opt:  phpserialize.WithObjectsAsArrays(),
+			want: php.Append(php.Array(),
+				php.Array(
+					php.Element(php.String("__class"), php.String("stdClass")),
+					php.Element(php.String("a"), php.Int(1)),
+					php.Element(php.Int(7), php.Bool(true)),
+				),
+				php.Array(
+					php.Element(php.String("__class"), php.String("Foo")),
+					php.Element(php.String("\x00Foo\x00p"), php.Null()),
+				),
+			),
+		},
+		{
+			opts: []phpserialize.Option{phpserialize.WithClassKey("a")},
+			opt:  phpserialize.WithStdClassAsArray(),
+			want: php.Append(php.Array(),
+				php.Array(
+					php.Element(php.String("a"), php.Int(1)),
+					php.Element(php.Int(7), php.Bool(true)),
+				),
+				php.Object("Foo", php.PrivField("p", php.Null())),
+			),
+		},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.UnmarshalWithOptions(data, append(tc.opts, tc.opt)...)
+		if err != nil {
+			t.Fatalf("#%d: UnmarshalWithOptions(...) returns error: %v", i, err)
+		}
+		if !php.Equal(got, tc.want) {
+			t.Errorf("#%d: UnmarshalWithOptions(...) == %#v, want: %#v", i, got, tc.want)
+		}
+	}
+}
+
+func TestUnmarshalDeepNesting(t *testing.T) {
+	// with a small stack, a recursive decoder would crash at this depth
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+	const depth = 100000
+	data := strings.Repeat("a:1:{i:0;", depth) + "N;" + strings.Repeat("}", depth)
+	for _, opts := range [][]phpserialize.Option{nil, {phpserialize.WithLazy()}} {
+		v, err := phpserialize.UnmarshalWithOptions([]byte(data), opts...)
+		if err != nil {
+			t.Fatalf("UnmarshalWithOptions(...) returns error: %v", err)
+		}
+		if opts != nil {
+			continue // the members are decoded when accessed
+		}
+		n := 0
+		for ; v.Type() == php.TypeArray; n++ {
+			v = v.AtIndex(0)
+		}
+		if n != depth || !v.IsNil() {
+			t.Errorf("UnmarshalWithOptions(...) gives %d nested arrays around %v, want: %d around null", n, v, depth)
+		}
+	}
+	if _, err := phpserialize.UnmarshalWithOptions([]byte(data), phpserialize.WithMaxDepth(1000)); err == nil {
+		t.Errorf("UnmarshalWithOptions(...) with max depth wants error but no error occurred")
+	}
+}
+
+func TestUnmarshalWithAllowedClasses(t *testing.T) {
+	data := []byte(`a:2:{i:0;O:4:"User":1:{s:4:"name";s:3:"bob";}i:1;O:4:"Evil":1:{s:3:"cmd";s:2:"rm";}}`)
+	v, err := phpserialize.UnmarshalWithOptions(data, phpserialize.WithAllowedClasses("user"))
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions(...) returns error: %v", err)
+	}
+	want := php.Append(php.Array(),
+		php.Object("User", php.PubField("name", php.String("bob"))),
+		php.Object(php.IncompleteClass,
+			php.PubField(php.IncompleteClassNameField, php.String("Evil")),
+			php.PubField("cmd", php.String("rm")),
+		),
+	)
+	if !php.Equal(v, want) {
+		t.Errorf("UnmarshalWithOptions(...) == %#v, want: %#v", v, want)
+	}
+	if name, ok := v.AtIndex(1).Object().IncompleteClassName(); !ok || name != "Evil" {
+		t.Errorf("IncompleteClassName() == %q, %v, want: Evil, true", name, ok)
+	}
+
+	got, err := phpserialize.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal(...) returns error: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("Marshal(...) == %s, want: %s", got, data)
+	}
+}
+
+func TestUnmarshalWithAllowedClassesCustomObject(t *testing.T) {
+	data := []byte(`a:1:{i:0;C:4:"Evil":6:{rm -rf}}`)
+	v, err := phpserialize.UnmarshalWithOptions(data, phpserialize.WithAllowedClasses())
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions(...) returns error: %v", err)
+	}
+	obj := v.AtIndex(0).Object()
+	if name, ok := obj.IncompleteClassName(); obj.Name != php.IncompleteClass || !ok || name != "Evil" {
+		t.Errorf("object %s, IncompleteClassName() == %q, %v, want: %s, Evil, true", obj.Name, name, ok, php.IncompleteClass)
+	}
+	got, err := phpserialize.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal(...) returns error: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("Marshal(...) == %s, want: %s", got, data)
+	}
+	if got, err := v.Serialize(); err != nil || string(got) != string(data) {
+		t.Errorf("Serialize() == %s, %v, want: %s", got, err, data)
+	}
+}
+
+func TestUnmarshalWithRejectDisallowedClasses(t *testing.T) {
+	opts := []phpserialize.Option{phpserialize.WithAllowedClasses("User"), phpserialize.WithRejectDisallowedClasses()}
+	cases := []struct {
+		data       string
+		wantsError bool
+	}{
+		{data: `O:4:"User":0:{}`},
+		{data: `a:1:{i:0;O:4:"Evil":0:{}}`, wantsError: true},
+		{data: `C:4:"Evil":0:{}`, wantsError: true},
+	}
+	for i, tc := range cases {
+		_, err := phpserialize.UnmarshalWithOptions([]byte(tc.data), opts...)
+		if err != nil && !tc.wantsError {
+			t.Errorf("#%d: UnmarshalWithOptions(%q) returns error: %v", i, tc.data, err)
+		} else if err == nil && tc.wantsError {
+			t.Errorf("#%d: UnmarshalWithOptions(%q) wants error but no error occurred", i, tc.data)
+		}
+	}
+	if _, err := phpserialize.UnmarshalWithOptions([]byte(`O:4:"Evil":0:{}`), phpserialize.WithRejectDisallowedClasses()); err != nil {
+		t.Errorf("UnmarshalWithOptions without allowed classes returns error: %v", err)
+	}
+}
+
+func TestUnmarshalAnonymousClass(t *testing.T) {
+	class := "class@anonymous\x00/app/src/a.php:3$0"
+	data := []byte(fmt.Sprintf(`O:%d:"%s":2:{s:1:"a";i:1;s:%d:"%s";i:2;}`,
+		len(class), class, len(class)+3, "\x00"+class+"\x00b"))
+	v, err := phpserialize.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal(...) returns error: %v", err)
+	}
+	obj := v.Object()
+	if obj.Name != class || !obj.IsAnonymous() {
+		t.Errorf("Unmarshal(...) decodes class %q, want anonymous class %q", obj.Name, class)
+	}
+	if f := obj.Field("b"); f == nil || f.Visibility != php.VisibilityPrivate {
+		t.Errorf("Field(b) == %#v, want private field", f)
+	}
+
+	got, err := phpserialize.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal(...) returns error: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("Marshal(...) == %q, want: %q", got, data)
+	}
+}
+
+func TestUnmarshalDuplicateKeys(t *testing.T) {
+	var large bytes.Buffer
+	large.WriteString("a:21:{")
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&large, "i:%d;i:%d;", i, i)
+	}
+	large.WriteString("i:3;s:1:\"x\";}")
+	var ls []*php.Value
+	for i := 0; i < 20; i++ {
+		ls = append(ls, php.Int(i))
+	}
+	ls[3] = php.String("x")
+
+	cases := []struct {
+		data string
+		want *php.Value
+	}{
+		{
+			data: `a:3:{s:1:"a";i:1;s:1:"b";i:2;s:1:"a";i:3;}`,
+			want: php.Array(
+				php.Element(php.String("a"), php.Int(3)),
+				php.Element(php.String("b"), php.Int(2)),
+			),
+		},
+		{
+			data: `a:2:{i:1;i:1;s:1:"1";i:2;}`,
+			want: php.Array(
+				php.Element(php.Int(1), php.Int(2)),
+			),
+		},
+		{data: large.String(), want: php.Append(php.Array(), ls...)},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.Unmarshal([]byte(tc.data))
+		if err != nil {
+			t.Fatalf("#%d: Unmarshal(%s) returns error: %v", i, tc.data, err)
+		}
+		if !php.Equal(got, tc.want) {
+			t.Errorf("#%d: Unmarshal(%s) == %#v, want: %#v", i, tc.data, got, tc.want)
+		}
+	}
+
+	for _, data := range []string{cases[0].data, cases[2].data} {
+		if _, err := phpserialize.UnmarshalWithOptions([]byte(data), phpserialize.WithStrictKeys()); err == nil {
+			t.Errorf("UnmarshalWithOptions(%s, WithStrictKeys()) returns no error", data)
+		}
+	}
+}
+
+func TestUnmarshalNumericStringKeys(t *testing.T) {
+	data := []byte(`a:5:{s:1:"5";i:0;s:2:"05";i:1;s:2:"-1";i:2;s:2:"+1";i:3;s:10:"4294967296";i:4;}`)
+	cases := []struct {
+		opts []phpserialize.Option
+		keys []*php.Value
+	}{
+		{keys: []*php.Value{php.Int(5), php.String("05"), php.Int(-1), php.String("+1"), php.Int(4294967296)}},
+		{
+			opts: []phpserialize.Option{phpserialize.WithInt32()},
+			keys: []*php.Value{php.Int(5), php.String("05"), php.Int(-1), php.String("+1"), php.String("4294967296")},
+		},
+		{
+			opts: []phpserialize.Option{phpserialize.WithVerbatimKeys()},
+			keys: []*php.Value{php.String("5"), php.String("05"), php.String("-1"), php.String("+1"), php.String("4294967296")},
+		},
+	}
+	for i, tc := range cases {
+		v, err := phpserialize.UnmarshalWithOptions(data, tc.opts...)
+		if err != nil {
+			t.Fatalf("#%d: UnmarshalWithOptions(...) returns error: %v", i, err)
+		}
+		keys := v.Keys()
+		for j, want := range tc.keys {
+			if !php.Equal(keys[j], want) {
+				t.Errorf("#%d: key %d == %#v, want: %#v", i, j, keys[j].Interface(), want.Interface())
+			}
+		}
+	}
+}
+
+func TestUnmarshalWithCastKeys(t *testing.T) {
+	data := []byte(`a:5:{b:1;s:1:"a";b:0;s:1:"b";d:2.9;s:1:"c";d:-2.5;s:1:"d";N;s:1:"e";}`)
+	if _, err := phpserialize.Unmarshal(data); err == nil {
+		t.Errorf("Unmarshal(%s) returns no error", data)
+	}
+
+	v, err := phpserialize.UnmarshalWithOptions(data, phpserialize.WithCastKeys())
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions(...) returns error: %v", err)
+	}
+	want := []*php.Value{php.Int(1), php.Int(0), php.Int(2), php.Int(-2), php.String("")}
+	keys := v.Keys()
+	if len(keys) != len(want) {
+		t.Fatalf("UnmarshalWithOptions(...) has %d keys, want: %d", len(keys), len(want))
+	}
+	for i, k := range want {
+		if !php.Equal(keys[i], k) {
+			t.Errorf("key %d == %#v, want: %#v", i, keys[i].Interface(), k.Interface())
+		}
+	}
+
+	for _, data := range []string{`a:1:{d:1.0E+25;i:0;}`, `a:1:{d:NAN;i:0;}`, `a:1:{a:0:{}i:0;}`} {
+		if _, err := phpserialize.UnmarshalWithOptions([]byte(data), phpserialize.WithCastKeys()); err == nil {
+			t.Errorf("UnmarshalWithOptions(%s) returns no error", data)
+		}
+	}
+}
+
+func TestUnmarshalErrorPath(t *testing.T) {
+	cases := []struct {
+		data string
+		path string
+	}{
+		{`a:1:{s:6:"orders";a:4:{i:0;N;i:1;N;i:2;N;i:3;a:1:{s:5:"items";a:1:{i:0;O:4:"Item":1:{s:5:"price";d:x;}}}}}`, ", path: orders[3].items[0].price"},
+		{`a:1:{s:1:"a";a:1:{i:0;s:5:"x";}}`, ", path: a[0]"},
+		{`a:1:{s:1:"a";a:1:{b:1;i:0;}}`, ", path: a"},
+		{`i:x;`, "invalid syntax"},
+	}
+	for i, c := range cases {
+		_, err := phpserialize.Unmarshal([]byte(c.data))
+		if err == nil {
+			t.Errorf("#%d: Unmarshal(%s) returns no error", i, c.data)
+			continue
+		}
+		if msg := err.Error(); !strings.HasSuffix(msg, c.path) {
+			t.Errorf("#%d: Unmarshal(%s) returns error %q, want suffix %q", i, c.data, msg, c.path)
+		}
+	}
+}
+
+func TestUnmarshalEscapedStrings(t *testing.T) {
+	cases := []struct {
+		data    string
+		want    *php.Value
+		wantErr bool
+	}{
+		{data: `S:3:"a\5Cb";`, want: php.String(`a\b`)},
+		{data: `S:0:"";`, want: php.String("")},
+		{data: `a:1:{S:1:"\6b";S:2:"\00\ff";}`, want: php.Array(php.Element(php.String("k"), php.String("\x00\xff")))},
+		{data: `O:3:"Foo":1:{S:4:"\00*\00a";i:1;}`, want: php.Object("Foo", php.Field("a", php.Int(1), php.VisibilityProtected))},
+		{data: `S:1:"\zz";`, wantErr: true},
+		{data: `S:2:"\41";`, wantErr: true},
+		{data: `S:1:"\4`, wantErr: true},
+	}
+	for i, c := range cases {
+		for _, opts := range [][]phpserialize.Option{nil, {phpserialize.WithLazy()}} {
+			got, err := phpserialize.UnmarshalWithOptions([]byte(c.data), opts...)
+			if c.wantErr {
+				if err == nil {
+					t.Errorf("#%d: Unmarshal(%s) returns no error", i, c.data)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("#%d: Unmarshal(%s) returns error: %v", i, c.data, err)
+				continue
+			}
+			if !php.Equal(got, c.want) {
+				t.Errorf("#%d: Unmarshal(%s) == %v, want: %v", i, c.data, got, c.want)
+			}
+		}
+	}
+}
+
+func TestUnmarshalCustomObject(t *testing.T) {
+	cases := []struct {
+		data    string
+		want    *php.Value
+		wantErr bool
+	}{
+		{data: `C:11:"ArrayObject":21:{x:i:0;a:0:{};m:a:0:{}}`, want: php.CustomObject("ArrayObject", []byte("x:i:0;a:0:{};m:a:0:{}"))},
+		{data: `a:1:{i:0;C:3:"Foo":0:{}}`, want: php.Array(php.Element(php.Int(0), php.CustomObject("Foo", nil)))},
+		{data: `C:3:"Foo":5:{abc}`, wantErr: true},
+		{data: `C:3:"Foo":-1:{}`, wantErr: true},
+	}
+	for i, c := range cases {
+		for _, opts := range [][]phpserialize.Option{nil, {phpserialize.WithLazy()}} {
+			got, err := phpserialize.UnmarshalWithOptions([]byte(c.data), opts...)
+			if c.wantErr {
+				if err == nil {
+					t.Errorf("#%d: Unmarshal(%s) returns no error", i, c.data)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("#%d: Unmarshal(%s) returns error: %v", i, c.data, err)
+				continue
+			}
+			if !php.Equal(got, c.want) {
+				t.Errorf("#%d: Unmarshal(%s) == %v, want: %v", i, c.data, got, c.want)
+			}
+			bs, err := phpserialize.Marshal(got)
+			if err != nil || string(bs) != c.data {
+				t.Errorf("#%d: Marshal(Unmarshal(%s)) == %s, %v", i, c.data, bs, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package phpserialize
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kamiaka/go-phpserialize/internal/phpfloat"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// Dump writes v to w in the human-readable form of PHP's var_dump, with
+// class names, field visibilities and nested values indented by two spaces.
+// Objects are numbered in the order they appear, like PHP object handles.
+// Values that do not exist, such as php.Missing, are written as "*MISSING*".
+func Dump(w io.Writer, v *php.Value) error {
+	d := &dumpState{}
+	d.dump(v, 0)
+	_, err := writeAll(w, d.Bytes())
+	return err
+}
+
+type dumpState struct {
+	bytes.Buffer
+	objects int
+}
+
+func (d *dumpState) indent(level int) {
+	d.WriteString(strings.Repeat("  ", level))
+}
+
+func (d *dumpState) dump(v *php.Value, level int) {
+	d.indent(level)
+	if v.IsNil() {
+		d.WriteString("NULL\n")
+		return
+	}
+	switch v.Type() {
+	case php.TypeBool:
+		d.WriteString("bool(" + strconv.FormatBool(v.Bool()) + ")\n")
+	case php.TypeInt:
+		d.WriteString("int(" + strconv.FormatInt(v.Int(), 10) + ")\n")
+	case php.TypeFloat:
+		d.WriteString("float(" + dumpFloat(v.Float()) + ")\n")
+	case php.TypeString:
+		s := v.String()
+		d.WriteString("string(" + strconv.Itoa(len(s)) + `) "` + s + "\"\n")
+	case php.TypeArray:
+		arr := v.Array()
+		d.WriteString("array(" + strconv.Itoa(len(arr)) + ") {\n")
+		for _, e := range arr {
+			d.indent(level + 1)
+			if e.Index.Type() == php.TypeInt {
+				d.WriteString("[" + strconv.FormatInt(e.Index.Int(), 10) + "]=>\n")
+			} else {
+				d.WriteString(`["` + e.Index.String() + "\"]=>\n")
+			}
+			d.dump(e.Value, level+1)
+		}
+		d.indent(level)
+		d.WriteString("}\n")
+	case php.TypeObject:
+		obj := v.Object()
+		d.objects++
+		d.WriteString("object(" + obj.Name + ")#" + strconv.Itoa(d.objects) +
+			" (" + strconv.Itoa(len(obj.Fields)) + ") {\n")
+		for _, f := range obj.Fields {
+			d.indent(level + 1)
+			d.WriteString(`["` + f.Name + `"`)
+			switch f.Visibility {
+			case php.VisibilityProtected:
+				d.WriteString(":protected")
+			case php.VisibilityPrivate:
+				class := obj.Name
+				if f.Class != "" {
+					class = f.Class
+				}
+				d.WriteString(`:"` + class + `":private`)
+			}
+			d.WriteString("]=>\n")
+			d.dump(f.Value, level+1)
+		}
+		d.indent(level)
+		d.WriteString("}\n")
+	default:
+		d.WriteString("*MISSING*\n")
+	}
+}
+
+// dumpFloat formats f as var_dump does.
+func dumpFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NAN"
+	case math.IsInf(f, 1):
+		return "INF"
+	case math.IsInf(f, -1):
+		return "-INF"
+	}
+	return phpfloat.Format(f, -1)
+}
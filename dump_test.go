@@ -0,0 +1,64 @@
+package phpserialize_test
+
+import (
+	"bytes"
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestDump(t *testing.T) {
+	v := php.Array(
+		php.Element(php.Int(0), php.Null()),
+		php.Element(php.String("a"), php.List(php.Bool(true), php.Float(1), php.Float(2.5e25), php.NaN())),
+		php.Element(php.String("user"), php.Object("User",
+			php.PubField("name", php.String("bob")),
+			php.ProtectedField("age", php.Int(42)),
+			php.PrivField("tags", php.Array()),
+			php.PubField("friend", php.Object("User")),
+		)),
+	)
+	want := `array(3) {
+  [0]=>
+  NULL
+  ["a"]=>
+  array(4) {
+    [0]=>
+    bool(true)
+    [1]=>
+    float(1)
+    [2]=>
+    float(2.5E+25)
+    [3]=>
+    float(NAN)
+  }
+  ["user"]=>
+  object(User)#1 (4) {
+    ["name"]=>
+    string(3) "bob"
+    ["age":protected]=>
+    int(42)
+    ["tags":"User":private]=>
+    array(0) {
+    }
+    ["friend"]=>
+    object(User)#2 (0) {
+    }
+  }
+}
+`
+	var buf bytes.Buffer
+	if err := phpserialize.Dump(&buf, v); err != nil {
+		t.Fatalf("Dump(...) returns error: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Dump(...) writes:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	phpserialize.Dump(&buf, php.Missing())
+	if got := buf.String(); got != "*MISSING*\n" {
+		t.Errorf("Dump(Missing()) writes %q, want: %q", got, "*MISSING*\n")
+	}
+}
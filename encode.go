@@ -2,12 +2,16 @@ package phpserialize
 
 import (
 	"bytes"
+	"encoding"
 	"fmt"
 	"io"
 	"math"
 	"reflect"
 	"sort"
+	"strconv"
+	"sync"
 
+	"github.com/kamiaka/go-phpserialize/internal/phpfloat"
 	"github.com/kamiaka/go-phpserialize/php"
 )
 
@@ -18,22 +22,132 @@ type Marshaler interface {
 }
 
 // Marshal returns the PHP serialized bytes of i.
+//
+// Byte slices are encoded as PHP strings, since PHP strings are byte
+// strings; other slices and arrays are encoded as PHP lists. Map keys
+// implementing encoding.TextMarshaler are encoded as the string returned by
+// MarshalText; WithTextMarshalers does the same for values.
+//
+// Marshaler is honored anywhere in i, including struct fields, map values
+// and slice elements; a value whose pointer type implements it is encoded
+// through a pointer to it, or to a copy of it if it is not addressable.
 func Marshal(i interface{}) ([]byte, error) {
-	e := newEncodeState()
+	return marshal(i, options{})
+}
+
+// MarshalWithOptions is like Marshal but applies opts, the same options as
+// NewEncoder accepts.
+func MarshalWithOptions(i interface{}, opts ...Option) ([]byte, error) {
+	return marshal(i, newOptions(opts))
+}
+
+func marshal(i interface{}, opts options) ([]byte, error) {
+	e := newEncodeState(opts)
+	defer freeEncodeState(e)
 
 	err := e.marshal(i)
 	if err != nil {
 		return nil, err
 	}
-	return append([]byte(nil), e.Bytes()...), nil
+	buf := make([]byte, e.Len())
+	copy(buf, e.Bytes())
+	return buf, nil
+}
+
+// MarshalAppend appends the PHP serialized bytes of i to dst and returns the
+// extended slice. On error, dst is returned unchanged.
+func MarshalAppend(dst []byte, i interface{}) ([]byte, error) {
+	e := newEncodeState(options{})
+	defer freeEncodeState(e)
+
+	err := e.marshal(i)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, e.Bytes()...), nil
+}
+
+// SerializedLen returns the length of the PHP serialized bytes of i with
+// opts applied, the same length as MarshalWithOptions would return, so that
+// callers can allocate buffers, enforce quotas or set Content-Length before
+// writing. The bytes are counted and discarded as they are encoded, without
+// building the output, except with WithValidUTF8, whose check reads the
+// whole output.
+func SerializedLen(i interface{}, opts ...Option) (int, error) {
+	e := newEncodeState(newOptions(opts))
+	defer freeEncodeState(e)
+
+	if !e.validUTF8 {
+		e.w = io.Discard
+	}
+	if err := e.marshal(i); err != nil {
+		return 0, err
+	}
+	return e.written + e.Len(), nil
+}
+
+// Canonicalize parses the PHP serialized data and serializes it again in a
+// normalized form: floats in their shortest representation, numeric string
+// array keys cast to int keys, repeated array keys merged and protected
+// property names in PHP's current mangled form, so that equal values have
+// equal bytes.
+// It is useful for deduplicating and content-addressing serialized data.
+func Canonicalize(data []byte) ([]byte, error) {
+	v, err := Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return Marshal(v)
 }
 
 type encodeState struct {
 	bytes.Buffer
+	options
+	depth   int
+	scratch [64]byte
+	w       io.Writer // if not nil, takes the output in pieces as it grows
+	written int       // bytes written to w
 }
 
-func newEncodeState() *encodeState {
-	return new(encodeState)
+// spillSize is the size the buffer of an encodeState with a writer grows to
+// before its bytes are written.
+const spillSize = 4096
+
+// spill writes the buffered output to e.w once it has grown to spillSize.
+// It is called before a value is written, where nothing looks back at the
+// bytes written before.
+func (e *encodeState) spill() {
+	if e.w == nil || e.Len() < spillSize {
+		return
+	}
+	n, err := writeAll(e.w, e.Bytes())
+	e.written += n
+	if err != nil {
+		raiseError(err)
+	}
+	e.Reset()
+}
+
+var encodeStatePool sync.Pool
+
+func newEncodeState(opts options) *encodeState {
+	if v := encodeStatePool.Get(); v != nil {
+		e := v.(*encodeState)
+		e.Reset()
+		e.depth = 0
+		e.options = opts
+		e.w, e.written = nil, 0
+		return e
+	}
+	return &encodeState{
+		options: opts,
+	}
+}
+
+// freeEncodeState returns e to the pool. e must not be used afterwards.
+func freeEncodeState(e *encodeState) {
+	e.options = options{}
+	encodeStatePool.Put(e)
 }
 
 type serializeErr struct {
@@ -50,7 +164,11 @@ func (e *encodeState) marshal(i interface{}) (err error) {
 			}
 		}
 	}()
-	writeInterface(e, i)
+	start := e.Len()
+	e.writeInterface(i)
+	if e.validUTF8 {
+		return invalidUTF8(e.Bytes()[start:], e.escapedStrings)
+	}
 	return nil
 }
 
@@ -72,6 +190,17 @@ func (e *UnsupportedMapKeyTypeError) Error() string {
 	return "PHP serialize: unsupported map key type: " + e.Type.String()
 }
 
+// UnsupportedValueError is returned when attempting to encode a value that
+// PHP cannot represent.
+type UnsupportedValueError struct {
+	Value reflect.Value
+	Str   string
+}
+
+func (e *UnsupportedValueError) Error() string {
+	return "PHP serialize: unsupported value: " + e.Str
+}
+
 // fixed serialized values
 var (
 	sNil    = []byte("N;")
@@ -82,50 +211,186 @@ var (
 	sNegInf = []byte("d:-INF;")
 )
 
-func writeNil(w io.Writer) {
-	w.Write(sNil)
+func (e *encodeState) writeNil() {
+	e.Write(sNil)
 }
 
-func writeBool(w io.Writer, b bool) {
+func (e *encodeState) writeBool(b bool) {
 	if b {
-		w.Write(sTrue)
+		e.Write(sTrue)
 	} else {
-		w.Write(sFalse)
+		e.Write(sFalse)
+	}
+}
+
+func (e *encodeState) writeInt(v int64) {
+	if e.int32 && (v < math.MinInt32 || v > math.MaxInt32) {
+		e.writeFloat(float64(v))
+		return
+	}
+	e.writeIntToken(v)
+}
+
+// UintOverflow is the policy for encoding unsigned integers too large for a
+// PHP int.
+type UintOverflow uint
+
+// Policies
+const (
+	// UintOverflowFloat encodes such integers as floats, which is what PHP
+	// itself does with integers that overflow. Precision may be lost.
+	UintOverflowFloat UintOverflow = iota
+	// UintOverflowString encodes such integers as decimal strings.
+	UintOverflowString
+	// UintOverflowError fails with an UnsupportedValueError.
+	UintOverflowError
+)
+
+// maxInt returns the largest integer the target PHP int can hold.
+func (e *encodeState) maxInt() uint64 {
+	if e.int32 {
+		return math.MaxInt32
+	}
+	return math.MaxInt64
+}
+
+func (e *encodeState) writeUint(v uint64) {
+	if v > e.maxInt() {
+		switch e.uintOverflow {
+		case UintOverflowString:
+			e.writeString(strconv.FormatUint(v, 10))
+		case UintOverflowError:
+			raiseError(&UnsupportedValueError{reflect.ValueOf(v), strconv.FormatUint(v, 10) + " overflows PHP int"})
+		default:
+			e.writeFloat(float64(v))
+		}
+		return
+	}
+	e.WriteString("i:")
+	e.Write(strconv.AppendUint(e.scratch[:0], v, 10))
+	e.WriteByte(';')
+}
+
+// writeIntKey writes the array key v. A 32-bit PHP host cannot hold v as an
+// int key when it is out of the int32 range, and keeps it as a string key.
+func (e *encodeState) writeIntKey(v int64) {
+	if e.int32 && (v < math.MinInt32 || v > math.MaxInt32) {
+		e.writeString(strconv.FormatInt(v, 10))
+		return
 	}
+	e.writeIntToken(v)
 }
 
-func writeInt(w io.Writer, v int64) {
-	fmt.Fprintf(w, "i:%d;", v)
+// writeIntToken writes v as an i: token regardless of the target int size.
+func (e *encodeState) writeIntToken(v int64) {
+	e.WriteString("i:")
+	e.Write(strconv.AppendInt(e.scratch[:0], v, 10))
+	e.WriteByte(';')
 }
 
-func writeUint(w io.Writer, v uint64) {
-	fmt.Fprintf(w, "i:%d;", v)
+// writeStringKey writes the array key s, cast to an int key like PHP does
+// unless the verbatimKeys option is set.
+func (e *encodeState) writeStringKey(s string) {
+	if i, ok := e.numericKey(s); ok && !e.verbatimKeys {
+		e.writeIntToken(i)
+		return
+	}
+	e.writeString(s)
 }
 
-func writeFloat(w io.Writer, f float64) {
+// writeArrayHeader writes the start of an array of n elements.
+func (e *encodeState) writeArrayHeader(n int) {
+	e.enter()
+	e.WriteString("a:")
+	e.Write(strconv.AppendInt(e.scratch[:0], int64(n), 10))
+	e.WriteString(":{")
+}
+
+// enter records the start of an array or object, checking the depth limit.
+func (e *encodeState) enter() {
+	e.depth++
+	if e.maxDepth > 0 && e.depth > e.maxDepth {
+		raiseError(fmt.Errorf("php serialize: exceeded max depth of %d", e.maxDepth))
+	}
+}
+
+// writeEnd writes the end of an array or object.
+func (e *encodeState) writeEnd() {
+	e.depth--
+	e.WriteByte('}')
+}
+
+// writeObjectHeader writes the start of an object of class with n
+// properties.
+func (e *encodeState) writeObjectHeader(class string, n int) {
+	e.enter()
+	e.WriteString("O:")
+	e.Write(strconv.AppendInt(e.scratch[:0], int64(len(class)), 10))
+	e.WriteString(`:"`)
+	e.WriteString(class)
+	e.WriteString(`":`)
+	e.Write(strconv.AppendInt(e.scratch[:0], int64(n), 10))
+	e.WriteString(":{")
+}
+
+func (e *encodeState) writeFloat(f float64) {
 	if math.IsNaN(f) {
-		w.Write(sNAN)
+		e.Write(sNAN)
 	} else if math.IsInf(f, -1) {
-		w.Write(sNegInf)
+		e.Write(sNegInf)
 	} else if math.IsInf(f, 1) {
-		w.Write(sInf)
+		e.Write(sInf)
 	} else {
-		fmt.Fprintf(w, "d:%v;", f)
+		prec := e.floatPrecision
+		if prec < 1 {
+			prec = -1
+		}
+		e.WriteString("d:")
+		e.WriteString(phpfloat.Format(f, prec))
+		e.WriteByte(';')
+	}
+}
+
+func (e *encodeState) writeString(s string) {
+	if e.escapedStrings {
+		e.writeEscapedString(s)
+		return
 	}
+	e.WriteString("s:")
+	e.Write(strconv.AppendInt(e.scratch[:0], int64(len(s)), 10))
+	e.WriteString(`:"`)
+	e.WriteString(s)
+	e.WriteString(`";`)
 }
 
-func writeString(w io.Writer, s string) {
-	fmt.Fprintf(w, `s:%d:"%s";`, len(s), s)
+// writeBytes is like writeString for binary data.
+func (e *encodeState) writeBytes(b []byte) {
+	if e.escapedStrings {
+		e.writeEscapedString(string(b))
+		return
+	}
+	e.WriteString("s:")
+	e.Write(strconv.AppendInt(e.scratch[:0], int64(len(b)), 10))
+	e.WriteString(`:"`)
+	e.Write(b)
+	e.WriteString(`";`)
 }
 
-func writeArray(w io.Writer, v reflect.Value) {
-	l := v.Len()
-	fmt.Fprintf(w, "a:%d:{", l)
-	for i := 0; i < l; i++ {
-		writeInt(w, int64(i))
-		writeReflectValue(w, v.Index(i))
+// writeEscapedString writes s as an S: token, escaping backslashes and bytes
+// outside printable ASCII as \xx.
+func (e *encodeState) writeEscapedString(s string) {
+	const hex = "0123456789abcdef"
+	e.WriteString("S:")
+	e.Write(strconv.AppendInt(e.scratch[:0], int64(len(s)), 10))
+	e.WriteString(`:"`)
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < ' ' || c > '~' || c == '\\' {
+			e.Write([]byte{'\\', hex[c>>4], hex[c&0xf]})
+		} else {
+			e.WriteByte(c)
+		}
 	}
-	w.Write([]byte{'}'})
+	e.WriteString(`";`)
 }
 
 func intVal(v reflect.Value) (i int64, ok bool) {
@@ -158,153 +423,270 @@ func sortKeys(keys []reflect.Value) {
 	})
 }
 
-func writeMap(w io.Writer, v reflect.Value) {
-	keys := v.MapKeys()
-	sortKeys(keys)
-	fmt.Fprintf(w, "a:%d:{", len(keys))
-	for _, k := range keys {
-		writeMapKey(w, k)
-		writeReflectValue(w, v.MapIndex(k))
+var orderedMapType = reflect.TypeOf(php.OrderedMap{})
+
+func (e *encodeState) writeOrderedMap(v reflect.Value) {
+	var m *php.OrderedMap
+	if v.CanAddr() {
+		m = v.Addr().Interface().(*php.OrderedMap)
+	} else {
+		om := v.Interface().(php.OrderedMap)
+		m = &om
+	}
+	e.writeArrayHeader(m.Len())
+	var seen map[string]bool
+	for _, k := range m.Keys() {
+		val, _ := m.Get(k)
+		e.writeUniqueMapKey(reflect.ValueOf(k), &seen)
+		e.writeReflectValue(reflect.ValueOf(val))
 	}
-	w.Write([]byte{'}'})
+	e.writeEnd()
 }
 
-func writeMapKey(w io.Writer, v reflect.Value) {
+// writeUniqueMapKey writes the map key v and, if the strictKeys option is
+// set, checks that it differs from the keys written before, which *seen
+// records by their tokens.
+func (e *encodeState) writeUniqueMapKey(v reflect.Value, seen *map[string]bool) {
+	start := e.Len()
+	e.writeMapKey(v)
+	if !e.strictKeys {
+		return
+	}
+	if *seen == nil {
+		*seen = make(map[string]bool)
+	}
+	token := string(e.Bytes()[start:])
+	if (*seen)[token] {
+		raiseError(&UnsupportedValueError{v, "duplicate PHP array key " + token[:len(token)-1]})
+	}
+	(*seen)[token] = true
+}
+
+func (e *encodeState) writeMapKey(v reflect.Value) {
 	switch v.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		writeInt(w, v.Int())
+		e.writeIntKey(v.Int())
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		writeUint(w, v.Uint())
+		u := v.Uint()
+		if u <= e.maxInt() {
+			e.writeUint(u)
+			break
+		}
+		if e.uintOverflow == UintOverflowError {
+			raiseError(&UnsupportedValueError{v, strconv.FormatUint(u, 10) + " overflows PHP int"})
+		}
+		e.writeString(strconv.FormatUint(u, 10))
 	case reflect.String:
-		writeString(w, v.String())
+		e.writeStringKey(v.String())
 	case reflect.Interface:
-		writeMapKey(w, reflect.ValueOf(v.Interface()))
+		e.writeMapKey(reflect.ValueOf(v.Interface()))
 	default:
-		raiseError(&UnsupportedMapKeyTypeError{v.Type()})
+		m, ok := textMarshaler(v)
+		if !ok {
+			raiseError(&UnsupportedMapKeyTypeError{v.Type()})
+		}
+		bs, err := m.MarshalText()
+		if err != nil {
+			raiseError(&MarshalerError{v.Type(), err})
+		}
+		e.writeStringKey(string(bs))
 	}
 }
 
-func writeStruct(w io.Writer, v reflect.Value) {
-	name := v.Type().Name()
+// ClassNamer is the interface implemented by types that choose the PHP class
+// name they are serialized as, such as a namespaced "App\\Model\\User".
+type ClassNamer interface {
+	PHPClassName() string
+}
+
+var classNamerType = reflect.TypeOf((*ClassNamer)(nil)).Elem()
+
+// className returns the PHP class name of the struct v: the result of its
+// PHPClassName method, else of the class name mapper, else its Go type name.
+func (e *encodeState) className(v reflect.Value) string {
 	t := v.Type()
-	num := t.NumField()
-	fmt.Fprintf(w, `O:%d:"%s":%d:{`, len(name), name, num)
-
-	for i := 0; i < num; i++ {
-		f := t.Field(i)
-		var n string
-		if 'a' <= f.Name[0] && f.Name[0] <= 'z' {
-			n = fmt.Sprintf("\x00%s\x00%s", name, f.Name)
-		} else {
-			n = f.Name
-		}
-		writeString(w, n)
-		writeReflectValue(w, v.Field(i))
+	ti := cachedTypeInfo(t)
+	if ti.classNamer && v.CanInterface() {
+		return v.Interface().(ClassNamer).PHPClassName()
+	}
+	if v.CanAddr() && ti.ptrClassNamer && v.Addr().CanInterface() {
+		return v.Addr().Interface().(ClassNamer).PHPClassName()
 	}
-	w.Write([]byte{'}'})
+	if e.classNameMapper != nil {
+		return e.classNameMapper(t)
+	}
+	return t.Name()
 }
 
-func writeInterface(w io.Writer, i interface{}) {
+func (e *encodeState) writeInterface(i interface{}) {
 	if v, ok := i.(Marshaler); ok {
-		bs, err := v.MarshalPHPSerialize()
-		if err != nil {
-			panic(serializeErr{err})
-		}
-		w.Write(bs)
+		e.writeMarshaler(v)
 		return
 	}
 	if v, ok := i.(*php.Value); ok {
-		writePHPValue(w, v)
+		e.writePHPValue(v)
 		return
 	}
-	writeReflectValue(w, reflect.ValueOf(i))
+	e.writeReflectValue(reflect.ValueOf(i))
 }
 
-func writePHPValue(w io.Writer, v *php.Value) {
+func (e *encodeState) writePHPValue(v *php.Value) {
+	e.spill()
+	if r := v.Raw(); r != nil {
+		e.Write(r.Bytes())
+		return
+	}
 	if v.IsNil() {
-		writeNil(w)
+		e.writeNil()
 		return
 	}
 	switch v.Type() {
 	case php.TypeBool:
-		writeBool(w, v.Bool())
+		e.writeBool(v.Bool())
 	case php.TypeInt:
-		writeInt(w, v.Int())
+		if lex := v.Lexeme(); lex != "" {
+			e.WriteString("i:")
+			e.WriteString(lex)
+			e.WriteByte(';')
+			return
+		}
+		e.writeInt(v.Int())
 	case php.TypeFloat:
-		writeFloat(w, v.Float())
+		if lex := v.Lexeme(); lex != "" {
+			e.WriteString("d:")
+			e.WriteString(lex)
+			e.WriteByte(';')
+			return
+		}
+		e.writeFloat(v.Float())
 	case php.TypeString:
-		writeString(w, v.String())
+		if v.IsBytes() {
+			e.writeBytes(v.Bytes())
+			return
+		}
+		e.writeString(v.String())
 	case php.TypeArray:
-		writePHPArray(w, v.Array())
+		e.writePHPArray(v.Array())
 	case php.TypeObject:
-		writePHPObject(w, v.Object())
+		e.writePHPObject(v.Object())
 	default:
 		panic(serializeErr{fmt.Errorf("invalid PHPValue Type: %v", v.Type())})
 	}
 }
 
-func writePHPArray(w io.Writer, arr []*php.ArrayElement) {
-	fmt.Fprintf(w, "a:%d:{", len(arr))
+func (e *encodeState) writePHPArray(arr []*php.ArrayElement) {
+	e.writeArrayHeader(len(arr))
 	for _, val := range arr {
-		writePHPValue(w, val.Index)
-		writePHPValue(w, val.Value)
-	}
-	w.Write([]byte{'}'})
-}
-
-func writePHPObject(w io.Writer, obj *php.Obj) {
-	fmt.Fprintf(w, `O:%d:"%s":%d:{`, len(obj.Name), obj.Name, len(obj.Fields))
-	for _, f := range obj.Fields {
-		var name string
-		switch f.Visibility {
-		case php.VisibilityProtected:
-			name = fmt.Sprintf("*%s", f.Name)
-		case php.VisibilityPrivate:
-			name = fmt.Sprintf("\x00%s\x00%s", obj.Name, f.Name)
-		default: // public
-			name = f.Name
+		switch {
+		case val.Index.Type() == php.TypeInt && val.Index.Lexeme() == "":
+			e.writeIntKey(val.Index.Int())
+		case val.Index.Type() == php.TypeString:
+			e.writeStringKey(val.Index.String())
+		default:
+			e.writePHPValue(val.Index)
 		}
-		writeString(w, name)
-		writePHPValue(w, f.Value)
+		e.writePHPValue(val.Value)
 	}
-	w.Write([]byte{'}'})
+	e.writeEnd()
 }
 
-func writeReflectValue(w io.Writer, v reflect.Value) {
+func (e *encodeState) writePHPObject(obj *php.Obj) {
+	if obj.Serialized != nil {
+		name := obj.Name
+		if n, ok := obj.IncompleteClassName(); ok {
+			name = n
+		}
+		e.writeCustomObject(name, obj.Serialized)
+		return
+	}
+	name, fields := obj.Name, obj.Fields
+	if n, ok := obj.IncompleteClassName(); ok {
+		// PHP writes incomplete class objects as they were read
+		name, fields = n, fields[1:]
+	}
+	e.writeObjectHeader(name, len(fields))
+	for _, f := range fields {
+		e.writeString(f.MangledName(name))
+		e.writePHPValue(f.Value)
+	}
+	e.writeEnd()
+}
+
+// writeCustomObject writes an object of class serialized by the
+// Serializable interface as data.
+func (e *encodeState) writeCustomObject(class string, data []byte) {
+	e.WriteString("C:")
+	e.Write(strconv.AppendInt(e.scratch[:0], int64(len(class)), 10))
+	e.WriteString(`:"`)
+	e.WriteString(class)
+	e.WriteString(`":`)
+	e.Write(strconv.AppendInt(e.scratch[:0], int64(len(data)), 10))
+	e.WriteString(":{")
+	e.Write(data)
+	e.WriteByte('}')
+}
+
+// writeReflectValue writes v with the encoder compiled for its type.
+func (e *encodeState) writeReflectValue(v reflect.Value) {
+	e.spill()
 	if !v.IsValid() {
-		writeNil(w)
+		e.writeNil()
 		return
 	}
-	if v.Kind() == reflect.Ptr {
-		if v.IsNil() {
-			writeNil(w)
-			return
-		}
-		v = v.Elem()
+	cachedTypeEncoder(v.Type(), &e.options)(e, v)
+}
+
+// phpValue returns the *php.Value or php.Value v as a *php.Value, and
+// reports whether v is one.
+func phpValue(v reflect.Value) (*php.Value, bool) {
+	t := v.Type()
+	if t != phpValueType && t != phpValueType.Elem() {
+		return nil, false
 	}
+	if !v.CanInterface() {
+		// the fields of an unexported php.Value are not its value
+		raiseError(&UnsupportedValueError{v, "unexported " + t.String()})
+	}
+	switch x := v.Interface().(type) {
+	case *php.Value:
+		return x, true
+	case php.Value:
+		return &x, true
+	}
+	return nil, false
+}
 
-	switch v.Kind() {
-	case reflect.Bool:
-		writeBool(w, v.Bool())
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		writeInt(w, v.Int())
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		writeUint(w, v.Uint())
-	case reflect.Float32, reflect.Float64:
-		writeFloat(w, v.Float())
-	case reflect.String:
-		writeString(w, v.String())
-	case reflect.Array, reflect.Slice:
-		writeArray(w, v)
-	case reflect.Map:
-		writeMap(w, v)
-	case reflect.Struct:
-		writeStruct(w, v)
-	case reflect.Interface:
-		writeReflectValue(w, reflect.ValueOf(v.Interface()))
-	default:
-		raiseError(&UnsupportedTypeError{v.Type()})
+// writeRegistered writes v with the encoder registered for its type, and
+// reports whether there is one.
+func (e *encodeState) writeRegistered(v reflect.Value) bool {
+	fn, ok := typeEncoder(v.Type())
+	if !ok || !v.CanInterface() {
+		return false
+	}
+	pv, err := fn(v)
+	if err != nil {
+		raiseError(&MarshalerError{v.Type(), err})
+	}
+	e.writePHPValue(pv)
+	return true
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// textMarshaler returns v, or its address if addressable, as an
+// encoding.TextMarshaler if it implements it.
+func textMarshaler(v reflect.Value) (encoding.TextMarshaler, bool) {
+	ti := cachedTypeInfo(v.Type())
+	if ti.textMarshaler && v.CanInterface() {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil, false
+		}
+		return v.Interface().(encoding.TextMarshaler), true
+	}
+	if v.CanAddr() && ti.ptrTextMarshaler && v.Addr().CanInterface() {
+		return v.Addr().Interface().(encoding.TextMarshaler), true
 	}
+	return nil, false
 }
 
 func raiseError(e error) {
@@ -3,7 +3,12 @@ package phpserialize_test
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	phpserialize "github.com/kamiaka/go-phpserialize"
 	"github.com/kamiaka/go-phpserialize/php"
@@ -49,7 +54,11 @@ func TestMarshals(t *testing.T) {
 		},
 		{
 			val:  12345678901234567890.0,
-			want: []byte("d:1.2345678901234567e+19;"),
+			want: []byte("d:1.2345678901234567E+19;"),
+		},
+		{
+			val:  php.Bytes([]byte("\x00\xff")),
+			want: []byte("s:2:\"\x00\xff\";"),
 		},
 		{
 			val:  "日本語",
@@ -75,6 +84,14 @@ func TestMarshals(t *testing.T) {
 			val:  []int(nil),
 			want: []byte(`a:0:{}`),
 		},
+		{
+			val:  []byte("\x00\xffbin"),
+			want: []byte("s:5:\"\x00\xffbin\";"),
+		},
+		{
+			val:  [2]byte{1, 2},
+			want: []byte(`a:2:{i:0;i:1;i:1;i:2;}`),
+		},
 		{
 			val: map[interface{}]int{
 				"a":  0,
@@ -94,6 +111,10 @@ func TestMarshals(t *testing.T) {
 			},
 			want: []byte(`O:7:"testVal":4:{s:5:"First";s:5:"f` + "\n" + `val";s:6:"Second";i:42;s:5:"Third";b:1;s:15:"` + "\x00testVal\x00fourth" + `";i:3;}`),
 		},
+		{
+			val:  php.NewOrderedMap().Set("z", 1).Set(5, "a").Set("b", []int{1}).Set(int64(5), "c"),
+			want: []byte(`a:3:{s:1:"z";i:1;i:5;s:1:"c";s:1:"b";a:1:{i:0;i:1;}}`),
+		},
 		{
 			val: php.Array([]*php.ArrayElement{
 				{Index: php.Int(0), Value: php.Int(1)},
@@ -110,7 +131,7 @@ func TestMarshals(t *testing.T) {
 					php.Field("c", php.Bool(true), php.VisibilityPrivate),
 				}...,
 			),
-			want: []byte(`O:3:"Foo":3:{s:1:"a";i:42;s:2:"*b";s:3:"aaa";s:6:"` + "\x00Foo\x00c" + `";b:1;}`),
+			want: []byte(`O:3:"Foo":3:{s:1:"a";i:42;s:4:"` + "\x00*\x00b" + `";s:3:"aaa";s:6:"` + "\x00Foo\x00c" + `";b:1;}`),
 		},
 	}
 
@@ -132,3 +153,1036 @@ func ExampleMarshal() {
 	// Output:
 	// a:2:{i:0;s:1:"a";i:1;s:3:"bbb";}
 }
+
+func ExampleMarshal_visibility() {
+	type User struct {
+		Name     string `php:"name"`
+		Password string `php:"password,private"`
+		Role     string `php:",protected"`
+	}
+	bs, _ := phpserialize.Marshal(User{Name: "bob", Password: "secret", Role: "admin"})
+	fmt.Printf("%q\n", bs)
+
+	// Output:
+	// "O:4:\"User\":3:{s:4:\"name\";s:3:\"bob\";s:14:\"\x00User\x00password\";s:6:\"secret\";s:7:\"\x00*\x00Role\";s:5:\"admin\";}"
+}
+
+func TestMarshalVisibilityTags(t *testing.T) {
+	type Base struct {
+		ID int `php:"id,private"`
+	}
+	type Account struct {
+		Base
+		Name   string `php:"name"`
+		Secret string `php:"secret,private"`
+		Token  string `php:"token,protected"`
+		Public string `php:"token,public"`
+	}
+	v := Account{Base: Base{ID: 1}, Name: "bob", Secret: "s", Token: "t", Public: "p"}
+	cases := []struct {
+		opts []phpserialize.Option
+		want string
+	}{
+		{
+			want: "O:7:\"Account\":5:{s:4:\"Base\";O:4:\"Base\":1:{s:8:\"\x00Base\x00id\";i:1;}s:4:\"name\";s:3:\"bob\";" +
+				"s:15:\"\x00Account\x00secret\";s:1:\"s\";s:8:\"\x00*\x00token\";s:1:\"t\";s:5:\"token\";s:1:\"p\";}",
+		},
+		{
+			opts: []phpserialize.Option{phpserialize.WithPromoteEmbedded()},
+			want: "O:7:\"Account\":5:{s:8:\"\x00Base\x00id\";i:1;s:4:\"name\";s:3:\"bob\";" +
+				"s:15:\"\x00Account\x00secret\";s:1:\"s\";s:8:\"\x00*\x00token\";s:1:\"t\";s:5:\"token\";s:1:\"p\";}",
+		},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.MarshalWithOptions(v, tc.opts...)
+		if err != nil {
+			t.Errorf("#%d: Marshal(%+v) returns error: %v", i, v, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("#%d: Marshal(%+v) == %q, want: %q", i, v, got, tc.want)
+		}
+	}
+
+	data, _ := phpserialize.Marshal(v)
+	var got Account
+	if err := phpserialize.UnmarshalInto(data, &got); err != nil {
+		t.Fatalf("UnmarshalInto(%q) returns error: %v", data, err)
+	}
+	if got != v {
+		t.Errorf("UnmarshalInto(%q) == %+v, want: %+v", data, got, v)
+	}
+}
+
+func TestEncoderSetFieldNameMapper(t *testing.T) {
+	type user struct {
+		UserID    int
+		HTTPProxy string
+		secret    string
+	}
+	v := user{UserID: 1, HTTPProxy: "p", secret: "s"}
+
+	cases := []struct {
+		mapper func(string) string
+		want   string
+	}{
+		{
+			mapper: phpserialize.SnakeCase,
+			want:   `O:4:"user":3:{s:7:"user_id";i:1;s:10:"http_proxy";s:1:"p";s:12:"` + "\x00user\x00secret" + `";s:1:"s";}`,
+		},
+		{
+			mapper: phpserialize.LowerCamelCase,
+			want:   `O:4:"user":3:{s:6:"userID";i:1;s:9:"httpProxy";s:1:"p";s:12:"` + "\x00user\x00secret" + `";s:1:"s";}`,
+		},
+	}
+	for i, tc := range cases {
+		var buf bytes.Buffer
+		enc := phpserialize.NewEncoder(&buf, phpserialize.WithFieldNameMapper(tc.mapper))
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("#%d: Encode(...) returns error: %v", i, err)
+		}
+		if got := buf.String(); got != tc.want {
+			t.Errorf("#%d: Encode(...) writes %q\nwant: %q", i, got, tc.want)
+		}
+	}
+}
+
+type namespacedUser struct {
+	Name string
+	role string
+}
+
+func (namespacedUser) PHPClassName() string {
+	return `App\Model\User`
+}
+
+func TestMarshalClassNamer(t *testing.T) {
+	got, err := phpserialize.Marshal(&namespacedUser{Name: "a", role: "b"})
+	if err != nil {
+		t.Fatalf("Marshal(...) returns error: %v", err)
+	}
+	want := `O:14:"App\Model\User":2:{s:4:"Name";s:1:"a";s:20:"` + "\x00App\\Model\\User\x00role" + `";s:1:"b";}`
+	if string(got) != want {
+		t.Errorf("Marshal(...) == %q\nwant: %q", got, want)
+	}
+}
+
+func TestEncoderWithClassNameMapper(t *testing.T) {
+	var buf bytes.Buffer
+	enc := phpserialize.NewEncoder(&buf, phpserialize.WithClassNameMapper(func(t reflect.Type) string {
+		return `App\` + t.Name()
+	}))
+	if err := enc.Encode([]interface{}{testVal{}, namespacedUser{}}); err != nil {
+		t.Fatalf("Encode(...) returns error: %v", err)
+	}
+	got := buf.String()
+	for _, want := range []string{`O:11:"App\testVal":`, `O:14:"App\Model\User":`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Encode(...) writes %q, want to contain %q", got, want)
+		}
+	}
+}
+
+type Base struct {
+	ID      int
+	Name    string
+	created int
+}
+
+type Tagged struct {
+	Name string
+}
+
+type article struct {
+	Base
+	*Tagged
+	Title string
+	Name  string
+}
+
+func TestEncoderSetPromoteEmbedded(t *testing.T) {
+	v := article{Base: Base{ID: 1, Name: "base", created: 2}, Title: "t", Name: "n"}
+
+	var buf bytes.Buffer
+	enc := phpserialize.NewEncoder(&buf, phpserialize.WithPromoteEmbedded())
+	if err := enc.Encode(v); err != nil {
+		t.Fatalf("Encode(...) returns error: %v", err)
+	}
+	want := `O:7:"article":4:{s:2:"ID";i:1;s:13:"` + "\x00Base\x00created" + `";i:2;s:5:"Title";s:1:"t";s:4:"Name";s:1:"n";}`
+	if got := buf.String(); got != want {
+		t.Errorf("Encode(...) writes %q\nwant: %q", got, want)
+	}
+
+	got, err := phpserialize.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal(...) returns error: %v", err)
+	}
+	if !strings.HasPrefix(string(got), `O:7:"article":4:{s:4:"Base";O:4:"Base":3:{`) {
+		t.Errorf("Marshal(...) == %q, want embedded struct as nested object", got)
+	}
+}
+
+type selfNode struct {
+	*selfNode
+	X int
+}
+
+type cycleA struct {
+	*cycleB
+	A int
+}
+
+type cycleB struct {
+	*cycleA
+	B int
+}
+
+type diamondLeaf struct{ L int }
+
+type diamondLeft struct{ diamondLeaf }
+
+type diamondRight struct{ diamondLeaf }
+
+type diamond struct {
+	diamondLeft
+	diamondRight
+	D int
+}
+
+func TestMarshalPromoteEmbeddedCycle(t *testing.T) {
+	cases := []struct {
+		v    interface{}
+		want string
+	}{
+		{
+			v:    selfNode{selfNode: &selfNode{X: 2}, X: 1},
+			want: `O:8:"selfNode":1:{s:1:"X";i:1;}`,
+		},
+		{
+			v:    cycleA{cycleB: &cycleB{B: 2}, A: 1},
+			want: `O:6:"cycleA":2:{s:1:"B";i:2;s:1:"A";i:1;}`,
+		},
+		{
+			// a type reached twice at the same depth is walked once, and
+			// its fields are ambiguous
+			v:    diamond{D: 1},
+			want: `O:7:"diamond":1:{s:1:"D";i:1;}`,
+		},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.MarshalWithOptions(tc.v, phpserialize.WithPromoteEmbedded())
+		if err != nil {
+			t.Errorf("#%d: Marshal(%+v) returns error: %v", i, tc.v, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("#%d: Marshal(%+v) == %q, want: %q", i, tc.v, got, tc.want)
+		}
+	}
+}
+
+func TestMarshalStructTags(t *testing.T) {
+	type tagged struct {
+		ID      int    `php:"id"`
+		Name    string `php:"name,omitempty"`
+		Note    string `php:",omitempty"`
+		Ignored string `php:"-"`
+		Dash    int    `php:"-,"`
+	}
+	cases := []struct {
+		val  tagged
+		want string
+	}{
+		{
+			val:  tagged{ID: 1, Ignored: "x"},
+			want: `O:6:"tagged":2:{s:2:"id";i:1;s:1:"-";i:0;}`,
+		},
+		{
+			val:  tagged{ID: 1, Name: "a", Note: "b", Dash: 2},
+			want: `O:6:"tagged":4:{s:2:"id";i:1;s:4:"name";s:1:"a";s:4:"Note";s:1:"b";s:1:"-";i:2;}`,
+		},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.Marshal(tc.val)
+		if err != nil {
+			t.Fatalf("#%d: Marshal(...) returns error: %v", i, err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("#%d: Marshal(...) == %s\nwant: %s", i, got, tc.want)
+		}
+	}
+}
+
+func TestEncoderSetTimeEncoding(t *testing.T) {
+	tm := time.Date(2021, 1, 2, 3, 4, 5, 6000, time.UTC)
+	cases := []struct {
+		enc    phpserialize.TimeEncoding
+		layout string
+		val    time.Time
+		want   string
+	}{
+		{enc: phpserialize.TimeUnix, val: tm, want: `i:1609556645;`},
+		{enc: phpserialize.TimeString, val: tm, want: `s:20:"2021-01-02T03:04:05Z";`},
+		{enc: phpserialize.TimeString, layout: "2006-01-02", val: tm, want: `s:10:"2021-01-02";`},
+		{
+			enc:  phpserialize.TimeDateTime,
+			val:  tm,
+			want: `O:8:"DateTime":3:{s:4:"date";s:26:"2021-01-02 03:04:05.000006";s:13:"timezone_type";i:3;s:8:"timezone";s:3:"UTC";}`,
+		},
+		{
+			enc:  phpserialize.TimeDateTimeImmutable,
+			val:  tm.In(time.FixedZone("", -(9*3600 + 30*60))),
+			want: `O:17:"DateTimeImmutable":3:{s:4:"date";s:26:"2021-01-01 17:34:05.000006";s:13:"timezone_type";i:1;s:8:"timezone";s:6:"-09:30";}`,
+		},
+	}
+	for i, tc := range cases {
+		var buf bytes.Buffer
+		enc := phpserialize.NewEncoder(&buf, phpserialize.WithTimeEncoding(tc.enc), phpserialize.WithTimeLayout(tc.layout))
+		if err := enc.Encode(&tc.val); err != nil {
+			t.Fatalf("#%d: Encode(...) returns error: %v", i, err)
+		}
+		if got := buf.String(); got != tc.want {
+			t.Errorf("#%d: Encode(...) writes %s\nwant: %s", i, got, tc.want)
+		}
+	}
+}
+
+func TestMarshalFloat(t *testing.T) {
+	cases := []struct {
+		val  float64
+		prec int
+		want string
+	}{
+		{val: 0, want: "d:0;"},
+		{val: math.Copysign(0, -1), want: "d:-0;"},
+		{val: 1, want: "d:1;"},
+		{val: 0.1, want: "d:0.1;"},
+		{val: -1.5, want: "d:-1.5;"},
+		{val: 0.0001, want: "d:0.0001;"},
+		{val: 0.00001, want: "d:1.0E-5;"},
+		{val: 1.5e-7, want: "d:1.5E-7;"},
+		{val: 1e17, want: "d:1.0E+17;"},
+		{val: 1e16, want: "d:10000000000000000;"},
+		{val: 123456789.125, want: "d:123456789.125;"},
+		{val: 1e100, want: "d:1.0E+100;"},
+		{val: 0.1, prec: 17, want: "d:0.10000000000000001;"},
+		{val: 1, prec: 17, want: "d:1;"},
+		{val: 3.14159, prec: 3, want: "d:3.14;"},
+		{val: 1234.5, prec: 3, want: "d:1.23E+3;"},
+	}
+	for i, tc := range cases {
+		var buf bytes.Buffer
+		enc := phpserialize.NewEncoder(&buf, phpserialize.WithFloatPrecision(tc.prec))
+		if err := enc.Encode(tc.val); err != nil {
+			t.Fatalf("#%d: Encode(%v) returns error: %v", i, tc.val, err)
+		}
+		if got := buf.String(); got != tc.want {
+			t.Errorf("#%d: Encode(%v) writes %s, want: %s", i, tc.val, got, tc.want)
+		}
+	}
+}
+
+func TestEncoderSetInt32(t *testing.T) {
+	cases := []struct {
+		val  interface{}
+		want string
+	}{
+		{val: int64(math.MaxInt32), want: "i:2147483647;"},
+		{val: int64(math.MinInt32), want: "i:-2147483648;"},
+		{val: int64(math.MaxInt32 + 1), want: "d:2147483648;"},
+		{val: int64(math.MinInt32 - 1), want: "d:-2147483649;"},
+		{val: uint32(math.MaxUint32), want: "d:4294967295;"},
+		{val: map[int64]int{1 << 32: 1}, want: `a:1:{s:10:"4294967296";i:1;}`},
+		{
+			val:  php.Array(php.Element(php.Int(1<<32), php.Int(1<<32))),
+			want: `a:1:{s:10:"4294967296";d:4294967296;}`,
+		},
+	}
+	for i, tc := range cases {
+		var buf bytes.Buffer
+		enc := phpserialize.NewEncoder(&buf, phpserialize.WithInt32())
+		if err := enc.Encode(tc.val); err != nil {
+			t.Fatalf("#%d: Encode(%v) returns error: %v", i, tc.val, err)
+		}
+		if got := buf.String(); got != tc.want {
+			t.Errorf("#%d: Encode(%v) writes %s, want: %s", i, tc.val, got, tc.want)
+		}
+	}
+}
+
+func TestEncoderSetUintOverflow(t *testing.T) {
+	const big = uint64(math.MaxInt64) + 1
+	cases := []struct {
+		policy phpserialize.UintOverflow
+		val    interface{}
+		want   string
+		err    bool
+	}{
+		{policy: phpserialize.UintOverflowFloat, val: uint64(math.MaxInt64), want: "i:9223372036854775807;"},
+		{policy: phpserialize.UintOverflowFloat, val: big, want: "d:9.223372036854776E+18;"},
+		{policy: phpserialize.UintOverflowString, val: big, want: `s:19:"9223372036854775808";`},
+		{policy: phpserialize.UintOverflowError, val: big, err: true},
+		{policy: phpserialize.UintOverflowFloat, val: map[uint64]int{big: 1}, want: `a:1:{s:19:"9223372036854775808";i:1;}`},
+		{policy: phpserialize.UintOverflowError, val: map[uint64]int{big: 1}, err: true},
+	}
+	for i, tc := range cases {
+		var buf bytes.Buffer
+		enc := phpserialize.NewEncoder(&buf, phpserialize.WithUintOverflow(tc.policy))
+		err := enc.Encode(tc.val)
+		if tc.err {
+			if _, ok := err.(*phpserialize.UnsupportedValueError); !ok {
+				t.Errorf("#%d: Encode(%v) returns error %v, want: *UnsupportedValueError", i, tc.val, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d: Encode(%v) returns error: %v", i, tc.val, err)
+		}
+		if got := buf.String(); got != tc.want {
+			t.Errorf("#%d: Encode(%v) writes %s, want: %s", i, tc.val, got, tc.want)
+		}
+	}
+}
+
+func TestEncoderSetMapKeyOrder(t *testing.T) {
+	m := map[interface{}]int{"img10": 1, "img2": 2, 10: 3, 9: 4, "1e1": 5, "B": 6}
+	byLength := func(a, b interface{}) int {
+		return len(fmt.Sprint(a)) - len(fmt.Sprint(b))
+	}
+	cases := []struct {
+		order phpserialize.MapKeyOrder
+		cmp   func(a, b interface{}) int
+		want  string
+	}{
+		{order: phpserialize.MapKeyOrderDefault, want: `i:9;i:10;s:3:"1e1";s:1:"B";s:5:"img10";s:4:"img2";`},
+		{order: phpserialize.MapKeyOrderString, want: `i:10;s:3:"1e1";i:9;s:1:"B";s:5:"img10";s:4:"img2";`},
+		{order: phpserialize.MapKeyOrderNatural, want: `s:3:"1e1";i:9;i:10;s:1:"B";s:4:"img2";s:5:"img10";`},
+		{order: phpserialize.MapKeyOrderPHP, want: `i:9;i:10;s:3:"1e1";s:1:"B";s:5:"img10";s:4:"img2";`},
+		{order: phpserialize.MapKeyOrderPHP, cmp: byLength, want: `i:9;s:1:"B";i:10;s:3:"1e1";s:4:"img2";s:5:"img10";`},
+	}
+	for i, tc := range cases {
+		var buf bytes.Buffer
+		enc := phpserialize.NewEncoder(&buf, phpserialize.WithMapKeyOrder(tc.order), phpserialize.WithMapKeyCompare(tc.cmp))
+		if err := enc.Encode(m); err != nil {
+			t.Fatalf("#%d: Encode(...) returns error: %v", i, err)
+		}
+		got := buf.String()
+		got = got[strings.Index(got, "{")+1 : len(got)-1]
+		var keys []string
+		for j, part := range strings.SplitAfter(got, ";") {
+			if j%2 == 0 {
+				keys = append(keys, part)
+			}
+		}
+		if k := strings.Join(keys, ""); k != tc.want {
+			t.Errorf("#%d: keys are %s, want: %s", i, k, tc.want)
+		}
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	v := map[string]interface{}{
+		"id":    42,
+		"name":  "foo",
+		"score": 1.5,
+		"tags":  []string{"a", "b", "c"},
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := phpserialize.Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMarshalStructOptionsAfterCaching(t *testing.T) {
+	type user struct {
+		UserName string `json:"login"`
+	}
+	v := user{UserName: "bob"}
+	cases := []struct {
+		opts []phpserialize.Option
+		want string
+	}{
+		{want: `O:4:"user":1:{s:8:"UserName";s:3:"bob";}`},
+		{opts: []phpserialize.Option{phpserialize.WithJSONTags()}, want: `O:4:"user":1:{s:5:"login";s:3:"bob";}`},
+		{opts: []phpserialize.Option{phpserialize.WithFieldNameMapper(phpserialize.SnakeCase)}, want: `O:4:"user":1:{s:9:"user_name";s:3:"bob";}`},
+		{opts: []phpserialize.Option{phpserialize.WithFieldNameMapper(strings.ToUpper)}, want: `O:4:"user":1:{s:8:"USERNAME";s:3:"bob";}`},
+		{want: `O:4:"user":1:{s:8:"UserName";s:3:"bob";}`},
+	}
+	for i, tc := range cases {
+		bs, err := phpserialize.MarshalWithOptions(v, tc.opts...)
+		if err != nil || string(bs) != tc.want {
+			t.Errorf("#%d: MarshalWithOptions(...) == %s, %v, want: %s, nil", i, bs, err, tc.want)
+		}
+	}
+
+	// closures of the same code are different mappers
+	prefixed := func(p string) func(string) string {
+		return func(s string) string { return p + s }
+	}
+	for _, p := range []string{"a_", "b_"} {
+		bs, err := phpserialize.MarshalWithOptions(v, phpserialize.WithFieldNameMapper(prefixed(p)))
+		if want := `O:4:"user":1:{s:10:"` + p + `UserName";s:3:"bob";}`; err != nil || string(bs) != want {
+			t.Errorf("MarshalWithOptions(...) with mapper %q == %s, %v, want: %s, nil", p, bs, err, want)
+		}
+	}
+}
+
+func TestMarshalFieldNameMapperCached(t *testing.T) {
+	type item struct {
+		ItemName  string
+		ItemPrice float64
+	}
+	v := []item{{"pen", 1.5}, {"ink", 2}}
+	marshal := func(opts ...phpserialize.Option) func() {
+		return func() {
+			if _, err := phpserialize.MarshalWithOptions(v, opts...); err != nil {
+				t.Fatalf("MarshalWithOptions(...) returns error: %v", err)
+			}
+		}
+	}
+	want := testing.AllocsPerRun(100, marshal())
+	// the option itself is one more allocation
+	if got := testing.AllocsPerRun(100, marshal(phpserialize.WithFieldNameMapper(phpserialize.SnakeCase))); got > want+1 {
+		t.Errorf("MarshalWithOptions(...) with a field name mapper allocates %v times, want: %v at most", got, want+1)
+	}
+}
+
+func BenchmarkMarshalStruct(b *testing.B) {
+	type item struct {
+		SKU   string  `php:"sku"`
+		Qty   int     `php:"qty,omitempty"`
+		Price float64 `php:"price"`
+	}
+	type order struct {
+		ID     int       `php:"id"`
+		Note   string    `php:"note,protected"`
+		Items  []item    `php:"items"`
+		Placed time.Time `php:"placed"`
+	}
+	v := order{ID: 42, Note: "gift", Items: []item{{"a", 1, 1.5}, {"b", 2, 2.5}, {"c", 0, 3}}, Placed: time.Unix(0, 0)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := phpserialize.Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMarshalAppend(t *testing.T) {
+	dst := make([]byte, 0, 64)
+	dst = append(dst, "prefix|"...)
+	got, err := phpserialize.MarshalAppend(dst, []int{1})
+	if err != nil {
+		t.Fatalf("MarshalAppend(...) returns error: %v", err)
+	}
+	if want := "prefix|a:1:{i:0;i:1;}"; string(got) != want {
+		t.Errorf("MarshalAppend(...) == %s, want: %s", got, want)
+	}
+	if &got[0] != &dst[0] {
+		t.Errorf("MarshalAppend(...) reallocates dst with spare capacity")
+	}
+
+	got, err = phpserialize.MarshalAppend(dst, func() {})
+	if err == nil || string(got) != "prefix|" {
+		t.Errorf("MarshalAppend(func) == %s, %v, want: prefix| and error", got, err)
+	}
+}
+
+func TestSerializedLen(t *testing.T) {
+	type item struct {
+		Name  string
+		Price float64
+	}
+	cases := []struct {
+		val  interface{}
+		opts []phpserialize.Option
+	}{
+		{val: nil},
+		{val: "héllo"},
+		{val: []interface{}{1, 2.5, true, map[string]int{"a": 1, "b": 2}}},
+		{val: item{Name: "pen", Price: 0.1}},
+		{val: item{Name: "pen", Price: 0.1}, opts: []phpserialize.Option{phpserialize.WithFloatPrecision(17), phpserialize.WithFieldNameMapper(phpserialize.SnakeCase)}},
+	}
+	for i, tc := range cases {
+		want, err := phpserialize.MarshalWithOptions(tc.val, tc.opts...)
+		if err != nil {
+			t.Fatalf("#%d: MarshalWithOptions(%#v) returns error: %v", i, tc.val, err)
+		}
+		got, err := phpserialize.SerializedLen(tc.val, tc.opts...)
+		if err != nil || got != len(want) {
+			t.Errorf("#%d: SerializedLen(%#v) == %d, %v, want: %d, nil", i, tc.val, got, err, len(want))
+		}
+	}
+
+	if n, err := phpserialize.SerializedLen(func() {}); err == nil {
+		t.Errorf("SerializedLen(func) == %d, nil, want error", n)
+	}
+}
+
+func TestSerializedLenLarge(t *testing.T) {
+	// the bytes are counted as they are encoded rather than kept
+	big := make([]map[string]string, 10000)
+	for i := range big {
+		big[i] = map[string]string{"key": strings.Repeat("x", 100)}
+	}
+	opts := [][]phpserialize.Option{nil, {phpserialize.WithStrictKeys()}, {phpserialize.WithValidUTF8()}}
+	for i, o := range opts {
+		want, err := phpserialize.MarshalWithOptions(big, o...)
+		if err != nil {
+			t.Fatalf("#%d: MarshalWithOptions(...) returns error: %v", i, err)
+		}
+		got, err := phpserialize.SerializedLen(big, o...)
+		if err != nil || got != len(want) {
+			t.Errorf("#%d: SerializedLen(...) == %d, %v, want: %d, nil", i, got, err, len(want))
+		}
+	}
+
+	list := make([]string, 10000)
+	for i := range list {
+		list[i] = strings.Repeat("x", 100)
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	n, _ := phpserialize.SerializedLen(list)
+	runtime.ReadMemStats(&after)
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > uint64(n)/4 {
+		t.Errorf("SerializedLen(...) of %d bytes allocates %d bytes", n, alloc)
+	}
+}
+
+func TestMarshalRawMessage(t *testing.T) {
+	type wrapper struct {
+		Name string
+		Data phpserialize.RawMessage
+	}
+	cases := []struct {
+		val  interface{}
+		want string
+	}{
+		{val: phpserialize.RawMessage(`a:1:{i:0;i:1;}`), want: `a:1:{i:0;i:1;}`},
+		{val: phpserialize.RawMessage(nil), want: `N;`},
+		{
+			val:  wrapper{Name: "a", Data: phpserialize.RawMessage(`b:1;`)},
+			want: `O:7:"wrapper":2:{s:4:"Name";s:1:"a";s:4:"Data";b:1;}`,
+		},
+		{val: []phpserialize.RawMessage{[]byte(`i:1;`), nil}, want: `a:2:{i:0;i:1;i:1;N;}`},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.Marshal(tc.val)
+		if err != nil {
+			t.Fatalf("#%d: Marshal(%v) returns error: %v", i, tc.val, err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("#%d: Marshal(%v) == %s, want: %s", i, tc.val, got, tc.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := phpserialize.NewEncoder(&buf, phpserialize.WithValidate())
+	err := enc.Encode(phpserialize.RawMessage(`i:1`))
+	if _, ok := err.(*phpserialize.MarshalerError); !ok {
+		t.Errorf("Encode(invalid RawMessage) returns error %v, want: *MarshalerError", err)
+	}
+}
+
+func TestEncoderSetMaxDepth(t *testing.T) {
+	type node struct {
+		Next *node
+	}
+	cyclic := &node{}
+	cyclic.Next = cyclic
+
+	m := map[string]interface{}{}
+	m["self"] = m
+
+	cases := []struct {
+		val   interface{}
+		depth int
+		err   bool
+	}{
+		{val: [][]int{{1}}, depth: 2},
+		{val: [][]int{{1}}, depth: 1, err: true},
+		{val: cyclic, depth: 100, err: true},
+		{val: m, depth: 100, err: true},
+		{val: php.Array(php.Element(php.Int(0), php.Array())), depth: 1, err: true},
+	}
+	for i, tc := range cases {
+		var buf bytes.Buffer
+		enc := phpserialize.NewEncoder(&buf, phpserialize.WithMaxDepth(tc.depth))
+		err := enc.Encode(tc.val)
+		if tc.err != (err != nil) {
+			t.Errorf("#%d: Encode(...) with max depth %d returns error %v, want error: %v", i, tc.depth, err, tc.err)
+		}
+	}
+}
+
+func TestMarshalWithOptions(t *testing.T) {
+	type item struct {
+		ItemID int
+		Price  uint64
+	}
+	got, err := phpserialize.MarshalWithOptions(
+		item{ItemID: 1, Price: math.MaxUint64},
+		phpserialize.WithFieldNameMapper(phpserialize.SnakeCase),
+		phpserialize.WithUintOverflow(phpserialize.UintOverflowString),
+	)
+	if err != nil {
+		t.Fatalf("MarshalWithOptions(...) returns error: %v", err)
+	}
+	want := `O:4:"item":2:{s:7:"item_id";i:1;s:5:"price";s:20:"18446744073709551615";}`
+	if string(got) != want {
+		t.Errorf("MarshalWithOptions(...) == %s\nwant: %s", got, want)
+	}
+}
+
+func TestMarshalNilSliceAsNull(t *testing.T) {
+	type lists struct {
+		Ints  []int
+		Map   map[string]int
+		Bytes []byte
+		Empty []int
+	}
+	v := lists{Empty: []int{}}
+	cases := []struct {
+		opts []phpserialize.Option
+		want string
+	}{
+		{want: `O:5:"lists":4:{s:4:"Ints";a:0:{}s:3:"Map";a:0:{}s:5:"Bytes";s:0:"";s:5:"Empty";a:0:{}}`},
+		{
+			opts: []phpserialize.Option{phpserialize.WithNilSliceAsNull()},
+			want: `O:5:"lists":4:{s:4:"Ints";N;s:3:"Map";N;s:5:"Bytes";N;s:5:"Empty";a:0:{}}`,
+		},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.MarshalWithOptions(v, tc.opts...)
+		if err != nil {
+			t.Fatalf("#%d: MarshalWithOptions(...) returns error: %v", i, err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("#%d: MarshalWithOptions(...) == %s\nwant: %s", i, got, tc.want)
+		}
+	}
+}
+
+func TestMarshalNumericStringKeys(t *testing.T) {
+	cases := []struct {
+		val  interface{}
+		opts []phpserialize.Option
+		want string
+	}{
+		{val: map[string]int{"5": 1, "05": 2}, want: `a:2:{s:2:"05";i:2;i:5;i:1;}`},
+		{val: map[string]int{"5": 1}, opts: []phpserialize.Option{phpserialize.WithVerbatimKeys()}, want: `a:1:{s:1:"5";i:1;}`},
+		{val: php.Array(php.Element(php.String("-3"), php.Null())), want: `a:1:{i:-3;N;}`},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.MarshalWithOptions(tc.val, tc.opts...)
+		if err != nil {
+			t.Fatalf("#%d: MarshalWithOptions(...) returns error: %v", i, err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("#%d: MarshalWithOptions(...) == %s, want: %s", i, got, tc.want)
+		}
+	}
+}
+
+func TestMarshalWithStrictKeys(t *testing.T) {
+	cases := []struct {
+		val        interface{}
+		wantsError bool
+	}{
+		{val: map[interface{}]int{"0": 1, 1: 2}},
+		{val: map[interface{}]int{"0": 1, 0: 2}, wantsError: true},
+		{val: map[interface{}]int{"5": 1, uint8(5): 2}, wantsError: true},
+		{val: map[interface{}]int{"05": 1, 5: 2}},
+		{val: php.NewOrderedMap().Set("7", 1).Set(7, 2), wantsError: true},
+	}
+	for i, tc := range cases {
+		_, err := phpserialize.MarshalWithOptions(tc.val, phpserialize.WithStrictKeys())
+		if tc.wantsError {
+			if _, ok := err.(*phpserialize.UnsupportedValueError); !ok {
+				t.Errorf("#%d: MarshalWithOptions(...) returns error %v, want: *UnsupportedValueError", i, err)
+			}
+		} else if err != nil {
+			t.Errorf("#%d: MarshalWithOptions(...) returns error: %v", i, err)
+		}
+		if _, err := phpserialize.Marshal(tc.val); err != nil {
+			t.Errorf("#%d: Marshal(...) returns error: %v", i, err)
+		}
+	}
+}
+
+type celsius float64
+
+func (c celsius) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%.1fC", float64(c))), nil
+}
+
+type upper string
+
+func (u *upper) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(string(*u))), nil
+}
+
+func TestMarshalTextMarshaler(t *testing.T) {
+	type reading struct {
+		Temp  celsius
+		Label upper
+		At    time.Time
+	}
+	opt := []phpserialize.Option{phpserialize.WithTextMarshalers()}
+	cases := []struct {
+		val  interface{}
+		opts []phpserialize.Option
+		want string
+	}{
+		{val: celsius(21.5), want: `d:21.5;`},
+		{val: celsius(21.5), opts: opt, want: `s:5:"21.5C";`},
+		{val: map[celsius]int{1: 1}, want: `a:1:{s:4:"1.0C";i:1;}`},
+		{
+			val:  &reading{Temp: 20, Label: "lab", At: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)},
+			opts: opt,
+			want: `O:7:"reading":3:{s:4:"Temp";s:5:"20.0C";s:5:"Label";s:3:"LAB";s:2:"At";s:20:"2021-01-02T03:04:05Z";}`,
+		},
+		{
+			val:  &reading{At: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)},
+			opts: []phpserialize.Option{phpserialize.WithTextMarshalers(), phpserialize.WithTimeEncoding(phpserialize.TimeUnix)},
+			want: `O:7:"reading":3:{s:4:"Temp";s:4:"0.0C";s:5:"Label";s:0:"";s:2:"At";i:1609556645;}`,
+		},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.MarshalWithOptions(tc.val, tc.opts...)
+		if err != nil {
+			t.Fatalf("#%d: Marshal(%v) returns error: %v", i, tc.val, err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("#%d: Marshal(%v) == %s\nwant: %s", i, tc.val, got, tc.want)
+		}
+	}
+}
+
+func TestMarshalWithJSONTags(t *testing.T) {
+	type account struct {
+		ID      int    `json:"id"`
+		Email   string `json:"email,omitempty"`
+		Secret  string `json:"-"`
+		Display string `json:"display_name" php:"displayName"`
+		Plain   bool
+	}
+	v := account{ID: 1, Secret: "x", Display: "Bob", Plain: true}
+	cases := []struct {
+		opts []phpserialize.Option
+		want string
+	}{
+		{want: `O:7:"account":5:{s:2:"ID";i:1;s:5:"Email";s:0:"";s:6:"Secret";s:1:"x";s:11:"displayName";s:3:"Bob";s:5:"Plain";b:1;}`},
+		{
+			opts: []phpserialize.Option{phpserialize.WithJSONTags()},
+			want: `O:7:"account":3:{s:2:"id";i:1;s:11:"displayName";s:3:"Bob";s:5:"Plain";b:1;}`,
+		},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.MarshalWithOptions(v, tc.opts...)
+		if err != nil {
+			t.Fatalf("#%d: MarshalWithOptions(...) returns error: %v", i, err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("#%d: MarshalWithOptions(...) == %s\nwant: %s", i, got, tc.want)
+		}
+	}
+}
+
+type money struct {
+	cents int64
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	phpserialize.RegisterEncoder(func(m money) (*php.Value, error) {
+		if m.cents < 0 {
+			return nil, fmt.Errorf("negative amount")
+		}
+		return php.String(fmt.Sprintf("%d.%02d", m.cents/100, m.cents%100)), nil
+	})
+	type order struct {
+		Total money
+		Tip   *money
+	}
+	got, err := phpserialize.Marshal(order{Total: money{1234}, Tip: &money{5}})
+	if err != nil {
+		t.Fatalf("Marshal(...) returns error: %v", err)
+	}
+	want := `O:5:"order":2:{s:5:"Total";s:5:"12.34";s:3:"Tip";s:4:"0.05";}`
+	if string(got) != want {
+		t.Errorf("Marshal(...) == %s\nwant: %s", got, want)
+	}
+
+	if _, err := phpserialize.Marshal(money{-1}); err == nil {
+		t.Errorf("Marshal(money{-1}) returns no error")
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	cases := []struct {
+		data string
+		want string
+	}{
+		{data: `d:1.50000;`, want: `d:1.5;`},
+		{data: `d:0.1000000000000000055511151231257827;`, want: `d:0.1;`},
+		{data: `i:+007;`, want: `i:7;`},
+		{data: `a:2:{s:1:"5";i:1;s:2:"05";i:2;}`, want: `a:2:{i:5;i:1;s:2:"05";i:2;}`},
+		{data: `a:2:{i:1;s:1:"a";s:1:"1";s:1:"b";}`, want: `a:1:{i:1;s:1:"b";}`},
+		{data: `O:3:"Foo":1:{s:2:"*a";b:0;}`, want: `O:3:"Foo":1:{s:4:"` + "\x00*\x00" + `a";b:0;}`},
+		{
+			data: `O:5:"Child":2:{s:9:"` + "\x00Parent\x00" + `x";i:1;s:8:"` + "\x00Child\x00" + `x";i:2;}`,
+			want: `O:5:"Child":2:{s:9:"` + "\x00Parent\x00" + `x";i:1;s:8:"` + "\x00Child\x00" + `x";i:2;}`,
+		},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.Canonicalize([]byte(tc.data))
+		if err != nil {
+			t.Errorf("#%d: Canonicalize(%q) returns error: %v", i, tc.data, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("#%d: Canonicalize(%q) == %q, want: %q", i, tc.data, got, tc.want)
+		}
+	}
+	if _, err := phpserialize.Canonicalize([]byte(`s:5:"abc";`)); err == nil {
+		t.Errorf("Canonicalize(invalid) returns no error")
+	}
+}
+
+func TestEncoderSetEscapedStrings(t *testing.T) {
+	v := map[string]string{"k\\": "a\x00é"}
+	want := `a:1:{S:2:"k\5c";S:4:"a\00\c3\a9";}`
+
+	var buf bytes.Buffer
+	enc := phpserialize.NewEncoder(&buf, phpserialize.WithEscapedStrings())
+	if err := enc.Encode(v); err != nil {
+		t.Fatalf("Encode(%q) returns error: %v", v, err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Encode(%q) == %s\nwant: %s", v, got, want)
+	}
+
+	var got map[string]string
+	if err := phpserialize.UnmarshalInto(buf.Bytes(), &got); err != nil {
+		t.Fatalf("UnmarshalInto(%s) returns error: %v", buf.Bytes(), err)
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Errorf("UnmarshalInto(%s) == %q, want: %q", buf.Bytes(), got, v)
+	}
+}
+
+func TestEncoderSetStructsAsArrays(t *testing.T) {
+	type Item struct {
+		SKU   string `php:"sku"`
+		Qty   int    `php:"qty,omitempty"`
+		ID    int    `php:"7"`
+		notes string
+	}
+	type Order struct {
+		Items []Item `php:"items"`
+	}
+	v := Order{Items: []Item{{SKU: "X", ID: 1, notes: "n"}}}
+	want := `a:1:{s:5:"items";a:1:{i:0;a:3:{s:3:"sku";s:1:"X";i:7;i:1;s:5:"notes";s:1:"n";}}}`
+
+	var buf bytes.Buffer
+	enc := phpserialize.NewEncoder(&buf, phpserialize.WithStructsAsArrays())
+	if err := enc.Encode(v); err != nil {
+		t.Fatalf("Encode(%+v) returns error: %v", v, err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Encode(%+v) == %s\nwant: %s", v, got, want)
+	}
+
+	var got Order
+	if err := phpserialize.UnmarshalInto(buf.Bytes(), &got); err != nil {
+		t.Fatalf("UnmarshalInto(%s) returns error: %v", buf.Bytes(), err)
+	}
+	if got.Items[0].SKU != "X" {
+		t.Errorf("UnmarshalInto(%s) == %+v", buf.Bytes(), got)
+	}
+}
+
+func TestMarshalNestedPHPValue(t *testing.T) {
+	type S struct {
+		V  *php.Value `php:"v"`
+		W  php.Value  `php:"w"`
+		I  interface{}
+		ps []*php.Value
+	}
+	cases := []struct {
+		val  interface{}
+		want string
+	}{
+		{
+			val:  map[string]interface{}{"a": php.Int(1), "b": []*php.Value{php.String("x"), nil}},
+			want: `a:2:{s:1:"a";i:1;s:1:"b";a:2:{i:0;s:1:"x";i:1;N;}}`,
+		},
+		{
+			val:  S{V: php.Bytes([]byte("y")), W: *php.Bool(true), I: php.Raw([]byte("i:7;"), nil)},
+			want: `O:1:"S":4:{s:1:"v";s:1:"y";s:1:"w";b:1;s:1:"I";i:7;s:5:"` + "\x00S\x00ps" + `";a:0:{}}`,
+		},
+		{
+			val:  map[string]php.Value{"k": *php.Object("Foo")},
+			want: `a:1:{s:1:"k";O:3:"Foo":0:{}}`,
+		},
+	}
+	for i, c := range cases {
+		got, err := phpserialize.Marshal(c.val)
+		if err != nil {
+			t.Errorf("#%d: Marshal(%#v) returns error: %v", i, c.val, err)
+			continue
+		}
+		if string(got) != c.want {
+			t.Errorf("#%d: Marshal(%#v) == %q, want: %q", i, c.val, got, c.want)
+		}
+	}
+
+	type U struct{ v *php.Value }
+	if _, err := phpserialize.Marshal(U{php.Int(1)}); err == nil {
+		t.Errorf("Marshal(unexported *php.Value) returns no error")
+	}
+}
+
+type valueMarshaler int
+
+func (m valueMarshaler) MarshalPHPSerialize() ([]byte, error) {
+	return []byte(fmt.Sprintf("s:1:\"%d\";", m)), nil
+}
+
+type pointerMarshaler int
+
+func (m *pointerMarshaler) MarshalPHPSerialize() ([]byte, error) {
+	return []byte(fmt.Sprintf("d:%d.5;", *m)), nil
+}
+
+func TestMarshalNestedMarshaler(t *testing.T) {
+	type S struct {
+		V valueMarshaler
+		P pointerMarshaler
+		N *pointerMarshaler
+	}
+	cases := []struct {
+		val  interface{}
+		want string
+	}{
+		{val: S{V: 1, P: 2}, want: `O:1:"S":3:{s:1:"V";s:1:"1";s:1:"P";d:2.5;s:1:"N";N;}`},
+		{val: &S{V: 1, P: 2}, want: `O:1:"S":3:{s:1:"V";s:1:"1";s:1:"P";d:2.5;s:1:"N";N;}`},
+		{val: map[string]pointerMarshaler{"a": 3}, want: `a:1:{s:1:"a";d:3.5;}`},
+		{val: map[string]interface{}{"a": valueMarshaler(4)}, want: `a:1:{s:1:"a";s:1:"4";}`},
+		{val: []pointerMarshaler{5}, want: `a:1:{i:0;d:5.5;}`},
+		{val: [1]pointerMarshaler{6}, want: `a:1:{i:0;d:6.5;}`},
+	}
+	for i, c := range cases {
+		got, err := phpserialize.Marshal(c.val)
+		if err != nil {
+			t.Errorf("#%d: Marshal(%v) returns error: %v", i, c.val, err)
+			continue
+		}
+		if string(got) != c.want {
+			t.Errorf("#%d: Marshal(%v) == %s, want: %s", i, c.val, got, c.want)
+		}
+	}
+}
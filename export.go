@@ -0,0 +1,70 @@
+package phpserialize
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// ExportOptions configures ExportNDJSON.
+type ExportOptions struct {
+	// LengthPrefixed reports whether every record is preceded by its length
+	// as a 4-byte big-endian unsigned integer. Otherwise records are read as
+	// concatenated PHP serialized values.
+	LengthPrefixed bool
+}
+
+// ExportNDJSON reads PHP serialized records from r and writes each of them to
+// w as a JSON document followed by a newline. Records are processed one at a
+// time, so memory usage is bounded by the largest record.
+//
+// Each document is the representation written by php.Value.MarshalJSON.
+func ExportNDJSON(r io.Reader, w io.Writer, opts ExportOptions) error {
+	bw := bufio.NewWriter(w)
+
+	next := NewDecoder(r).Decode
+	if opts.LengthPrefixed {
+		next = lengthPrefixedReader(bufio.NewReader(r))
+	}
+	for n := 0; ; n++ {
+		v, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("php serialize: record %d: %w", n, err)
+		}
+		bs, err := v.MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("php serialize: record %d: %w", n, err)
+		}
+		if _, err := bw.Write(append(bs, '\n')); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func lengthPrefixedReader(r io.Reader) func() (*php.Value, error) {
+	var buf bytes.Buffer
+	return func() (*php.Value, error) {
+		var hdr [4]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, err
+		}
+		// the buffer grows with the data read rather than with the length in
+		// the header, which may be forged
+		buf.Reset()
+		if _, err := io.CopyN(&buf, r, int64(binary.BigEndian.Uint32(hdr[:]))); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return Unmarshal(buf.Bytes())
+	}
+}
@@ -0,0 +1,61 @@
+package phpserialize_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+)
+
+func TestExportNDJSON(t *testing.T) {
+	records := []string{
+		`a:2:{i:0;i:1;i:1;d:NAN;}`,
+		`a:2:{s:1:"b";N;i:3;s:2:"x"";}`,
+		`O:3:"Foo":2:{s:1:"a";b:1;s:2:"*b";d:1.5;}`,
+	}
+	want := `[1,"NAN"]` + "\n" +
+		`{"b":null,"3":"x\""}` + "\n" +
+		`{"__class":"Foo","a":true,"b":1.5}` + "\n"
+
+	var out bytes.Buffer
+	if err := phpserialize.ExportNDJSON(strings.NewReader(strings.Join(records, "")), &out, phpserialize.ExportOptions{}); err != nil {
+		t.Fatalf("ExportNDJSON(...) returns error: %v", err)
+	}
+	if got := out.String(); got != want {
+		t.Errorf("ExportNDJSON(...) writes %s\nwant: %s", got, want)
+	}
+
+	var in bytes.Buffer
+	for _, r := range records {
+		binary.Write(&in, binary.BigEndian, uint32(len(r)))
+		in.WriteString(r)
+	}
+	out.Reset()
+	if err := phpserialize.ExportNDJSON(&in, &out, phpserialize.ExportOptions{LengthPrefixed: true}); err != nil {
+		t.Fatalf("ExportNDJSON(...) returns error: %v", err)
+	}
+	if got := out.String(); got != want {
+		t.Errorf("ExportNDJSON(...) writes %s\nwant: %s", got, want)
+	}
+}
+
+func TestExportNDJSONError(t *testing.T) {
+	var out bytes.Buffer
+	err := phpserialize.ExportNDJSON(strings.NewReader(`i:1;i:x;`), &out, phpserialize.ExportOptions{})
+	if err == nil {
+		t.Error("ExportNDJSON(...) wants error but no error occurred")
+	}
+}
+
+func TestExportNDJSONForgedLength(t *testing.T) {
+	var out bytes.Buffer
+	in := "\xff\xff\xff\xffi:1;"
+	err := phpserialize.ExportNDJSON(strings.NewReader(in), &out, phpserialize.ExportOptions{LengthPrefixed: true})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("ExportNDJSON(...) returns error: %v, want: %v", err, io.ErrUnexpectedEOF)
+	}
+}
@@ -0,0 +1,231 @@
+package phpserialize
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// field describes how a Go struct field is serialized as a PHP property.
+type field struct {
+	name    string // property name before mangling
+	index   []int  // index sequence for reflect.Value.FieldByIndex
+	private bool   // serialized as a private property
+	owner   []int  // index of the embedded struct declaring the field, nil for the outermost struct
+
+	tagged    bool // name was given by the php struct tag
+	omitEmpty bool
+
+	visibility    php.Visibility
+	hasVisibility bool // visibility was given by the php struct tag
+	json          bool // holds JSON text to transcode
+}
+
+// parseTag splits a php struct tag into its name and comma-separated options.
+func parseTag(tag string) (name string, opts []string) {
+	ls := strings.Split(tag, ",")
+	return ls[0], ls[1:]
+}
+
+var visibilityNames = map[php.Visibility]string{
+	php.VisibilityPublic:    "public",
+	php.VisibilityProtected: "protected",
+	php.VisibilityPrivate:   "private",
+}
+
+// visibilityKey returns the key of the property name with visibility vis
+// among field names.
+func visibilityKey(name string, vis php.Visibility) string {
+	return name + "\x00" + strconv.Itoa(int(vis))
+}
+
+func hasOption(opts []string, opt string) bool {
+	for _, o := range opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// typeFields returns the fields of the struct type t in serialization order.
+//
+// The php struct tag sets the property name, "-" excludes the field and the
+// "omitempty" option skips the field when it has an empty value:
+//
+//	Field int `php:"field,omitempty"`
+//
+// The "public", "protected" and "private" options give the visibility of the
+// property: the field is encoded with that visibility, private properties
+// being declared by the struct's class, and only decoded from properties
+// with that visibility. Fields without one match properties of any
+// visibility.
+//
+// The "json" option makes a string or []byte field holding JSON text be
+// encoded as the PHP value the text stands for, and decoded back to JSON.
+//
+// With the jsonTags option, the json struct tag is used the same way for
+// fields without a php tag.
+//
+// Fields of embedded structs without a tag name are promoted into the list
+// when the promoteEmbedded option is set; as in encoding/json, the
+// shallowest of several fields with the same name wins, then a tagged one,
+// and otherwise fields with the same name at the same depth are all dropped.
+func typeFields(t reflect.Type, o *options) []field {
+	type queued struct {
+		t     reflect.Type
+		index []int
+	}
+	var (
+		fields  []field
+		depths  = map[string]int{} // field key -> depth of the field in fields
+		pos     = map[string]int{} // field key -> position in fields, -1 if dropped
+		visited = map[reflect.Type]bool{}
+	)
+	add := func(f field, key string, depth int) {
+		if d, ok := depths[key]; ok {
+			if d == depth && pos[key] >= 0 {
+				switch old := &fields[pos[key]]; {
+				case old.tagged && !f.tagged:
+				case f.tagged && !old.tagged:
+					*old = f
+				default:
+					old.index = nil
+					pos[key] = -1
+				}
+			}
+			return
+		}
+		depths[key] = depth
+		pos[key] = len(fields)
+		fields = append(fields, f)
+	}
+	current := []queued{{t: t}}
+	for depth := 0; len(current) > 0; depth++ {
+		var next []queued
+		count := map[reflect.Type]int{}
+		for _, q := range current {
+			count[q.t]++
+		}
+		for _, q := range current {
+			// a struct embedding itself, directly or not, is walked once
+			if visited[q.t] {
+				continue
+			}
+			visited[q.t] = true
+			for i := 0; i < q.t.NumField(); i++ {
+				sf := q.t.Field(i)
+				tag, ok := sf.Tag.Lookup("php")
+				if !ok && o.jsonTags {
+					tag = sf.Tag.Get("json")
+				}
+				if tag == "-" {
+					continue
+				}
+				name, tagOpts := parseTag(tag)
+				index := append(append([]int(nil), q.index...), i)
+
+				ft := sf.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous && name == "" && o.promoteEmbedded && ft.Kind() == reflect.Struct {
+					next = append(next, queued{t: ft, index: index})
+					continue
+				}
+
+				f := field{
+					name:      name,
+					index:     index,
+					private:   sf.PkgPath != "",
+					owner:     q.index,
+					tagged:    name != "",
+					omitEmpty: hasOption(tagOpts, "omitempty"),
+					json:      hasOption(tagOpts, "json") && isJSONKind(sf.Type),
+				}
+				for _, v := range []php.Visibility{php.VisibilityPublic, php.VisibilityProtected, php.VisibilityPrivate} {
+					if hasOption(tagOpts, visibilityNames[v]) {
+						f.visibility, f.hasVisibility = v, true
+					}
+				}
+				if !f.tagged {
+					f.name = sf.Name
+					if o.fieldNameMapper != nil {
+						f.name = o.fieldNameMapper(f.name)
+					}
+				}
+
+				key := f.name
+				if f.private {
+					key = q.t.String() + "\x00" + key
+				}
+				if f.hasVisibility {
+					key = visibilityKey(key, f.visibility)
+				}
+				add(f, key, depth)
+				if count[q.t] > 1 {
+					// the type is embedded more than once at this depth,
+					// so its fields conflict with themselves
+					add(f, key, depth)
+				}
+			}
+		}
+		current = next
+	}
+
+	out := fields[:0]
+	for _, f := range fields {
+		if f.index != nil {
+			out = append(out, f)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].index, out[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return out
+}
+
+// fieldByIndex returns the nested field of v at index. ok is false if an
+// embedded struct pointer on the way is nil.
+func fieldByIndex(v reflect.Value, index []int) (fv reflect.Value, ok bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmptyValue reports whether v is empty in the sense of the omitempty
+// option: false, 0, a nil pointer or interface, or a zero-length array,
+// map, slice or string.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package phpserialize_test
+
+import (
+	"bytes"
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+type fuzzTarget struct {
+	Name  string            `php:"name"`
+	Items []int             `php:"items"`
+	Attrs map[string]string `php:"attrs"`
+	Next  *fuzzTarget       `php:"next,private"`
+	Any   interface{}       `php:"any"`
+}
+
+// FuzzUnmarshal checks that no input makes the decoding functions panic.
+func FuzzUnmarshal(f *testing.F) {
+	for _, s := range []string{
+		`a:2:{i:0;s:1:"a";s:1:"b";O:3:"Foo":1:{s:1:"x";d:1.5;}}`,
+		`C:11:"ArrayObject":21:{x:i:0;a:0:{};m:a:0:{}}`,
+		`O:8:"stdClass":1:{s:1:"a";r:1;}`,
+		`S:3:"\61bc";`, `E:7:"Foo:Bar";`, `b:1;`, `N;`, `i:-5;`, `d:INF;`,
+		`O:0:"":0:{}`, `O:0:"":1:{s:0:"";N;}`, `O:1:"A":1:{s:1:"\x00";N;}`, `a:-1:{}`, `s:-1:"";`, `a:1:{i:0;R:1;}`,
+		`s:9223372036854775806:"abc";`, `a:2:{i:0;s:1:"a";i:1;s:9223372036854775807:"abc";}`,
+	} {
+		f.Add([]byte(s))
+	}
+	optSets := [][]phpserialize.Option{
+		nil,
+		{phpserialize.WithLenient(), phpserialize.WithLexemes()},
+		{phpserialize.WithLazy()},
+		{phpserialize.WithArena(), phpserialize.WithInterning()},
+		{phpserialize.WithStringBytes(), phpserialize.WithValidUTF8()},
+		{phpserialize.WithObjectsAsArrays(), phpserialize.WithClassKey("__class")},
+		{phpserialize.WithSPLAsArrays(), phpserialize.WithStdClassAsArray()},
+		{phpserialize.WithAllowedClasses(), phpserialize.WithStrictKeys(), phpserialize.WithStrictInts()},
+		{phpserialize.WithAllErrors(), phpserialize.WithStrictKeys()},
+		{phpserialize.WithMaxDepth(3), phpserialize.WithMaxElements(10)},
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, opts := range optSets {
+			if v, err := phpserialize.UnmarshalWithOptions(data, opts...); err == nil {
+				phpserialize.Marshal(v)
+				v.Serialize()
+				var x interface{}
+				phpserialize.UnmarshalValue(v, &x)
+				php.Walk(v, func(php.Path, *php.Value) error { return nil })
+			}
+		}
+		phpserialize.UnmarshalRecover(data)
+		dec := phpserialize.NewDecoder(bytes.NewReader(data), phpserialize.WithLenient())
+		for i := 0; i < 10; i++ {
+			if _, err := dec.Decode(); err != nil {
+				break
+			}
+		}
+		var x interface{}
+		phpserialize.UnmarshalInto(data, &x)
+		var s fuzzTarget
+		phpserialize.UnmarshalInto(data, &s)
+		php.Parse(data)
+		phpserialize.Repair(data)
+	})
+}
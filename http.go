@@ -0,0 +1,93 @@
+package phpserialize
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// ContentType is the media type of PHP serialized HTTP bodies.
+const ContentType = "application/vnd.php.serialized"
+
+// DefaultMaxBodyBytes is the size limit of the bodies read by DecodeRequest
+// and DecodeResponse unless WithMaxInputBytes sets another.
+const DefaultMaxBodyBytes = 10 << 20
+
+// An HTTPError is returned by DecodeRequest with the status code to respond
+// with, such as http.StatusRequestEntityTooLarge for a body over the size
+// limit.
+type HTTPError struct {
+	Status int
+	Err    error
+}
+
+func (e *HTTPError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// EncodeResponse writes v as the PHP serialized body of a response with the
+// status code and ContentType. v is encoded before anything is written, so
+// that an encoding error can still be answered with an error response.
+func EncodeResponse(w http.ResponseWriter, status int, v interface{}, opts ...Option) error {
+	data, err := marshal(v, newOptions(opts))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	_, err = writeAll(w, data)
+	return err
+}
+
+// DecodeRequest decodes the PHP serialized body of r into the value pointed
+// to by v, following the rules of UnmarshalInto. It fails with an *HTTPError
+// of status http.StatusUnsupportedMediaType if r has a Content-Type other
+// than ContentType, http.StatusRequestEntityTooLarge if the body exceeds the
+// size limit, and http.StatusBadRequest if it cannot be decoded into v.
+func DecodeRequest(r *http.Request, v interface{}, opts ...Option) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != ContentType {
+			return &HTTPError{http.StatusUnsupportedMediaType, fmt.Errorf("php serialize: unsupported content type %q", ct)}
+		}
+	}
+	return decodeBody(r.Body, v, newOptions(opts))
+}
+
+// DecodeResponse decodes the PHP serialized body of resp into the value
+// pointed to by v like DecodeRequest, without checking its Content-Type,
+// for clients of services responding with PHP serialized data. It does not
+// close the body.
+func DecodeResponse(resp *http.Response, v interface{}, opts ...Option) error {
+	return decodeBody(resp.Body, v, newOptions(opts))
+}
+
+func decodeBody(body io.Reader, v interface{}, opts options) error {
+	max := opts.maxInputBytes
+	if max <= 0 {
+		max = DefaultMaxBodyBytes
+	}
+	data, err := io.ReadAll(io.LimitReader(body, max+1))
+	var mbe *http.MaxBytesError
+	switch {
+	case errors.As(err, &mbe) || int64(len(data)) > max:
+		return &HTTPError{http.StatusRequestEntityTooLarge, tooLargeError("body exceeds limit of %d bytes", max)}
+	case err != nil:
+		return &HTTPError{http.StatusBadRequest, fmt.Errorf("php serialize: reading body: %w", err)}
+	}
+	err = unmarshalData(data, v, opts)
+	var iue *InvalidUnmarshalError
+	switch {
+	case err == nil || errors.As(err, &iue):
+		// an invalid v is a programming error rather than a bad request
+		return err
+	case errors.Is(err, ErrTooLarge):
+		return &HTTPError{http.StatusRequestEntityTooLarge, err}
+	}
+	return &HTTPError{http.StatusBadRequest, err}
+}
@@ -0,0 +1,83 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+)
+
+func TestEncodeResponse(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := phpserialize.EncodeResponse(rec, http.StatusCreated, map[string]int{"id": 7}); err != nil {
+		t.Fatalf("EncodeResponse returns error: %v", err)
+	}
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status == %d, want: %d", rec.Code, http.StatusCreated)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != phpserialize.ContentType {
+		t.Errorf("Content-Type == %q, want: %q", ct, phpserialize.ContentType)
+	}
+	if body, want := rec.Body.String(), `a:1:{s:2:"id";i:7;}`; body != want {
+		t.Errorf("body == %s, want: %s", body, want)
+	}
+
+	var m map[string]int
+	if err := phpserialize.DecodeResponse(rec.Result(), &m); err != nil || m["id"] != 7 {
+		t.Errorf("DecodeResponse gives %v, %v, want: map[id:7], nil", m, err)
+	}
+
+	rec = httptest.NewRecorder()
+	if err := phpserialize.EncodeResponse(rec, http.StatusOK, make(chan int)); err == nil {
+		t.Errorf("EncodeResponse of a channel wants error but no error occurred")
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("EncodeResponse of a channel writes %q", rec.Body)
+	}
+}
+
+func TestDecodeRequest(t *testing.T) {
+	cases := []struct {
+		contentType string
+		body        string
+		opts        []phpserialize.Option
+		status      int
+	}{
+		{contentType: phpserialize.ContentType, body: `a:1:{s:4:"name";s:3:"bob";}`},
+		{contentType: "", body: `a:1:{s:4:"name";s:3:"bob";}`},
+		{contentType: phpserialize.ContentType + "; charset=utf-8", body: `a:0:{}`},
+		{contentType: "application/json", body: `{}`, status: http.StatusUnsupportedMediaType},
+		{contentType: phpserialize.ContentType, body: `a:1:{`, status: http.StatusBadRequest},
+		{contentType: phpserialize.ContentType, body: `i:1;`, status: http.StatusBadRequest},
+		{
+			contentType: phpserialize.ContentType,
+			body:        `a:1:{s:4:"name";s:3:"bob";}`,
+			opts:        []phpserialize.Option{phpserialize.WithMaxInputBytes(10)},
+			status:      http.StatusRequestEntityTooLarge,
+		},
+	}
+	for i, tc := range cases {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			r.Header.Set("Content-Type", tc.contentType)
+		}
+		var v map[string]string
+		err := phpserialize.DecodeRequest(r, &v, tc.opts...)
+		if tc.status == 0 {
+			if err != nil {
+				t.Errorf("#%d: DecodeRequest returns error: %v", i, err)
+			}
+			continue
+		}
+		var he *phpserialize.HTTPError
+		if !errors.As(err, &he) || he.Status != tc.status {
+			t.Errorf("#%d: DecodeRequest returns error %v, want status %d", i, err, tc.status)
+		}
+		if tc.status == http.StatusRequestEntityTooLarge && !errors.Is(err, phpserialize.ErrTooLarge) {
+			t.Errorf("#%d: DecodeRequest returns error %v, want: %v", i, err, phpserialize.ErrTooLarge)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package igbinary
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// maxPrealloc caps the capacity allocated up front for arrays and objects,
+// so that a forged count cannot allocate excessive memory.
+const maxPrealloc = 1024
+
+// defaultMaxDepth is the nesting depth Unmarshal accepts unless WithMaxDepth
+// sets another limit. The decoder recurses for every level, so the depth is
+// always limited.
+const defaultMaxDepth = 10000
+
+// An Option configures how Unmarshal decodes data.
+type Option func(*options)
+
+type options struct {
+	maxDepth int
+}
+
+// WithMaxDepth limits the nesting depth of arrays, objects and references in
+// the decoded value to n. A value of 0 or less keeps the default limit of
+// 10000 levels.
+func WithMaxDepth(n int) Option {
+	return func(o *options) {
+		o.maxDepth = n
+	}
+}
+
+// Unmarshal parses the igbinary encoded data, written in format version 1 or
+// 2, and returns the value. PHP references are resolved to the values they
+// refer to. Objects serialized by the Serializable interface are not
+// supported. Data nested deeper than the limit of WithMaxDepth is rejected.
+func Unmarshal(data []byte, opts ...Option) (v *php.Value, err error) {
+	if len(data) < 4 {
+		return nil, errors.New("igbinary: data too short")
+	}
+	if ver := binary.BigEndian.Uint32(data); ver != 1 && ver != 2 {
+		return nil, fmt.Errorf("igbinary: unsupported format version %#x", ver)
+	}
+	d := &decodeState{data: data, off: 4, maxDepth: defaultMaxDepth}
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.maxDepth > 0 {
+		d.maxDepth = o.maxDepth
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if de, ok := r.(decodeError); ok {
+				v, err = nil, de.error
+			} else {
+				panic(r)
+			}
+		}
+	}()
+	v = d.readValue()
+	if d.off != len(d.data) {
+		d.error("unexpected data after value, position: %d", d.off)
+	}
+	return v, nil
+}
+
+type decodeError struct{ error }
+
+type decodeState struct {
+	data     []byte
+	off      int
+	depth    int
+	maxDepth int
+	strings  []string     // strings read so far, by id
+	refs     []*php.Value // arrays and objects read so far, by id
+}
+
+func (d *decodeState) error(format string, args ...interface{}) {
+	panic(decodeError{fmt.Errorf("igbinary: "+format, args...)})
+}
+
+func (d *decodeState) next(n int) []byte {
+	if n < 0 || len(d.data)-d.off < n {
+		d.error("unexpected end of data, position: %d", d.off)
+	}
+	bs := d.data[d.off : d.off+n]
+	d.off += n
+	return bs
+}
+
+// enter records the start of a nested array, object or reference, checking
+// the depth limit.
+func (d *decodeState) enter() {
+	d.depth++
+	if d.depth > d.maxDepth {
+		d.error("exceeded max depth of %d, position: %d", d.maxDepth, d.off)
+	}
+}
+
+func (d *decodeState) leave() {
+	d.depth--
+}
+
+func (d *decodeState) readByte() byte {
+	return d.next(1)[0]
+}
+
+// readSized reads the 8, 16, 32 or 64 bit unsigned integer following a token
+// of the given size.
+func (d *decodeState) readSized(size int) uint64 {
+	switch size {
+	case 8:
+		return uint64(d.readByte())
+	case 16:
+		return uint64(binary.BigEndian.Uint16(d.next(2)))
+	case 32:
+		return uint64(binary.BigEndian.Uint32(d.next(4)))
+	default:
+		return binary.BigEndian.Uint64(d.next(8))
+	}
+}
+
+// readLen reads a length or count following a token of the given size,
+// checking that the rest of the data holds at least that many bytes.
+func (d *decodeState) readLen(size int) int {
+	n := d.readSized(size)
+	if n > uint64(len(d.data)-d.off) {
+		d.error("length %d exceeds data, position: %d", n, d.off)
+	}
+	return int(n)
+}
+
+func (d *decodeState) readValue() *php.Value {
+	start := d.off
+	switch t := d.readByte(); t {
+	case typeNull:
+		return php.Null()
+	case typeFalse:
+		return php.Bool(false)
+	case typeTrue:
+		return php.Bool(true)
+	case typeLong8p, typeLong16p, typeLong32p, typeLong64p,
+		typeLong8n, typeLong16n, typeLong32n, typeLong64n:
+		return php.Int(int(d.readLong(t)))
+	case typeDouble:
+		return php.Float(math.Float64frombits(binary.BigEndian.Uint64(d.next(8))))
+	case typeStringEmpty, typeStringID8, typeStringID16, typeStringID32,
+		typeString8, typeString16, typeString32:
+		return php.String(d.readString(t))
+	case typeArray8, typeArray16, typeArray32:
+		d.off = start
+		return d.readArray()
+	case typeObject8, typeObject16, typeObject32,
+		typeObjectID8, typeObjectID16, typeObjectID32:
+		return d.readObject(t)
+	case typeRef:
+		d.enter()
+		defer d.leave()
+		if d.off < len(d.data) && typeArray8 <= d.data[d.off] && d.data[d.off] <= typeObjectID32 {
+			// arrays and objects are numbered by themselves
+			return d.readValue()
+		}
+		id := len(d.refs)
+		d.refs = append(d.refs, nil)
+		v := d.readValue()
+		d.refs[id] = v
+		return v
+	case typeRef8, typeObjRef8:
+		return d.ref(d.readSized(8))
+	case typeRef16, typeObjRef16:
+		return d.ref(d.readSized(16))
+	case typeRef32, typeObjRef32:
+		return d.ref(d.readSized(32))
+	default:
+		d.error("unsupported type %#x, position: %d", t, start)
+		return nil
+	}
+}
+
+func (d *decodeState) ref(id uint64) *php.Value {
+	if id >= uint64(len(d.refs)) || d.refs[id] == nil {
+		d.error("invalid reference %d, position: %d", id, d.off)
+	}
+	return d.refs[id]
+}
+
+func (d *decodeState) readLong(t byte) int64 {
+	var u uint64
+	switch t {
+	case typeLong8p, typeLong8n:
+		u = d.readSized(8)
+	case typeLong16p, typeLong16n:
+		u = d.readSized(16)
+	case typeLong32p, typeLong32n:
+		u = d.readSized(32)
+	default:
+		u = d.readSized(64)
+	}
+	switch t {
+	case typeLong8p, typeLong16p, typeLong32p, typeLong64p:
+		if u > math.MaxInt64 {
+			d.error("integer %d overflows int64, position: %d", u, d.off)
+		}
+		return int64(u)
+	default:
+		if u > 1<<63 {
+			d.error("integer -%d overflows int64, position: %d", u, d.off)
+		}
+		return -int64(u-1) - 1
+	}
+}
+
+func (d *decodeState) readString(t byte) string {
+	var s string
+	switch t {
+	case typeStringEmpty:
+		return ""
+	case typeStringID8:
+		return d.stringID(d.readSized(8))
+	case typeStringID16:
+		return d.stringID(d.readSized(16))
+	case typeStringID32:
+		return d.stringID(d.readSized(32))
+	case typeString8:
+		s = string(d.next(d.readLen(8)))
+	case typeString16:
+		s = string(d.next(d.readLen(16)))
+	case typeString32:
+		s = string(d.next(d.readLen(32)))
+	default:
+		d.error("expected string, found type %#x, position: %d", t, d.off-1)
+	}
+	d.strings = append(d.strings, s)
+	return s
+}
+
+func (d *decodeState) stringID(id uint64) string {
+	if id >= uint64(len(d.strings)) {
+		d.error("invalid string id %d, position: %d", id, d.off)
+	}
+	return d.strings[id]
+}
+
+// readCount reads the member count of an array.
+func (d *decodeState) readCount() int {
+	var n int
+	switch t := d.readByte(); t {
+	case typeArray8:
+		n = d.readLen(8)
+	case typeArray16:
+		n = d.readLen(16)
+	case typeArray32:
+		n = d.readLen(32)
+	case typeObjectSer8, typeObjectSer16, typeObjectSer32:
+		d.error("objects serialized by Serializable are not supported, position: %d", d.off-1)
+	default:
+		d.error("expected array, found type %#x, position: %d", t, d.off-1)
+	}
+	return n
+}
+
+func preallocSize(n int) int {
+	if n > maxPrealloc {
+		return maxPrealloc
+	}
+	return n
+}
+
+func (d *decodeState) readArray() *php.Value {
+	n := d.readCount()
+	d.enter()
+	defer d.leave()
+	id := len(d.refs)
+	d.refs = append(d.refs, nil)
+	es := make([]*php.ArrayElement, 0, preallocSize(n))
+	for i := 0; i < n; i++ {
+		var key *php.Value
+		switch kt := d.readByte(); kt {
+		case typeLong8p, typeLong16p, typeLong32p, typeLong64p,
+			typeLong8n, typeLong16n, typeLong32n, typeLong64n:
+			key = php.Int(int(d.readLong(kt)))
+		default:
+			key = php.String(d.readString(kt))
+		}
+		es = append(es, php.Element(key, d.readValue()))
+	}
+	v := php.Array(es...)
+	d.refs[id] = v
+	return v
+}
+
+func (d *decodeState) readObject(t byte) *php.Value {
+	var name string
+	switch t {
+	case typeObject8:
+		name = string(d.next(d.readLen(8)))
+	case typeObject16:
+		name = string(d.next(d.readLen(16)))
+	case typeObject32:
+		name = string(d.next(d.readLen(32)))
+	case typeObjectID8:
+		name = d.stringID(d.readSized(8))
+	case typeObjectID16:
+		name = d.stringID(d.readSized(16))
+	case typeObjectID32:
+		name = d.stringID(d.readSized(32))
+	}
+	if t == typeObject8 || t == typeObject16 || t == typeObject32 {
+		d.strings = append(d.strings, name)
+	}
+	n := d.readCount()
+	d.enter()
+	defer d.leave()
+	id := len(d.refs)
+	d.refs = append(d.refs, nil)
+	fields := make([]*php.ObjField, 0, preallocSize(n))
+	for i := 0; i < n; i++ {
+		mangled := d.readString(d.readByte())
+		fname, vis, class := php.DemangleName(mangled)
+		if vis == php.VisibilityPublic && strings.HasPrefix(mangled, "\x00") {
+			d.error("invalid field name: %q", mangled)
+		}
+		f := php.Field(fname, d.readValue(), vis)
+		if class != name {
+			f.Class = class
+		}
+		fields = append(fields, f)
+	}
+	v := php.Object(name, fields...)
+	d.refs[id] = v
+	return v
+}
@@ -0,0 +1,190 @@
+// Package igbinary encodes and decodes php.Values in the binary format of
+// PHP's igbinary extension, which is often used in place of serialize for
+// sessions and caches.
+package igbinary
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// format version written by Marshal
+const version = 2
+
+// type tokens
+const (
+	typeNull        = 0x00
+	typeRef8        = 0x01
+	typeRef16       = 0x02
+	typeRef32       = 0x03
+	typeFalse       = 0x04
+	typeTrue        = 0x05
+	typeLong8p      = 0x06
+	typeLong8n      = 0x07
+	typeLong16p     = 0x08
+	typeLong16n     = 0x09
+	typeLong32p     = 0x0a
+	typeLong32n     = 0x0b
+	typeDouble      = 0x0c
+	typeStringEmpty = 0x0d
+	typeStringID8   = 0x0e
+	typeStringID16  = 0x0f
+	typeStringID32  = 0x10
+	typeString8     = 0x11
+	typeString16    = 0x12
+	typeString32    = 0x13
+	typeArray8      = 0x14
+	typeArray16     = 0x15
+	typeArray32     = 0x16
+	typeObject8     = 0x17
+	typeObject16    = 0x18
+	typeObject32    = 0x19
+	typeObjectID8   = 0x1a
+	typeObjectID16  = 0x1b
+	typeObjectID32  = 0x1c
+	typeObjectSer8  = 0x1d
+	typeObjectSer16 = 0x1e
+	typeObjectSer32 = 0x1f
+	typeLong64p     = 0x20
+	typeLong64n     = 0x21
+	typeObjRef8     = 0x22
+	typeObjRef16    = 0x23
+	typeObjRef32    = 0x24
+	typeRef         = 0x25
+)
+
+// Marshal returns the igbinary encoding of v. Like the igbinary extension
+// with its default settings, repeated strings and class names are written
+// once and referred to by their index afterwards.
+func Marshal(v *php.Value) (bs []byte, err error) {
+	e := &encodeState{strings: map[string]int{}}
+	defer func() {
+		if r := recover(); r != nil {
+			if ee, ok := r.(encodeError); ok {
+				err = ee.error
+			} else {
+				panic(r)
+			}
+		}
+	}()
+	e.buf = binary.BigEndian.AppendUint32(e.buf, version)
+	e.writeValue(v)
+	return e.buf, nil
+}
+
+type encodeError struct{ error }
+
+type encodeState struct {
+	buf     []byte
+	strings map[string]int // ids of the strings written so far
+}
+
+// writeSized writes the token of the 8, 16 or 32 bit variant of a type
+// followed by n in as many bits.
+func (e *encodeState) writeSized(t8, t16, t32 byte, n uint64) {
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, t8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, t16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, t32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		panic(encodeError{fmt.Errorf("igbinary: length %d too large", n)})
+	}
+}
+
+func (e *encodeState) writeValue(v *php.Value) {
+	if v.IsNil() {
+		e.buf = append(e.buf, typeNull)
+		return
+	}
+	switch v.Type() {
+	case php.TypeBool:
+		if v.Bool() {
+			e.buf = append(e.buf, typeTrue)
+		} else {
+			e.buf = append(e.buf, typeFalse)
+		}
+	case php.TypeInt:
+		e.writeLong(v.Int())
+	case php.TypeFloat:
+		e.buf = append(e.buf, typeDouble)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	case php.TypeString:
+		e.writeString(v.String())
+	case php.TypeArray:
+		arr := v.Array()
+		e.writeSized(typeArray8, typeArray16, typeArray32, uint64(len(arr)))
+		for _, el := range arr {
+			if el.Index.Type() == php.TypeInt {
+				e.writeLong(el.Index.Int())
+			} else {
+				e.writeString(el.Index.String())
+			}
+			e.writeValue(el.Value)
+		}
+	case php.TypeObject:
+		e.writeObject(v.Object())
+	default:
+		panic(encodeError{fmt.Errorf("igbinary: invalid value type: %v", v.Type())})
+	}
+}
+
+func (e *encodeState) writeLong(i int64) {
+	if i >= 0 {
+		e.writeMagnitude(typeLong8p, typeLong16p, typeLong32p, typeLong64p, uint64(i))
+	} else {
+		e.writeMagnitude(typeLong8n, typeLong16n, typeLong32n, typeLong64n, uint64(-(i+1))+1)
+	}
+}
+
+func (e *encodeState) writeMagnitude(t8, t16, t32, t64 byte, u uint64) {
+	if u > math.MaxUint32 {
+		e.buf = append(e.buf, t64)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, u)
+		return
+	}
+	e.writeSized(t8, t16, t32, u)
+}
+
+func (e *encodeState) writeString(s string) {
+	if s == "" {
+		e.buf = append(e.buf, typeStringEmpty)
+		return
+	}
+	if id, ok := e.strings[s]; ok {
+		e.writeSized(typeStringID8, typeStringID16, typeStringID32, uint64(id))
+		return
+	}
+	e.strings[s] = len(e.strings)
+	e.writeSized(typeString8, typeString16, typeString32, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encodeState) writeObject(obj *php.Obj) {
+	if obj.Serialized != nil {
+		panic(encodeError{fmt.Errorf("igbinary: objects serialized by Serializable are not supported")})
+	}
+	name, fields := obj.Name, obj.Fields
+	if n, ok := obj.IncompleteClassName(); ok {
+		name, fields = n, fields[1:]
+	}
+	if id, ok := e.strings[name]; ok {
+		e.writeSized(typeObjectID8, typeObjectID16, typeObjectID32, uint64(id))
+	} else {
+		e.strings[name] = len(e.strings)
+		e.writeSized(typeObject8, typeObject16, typeObject32, uint64(len(name)))
+		e.buf = append(e.buf, name...)
+	}
+	e.writeSized(typeArray8, typeArray16, typeArray32, uint64(len(fields)))
+	for _, f := range fields {
+		e.writeString(f.MangledName(name))
+		e.writeValue(f.Value)
+	}
+}
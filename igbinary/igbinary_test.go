@@ -0,0 +1,131 @@
+package igbinary_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/igbinary"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+const header = "\x00\x00\x00\x02"
+
+func TestMarshal(t *testing.T) {
+	cases := []struct {
+		v    *php.Value
+		want string
+	}{
+		{v: php.Null(), want: "\x00"},
+		{v: php.Bool(true), want: "\x05"},
+		{v: php.Int(0), want: "\x06\x00"},
+		{v: php.Int(-1), want: "\x07\x01"},
+		{v: php.Int(-300), want: "\x09\x01\x2c"},
+		{v: php.Int(1 << 40), want: "\x20\x00\x00\x01\x00\x00\x00\x00\x00"},
+		{v: php.Float(1.5), want: "\x0c\x3f\xf8\x00\x00\x00\x00\x00\x00"},
+		{v: php.String(""), want: "\x0d"},
+		{v: php.String("test"), want: "\x11\x04test"},
+		{
+			v:    php.Assoc(map[string]*php.Value{"a": php.Int(1), "b": php.List(php.Bool(true))}),
+			want: "\x14\x02\x11\x01a\x06\x01\x11\x01b\x14\x01\x06\x00\x05",
+		},
+		{v: php.List(php.String("x"), php.String("x")), want: "\x14\x02\x06\x00\x11\x01x\x06\x01\x0e\x00"},
+		{
+			v: php.List(
+				php.Object("Foo", php.PubField("a", php.Int(1)), php.ProtectedField("b", php.Int(2))),
+				php.Object("Foo"),
+			),
+			want: "\x14\x02\x06\x00\x17\x03Foo\x14\x02\x11\x01a\x06\x01\x11\x04\x00*\x00b\x06\x02" +
+				"\x06\x01\x1a\x00\x14\x00",
+		},
+		{
+			v: php.Object("B",
+				&php.ObjField{Name: "x", Visibility: php.VisibilityPrivate, Value: php.Int(1), Class: "A"},
+				php.PrivField("x", php.Int(2)),
+			),
+			want: "\x17\x01B\x14\x02\x11\x04\x00A\x00x\x06\x01\x11\x04\x00B\x00x\x06\x02",
+		},
+	}
+	for i, tc := range cases {
+		got, err := igbinary.Marshal(tc.v)
+		if err != nil {
+			t.Errorf("#%d: Marshal(...) returns error: %v", i, err)
+			continue
+		}
+		if string(got) != header+tc.want {
+			t.Errorf("#%d: Marshal(...) == %q, want: %q", i, got, header+tc.want)
+		}
+		v, err := igbinary.Unmarshal(got)
+		if err != nil {
+			t.Errorf("#%d: Unmarshal(%q) returns error: %v", i, got, err)
+			continue
+		}
+		if !php.Equal(v, tc.v) {
+			t.Errorf("#%d: Unmarshal(%q) == %#v, want: %#v", i, got, v, tc.v)
+		}
+	}
+}
+
+func TestUnmarshalRefs(t *testing.T) {
+	obj := php.Object("Foo")
+	cases := []struct {
+		data string
+		want *php.Value
+	}{
+		{data: "\x14\x02\x06\x00\x17\x03Foo\x14\x00\x06\x01\x22\x01", want: php.List(obj, obj)},
+		{data: "\x14\x02\x06\x00\x25\x06\x07\x06\x01\x01\x01", want: php.List(php.Int(7), php.Int(7))},
+	}
+	for i, tc := range cases {
+		v, err := igbinary.Unmarshal([]byte(header + tc.data))
+		if err != nil {
+			t.Errorf("#%d: Unmarshal(%q) returns error: %v", i, tc.data, err)
+			continue
+		}
+		if !php.Equal(v, tc.want) {
+			t.Errorf("#%d: Unmarshal(%q) == %#v, want: %#v", i, tc.data, v, tc.want)
+		}
+	}
+}
+
+func TestUnmarshalError(t *testing.T) {
+	cases := []string{
+		"",
+		"\x00\x00\x00\x03\x00",
+		header,
+		header + "\x11\x05abc",
+		header + "\x14\xff",
+		header + "\x0e\x00",
+		header + "\x22\x00",
+		header + "\x17\x03Foo\x1d\x01x",
+		header + "\x00\x00",
+		header + "\xff",
+	}
+	for i, data := range cases {
+		if _, err := igbinary.Unmarshal([]byte(data)); err == nil {
+			t.Errorf("#%d: Unmarshal(%q) returns no error", i, data)
+		}
+	}
+}
+
+func TestUnmarshalMaxDepth(t *testing.T) {
+	nested := func(n int) []byte {
+		return []byte(header + strings.Repeat("\x14\x01\x06\x00", n) + "\x00")
+	}
+	cases := []struct {
+		data       []byte
+		opts       []igbinary.Option
+		wantsError bool
+	}{
+		{data: nested(3), opts: []igbinary.Option{igbinary.WithMaxDepth(3)}},
+		{data: nested(3), opts: []igbinary.Option{igbinary.WithMaxDepth(2)}, wantsError: true},
+		{data: nested(10000)},
+		{data: nested(10001), wantsError: true},
+		{data: nested(20000), opts: []igbinary.Option{igbinary.WithMaxDepth(20000)}},
+		{data: []byte(header + strings.Repeat("\x25", 10001) + "\x00"), wantsError: true},
+	}
+	for i, tc := range cases {
+		_, err := igbinary.Unmarshal(tc.data, tc.opts...)
+		if tc.wantsError != (err != nil) {
+			t.Errorf("#%d: Unmarshal(...) returns error: %v, wants error: %v", i, err, tc.wantsError)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Package decoding connects the decoder of PHP serialized data, which lives
+// in package php so that php.Parse and the lazily decoded values of
+// php.Raw can use it, with the phpserialize package, which imports php and
+// so cannot be imported by it.
+//
+// The entry point of the decoder takes the Options, Request and Result
+// types below, which are not part of the API of package php, so rather than
+// exporting it, package php registers it with Register when it is
+// initialized, and phpserialize gets it with Get. Apart from this, imports
+// go one way: phpserialize imports php, and both import decoding.
+//
+// The grammar of serialized values is defined once, in grammar.go, and
+// both the decoder and the Scanner, which checks values arriving in pieces,
+// read tokens with it.
+package decoding
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+)
+
+// ErrTooLarge is wrapped by the errors reporting data beyond the limits of
+// the Options.
+var ErrTooLarge = errors.New("php serialize: data too large")
+
+// Options are the options of phpserialize that apply to decoding.
+type Options struct {
+	MaxBytes      int   // maximum size of a serialized value, 0 means no limit
+	MaxInputBytes int64 // maximum size of the whole input, 0 means no limit
+	MaxElements   int   // maximum total of array elements and object fields, 0 means no limit
+	MaxDepth      int   // maximum nesting of arrays and objects, 0 means no limit
+
+	KeepLexemes  bool
+	StrictInts   bool
+	StrictKeys   bool
+	CastKeys     bool
+	Lenient      bool
+	Lazy         bool
+	Arena        bool
+	Intern       bool // share Values for common scalars
+	StringBytes  bool // keep string contents as slices of the data
+	AllErrors    bool // report all problems instead of the first
+	Int32        bool // emulate 32-bit PHP integers
+	VerbatimKeys bool // do not cast numeric string keys to int
+	ValidUTF8    bool // reject strings that are not valid UTF-8
+
+	StdClassAsArray bool
+	ObjectsAsArrays bool
+	ClassKey        string // array key of the class names of objects decoded as arrays
+	SPLAsArrays     bool
+	AllowedClasses  map[string]bool // lower-cased class names, nil means all
+	RejectClasses   bool            // fail on classes not in AllowedClasses
+}
+
+// Mode tells what a Request decodes.
+type Mode int
+
+const (
+	Whole  Mode = iota // the value filling the data
+	Prefix             // the value at the head of the data
+)
+
+// A Request asks to decode Data.
+type Request struct {
+	Data    []byte
+	Options Options
+	Mode    Mode
+	Ctx     context.Context // checked while decoding if not nil
+	Recover bool            // salvage what can be decoded of damaged data
+	Offsets bool            // record the start offsets of the decoded values
+}
+
+// A Result is the outcome of a Request.
+type Result[V comparable] struct {
+	Value    V
+	N        int // bytes read
+	Err      error
+	Problems []Problem // problems decoded past, in the order they were found
+	Offsets  map[V]int // start offsets of values if Request.Offsets is set
+}
+
+// A Problem is a problem found in damaged data that decoding went past, in
+// recovery mode or with Options.AllErrors.
+type Problem struct {
+	Offset int    // offset in the data where the problem was found
+	Path   string // path of the value being read, like "orders[3].price"
+	Err    error
+}
+
+// Funcs are the functions package php registers.
+type Funcs[V comparable] struct {
+	Decode     func(*Request) *Result[V]
+	SPLAsArray func(v V) (V, error)
+}
+
+var funcs interface{}
+
+// Register registers the functions of package php.
+func Register[V comparable](f Funcs[V]) {
+	funcs = f
+}
+
+// Get returns the functions registered by package php.
+func Get[V comparable]() Funcs[V] {
+	return funcs.(Funcs[V])
+}
+
+// ClosureClasses holds the lower-cased names of the wrapper classes PHP
+// libraries serialize closures with.
+var ClosureClasses = map[string]bool{
+	`opis\closure\serializableclosure`:                        true,
+	`laravel\serializableclosure\serializableclosure`:         true,
+	`laravel\serializableclosure\unsignedserializableclosure`: true,
+	`illuminate\queue\serializableclosure`:                    true,
+	`superclosure\serializableclosure`:                        true,
+}
+
+// NumericKey returns the int key PHP casts the string key s to, and whether
+// it does. int32 emulates a 32-bit PHP host.
+func NumericKey(s string, int32 bool) (int64, bool) {
+	if s == "" || len(s) > 20 {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(s, 10, strconv.IntSize)
+	if err != nil || strconv.FormatInt(i, 10) != s {
+		return 0, false
+	}
+	if int32 && (i < math.MinInt32 || i > math.MaxInt32) {
+		return 0, false
+	}
+	return i, true
+}
+
+// FloatToInt converts f like PHP's (int) cast, reporting whether f is in
+// the int64 range.
+func FloatToInt(f float64) (int64, bool) {
+	if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
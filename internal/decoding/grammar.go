@@ -0,0 +1,112 @@
+package decoding
+
+import (
+	"math"
+	"strconv"
+)
+
+// A PartKind is a kind of part of a token.
+type PartKind uint8
+
+const (
+	PartLit       PartKind = iota // the bytes of Lit
+	PartLen                       // a string length ending with ':'
+	PartCount                     // a count of members ending with ':'
+	PartCustomLen                 // the data length of a C: object ending with ':'
+	PartScalar                    // the contents of a bool, int or float ending with ';'
+	PartBody                      // a string body or C: object data of the last length
+	PartEscaped                   // the body of an S: string of the last length
+)
+
+// A Part is a part of a token following its type token.
+type Part struct {
+	Kind PartKind
+	Lit  string
+}
+
+func lit(s string) Part {
+	return Part{Kind: PartLit, Lit: s}
+}
+
+// tokenParts holds the parts following each type token. The members of
+// arrays and objects follow their opening braces, up to the closing brace.
+var tokenParts = [256][]Part{
+	'N': {lit(";")},
+	'b': {lit(":"), {Kind: PartScalar}},
+	'i': {lit(":"), {Kind: PartScalar}},
+	'd': {lit(":"), {Kind: PartScalar}},
+	's': {lit(":"), {Kind: PartLen}, lit(`"`), {Kind: PartBody}, lit(`";`)},
+	'S': {lit(":"), {Kind: PartLen}, lit(`"`), {Kind: PartEscaped}, lit(`";`)},
+	'a': {lit(":"), {Kind: PartCount}, lit("{")},
+	'O': {lit(":"), {Kind: PartLen}, lit(`"`), {Kind: PartBody}, lit(`":`), {Kind: PartCount}, lit("{")},
+	'C': {lit(":"), {Kind: PartLen}, lit(`"`), {Kind: PartBody}, lit(`":`), {Kind: PartCustomLen}, lit("{"), {Kind: PartBody}, lit("}")},
+}
+
+// Parts returns the parts following the type token c, or nil if c is not a
+// type token.
+func Parts(c byte) []Part {
+	return tokenParts[c]
+}
+
+// foldedTokens maps type tokens in the wrong case to the right ones. S, C, E
+// and R are not folded, since they are distinct tokens in PHP.
+var foldedTokens = map[byte]byte{
+	'n': 'N', 'B': 'b', 'I': 'i', 'D': 'd', 'A': 'a', 'o': 'O',
+}
+
+// FoldToken returns the type token c folded to the right case, as lenient
+// mode reads it.
+func FoldToken(c byte) byte {
+	if f, ok := foldedTokens[c]; ok {
+		return f
+	}
+	return c
+}
+
+// MaxNumberLen is the maximum length of a length, count or scalar. The
+// longest numbers PHP writes are floats such as -1.7976931348623157E+308,
+// of 24 bytes; the limit leaves room for floats written with a higher
+// serialize_precision and for leading zeros.
+const MaxNumberLen = 64
+
+// ParseLength parses a string length, member count or C: object data
+// length.
+func ParseLength(bs []byte) (int, error) {
+	return strconv.Atoi(string(bs))
+}
+
+// ParseBool parses the contents of a b: value, reporting whether they are
+// valid.
+func ParseBool(bs []byte) (b, ok bool) {
+	if len(bs) != 1 || bs[0] != '0' && bs[0] != '1' {
+		return false, false
+	}
+	return bs[0] == '1', true
+}
+
+// ParseInt parses the contents of an i: value. Values out of the int range
+// fail with an error wrapping strconv.ErrRange.
+func ParseInt(bs []byte) (int64, error) {
+	return strconv.ParseInt(string(bs), 10, strconv.IntSize)
+}
+
+// ParseFloat parses the contents of a d: value, which may also be NAN, INF
+// or -INF.
+func ParseFloat(bs []byte) (float64, error) {
+	switch string(bs) {
+	case "NAN":
+		return math.NaN(), nil
+	case "INF":
+		return math.Inf(1), nil
+	case "-INF":
+		return math.Inf(-1), nil
+	}
+	return strconv.ParseFloat(string(bs), 64)
+}
+
+// Unhex returns the byte an S: string escape stands for, given the two hex
+// digits following its backslash, and whether they are valid.
+func Unhex(bs []byte) (byte, bool) {
+	x, err := strconv.ParseUint(string(bs), 16, 8)
+	return byte(x), err == nil && len(bs) == 2
+}
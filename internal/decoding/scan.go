@@ -0,0 +1,297 @@
+package decoding
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ContextCheckInterval is the number of arrays, objects and members read
+// between checks of the context of a decoding request.
+const ContextCheckInterval = 1024
+
+// A Scanner checks the syntax of a PHP serialized value that may arrive in
+// pieces, and finds where the value ends. It keeps its state between calls,
+// with the arrays and objects being read in an explicit stack, so that each
+// byte is scanned once however the value is split and however deeply it is
+// nested.
+//
+// Like the decoder in lazy mode, a Scanner only checks the structure of the
+// value unless Check is set: scalars are checked when the value is decoded.
+type Scanner struct {
+	Options  Options
+	Check    bool            // also check scalars and the types of array keys
+	Ctx      context.Context // checked while scanning if not nil
+	Base     int             // position of the value in the data, for errors
+	Depth    int             // nesting depth of the value
+	Elements int             // array elements and object fields declared so far
+
+	off     int    // bytes of the value scanned
+	checked int    // bytes of the value known to be valid
+	token   byte   // type token of the value being scanned
+	parts   []Part // the rest of the token being scanned
+	lit     int    // bytes of the PartLit being scanned matched so far
+	n       int    // the last length or count, or the bytes left of a body
+	num     []byte // the number or scalar being read, or the escape in a body
+	escape  bool   // a backslash escape of an S: string is being read
+	open    []int  // keys and values left in each open array or object
+	semis   bool   // doubled semicolons may follow, in lenient mode
+	ticks   int    // arrays and objects counted for checking Ctx
+	done    bool
+	err     error
+}
+
+// Scan scans data, which follows the bytes passed to the previous calls, and
+// returns how many of its bytes belong to the value: all of them unless the
+// value ends within data, which done reports. Once Scan has returned an
+// error, it returns the same error.
+func (s *Scanner) Scan(data []byte) (n int, done bool, err error) {
+	for s.err == nil && !s.done && n < len(data) {
+		var m int
+		m, s.err = s.step(data[n:])
+		n += m
+		s.off += m
+	}
+	if max := s.Options.MaxBytes; s.err == nil && max > 0 && s.off > max {
+		s.err = fmt.Errorf("%w: value size exceeds limit of %d bytes", ErrTooLarge, max)
+	}
+	return n, s.done, s.err
+}
+
+// Started reports whether a value has started, rather than nothing or only
+// doubled semicolons having been scanned.
+func (s *Scanner) Started() bool {
+	return s.token != 0
+}
+
+// Checked returns the number of bytes of the value scanned so far that are
+// known to be valid, which the rest of the value cannot make invalid.
+func (s *Scanner) Checked() int {
+	return s.checked
+}
+
+func (s *Scanner) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("php serialize: "+format, args...)
+}
+
+// pos returns the position of the byte at i in the data being scanned.
+func (s *Scanner) pos(i int) int {
+	return s.Base + s.off + i
+}
+
+// step scans the start of data, which is not empty, returning the number of
+// bytes scanned.
+func (s *Scanner) step(data []byte) (int, error) {
+	if len(s.parts) == 0 {
+		return 1, s.startValue(data[0])
+	}
+	switch p := s.parts[0]; p.Kind {
+	case PartLit:
+		if c := data[0]; c != p.Lit[s.lit] {
+			return 0, s.errorf("unexpected token %s, position: %d", []byte{c}, s.pos(0))
+		}
+		if s.lit++; s.lit < len(p.Lit) {
+			s.checked = s.off + 1
+			return 1, nil
+		}
+		s.lit = 0
+		if err := s.nextPart(); err != nil {
+			return 1, err
+		}
+		s.checked = s.off + 1
+		return 1, nil
+	case PartBody:
+		m := s.n
+		if m > len(data) {
+			m = len(data)
+		}
+		s.n -= m
+		s.checked = s.off + m
+		if s.n > 0 {
+			return m, nil
+		}
+		return m, s.nextPart()
+	case PartEscaped:
+		return 1, s.escaped(data[0])
+	case PartScalar:
+		return s.number(data, ';')
+	default:
+		return s.number(data, ':')
+	}
+}
+
+// startValue scans c, which starts a value or closes an array or object
+// unless it is a semicolon doubled in lenient mode. Doubled semicolons left
+// before the value by the previous one are skipped too.
+func (s *Scanner) startValue(c byte) error {
+	if c == ';' && (s.semis || s.token == 0 && s.Options.Lenient) {
+		s.checked = s.off + 1
+		return nil
+	}
+	s.semis = false
+	k := len(s.open)
+	if k > 0 && s.open[k-1] == 0 {
+		if c != '}' {
+			return s.errorf("unexpected token %s, want: }, position: %d", []byte{c}, s.pos(0))
+		}
+		s.open = s.open[:k-1]
+		s.Depth--
+		s.checked = s.off + 1
+		s.endValue()
+		return nil
+	}
+	if s.Options.Lenient {
+		c = FoldToken(c)
+	}
+	if k > 0 {
+		s.open[k-1]--
+		if s.Check && s.open[k-1]%2 == 1 && c != 'i' && c != 's' && c != 'S' {
+			return s.errorf("invalid array key token %s at position: %d", []byte{c}, s.pos(0))
+		}
+	}
+	parts := Parts(c)
+	if parts == nil {
+		return s.errorf("unexpected token %s at position: %d", []byte{c}, s.pos(0))
+	}
+	s.token, s.parts = c, parts
+	s.checked = s.off + 1
+	return nil
+}
+
+// endValue records the end of a value.
+func (s *Scanner) endValue() {
+	if len(s.open) == 0 {
+		s.done = true
+		return
+	}
+	s.semis = s.Options.Lenient
+}
+
+// nextPart moves to the next part of the token, skipping empty bodies, and
+// ends the token after its last part.
+func (s *Scanner) nextPart() error {
+	s.parts = s.parts[1:]
+	if len(s.parts) > 0 && (s.parts[0].Kind == PartBody || s.parts[0].Kind == PartEscaped) {
+		switch {
+		case s.n < 0 && s.token == 'C' && len(s.parts) == 2:
+			return s.errorf("invalid custom object length %d, position: %d", s.n, s.pos(1))
+		case s.n < 0:
+			return s.errorf("invalid string length %d, position: %d", s.n, s.pos(1))
+		case s.n == 0:
+			s.parts = s.parts[1:]
+		}
+	}
+	if len(s.parts) > 0 {
+		return nil
+	}
+	if s.token != 'a' && s.token != 'O' {
+		s.endValue()
+		return nil
+	}
+	// an array or object opens
+	if s.Ctx != nil {
+		if s.ticks++; s.ticks%ContextCheckInterval == 0 {
+			if err := s.Ctx.Err(); err != nil {
+				return fmt.Errorf("php serialize: %w, position: %d", err, s.pos(0))
+			}
+		}
+	}
+	s.Depth++
+	if s.Options.MaxDepth > 0 && s.Depth > s.Options.MaxDepth {
+		return s.errorf("exceeded max depth of %d, position: %d", s.Options.MaxDepth, s.pos(1))
+	}
+	s.Elements += s.n
+	if s.Options.MaxElements > 0 && s.Elements > s.Options.MaxElements {
+		return s.errorf("exceeded max elements of %d, position: %d", s.Options.MaxElements, s.pos(1))
+	}
+	s.open = append(s.open, 2*s.n)
+	return nil
+}
+
+// escaped scans the byte c of the body of an S: string, in which a
+// backslash followed by two hex digits stands for one byte.
+func (s *Scanner) escaped(c byte) error {
+	switch {
+	case s.escape:
+		if s.num = append(s.num, c); len(s.num) < 2 {
+			return nil
+		}
+		if _, ok := Unhex(s.num); !ok {
+			return s.errorf("invalid string escape %q, position: %d", `\`+string(s.num), s.pos(-2))
+		}
+		s.escape, s.num = false, s.num[:0]
+	case c == '\\':
+		s.escape = true
+		return nil
+	}
+	s.checked = s.off + 1
+	if s.n--; s.n > 0 {
+		return nil
+	}
+	return s.nextPart()
+}
+
+// number scans data for the number or scalar ending with delim, of at most
+// MaxNumberLen bytes, returning the number of bytes scanned.
+func (s *Scanner) number(data []byte, delim byte) (int, error) {
+	i := bytes.IndexByte(data, delim)
+	end := i
+	if i < 0 {
+		end = len(data)
+	}
+	if len(s.num)+end > MaxNumberLen {
+		return 0, s.errorf("number longer than %d bytes, position: %d", MaxNumberLen, s.pos(0)-len(s.num))
+	}
+	if i < 0 {
+		s.num = append(s.num, data...)
+		return len(data), nil
+	}
+	s.num = append(s.num, data[:i]...)
+	bs := s.num
+	s.num = s.num[:0]
+	if s.parts[0].Kind == PartScalar {
+		if s.Check {
+			if err := s.checkScalar(bs); err != nil {
+				return i, err
+			}
+		}
+	} else {
+		l, err := ParseLength(bs)
+		if err != nil {
+			return i, s.errorf("cannot convert `%s` to int: %v", bs, err)
+		}
+		if l < 0 && s.parts[0].Kind == PartCount {
+			return i, s.errorf("invalid count %d, position: %d", l, s.pos(i)-len(bs))
+		}
+		s.n = l
+		if l < 0 {
+			// reported after the quote or brace that follows, as the
+			// decoder does
+			return i + 1, s.nextPart()
+		}
+	}
+	s.checked = s.off + i + 1
+	return i + 1, s.nextPart()
+}
+
+// checkScalar checks the contents bs of a bool, int or float.
+func (s *Scanner) checkScalar(bs []byte) error {
+	switch s.token {
+	case 'b':
+		if _, ok := ParseBool(bs); !ok {
+			return s.errorf("cannot convert `%s` to bool", bs)
+		}
+	case 'i':
+		_, err := ParseInt(bs)
+		if err != nil && !(errors.Is(err, strconv.ErrRange) && !s.Options.StrictInts) {
+			return s.errorf("cannot convert `%s` to int: %v", bs, err)
+		}
+	default:
+		if _, err := ParseFloat(bs); err != nil {
+			return s.errorf("cannot convert `%s` to float: %v", bs, err)
+		}
+	}
+	return nil
+}
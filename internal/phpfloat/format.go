@@ -0,0 +1,84 @@
+// Package phpfloat formats floats the way PHP does.
+package phpfloat
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Format formats the finite f the way PHP's serialize() does with the
+// serialize_precision setting prec: -1 selects the shortest representation
+// that round-trips, 1 to 17 round to that many significant digits.
+// It follows php_gcvt: the exponential form "1.0E+25" is used when the
+// decimal exponent is below -4 or not below the precision (17 for -1).
+func Format(f float64, prec int) string {
+	ndigit := prec
+	if prec < 0 {
+		ndigit = 17
+	}
+	if ndigit == 0 {
+		ndigit = 1
+	}
+
+	// s is [-]d[.ddd]e±dd
+	var s string
+	if prec < 0 {
+		s = strconv.FormatFloat(f, 'e', -1, 64)
+	} else {
+		s = strconv.FormatFloat(f, 'e', ndigit-1, 64)
+	}
+	neg := s[0] == '-'
+	if neg {
+		s = s[1:]
+	}
+	i := strings.IndexByte(s, 'e')
+	exp, _ := strconv.Atoi(s[i+1:])
+	digits := strings.TrimRight(strings.Replace(s[:i], ".", "", 1), "0")
+	if digits == "" {
+		digits = "0"
+	}
+	decpt := exp + 1
+	if f == 0 {
+		decpt = 1
+	}
+
+	var b strings.Builder
+	if neg {
+		b.WriteByte('-')
+	}
+	switch {
+	case decpt < -3 || decpt > ndigit:
+		b.WriteByte(digits[0])
+		b.WriteByte('.')
+		if len(digits) == 1 {
+			b.WriteByte('0')
+		} else {
+			b.WriteString(digits[1:])
+		}
+		b.WriteByte('E')
+		if decpt-1 < 0 {
+			b.WriteByte('-')
+		} else {
+			b.WriteByte('+')
+		}
+		e := decpt - 1
+		if e < 0 {
+			e = -e
+		}
+		b.WriteString(strconv.Itoa(e))
+	case decpt <= 0:
+		b.WriteString("0.")
+		b.WriteString(strings.Repeat("0", -decpt))
+		b.WriteString(digits)
+	default:
+		if len(digits) <= decpt {
+			b.WriteString(digits)
+			b.WriteString(strings.Repeat("0", decpt-len(digits)))
+		} else {
+			b.WriteString(digits[:decpt])
+			b.WriteByte('.')
+			b.WriteString(digits[decpt:])
+		}
+	}
+	return b.String()
+}
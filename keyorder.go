@@ -0,0 +1,134 @@
+package phpserialize
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// MapKeyOrder is the policy for ordering the keys of Go maps, which have no
+// order of their own, in encoded arrays. To keep the insertion order of
+// keys, encode a php.OrderedMap instead.
+type MapKeyOrder uint
+
+// Map key orders
+const (
+	// MapKeyOrderDefault puts integer keys first, in numeric order, and
+	// then the other keys in lexicographic order of their string forms.
+	MapKeyOrderDefault MapKeyOrder = iota
+	// MapKeyOrderString orders all keys lexicographically by their string
+	// forms, like PHP's ksort with SORT_STRING.
+	MapKeyOrderString
+	// MapKeyOrderNatural orders keys by their string forms in natural
+	// order, in which runs of digits compare as numbers so that "img2"
+	// comes before "img10", like PHP's ksort with SORT_NATURAL.
+	MapKeyOrderNatural
+	// MapKeyOrderPHP orders keys like PHP 8's ksort with default flags,
+	// comparing them with php.Compare, so that numeric string keys compare
+	// with integer keys as numbers.
+	MapKeyOrderPHP
+)
+
+// sortMapKeys sorts the keys of a Go map by the map key comparator, else by
+// the map key order. Keys that compare equal keep the default order, so
+// that the output does not depend on the random order of map iteration.
+func (e *encodeState) sortMapKeys(keys []reflect.Value) {
+	sortKeys(keys)
+	var less func(a, b reflect.Value) bool
+	switch {
+	case e.mapKeyCompare != nil:
+		less = func(a, b reflect.Value) bool {
+			return e.mapKeyCompare(a.Interface(), b.Interface()) < 0
+		}
+	case e.mapKeyOrder == MapKeyOrderString:
+		less = func(a, b reflect.Value) bool {
+			return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+		}
+	case e.mapKeyOrder == MapKeyOrderNatural:
+		less = func(a, b reflect.Value) bool {
+			return naturalCompare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface())) < 0
+		}
+	case e.mapKeyOrder == MapKeyOrderPHP:
+		less = func(a, b reflect.Value) bool {
+			return php.Compare(keyValue(a), keyValue(b)) < 0
+		}
+	default:
+		return
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return less(keys[i], keys[j])
+	})
+}
+
+// keyValue returns the Go map key v as a PHP value for comparison.
+func keyValue(v reflect.Value) *php.Value {
+	if i, ok := intVal(v); ok {
+		return php.Int(int(i))
+	}
+	return php.String(fmt.Sprint(v.Interface()))
+}
+
+// naturalCompare compares a and b like PHP's strnatcmp, in which runs of
+// digits compare by their numeric values, and returns -1, 0 or 1.
+func naturalCompare(a, b string) int {
+	for len(a) > 0 && len(b) > 0 {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			x, y := digitRun(a), digitRun(b)
+			if c := compareDigits(trimZeros(a[:x]), trimZeros(b[:y])); c != 0 {
+				return c
+			}
+			a, b = a[x:], b[y:]
+			continue
+		}
+		if a[0] != b[0] {
+			if a[0] < b[0] {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[1:], b[1:]
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func digitRun(s string) int {
+	n := 0
+	for n < len(s) && isDigit(s[n]) {
+		n++
+	}
+	return n
+}
+
+func trimZeros(s string) string {
+	for len(s) > 1 && s[0] == '0' {
+		s = s[1:]
+	}
+	return s
+}
+
+// compareDigits compares runs of digits without leading zeros by value.
+func compareDigits(a, b string) int {
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,117 @@
+package phpserialize
+
+import (
+	"encoding/base64"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// LaravelCache encodes and decodes the values of Laravel's cache stores. The
+// zero value handles stores that write the serialized values as they are,
+// such as the file store and the database store on MySQL.
+type LaravelCache struct {
+	// RawNumbers reports whether numbers, including numeric strings, are
+	// stored as their decimal text rather than serialized, as the Redis store
+	// does. Such values decode as int or float Values.
+	RawNumbers bool
+	// Base64 reports whether serialized values are base64-encoded, as the
+	// database store does on PostgreSQL.
+	Base64 bool
+}
+
+// Marshal returns the cache entry of v.
+func (c LaravelCache) Marshal(v interface{}) ([]byte, error) {
+	if c.RawNumbers {
+		if s, ok := laravelRawNumber(v); ok {
+			return []byte(s), nil
+		}
+	}
+	bs, err := Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if c.Base64 {
+		buf := make([]byte, base64.StdEncoding.EncodedLen(len(bs)))
+		base64.StdEncoding.Encode(buf, bs)
+		return buf, nil
+	}
+	return bs, nil
+}
+
+// Unmarshal decodes the cache entry data.
+func (c LaravelCache) Unmarshal(data []byte) (*php.Value, error) {
+	if c.RawNumbers && isNumeric(string(data)) {
+		s := trimNumeric(string(data))
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return php.Int(int(i)), nil
+		}
+		f, _ := strconv.ParseFloat(s, 64)
+		return php.Float(f), nil
+	}
+	if c.Base64 {
+		buf := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+		n, err := base64.StdEncoding.Decode(buf, data)
+		if err != nil {
+			return nil, err
+		}
+		data = buf[:n]
+	}
+	return Unmarshal(data)
+}
+
+// laravelRawNumber returns the text of v if it is a finite number or a
+// numeric string.
+func laravelRawNumber(v interface{}) (string, bool) {
+	if pv, ok := v.(*php.Value); ok {
+		switch pv.Type() {
+		case php.TypeInt:
+			return strconv.FormatInt(pv.Int(), 10), true
+		case php.TypeFloat:
+			return laravelFloat(pv.Float())
+		case php.TypeString:
+			return pv.String(), isNumeric(pv.String())
+		}
+		return "", false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return "", false
+		}
+		return strconv.FormatUint(rv.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return laravelFloat(rv.Float())
+	case reflect.String:
+		return rv.String(), isNumeric(rv.String())
+	}
+	return "", false
+}
+
+func laravelFloat(f float64) (string, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", false
+	}
+	return phpFloatString(f), true
+}
+
+// phpNumeric matches the strings PHP's is_numeric accepts.
+var phpNumeric = regexp.MustCompile(`^[ \t\n\r\v\f]*[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?[ \t\n\r\v\f]*$`)
+
+// isNumeric reports whether s is numeric by the rules of PHP's is_numeric.
+func isNumeric(s string) bool {
+	return phpNumeric.MatchString(s)
+}
+
+// trimNumeric removes the whitespace PHP allows around numeric strings.
+func trimNumeric(s string) string {
+	return phpNumericSpace.ReplaceAllString(s, "")
+}
+
+var phpNumericSpace = regexp.MustCompile(`^[ \t\n\r\v\f]+|[ \t\n\r\v\f]+$`)
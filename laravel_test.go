@@ -0,0 +1,43 @@
+package phpserialize_test
+
+import (
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestLaravelCache(t *testing.T) {
+	cases := []struct {
+		codec phpserialize.LaravelCache
+		v     interface{}
+		data  string
+		want  *php.Value
+	}{
+		{v: 5, data: `i:5;`, want: php.Int(5)},
+		{codec: phpserialize.LaravelCache{RawNumbers: true}, v: 5, data: `5`, want: php.Int(5)},
+		{codec: phpserialize.LaravelCache{RawNumbers: true}, v: 1.5, data: `1.5`, want: php.Float(1.5)},
+		{codec: phpserialize.LaravelCache{RawNumbers: true}, v: "12", data: `12`, want: php.Int(12)},
+		{codec: phpserialize.LaravelCache{RawNumbers: true}, v: "a", data: `s:1:"a";`, want: php.String("a")},
+		{codec: phpserialize.LaravelCache{RawNumbers: true}, v: []int{1}, data: `a:1:{i:0;i:1;}`, want: php.List(php.Int(1))},
+		{codec: phpserialize.LaravelCache{Base64: true}, v: "a", data: `czoxOiJhIjs=`, want: php.String("a")},
+	}
+	for i, tc := range cases {
+		bs, err := tc.codec.Marshal(tc.v)
+		if err != nil {
+			t.Errorf("#%d: Marshal(%#v) returns error: %v", i, tc.v, err)
+			continue
+		}
+		if string(bs) != tc.data {
+			t.Errorf("#%d: Marshal(%#v) == %q, want: %q", i, tc.v, bs, tc.data)
+		}
+		v, err := tc.codec.Unmarshal([]byte(tc.data))
+		if err != nil {
+			t.Errorf("#%d: Unmarshal(%q) returns error: %v", i, tc.data, err)
+			continue
+		}
+		if !php.Equal(v, tc.want) {
+			t.Errorf("#%d: Unmarshal(%q) == %#v, want: %#v", i, tc.data, v, tc.want)
+		}
+	}
+}
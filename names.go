@@ -0,0 +1,42 @@
+package phpserialize
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SnakeCase converts a Go identifier to snake_case, treating runs of capitals
+// as one word: "UserID" becomes "user_id" and "HTTPServer" "http_server".
+// It can be used as a field name mapper.
+func SnakeCase(s string) string {
+	rs := []rune(s)
+	var b strings.Builder
+	for i, r := range rs {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := rs[i-1]
+			nextLower := i+1 < len(rs) && unicode.IsLower(rs[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// LowerCamelCase converts a Go identifier to lowerCamelCase by lowering its
+// leading capitals: "UserID" becomes "userID" and "HTTPServer" "httpServer".
+// It can be used as a field name mapper.
+func LowerCamelCase(s string) string {
+	rs := []rune(s)
+	for i, r := range rs {
+		if !unicode.IsUpper(r) {
+			break
+		}
+		if i > 0 && i+1 < len(rs) && unicode.IsLower(rs[i+1]) {
+			break
+		}
+		rs[i] = unicode.ToLower(r)
+	}
+	return string(rs)
+}
@@ -0,0 +1,616 @@
+package phpserialize
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/kamiaka/go-phpserialize/internal/decoding"
+)
+
+// An Option configures how values are encoded or decoded.
+type Option func(*options)
+
+type options struct {
+	// decoding
+	maxBytes    int // maximum size of a serialized value, 0 means no limit
+	maxElements int // maximum total of array elements and object fields, 0 means no limit
+	keepLexemes bool
+	strictInts  bool
+	strictKeys  bool
+	castKeys    bool
+	lenient     bool
+	lazy        bool
+	arena       bool
+	intern      bool // share Values for common scalars
+	stringBytes bool // keep string contents as slices of the data
+	allErrors   bool // report all problems instead of the first
+
+	maxInputBytes int64 // maximum size of the whole input, 0 means no limit
+
+	stdClassAsArray bool
+	objectsAsArrays bool
+	classKey        string // array key of the class names of objects decoded as arrays
+	splAsArrays     bool
+	allowedClasses  map[string]bool // lower-cased class names, nil means all
+	rejectClasses   bool            // fail on classes not in allowedClasses
+
+	disallowUnknownFields bool
+	caseInsensitive       bool // match property names to struct fields case-insensitively
+	weakTypes             bool // convert scalars like PHP's type juggling
+
+	// encoding and decoding
+	maxDepth     int  // maximum nesting of arrays and objects, 0 means no limit
+	int32        bool // emulate 32-bit PHP integers
+	verbatimKeys bool // do not cast numeric string keys to int
+	validUTF8    bool // reject strings that are not valid UTF-8
+
+	// encoding
+	fieldNameMapper func(string) string
+	classNameMapper func(reflect.Type) string
+	promoteEmbedded bool
+	jsonTags        bool
+	timeEncoding    TimeEncoding
+	timeLayout      string
+	floatPrecision  int // serialize_precision, values below 1 mean -1
+	uintOverflow    UintOverflow
+	complexEncoding ComplexEncoding
+	mapKeyOrder     MapKeyOrder
+	// overrides mapKeyOrder if not nil
+	mapKeyCompare   func(a, b interface{}) int
+	jsonTranscoding bool // encode json.RawMessage as the PHP value it holds
+	validate        bool // validate the output of Marshalers
+	nilSliceAsNull  bool // encode nil slices and maps as N;
+	escapedStrings  bool // encode strings as S: tokens
+	structsAsArrays bool // encode structs as associative arrays
+	textMarshalers  bool // encode encoding.TextMarshaler values as strings
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// decodeOptions returns the options of the decoder in package php.
+func (o *options) decodeOptions() decoding.Options {
+	return decoding.Options{
+		MaxBytes:        o.maxBytes,
+		MaxInputBytes:   o.maxInputBytes,
+		MaxElements:     o.maxElements,
+		MaxDepth:        o.maxDepth,
+		KeepLexemes:     o.keepLexemes,
+		StrictInts:      o.strictInts,
+		StrictKeys:      o.strictKeys,
+		CastKeys:        o.castKeys,
+		Lenient:         o.lenient,
+		Lazy:            o.lazy,
+		Arena:           o.arena,
+		Intern:          o.intern,
+		StringBytes:     o.stringBytes,
+		AllErrors:       o.allErrors,
+		Int32:           o.int32,
+		VerbatimKeys:    o.verbatimKeys,
+		ValidUTF8:       o.validUTF8,
+		StdClassAsArray: o.stdClassAsArray,
+		ObjectsAsArrays: o.objectsAsArrays,
+		ClassKey:        o.classKey,
+		SPLAsArrays:     o.splAsArrays,
+		AllowedClasses:  o.allowedClasses,
+		RejectClasses:   o.rejectClasses,
+	}
+}
+
+// WithMaxDepth limits the nesting depth of arrays and objects in a decoded
+// or encoded value to n. A value of 0 means no limit.
+func WithMaxDepth(n int) Option {
+	return func(o *options) {
+		o.maxDepth = n
+	}
+}
+
+// WithMaxBytes limits the size of a single serialized value to n bytes.
+// A value of 0 means no limit.
+func WithMaxBytes(n int) Option {
+	return func(o *options) {
+		o.maxBytes = n
+	}
+}
+
+// WithMaxInputBytes limits the size of the whole input to n bytes: the data
+// passed to the Unmarshal functions, or all the data a Decoder reads from its
+// stream. The Decoder reads at most one byte beyond the limit. A value of 0
+// means no limit. Errors reporting larger inputs wrap ErrTooLarge.
+//
+// A Decoder reading from an io.LimitedReader also reports a value cut off by
+// the end of the limit with an error wrapping ErrTooLarge rather than
+// io.ErrUnexpectedEOF, so that both limits are reported alike.
+func WithMaxInputBytes(n int64) Option {
+	return func(o *options) {
+		o.maxInputBytes = n
+	}
+}
+
+// WithMaxElements limits the total number of array elements and object
+// fields declared in a decoded value to n, bounding the memory allocated for
+// them. A value of 0 means no limit.
+func WithMaxElements(n int) Option {
+	return func(o *options) {
+		o.maxElements = n
+	}
+}
+
+// WithLexemes makes the decoder keep the original text of every int and
+// float on the decoded php.Value (see php.Value.Lexeme), so that re-encoding
+// an unmodified value reproduces the input exactly, which matters when
+// payloads are signed or hashed.
+func WithLexemes() Option {
+	return func(o *options) {
+		o.keepLexemes = true
+	}
+}
+
+// WithStrictInts makes the decoder fail on i: values that do not fit in an
+// int, which by default decode as floats, as PHP's unserialize does.
+func WithStrictInts() Option {
+	return func(o *options) {
+		o.strictInts = true
+	}
+}
+
+// WithStrictKeys makes the decoder fail on arrays that repeat a key. By
+// default, as in PHP's unserialize, the last value of a repeated key wins and
+// the key keeps its first position.
+//
+// It also makes the encoder fail on Go maps and php.OrderedMaps with keys
+// that are the same PHP key once cast like PHP casts array keys, such as
+// "5" and 5, which PHP would collapse into one element.
+func WithStrictKeys() Option {
+	return func(o *options) {
+		o.strictKeys = true
+	}
+}
+
+// WithCastKeys makes the decoder accept array keys that are bools, floats or
+// null, which PHP never writes but buggy writers do, and cast them like PHP
+// casts such keys: true to 1, false to 0, floats to int toward zero, such as
+// 1.9 to 1, and null to "". Float keys out of the int range still fail. By
+// default, keys other than ints and strings fail.
+func WithCastKeys() Option {
+	return func(o *options) {
+		o.castKeys = true
+	}
+}
+
+// WithAllErrors makes the decoder go on after the problems UnmarshalRecover
+// decodes past, such as a string length that does not match its contents, a
+// wrong member count, a repeated key with WithStrictKeys or invalid UTF-8 with
+// WithValidUTF8, and fail with all of them joined by errors.Join. Each of the
+// joined errors is a *DecodeError. Values are decoded eagerly even with
+// WithLazy, so that the whole data is checked.
+//
+// The Decoder, which reads values from a stream, reports the first problem
+// only.
+func WithAllErrors() Option {
+	return func(o *options) {
+		o.allErrors = true
+	}
+}
+
+// WithLenient makes the decoder tolerate the following deviations found in
+// data written by old or buggy PHP serializers:
+//
+//   - type tokens in the wrong case, such as "I:1;" or "o:...", except for
+//     S, C, E and R, which are distinct tokens
+//   - doubled semicolons after a value, such as "i:1;;"
+//   - trailing whitespace after the value passed to Unmarshal
+//
+// Signs and leading zeros in integers, such as "i:+007;", are accepted in
+// any mode, as PHP accepts them.
+func WithLenient() Option {
+	return func(o *options) {
+		o.lenient = true
+	}
+}
+
+// WithLazy makes the decoder defer decoding the values of array elements and
+// object fields until they are first accessed (see php.Raw); encoders write
+// untouched values back byte for byte. This saves work when only a few values
+// of a large blob are read. A value whose decoding fails behaves like
+// php.Missing, and its Load method returns the error.
+func WithLazy() Option {
+	return func(o *options) {
+		o.lazy = true
+	}
+}
+
+// WithArena makes the decoder allocate the parts of each decoded value from
+// a php.Arena, which greatly reduces allocations for large arrays. In
+// exchange, the memory of a decoded value is only released once none of its
+// parts is reachable.
+func WithArena() Option {
+	return func(o *options) {
+		o.arena = true
+	}
+}
+
+// WithInterning makes the decoder return shared Values for null, booleans,
+// the empty string and ints from -128 to 1023 instead of allocating them
+// for each occurrence, which saves most allocations when decoding large
+// arrays of such values, and their int keys. The shared Values are used by
+// all decoders and goroutines, so they must not be modified, e.g. with
+// UnmarshalJSON, and cannot be told apart by pointer.
+func WithInterning() Option {
+	return func(o *options) {
+		o.intern = true
+	}
+}
+
+// WithStringBytes makes the decoder keep the contents of string values as
+// byte slices of the decoded data instead of copying them into Go strings,
+// for binary data and for large strings that need not be copied. The Bytes
+// method of such Values returns the contents without copying. The data must
+// not be modified while the decoded values are in use. Array keys are still
+// decoded as Go strings.
+func WithStringBytes() Option {
+	return func(o *options) {
+		o.stringBytes = true
+	}
+}
+
+// WithStdClassAsArray makes the decoder decode stdClass objects into array
+// Values, which give plain key/value access. Like PHP's (array) cast, it
+// keeps the names of non-public properties mangled and turns integer-like
+// names into int keys.
+func WithStdClassAsArray() Option {
+	return func(o *options) {
+		o.stdClassAsArray = true
+	}
+}
+
+// WithObjectsAsArrays is like WithStdClassAsArray but applies to objects of
+// any class, dropping the class names.
+func WithObjectsAsArrays() Option {
+	return func(o *options) {
+		o.objectsAsArrays = true
+	}
+}
+
+// WithClassKey makes objects decoded as arrays by WithObjectsAsArrays or
+// WithStdClassAsArray keep their class name under key, as the first element
+// of the array, so that a tree of arrays still tells what the objects were.
+// A property of the object named key replaces the class name. Objects of
+// classes implementing Serializable, written as C: tokens, stay objects as
+// their data is only meaningful to the class.
+func WithClassKey(key string) Option {
+	return func(o *options) {
+		o.classKey = key
+	}
+}
+
+// WithSPLAsArrays makes the decoder decode ArrayObject, SplFixedArray,
+// SplObjectStorage, SplDoublyLinkedList and related SPL container objects
+// into array Values of their contents, as SPLAsArray does.
+func WithSPLAsArrays() Option {
+	return func(o *options) {
+		o.splAsArrays = true
+	}
+}
+
+// WithAllowedClasses restricts the classes the decoder instantiates to
+// names, like the allowed_classes option of PHP's unserialize. Objects of
+// other classes decode as PHP's __PHP_Incomplete_Class objects (see
+// php.Obj.IncompleteClassName), which encoders write back unchanged.
+// Class names are matched case-insensitively; no names allow no classes,
+// like allowed_classes set to false. The restriction applies to both O: and
+// C: objects.
+func WithAllowedClasses(names ...string) Option {
+	return func(o *options) {
+		o.allowedClasses = make(map[string]bool, len(names))
+		for _, n := range names {
+			o.allowedClasses[strings.ToLower(n)] = true
+		}
+	}
+}
+
+// WithRejectDisallowedClasses makes the decoder fail on objects of classes
+// not allowed by WithAllowedClasses instead of decoding them as incomplete
+// class objects, before reading their contents. It has no effect without
+// WithAllowedClasses.
+func WithRejectDisallowedClasses() Option {
+	return func(o *options) {
+		o.rejectClasses = true
+	}
+}
+
+// WithDisallowUnknownFields makes decoding into a struct fail on properties
+// and array keys that do not match any field of the struct, to catch
+// schema drift between PHP producers and Go consumers early. It applies to
+// Decode, Decoder.DecodeInto and Codec.
+func WithDisallowUnknownFields() Option {
+	return func(o *options) {
+		o.disallowUnknownFields = true
+	}
+}
+
+// WithCaseInsensitiveFields makes decoding into a struct match properties and
+// array keys to field names case-insensitively, such as "userId" to UserID,
+// when no field name matches exactly. It applies to Decode,
+// Decoder.DecodeInto and Codec.
+func WithCaseInsensitiveFields() Option {
+	return func(o *options) {
+		o.caseInsensitive = true
+	}
+}
+
+// WithWeakTypes makes decoding into bool, integer, float and string Go values
+// convert scalars of other types the way PHP's casts do rather than failing,
+// so that "42" decodes into an int as 42, 1 into a bool as true and 1.5 into
+// a string as "1.5". Strings without a numeric prefix convert to zero, and
+// floats out of the range of int64 still fail, as do overflows of the target
+// type. It applies to Decode, Decoder.DecodeInto and Codec.
+func WithWeakTypes() Option {
+	return func(o *options) {
+		o.weakTypes = true
+	}
+}
+
+// WithInt32 emulates a 32-bit PHP host, on which integers outside the int32
+// range overflow into floats: such i: values decode as floats rather than
+// ints, and such integers are encoded as floats, or as string keys when used
+// as array keys, as PHP would store them.
+func WithInt32() Option {
+	return func(o *options) {
+		o.int32 = true
+	}
+}
+
+// WithVerbatimKeys keeps string array keys as they are. By default, string
+// keys that are decimal integers in canonical form, such as "5" but not "05",
+// are cast to int keys when decoding and encoding, as PHP does when it
+// stores them.
+func WithVerbatimKeys() Option {
+	return func(o *options) {
+		o.verbatimKeys = true
+	}
+}
+
+// numericKey returns the int key PHP casts the string key s to, and whether
+// it does.
+func (o *options) numericKey(s string) (int64, bool) {
+	return decoding.NumericKey(s, o.int32)
+}
+
+// WithFieldNameMapper makes the encoder transform Go struct field names into
+// the serialized property names with fn, e.g. SnakeCase or LowerCamelCase.
+func WithFieldNameMapper(fn func(string) string) Option {
+	return func(o *options) {
+		o.fieldNameMapper = fn
+	}
+}
+
+// WithClassNameMapper makes the encoder choose the PHP class name of Go
+// struct types that do not implement ClassNamer with fn, instead of using
+// the Go type names.
+func WithClassNameMapper(fn func(reflect.Type) string) Option {
+	return func(o *options) {
+		o.classNameMapper = fn
+	}
+}
+
+// WithPromoteEmbedded makes the encoder promote the fields of embedded
+// structs into the properties of the outer object, like encoding/json does,
+// instead of serializing them as a nested object property named after the
+// type.
+func WithPromoteEmbedded() Option {
+	return func(o *options) {
+		o.promoteEmbedded = true
+	}
+}
+
+// WithJSONTags makes fields without a php struct tag read their json struct
+// tag instead, whose name, "-" and omitempty option then apply as in a php
+// tag. It suits structs whose json tags already match the property names the
+// PHP side expects, and applies to encoding and to decoding into structs.
+func WithJSONTags() Option {
+	return func(o *options) {
+		o.jsonTags = true
+	}
+}
+
+// WithTimeEncoding sets how the encoder encodes time.Time values. The
+// default is TimeReflect.
+func WithTimeEncoding(te TimeEncoding) Option {
+	return func(o *options) {
+		o.timeEncoding = te
+	}
+}
+
+// WithTimeLayout sets the layout used by the TimeString encoding.
+// An empty layout means time.RFC3339.
+func WithTimeLayout(layout string) Option {
+	return func(o *options) {
+		o.timeLayout = layout
+	}
+}
+
+// WithFloatPrecision sets the number of significant digits of encoded
+// floats, like PHP's serialize_precision setting. The default of -1, used
+// for any value below 1, selects the shortest representation that
+// round-trips; 17 matches PHP versions before 7.1.
+func WithFloatPrecision(prec int) Option {
+	return func(o *options) {
+		o.floatPrecision = prec
+	}
+}
+
+// WithUintOverflow sets how the encoder encodes unsigned integers larger
+// than a PHP int can hold. The default is UintOverflowFloat.
+func WithUintOverflow(p UintOverflow) Option {
+	return func(o *options) {
+		o.uintOverflow = p
+	}
+}
+
+// WithComplexEncoding sets how the encoder encodes complex numbers. The
+// default is ComplexError. Unmarshal decodes any of the encodings, and
+// numbers, into complex numbers.
+func WithComplexEncoding(ce ComplexEncoding) Option {
+	return func(o *options) {
+		o.complexEncoding = ce
+	}
+}
+
+// WithMapKeyOrder sets the order in which the keys of Go maps are encoded.
+// The default is MapKeyOrderDefault.
+func WithMapKeyOrder(order MapKeyOrder) Option {
+	return func(o *options) {
+		o.mapKeyOrder = order
+	}
+}
+
+// WithMapKeyCompare makes the encoder order the keys of Go maps by cmp,
+// which returns a negative number, zero or a positive number when the key a
+// comes before, along with or after the key b. It overrides
+// WithMapKeyOrder; passing nil restores it.
+func WithMapKeyCompare(cmp func(a, b interface{}) int) Option {
+	return func(o *options) {
+		o.mapKeyCompare = cmp
+	}
+}
+
+// WithJSONTranscoding makes the encoder write json.RawMessage values as the
+// PHP values their JSON text stands for, as php.Value.UnmarshalJSON converts
+// it, instead of strings holding JSON, and the decoder convert PHP values
+// back to JSON text for them. The "json" option of the php struct tag does
+// the same for a single string or []byte field:
+//
+//	Payload string `php:"payload,json"`
+func WithJSONTranscoding() Option {
+	return func(o *options) {
+		o.jsonTranscoding = true
+	}
+}
+
+// WithValidate makes the encoder check that the output of Marshaler
+// implementations, including RawMessage, is a valid PHP serialized value.
+func WithValidate() Option {
+	return func(o *options) {
+		o.validate = true
+	}
+}
+
+// WithNilSliceAsNull makes the encoder encode nil slices and maps as PHP
+// null instead of an empty array or string, for PHP consumers that tell null
+// from an empty array.
+func WithNilSliceAsNull() Option {
+	return func(o *options) {
+		o.nilSliceAsNull = true
+	}
+}
+
+// WithEscapedStrings makes the encoder write strings, including array keys
+// and property names, as S: tokens in which backslashes and bytes outside
+// printable ASCII are written as \xx hex escapes, the form some APC caches
+// and PHP builds store. Only PHP versions that accept S: can decode it.
+func WithEscapedStrings() Option {
+	return func(o *options) {
+		o.escapedStrings = true
+	}
+}
+
+// WithStructsAsArrays makes the encoder write structs as associative arrays
+// keyed by property name instead of objects, for PHP code that expects
+// arrays. Unexported fields are keyed by their plain names. Such arrays
+// decode back into structs.
+func WithStructsAsArrays() Option {
+	return func(o *options) {
+		o.structsAsArrays = true
+	}
+}
+
+// WithTextMarshalers makes the encoder write values implementing
+// encoding.TextMarshaler, but not Marshaler, as the string MarshalText
+// returns, like encoding/json, instead of by their kind, so that types such
+// as net.IP and UUIDs become readable strings. With TimeReflect, time.Time
+// is then written as an RFC 3339 string.
+func WithTextMarshalers() Option {
+	return func(o *options) {
+		o.textMarshalers = true
+	}
+}
+
+// WithValidUTF8 makes the decoder and the encoder fail on strings, array
+// keys and property names that are not valid UTF-8, for PHP applications
+// that assume UTF-8 text, e.g. through mbstring, and would corrupt them.
+// The error tells the path of the string. Use php.Bytes and []byte for
+// binary data, which WithValidUTF8 checks as well.
+func WithValidUTF8() Option {
+	return func(o *options) {
+		o.validUTF8 = true
+	}
+}
+
+// Profile is a named preset of decoding limits, allocation and strictness
+// settings.
+type Profile uint
+
+// Profiles
+const (
+	// ProfileUntrustedInput suits data received from clients: depth 32,
+	// 1 MiB per value and 100,000 elements, with WithStrictInts and
+	// WithStrictKeys, and no classes allowed, so that objects decode as
+	// incomplete class objects. Strings are copied out of the data.
+	ProfileUntrustedInput Profile = iota
+	// ProfileTrustedBulk suits large data produced by your own systems: no
+	// limits, no strictness, all classes allowed, and values allocated from
+	// an arena (see WithArena).
+	ProfileTrustedBulk
+	// ProfileLowMemory bounds the memory spent on a single value: depth 32,
+	// 512 KiB per value and 50,000 elements, with WithStrictInts and
+	// WithStrictKeys, no classes allowed, and shared Values for common
+	// scalars (see WithInterning).
+	ProfileLowMemory
+)
+
+// WithProfile applies the settings of the profile p, replacing those of the
+// options given before it. Options given after it override individual
+// settings.
+func WithProfile(p Profile) Option {
+	return func(o *options) {
+		switch p {
+		case ProfileUntrustedInput:
+			o.maxDepth = 32
+			o.maxBytes = 1 << 20
+			o.maxElements = 100000
+			o.setProfileFlags(true, false, false)
+		case ProfileTrustedBulk:
+			o.maxDepth = 0
+			o.maxBytes = 0
+			o.maxElements = 0
+			o.setProfileFlags(false, true, false)
+		case ProfileLowMemory:
+			o.maxDepth = 32
+			o.maxBytes = 512 << 10
+			o.maxElements = 50000
+			o.setProfileFlags(true, false, true)
+		}
+	}
+}
+
+// setProfileFlags sets the strictness and allocation settings of a profile.
+// Strict profiles allow no classes.
+func (o *options) setProfileFlags(strict, arena, intern bool) {
+	o.strictInts = strict
+	o.strictKeys = strict
+	o.allowedClasses = nil
+	if strict {
+		o.allowedClasses = map[string]bool{}
+	}
+	o.rejectClasses = false
+	o.lazy = false
+	o.arena = arena
+	o.intern = intern
+	o.stringBytes = false
+}
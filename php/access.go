@@ -0,0 +1,285 @@
+package php
+
+import (
+	"math"
+	"strconv"
+)
+
+// Missing returns a new Value that represents a nonexistent array element
+// or object field, as At and AtIndex return when the path does not exist.
+// It has TypeInvalid, so the strict getters panic on it while the *Or
+// getters return their defaults. Each call returns a new Value, so storing
+// into one, e.g. with json.Unmarshal, does not affect the others.
+func Missing() *Value {
+	return &Value{}
+}
+
+// Exists reports whether v represents an existing value, i.e. v is neither
+// nil nor a missing Value. A PHP null exists.
+func (v *Value) Exists() bool {
+	v.load()
+	return v != nil && v.t != TypeInvalid
+}
+
+// At returns v's element with the key name, or the field named name if v is
+// an object. Like PHP, a name that is a decimal integer also matches the
+// equal int key.
+// At never returns nil: if v is not an array or object, or the key does not
+// exist, it returns a missing Value, so calls can be chained safely:
+//
+//	v.At("user").At("age").IntOr(0)
+func (v *Value) At(name string) *Value {
+	v.load()
+	if v == nil {
+		return Missing()
+	}
+	switch v.t {
+	case TypeArray:
+		for _, e := range v.Array() {
+			switch e.Index.t {
+			case TypeString:
+				if e.Index.String() == name {
+					return e.Value
+				}
+			case TypeInt:
+				if strconv.FormatInt(e.Index.Int(), 10) == name {
+					return e.Value
+				}
+			}
+		}
+	case TypeObject:
+		if f := v.Object().Field(name); f != nil {
+			return f.Value
+		}
+	}
+	return Missing()
+}
+
+// AtIndex returns v's element with the int key i.
+// Like At, it returns a missing Value instead of nil.
+func (v *Value) AtIndex(i int) *Value {
+	v.load()
+	if v == nil || v.t != TypeArray {
+		return Missing()
+	}
+	for _, e := range v.Array() {
+		if e.Index.t == TypeInt && e.Index.Int() == int64(i) {
+			return e.Value
+		}
+	}
+	return Missing()
+}
+
+// BoolOr returns v's underlying value, or def if v is not a bool Value.
+func (v *Value) BoolOr(def bool) bool {
+	v.load()
+	if v == nil || v.t != TypeBool {
+		return def
+	}
+	return v.Bool()
+}
+
+// IntOr returns v's underlying value, or def if v is not an int Value.
+func (v *Value) IntOr(def int64) int64 {
+	v.load()
+	if v == nil || v.t != TypeInt {
+		return def
+	}
+	return v.Int()
+}
+
+// FloatOr returns v's underlying value, or def if v is not a float Value.
+func (v *Value) FloatOr(def float64) float64 {
+	v.load()
+	if v == nil || v.t != TypeFloat {
+		return def
+	}
+	return v.Float()
+}
+
+// StringOr returns v's underlying value, or def if v is not a string Value.
+func (v *Value) StringOr(def string) string {
+	v.load()
+	if v == nil || v.t != TypeString {
+		return def
+	}
+	return v.String()
+}
+
+// Scalar is the set of Go types As converts Values to.
+type Scalar interface {
+	bool | int | int8 | int16 | int32 | int64 |
+		uint | uint8 | uint16 | uint32 | uint64 |
+		float32 | float64 | string | []byte
+}
+
+// As converts v's underlying value to T, and reports whether it can. Besides
+// values of the matching type:
+//
+//   - integer types accept ints in their range, floats with an integral value
+//     in their range and decimal integer strings
+//   - float types accept ints and numeric strings
+//   - string accepts ints and floats, formatted in decimal
+//
+// bool and []byte only accept bool and string Values. As returns the zero
+// value and false for Values that cannot be converted, including nil and
+// Missing.
+func As[T Scalar](v *Value) (T, bool) {
+	var t T
+	ok := false
+	switch p := interface{}(&t).(type) {
+	case *bool:
+		*p, ok = asBool(v)
+	case *int:
+		var i int64
+		i, ok = asInt(v, strconv.IntSize)
+		*p = int(i)
+	case *int8:
+		var i int64
+		i, ok = asInt(v, 8)
+		*p = int8(i)
+	case *int16:
+		var i int64
+		i, ok = asInt(v, 16)
+		*p = int16(i)
+	case *int32:
+		var i int64
+		i, ok = asInt(v, 32)
+		*p = int32(i)
+	case *int64:
+		*p, ok = asInt(v, 64)
+	case *uint:
+		var u uint64
+		u, ok = asUint(v, strconv.IntSize)
+		*p = uint(u)
+	case *uint8:
+		var u uint64
+		u, ok = asUint(v, 8)
+		*p = uint8(u)
+	case *uint16:
+		var u uint64
+		u, ok = asUint(v, 16)
+		*p = uint16(u)
+	case *uint32:
+		var u uint64
+		u, ok = asUint(v, 32)
+		*p = uint32(u)
+	case *uint64:
+		*p, ok = asUint(v, 64)
+	case *float32:
+		var f float64
+		f, ok = asFloat(v, 32)
+		*p = float32(f)
+	case *float64:
+		*p, ok = asFloat(v, 64)
+	case *string:
+		*p, ok = asString(v)
+	case *[]byte:
+		if s, err := v.TryString(); err == nil {
+			*p, ok = []byte(s), true
+		}
+	}
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return t, true
+}
+
+func asBool(v *Value) (bool, bool) {
+	b, err := v.TryBool()
+	return b, err == nil
+}
+
+// asInt converts v to an integer of the given bit size.
+func asInt(v *Value, bits int) (int64, bool) {
+	v.load()
+	if v == nil {
+		return 0, false
+	}
+	var i int64
+	switch x := v.i.(type) {
+	case int64:
+		i = x
+	case float64:
+		if x != math.Trunc(x) || x < math.MinInt64 || x >= math.MaxInt64 {
+			return 0, false
+		}
+		i = int64(x)
+	case string, []byte:
+		n, err := strconv.ParseInt(v.String(), 10, bits)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+	if bits < 64 && (i < -1<<(bits-1) || i >= 1<<(bits-1)) {
+		return 0, false
+	}
+	return i, true
+}
+
+// asUint converts v to an unsigned integer of the given bit size.
+func asUint(v *Value, bits int) (uint64, bool) {
+	v.load()
+	if v == nil {
+		return 0, false
+	}
+	var u uint64
+	switch x := v.i.(type) {
+	case int64:
+		if x < 0 {
+			return 0, false
+		}
+		u = uint64(x)
+	case float64:
+		if x != math.Trunc(x) || x < 0 || x >= math.MaxUint64 {
+			return 0, false
+		}
+		u = uint64(x)
+	case string, []byte:
+		n, err := strconv.ParseUint(v.String(), 10, bits)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+	if bits < 64 && u >= 1<<bits {
+		return 0, false
+	}
+	return u, true
+}
+
+// asFloat converts v to a float of the given bit size.
+func asFloat(v *Value, bits int) (float64, bool) {
+	v.load()
+	if v == nil {
+		return 0, false
+	}
+	switch x := v.i.(type) {
+	case float64:
+		return x, true
+	case int64:
+		return float64(x), true
+	case string, []byte:
+		f, err := strconv.ParseFloat(v.String(), bits)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func asString(v *Value) (string, bool) {
+	v.load()
+	if v == nil {
+		return "", false
+	}
+	switch x := v.i.(type) {
+	case string:
+		return x, true
+	case []byte:
+		return string(x), true
+	case int64:
+		return strconv.FormatInt(x, 10), true
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64), true
+	}
+	return "", false
+}
@@ -0,0 +1,113 @@
+package php_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestAt(t *testing.T) {
+	v := php.Array(
+		php.Element(php.String("user"), php.Object(
+			"User",
+			php.PubField("name", php.String("bob")),
+			php.ProtectedField("age", php.Int(42)),
+		)),
+		php.Element(php.Int(3), php.Append(php.Array(), php.Float(1.5))),
+	)
+
+	if got := v.At("user").At("age").IntOr(0); got != 42 {
+		t.Errorf(`At("user").At("age").IntOr(0) == %d, want: 42`, got)
+	}
+	if got := v.At("user").At("name").StringOr(""); got != "bob" {
+		t.Errorf(`At("user").At("name").StringOr("") == %q, want: "bob"`, got)
+	}
+	if got := v.At("3").AtIndex(0).FloatOr(0); got != 1.5 {
+		t.Errorf(`At("3").AtIndex(0).FloatOr(0) == %v, want: 1.5`, got)
+	}
+	if got := v.At("none").At("age").IntOr(-1); got != -1 {
+		t.Errorf(`At("none").At("age").IntOr(-1) == %d, want: -1`, got)
+	}
+	if got := v.At("user").At("name").BoolOr(true); got != true {
+		t.Errorf(`At("user").At("name").BoolOr(true) == %v, want: true`, got)
+	}
+	if v.At("none").Exists() {
+		t.Error(`At("none").Exists() == true, want: false`)
+	}
+	if !php.Null().Exists() {
+		t.Error(`Null().Exists() == false, want: true`)
+	}
+	var nilValue *php.Value
+	if got := nilValue.At("a").AtIndex(1); got == nil || got.Exists() {
+		t.Errorf(`nil.At("a").AtIndex(1) == %#v, want: Missing()`, got)
+	}
+}
+
+func TestMissingNotShared(t *testing.T) {
+	v := php.Array()
+	if err := json.Unmarshal([]byte(`42`), v.At("x")); err != nil {
+		t.Fatalf("json.Unmarshal(42, At(x)) returns error: %v", err)
+	}
+	if err := v.AtIndex(0).Scan(`i:42;`); err != nil {
+		t.Fatalf("AtIndex(0).Scan(i:42;) returns error: %v", err)
+	}
+	if got := v.At("y"); got.Exists() || got.IntOr(-1) != -1 {
+		t.Errorf(`At("y") == %v after storing into a missing Value, want: Missing()`, got)
+	}
+	if got := php.Missing(); got.Exists() {
+		t.Errorf("Missing() == %v after storing into a missing Value, want it not to exist", got)
+	}
+}
+
+func TestAs(t *testing.T) {
+	check := func(name string, got, want interface{}, ok, wantOK bool) {
+		t.Helper()
+		if got != want || ok != wantOK {
+			t.Errorf("%s == %#v, %v, want: %#v, %v", name, got, ok, want, wantOK)
+		}
+	}
+
+	i, ok := php.As[int64](php.Int(42))
+	check("As[int64](Int(42))", i, int64(42), ok, true)
+	i, ok = php.As[int64](php.Float(3))
+	check("As[int64](Float(3))", i, int64(3), ok, true)
+	i, ok = php.As[int64](php.Float(3.5))
+	check("As[int64](Float(3.5))", i, int64(0), ok, false)
+	i, ok = php.As[int64](php.String("-12"))
+	check(`As[int64](String("-12"))`, i, int64(-12), ok, true)
+	i, ok = php.As[int64](php.String("1x"))
+	check(`As[int64](String("1x"))`, i, int64(0), ok, false)
+	i8, ok := php.As[int8](php.Int(300))
+	check("As[int8](Int(300))", i8, int8(0), ok, false)
+	u, ok := php.As[uint](php.Int(-1))
+	check("As[uint](Int(-1))", u, uint(0), ok, false)
+	u16, ok := php.As[uint16](php.Int(65535))
+	check("As[uint16](Int(65535))", u16, uint16(65535), ok, true)
+
+	f, ok := php.As[float64](php.Int(2))
+	check("As[float64](Int(2))", f, 2.0, ok, true)
+	f, ok = php.As[float64](php.String("1.5e3"))
+	check(`As[float64](String("1.5e3"))`, f, 1500.0, ok, true)
+
+	s, ok := php.As[string](php.Int(7))
+	check("As[string](Int(7))", s, "7", ok, true)
+	s, ok = php.As[string](php.Float(0.5))
+	check("As[string](Float(0.5))", s, "0.5", ok, true)
+	s, ok = php.As[string](php.Bool(true))
+	check("As[string](Bool(true))", s, "", ok, false)
+
+	b, ok := php.As[bool](php.Bool(true))
+	check("As[bool](Bool(true))", b, true, ok, true)
+	b, ok = php.As[bool](php.Int(1))
+	check("As[bool](Int(1))", b, false, ok, false)
+
+	bs, ok := php.As[[]byte](php.String("ab"))
+	check("As[[]byte](String(\"ab\"))", string(bs), "ab", ok, true)
+
+	i, ok = php.As[int64](php.Missing())
+	check("As[int64](Missing())", i, int64(0), ok, false)
+	var nilValue *php.Value
+	s, ok = php.As[string](nilValue)
+	check("As[string](nil)", s, "", ok, false)
+}
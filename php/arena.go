@@ -0,0 +1,143 @@
+package php
+
+// An Arena allocates Values, ArrayElements and object fields in blocks
+// instead of one by one, reducing allocations and GC pressure when building
+// large values. Its methods are like the package functions of the same
+// names. A block stays in memory as long as any value allocated from it is
+// reachable.
+//
+// A nil *Arena allocates from the heap like the package functions. An Arena
+// must not be used concurrently.
+type Arena struct {
+	values   []Value
+	elements []ArrayElement
+	fields   []ObjField
+}
+
+const (
+	minArenaBlock = 16
+	maxArenaBlock = 1024
+)
+
+// nextBlock returns the size of the block following one of size n.
+func nextBlock(n int) int {
+	switch {
+	case n < minArenaBlock:
+		return minArenaBlock
+	case n >= maxArenaBlock:
+		return maxArenaBlock
+	}
+	return 2 * n
+}
+
+func (a *Arena) value(t Type, i interface{}) *Value {
+	if len(a.values) == cap(a.values) {
+		a.values = make([]Value, 0, nextBlock(cap(a.values)))
+	}
+	a.values = append(a.values, Value{t: t, i: i})
+	return &a.values[len(a.values)-1]
+}
+
+// Null returns null PHP Value.
+func (a *Arena) Null() *Value {
+	if a == nil {
+		return Null()
+	}
+	return a.value(TypeNull, nil)
+}
+
+// Bool returns bool PHP Value.
+func (a *Arena) Bool(v bool) *Value {
+	if a == nil {
+		return Bool(v)
+	}
+	return a.value(TypeBool, v)
+}
+
+// Int returns int PHP Value.
+func (a *Arena) Int(v int) *Value {
+	if a == nil {
+		return Int(v)
+	}
+	return a.value(TypeInt, int64(v))
+}
+
+// Float returns float PHP Value.
+func (a *Arena) Float(v float64) *Value {
+	if a == nil {
+		return Float(v)
+	}
+	return a.value(TypeFloat, v)
+}
+
+// String returns string PHP Value.
+func (a *Arena) String(v string) *Value {
+	if a == nil {
+		return String(v)
+	}
+	return a.value(TypeString, v)
+}
+
+// Bytes returns string PHP Value holding v without copying it. The caller
+// must not modify v afterwards.
+func (a *Arena) Bytes(v []byte) *Value {
+	if a == nil {
+		return Bytes(v)
+	}
+	return a.value(TypeString, v)
+}
+
+// Array returns array PHP Value.
+func (a *Arena) Array(v ...*ArrayElement) *Value {
+	if a == nil {
+		return Array(v...)
+	}
+	return a.value(TypeArray, v)
+}
+
+// Element returns element of array PHP Value.
+func (a *Arena) Element(index, value *Value) *ArrayElement {
+	if a == nil {
+		return Element(index, value)
+	}
+	if len(a.elements) == cap(a.elements) {
+		a.elements = make([]ArrayElement, 0, nextBlock(cap(a.elements)))
+	}
+	a.elements = append(a.elements, ArrayElement{Index: index, Value: value})
+	return &a.elements[len(a.elements)-1]
+}
+
+// Object returns object PHP Value.
+func (a *Arena) Object(name string, fields ...*ObjField) *Value {
+	if a == nil {
+		return Object(name, fields...)
+	}
+	return a.value(TypeObject, &Obj{
+		Name:   name,
+		Fields: fields,
+	})
+}
+
+// CustomObject returns an object PHP Value of class name serialized by the
+// Serializable interface as data.
+func (a *Arena) CustomObject(name string, data []byte) *Value {
+	if a == nil || data == nil {
+		return CustomObject(name, data)
+	}
+	return a.value(TypeObject, &Obj{
+		Name:       name,
+		Serialized: data,
+	})
+}
+
+// Field returns PHP object field.
+func (a *Arena) Field(name string, v *Value, vis Visibility) *ObjField {
+	if a == nil {
+		return Field(name, v, vis)
+	}
+	if len(a.fields) == cap(a.fields) {
+		a.fields = make([]ObjField, 0, nextBlock(cap(a.fields)))
+	}
+	a.fields = append(a.fields, ObjField{Name: name, Visibility: vis, Value: v})
+	return &a.fields[len(a.fields)-1]
+}
@@ -0,0 +1,63 @@
+package php_test
+
+import (
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestArena(t *testing.T) {
+	for _, a := range []*php.Arena{nil, {}} {
+		var es []*php.ArrayElement
+		for i := 0; i < 100; i++ {
+			es = append(es, a.Element(a.Int(i), a.String("x")))
+		}
+		got := a.Array(
+			a.Element(a.String("list"), a.Array(es...)),
+			a.Element(a.String("obj"), a.Object("Foo", a.Field("b", a.Bool(true), php.VisibilityPrivate))),
+			a.Element(a.String("null"), a.Null()),
+			a.Element(a.String("float"), a.Float(1.5)),
+		)
+
+		var ls []*php.ArrayElement
+		for i := 0; i < 100; i++ {
+			ls = append(ls, php.Element(php.Int(i), php.String("x")))
+		}
+		want := php.Array(
+			php.Element(php.String("list"), php.Array(ls...)),
+			php.Element(php.String("obj"), php.Object("Foo", php.PrivField("b", php.Bool(true)))),
+			php.Element(php.String("null"), php.Null()),
+			php.Element(php.String("float"), php.Float(1.5)),
+		)
+		if !php.Equal(got, want) {
+			t.Errorf("Arena(%v) builds %#v, want: %#v", a, got, want)
+		}
+	}
+}
+
+func TestArenaBytes(t *testing.T) {
+	for _, a := range []*php.Arena{nil, {}} {
+		b := []byte("\x00\xff")
+		v := a.Bytes(b)
+		if got := v.Bytes(); &got[0] != &b[0] {
+			t.Errorf("Arena(%v).Bytes(b).Bytes() does not return b", a)
+		}
+		if !php.Equal(v, php.String("\x00\xff")) || v.String() != "\x00\xff" {
+			t.Errorf("Arena(%v).Bytes(b) == %#v, want a string Value", a, v)
+		}
+		if s, err := v.TryString(); err != nil || s != "\x00\xff" {
+			t.Errorf("TryString() == %q, %v", s, err)
+		}
+		c := v.Clone()
+		b[0] = 'x'
+		if c.String() != "\x00\xff" {
+			t.Errorf("Clone() shares bytes")
+		}
+	}
+	if got := php.String("ab").Bytes(); string(got) != "ab" {
+		t.Errorf(`String("ab").Bytes() == %q, want: "ab"`, got)
+	}
+	if !php.Bytes(nil).IsBytes() || php.String("").IsBytes() || php.Int(1).IsBytes() {
+		t.Errorf("IsBytes() does not tell byte strings apart")
+	}
+}
@@ -0,0 +1,102 @@
+package php
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// Auto returns the PHP Value of the Go value v, choosing the constructor by
+// its type, so that arrays of mixed values can be built without naming one
+// for every element:
+//
+//	php.List(php.Auto(1), php.Auto("a"), php.Auto(nil))
+//
+// nil and nil pointers, slices and maps are null, bools, integers, floats
+// and strings are the PHP values of the same type, and []byte is a string.
+// Unsigned integers beyond the int range are floats, as phpserialize.Marshal
+// encodes them by default. *Value is returned as it is, other pointers are
+// followed, slices and arrays are lists, and maps with integer or string
+// keys are arrays in the key order of phpserialize.Marshal, with string
+// keys converted by Key. Their elements are converted by Auto.
+//
+// Auto panics on other types, such as structs, which phpserialize.Marshal
+// encodes with its options.
+func Auto(v interface{}) *Value {
+	switch x := v.(type) {
+	case nil:
+		return Null()
+	case *Value:
+		return x
+	}
+	return autoValue(reflect.ValueOf(v))
+}
+
+func autoValue(rv reflect.Value) *Value {
+	switch rv.Kind() {
+	case reflect.Bool:
+		return Bool(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Value{t: TypeInt, i: rv.Int()}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := rv.Uint(); u > math.MaxInt64 {
+			return Float(float64(u))
+		}
+		return &Value{t: TypeInt, i: int64(rv.Uint())}
+	case reflect.Float32, reflect.Float64:
+		return Float(rv.Float())
+	case reflect.String:
+		return String(rv.String())
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return Null()
+		}
+		return Auto(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return Null()
+		}
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			return String(string(rv.Bytes()))
+		}
+		vs := make([]*Value, rv.Len())
+		for i := range vs {
+			vs[i] = Auto(rv.Index(i).Interface())
+		}
+		return List(vs...)
+	case reflect.Map:
+		if rv.IsNil() {
+			return Null()
+		}
+		return autoMap(rv)
+	}
+	panic(fmt.Sprintf("php: cannot convert %s to a Value", rv.Type()))
+}
+
+// autoMap converts the map rv to an array with its keys in sorted order, as
+// phpserialize.Marshal does by default.
+func autoMap(rv reflect.Value) *Value {
+	keys := rv.MapKeys()
+	switch rv.Type().Key().Kind() {
+	case reflect.String:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Int() < keys[j].Int() })
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Uint() < keys[j].Uint() })
+	default:
+		panic(fmt.Sprintf("php: cannot convert %s to a Value", rv.Type()))
+	}
+	es := make([]*ArrayElement, len(keys))
+	for i, k := range keys {
+		var key *Value
+		if k.Kind() == reflect.String {
+			key = Key(k.String())
+		} else if key = autoValue(k); key.t != TypeInt {
+			panic(fmt.Sprintf("php: map key %v out of the int range", k))
+		}
+		es[i] = Element(key, Auto(rv.MapIndex(k).Interface()))
+	}
+	return Array(es...)
+}
@@ -0,0 +1,65 @@
+package php_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestAuto(t *testing.T) {
+	var nilPtr *int
+	n := 3
+	cases := []struct {
+		v    interface{}
+		want *php.Value
+	}{
+		{nil, php.Null()},
+		{nilPtr, php.Null()},
+		{[]int(nil), php.Null()},
+		{true, php.Bool(true)},
+		{int8(-8), php.Int(-8)},
+		{int64(math.MinInt64), php.Int(math.MinInt64)},
+		{uint16(16), php.Int(16)},
+		{uint64(math.MaxUint64), php.Float(math.MaxUint64)},
+		{float32(1.5), php.Float(1.5)},
+		{"abc", php.String("abc")},
+		{[]byte("abc"), php.String("abc")},
+		{&n, php.Int(3)},
+		{[]interface{}{1, "a", nil, 2.5}, php.List(php.Int(1), php.String("a"), php.Null(), php.Float(2.5))},
+		{[2]bool{true, false}, php.List(php.Bool(true), php.Bool(false))},
+		{
+			map[string]interface{}{"b": 1, "10": "x", "a": []int{2}, "9": nil},
+			php.Array(
+				php.Element(php.Int(10), php.String("x")),
+				php.Element(php.Int(9), php.Null()),
+				php.Element(php.String("a"), php.List(php.Int(2))),
+				php.Element(php.String("b"), php.Int(1)),
+			),
+		},
+		{map[uint8]string{2: "b", 1: "a"}, php.Array(php.Element(php.Int(1), php.String("a")), php.Element(php.Int(2), php.String("b")))},
+	}
+	for i, tc := range cases {
+		if got := php.Auto(tc.v); !php.Equal(got, tc.want) {
+			t.Errorf("#%d: Auto(%#v) == %#v, want: %#v", i, tc.v, got.Interface(), tc.want.Interface())
+		}
+	}
+
+	v := php.String("x")
+	if got := php.Auto(v); got != v {
+		t.Errorf("Auto(*Value) returns another Value")
+	}
+}
+
+func TestAutoPanics(t *testing.T) {
+	for i, v := range []interface{}{struct{}{}, map[float64]int{1: 1}, []interface{}{make(chan int)}, map[uint64]int{math.MaxUint64: 1}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("#%d: Auto(%#v) does not panic", i, v)
+				}
+			}()
+			php.Auto(v)
+		}()
+	}
+}
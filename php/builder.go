@@ -0,0 +1,110 @@
+package php
+
+// ArrayBuilder builds an array PHP Value with chainable calls:
+//
+//	v := php.NewArrayBuilder().
+//		Set("name", php.String("bob")).
+//		Set("tags", php.List(php.String("a"), php.String("b"))).
+//		Push(php.Int(42)).
+//		Build()
+//
+// The zero value is an empty builder ready to use.
+type ArrayBuilder struct {
+	elements []*ArrayElement
+	index    map[interface{}]int // key -> position in elements
+	next     int64               // key used by Push
+}
+
+// NewArrayBuilder returns an empty ArrayBuilder.
+func NewArrayBuilder() *ArrayBuilder {
+	return &ArrayBuilder{}
+}
+
+// Set sets the value of key, which is converted by Key if it is a string, as
+// PHP would store it. A new key is appended at the end; an existing key
+// keeps its position.
+// It panics if key is not of an integer or string kind.
+func (b *ArrayBuilder) Set(key interface{}, value *Value) *ArrayBuilder {
+	k := orderedKey(key)
+	var index *Value
+	switch kv := k.(type) {
+	case int64:
+		index = Int(int(kv))
+	case string:
+		index = Key(kv)
+		k = index.i
+	}
+	if b.index == nil {
+		b.index = make(map[interface{}]int)
+	}
+	if i, ok := b.index[k]; ok {
+		b.elements[i] = Element(b.elements[i].Index, value)
+		return b
+	}
+	if n, ok := k.(int64); ok && n >= b.next {
+		b.next = n + 1
+	}
+	b.index[k] = len(b.elements)
+	b.elements = append(b.elements, Element(index, value))
+	return b
+}
+
+// Push appends value with the key following the largest int key so far,
+// like $a[] = value in PHP.
+func (b *ArrayBuilder) Push(value *Value) *ArrayBuilder {
+	return b.Set(b.next, value)
+}
+
+// Build returns the array PHP Value. The builder can continue to be used; it
+// does not share memory with the returned Value.
+func (b *ArrayBuilder) Build() *Value {
+	ls := make([]*ArrayElement, len(b.elements))
+	copy(ls, b.elements)
+	return Array(ls...)
+}
+
+// ObjectBuilder builds an object PHP Value with chainable calls:
+//
+//	v := php.NewObjectBuilder(`App\User`).
+//		Pub("name", php.String("bob")).
+//		Prot("role", php.String("admin")).
+//		Priv("secret", php.String("s3cr3t")).
+//		Build()
+type ObjectBuilder struct {
+	obj Obj
+}
+
+// NewObjectBuilder returns an ObjectBuilder of an object of class.
+func NewObjectBuilder(class string) *ObjectBuilder {
+	return &ObjectBuilder{obj: Obj{Name: class}}
+}
+
+// Field sets the field name with visibility vis to value. A field set again
+// with the same name and visibility keeps its position.
+func (b *ObjectBuilder) Field(name string, value *Value, vis Visibility) *ObjectBuilder {
+	b.obj.SetField(name, value, vis)
+	return b
+}
+
+// Pub sets the public field name to value.
+func (b *ObjectBuilder) Pub(name string, value *Value) *ObjectBuilder {
+	return b.Field(name, value, VisibilityPublic)
+}
+
+// Prot sets the protected field name to value.
+func (b *ObjectBuilder) Prot(name string, value *Value) *ObjectBuilder {
+	return b.Field(name, value, VisibilityProtected)
+}
+
+// Priv sets the private field name to value.
+func (b *ObjectBuilder) Priv(name string, value *Value) *ObjectBuilder {
+	return b.Field(name, value, VisibilityPrivate)
+}
+
+// Build returns the object PHP Value. The builder can continue to be used;
+// it does not share memory with the returned Value.
+func (b *ObjectBuilder) Build() *Value {
+	fs := make([]*ObjField, len(b.obj.Fields))
+	copy(fs, b.obj.Fields)
+	return Object(b.obj.Name, fs...)
+}
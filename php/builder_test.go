@@ -0,0 +1,50 @@
+package php_test
+
+import (
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestArrayBuilder(t *testing.T) {
+	b := php.NewArrayBuilder().
+		Set("name", php.String("bob")).
+		Push(php.Int(1)).
+		Set(5, php.Int(5)).
+		Push(php.Int(6)).
+		Set("7", php.Int(7)).
+		Set("name", php.String("alice"))
+	got := b.Build()
+	want := php.Array(
+		php.Element(php.String("name"), php.String("alice")),
+		php.Element(php.Int(0), php.Int(1)),
+		php.Element(php.Int(5), php.Int(5)),
+		php.Element(php.Int(6), php.Int(6)),
+		php.Element(php.Int(7), php.Int(7)),
+	)
+	if !php.Equal(got, want) {
+		t.Errorf("Build() == %#v, want: %#v", got, want)
+	}
+
+	b.Push(php.Null())
+	if n := len(got.Array()); n != 5 {
+		t.Errorf("Push after Build() changes the built value to %d elements", n)
+	}
+}
+
+func TestObjectBuilder(t *testing.T) {
+	got := php.NewObjectBuilder(`App\User`).
+		Pub("name", php.String("bob")).
+		Prot("role", php.String("admin")).
+		Priv("secret", php.String("s3cr3t")).
+		Pub("name", php.String("alice")).
+		Build()
+	want := php.Object(`App\User`,
+		php.PubField("name", php.String("alice")),
+		php.ProtectedField("role", php.String("admin")),
+		php.PrivField("secret", php.String("s3cr3t")),
+	)
+	if !php.Equal(got, want) {
+		t.Errorf("Build() == %#v, want: %#v", got, want)
+	}
+}
@@ -0,0 +1,136 @@
+package php
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AsBool converts v to bool like PHP's (bool) cast: false, 0, 0.0, "",
+// "0", empty arrays and null are false, and everything else is true. It
+// returns false for nil and Missing.
+func (v *Value) AsBool() bool {
+	return truthy(v)
+}
+
+// AsInt converts v to int like PHP's (int) cast. Strings are converted from
+// their leading numeric part, so "12abc" is 12, " 1e3" is 1000 and "abc" is
+// 0. Floats are truncated toward zero, NaN and infinities are 0 and other
+// floats out of the int range wrap around like in PHP on 64-bit platforms,
+// while numeric strings out of the range are capped to it. Arrays are 1 if
+// they have elements and 0 otherwise, objects are 1, and nil, Missing and
+// null are 0.
+func (v *Value) AsInt() int64 {
+	switch compareType(v) {
+	case TypeBool:
+		if v.Bool() {
+			return 1
+		}
+	case TypeInt:
+		return v.Int()
+	case TypeFloat:
+		return wrapInt(v.Float())
+	case TypeString:
+		s, isInt := numericPrefix(v.String())
+		if isInt {
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return i
+			}
+		}
+		return capInt(parsePrefix(s))
+	case TypeArray:
+		if len(v.Array()) > 0 {
+			return 1
+		}
+	case TypeObject:
+		return 1
+	}
+	return 0
+}
+
+// AsFloat converts v to float64 like PHP's (float) cast. Strings are
+// converted from their leading numeric part like AsInt, and other values
+// are converted like AsInt but keep their fraction.
+func (v *Value) AsFloat() float64 {
+	switch compareType(v) {
+	case TypeFloat:
+		return v.Float()
+	case TypeString:
+		s, _ := numericPrefix(v.String())
+		return parsePrefix(s)
+	}
+	return float64(v.AsInt())
+}
+
+// AsString converts v to string like PHP's (string) cast: true is "1",
+// false and null are "", ints and floats are formatted like PHP does with
+// its default precision of 14 digits, and arrays are "Array". It returns ""
+// for objects, which PHP cannot convert without __toString, and for nil and
+// Missing.
+func (v *Value) AsString() string {
+	switch compareType(v) {
+	case TypeBool:
+		if v.Bool() {
+			return "1"
+		}
+	case TypeInt, TypeFloat:
+		return numberString(v)
+	case TypeString:
+		return v.String()
+	case TypeArray:
+		return "Array"
+	}
+	return ""
+}
+
+var numericPrefixPattern = regexp.MustCompile(`^[ \t\n\r\v\f]*([+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?)`)
+
+// numericPrefix returns the leading numeric part of s, without the
+// whitespace before it, and reports whether it is a decimal integer. It
+// returns "" if s does not start with a number.
+func numericPrefix(s string) (string, bool) {
+	m := numericPrefixPattern.FindStringSubmatch(s)
+	if m == nil {
+		return "", false
+	}
+	return m[1], !strings.ContainsAny(m[1], ".eE")
+}
+
+// parsePrefix parses the result of numericPrefix, which overflows to
+// infinity rather than failing.
+func parsePrefix(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// wrapInt converts f to int64 like PHP's zend_dval_to_lval.
+func wrapInt(f float64) int64 {
+	switch {
+	case math.IsNaN(f) || math.IsInf(f, 0):
+		return 0
+	case f >= math.MinInt64 && f < math.MaxInt64:
+		return int64(f)
+	}
+	m := math.Mod(f, 1<<64)
+	if m < 0 {
+		m += 1 << 64
+	}
+	if m >= 1<<63 {
+		m -= 1 << 64
+	}
+	return int64(m)
+}
+
+// capInt converts f to int64 like PHP's zend_dval_to_lval_cap.
+func capInt(f float64) int64 {
+	switch {
+	case math.IsNaN(f) || math.IsInf(f, 0):
+		return 0
+	case f >= math.MaxInt64:
+		return math.MaxInt64
+	case f < math.MinInt64:
+		return math.MinInt64
+	}
+	return int64(f)
+}
@@ -0,0 +1,58 @@
+package php_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestCoerce(t *testing.T) {
+	var nilValue *php.Value
+	cases := []struct {
+		v      *php.Value
+		bool   bool
+		int    int64
+		float  float64
+		string string
+	}{
+		{v: nilValue, string: ""},
+		{v: php.Missing()},
+		{v: php.Null()},
+		{v: php.Bool(true), bool: true, int: 1, float: 1, string: "1"},
+		{v: php.Bool(false)},
+		{v: php.Int(-7), bool: true, int: -7, float: -7, string: "-7"},
+		{v: php.Int(0), string: "0"},
+		{v: php.Float(2.9), bool: true, int: 2, float: 2.9, string: "2.9"},
+		{v: php.Float(-0.1 - 0.2), bool: true, int: 0, float: -0.1 - 0.2, string: "-0.3"},
+		{v: php.Float(1e20), bool: true, int: 7766279631452241920, float: 1e20, string: "1.0E+20"},
+		{v: php.NaN(), bool: true, int: 0, float: math.NaN(), string: "NAN"},
+		{v: php.String(""), string: ""},
+		{v: php.String("0"), int: 0, string: "0"},
+		{v: php.String("0.0"), bool: true, string: "0.0"},
+		{v: php.String("12abc"), bool: true, int: 12, float: 12, string: "12abc"},
+		{v: php.String(" \n-3.5e2x"), bool: true, int: -350, float: -350, string: " \n-3.5e2x"},
+		{v: php.String(".5"), bool: true, int: 0, float: 0.5, string: ".5"},
+		{v: php.String("abc"), bool: true, string: "abc"},
+		{v: php.String("0x1A"), bool: true, string: "0x1A"},
+		{v: php.String("99999999999999999999"), bool: true, int: math.MaxInt64, float: 1e20, string: "99999999999999999999"},
+		{v: php.String("-1e400"), bool: true, int: 0, float: math.Inf(-1), string: "-1e400"},
+		{v: php.Array(), string: "Array"},
+		{v: php.List(php.Int(0)), bool: true, int: 1, float: 1, string: "Array"},
+		{v: php.StdClass(), bool: true, int: 1, float: 1, string: ""},
+	}
+	for i, tc := range cases {
+		if got := tc.v.AsBool(); got != tc.bool {
+			t.Errorf("#%d: AsBool() == %v, want: %v", i, got, tc.bool)
+		}
+		if got := tc.v.AsInt(); got != tc.int {
+			t.Errorf("#%d: AsInt() == %d, want: %d", i, got, tc.int)
+		}
+		if got := tc.v.AsFloat(); got != tc.float && !(math.IsNaN(got) && math.IsNaN(tc.float)) {
+			t.Errorf("#%d: AsFloat() == %v, want: %v", i, got, tc.float)
+		}
+		if got := tc.v.AsString(); got != tc.string {
+			t.Errorf("#%d: AsString() == %q, want: %q", i, got, tc.string)
+		}
+	}
+}
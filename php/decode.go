@@ -0,0 +1,921 @@
+package php
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kamiaka/go-phpserialize/internal/decoding"
+)
+
+// Parse parses the PHP serialized data and returns the value, with the
+// default behavior of Unmarshal of the phpserialize package, for code that
+// only works with Values. Use the phpserialize package for decoding options,
+// streams and decoding into Go types.
+func Parse(data []byte) (*Value, error) {
+	return newDecodeState(data, decoding.Options{}).unmarshal()
+}
+
+func init() {
+	decoding.Register(decoding.Funcs[*Value]{
+		Decode: decode,
+		SPLAsArray: func(v *Value) (*Value, error) {
+			return splArray(v, decoding.Options{})
+		},
+	})
+}
+
+// decode serves a decoding request of the phpserialize package.
+func decode(r *decoding.Request) *decoding.Result[*Value] {
+	d := newDecodeState(r.Data, r.Options)
+	d.ctx = r.Ctx
+	d.recovering = r.Recover
+	if r.Offsets {
+		d.offsets = map[*Value]int{}
+	}
+	res := &decoding.Result[*Value]{Offsets: d.offsets}
+	switch r.Mode {
+	case decoding.Whole:
+		res.Value, res.Err = d.unmarshal()
+	case decoding.Prefix:
+		res.Value, res.Err = d.unmarshalPrefix()
+	}
+	res.N, res.Problems = d.off, d.problems
+	return res
+}
+
+type serializeErr struct {
+	error
+}
+
+type decodeState struct {
+	decoding.Options
+	data     []byte
+	off      int
+	depth    int
+	elements int
+	ownsData bool            // data is not shared with the caller
+	arena    *Arena          // nil unless the arena option is set
+	offsets  map[*Value]int  // start offsets of values, if not nil
+	path     []pathStep      // from the root to the value being read
+	stack    []frame         // arrays and objects being read
+	ctx      context.Context // checked while decoding if not nil
+	ticks    int             // steps counted for checking ctx
+
+	// set in recovery mode
+	recovering bool
+	stopped    bool               // an unrecoverable error ended decoding
+	problems   []decoding.Problem // problems decoded past so far
+}
+
+func newDecodeState(data []byte, opts decoding.Options) *decodeState {
+	d := &decodeState{
+		Options: opts,
+		data:    data,
+	}
+	if opts.Arena {
+		d.arena = &Arena{}
+	}
+	return d
+}
+
+func (d *decodeState) error(format string, args ...interface{}) error {
+	panic(serializeErr{d.errorf(format, args...)})
+}
+
+func (d *decodeState) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("php serialize: %v%s", fmt.Sprintf(format, args...), d.pathSuffix())
+}
+
+// tooLarge reports that the data exceeds a size limit with an error wrapping
+// decoding.ErrTooLarge.
+func (d *decodeState) tooLarge(format string, args ...interface{}) {
+	panic(serializeErr{fmt.Errorf("%w: %s", decoding.ErrTooLarge, fmt.Sprintf(format, args...))})
+}
+
+// eofError reports that the data ended in the middle of a value.
+// The returned error wraps io.ErrUnexpectedEOF so that the streaming Decoder
+// of phpserialize can tell incomplete input from malformed input.
+func (d *decodeState) eofError(format string, args ...interface{}) error {
+	panic(serializeErr{fmt.Errorf("php serialize: %w%s%s", io.ErrUnexpectedEOF, fmt.Sprintf(format, args...), d.pathSuffix())})
+}
+
+// A pathStep is a step from an array or object being read to one of its
+// members.
+type pathStep struct {
+	key  *Value // array key, nil for object fields
+	name string // field name
+}
+
+// pathSuffix returns the path of the value being read for error messages,
+// such as ", path: orders[3].price", or "" at the root.
+func (d *decodeState) pathSuffix() string {
+	if len(d.path) == 0 {
+		return ""
+	}
+	return ", path: " + d.formatPath()
+}
+
+// formatPath formats the path of the value being read like
+// "orders[3].items[0].price".
+func (d *decodeState) formatPath() string {
+	var b strings.Builder
+	for _, p := range d.path {
+		switch {
+		case p.key != nil && p.key.Type() == TypeInt:
+			b.WriteString("[" + strconv.FormatInt(p.key.Int(), 10) + "]")
+		case p.key != nil:
+			p.name = p.key.String()
+			fallthrough
+		default:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(p.name)
+		}
+	}
+	return b.String()
+}
+
+func (d *decodeState) unmarshal() (v *Value, err error) {
+	defer d.recover(&err)
+
+	if d.AllErrors {
+		d.recovering = true
+		d.Lazy = false
+	}
+	if d.MaxBytes > 0 && len(d.data) > d.MaxBytes {
+		d.tooLarge("input size %d exceeds limit of %d bytes", len(d.data), d.MaxBytes)
+	}
+	if d.MaxInputBytes > 0 && int64(len(d.data)) > d.MaxInputBytes {
+		d.tooLarge("input size %d exceeds limit of %d bytes", len(d.data), d.MaxInputBytes)
+	}
+	if d.Lenient {
+		d.data = bytes.TrimRight(d.data, " \t\r\n")
+	}
+	if d.Lazy && !d.ownsData {
+		// lazily decoded values must not share memory with the caller
+		d.data = append([]byte(nil), d.data...)
+	}
+	v = d.readValue()
+	if !d.isEOF() && !d.stopped {
+		d.warn(d.off, "unexpected token: %s, position: %d", []byte{d.data[d.off]}, d.off)
+	}
+	return
+}
+
+// unmarshalPrefix decodes a single value from the head of the data and
+// leaves d.off at the first byte after it.
+func (d *decodeState) unmarshalPrefix() (v *Value, err error) {
+	defer d.recover(&err)
+
+	// semicolons doubled after the previous value of a stream
+	d.skipSemicolons()
+	if d.Lazy && !d.ownsData {
+		// lazily decoded values must not share memory with the caller, so
+		// find the end of the value and decode a copy of it
+		start := d.off
+		d.skipValue()
+		d.data = append([]byte(nil), d.data[:d.off]...)
+		d.off, d.depth, d.elements = start, 0, 0
+	}
+	v = d.readValue()
+	if d.MaxBytes > 0 && d.off > d.MaxBytes {
+		d.tooLarge("value size %d exceeds limit of %d bytes", d.off, d.MaxBytes)
+	}
+	return v, nil
+}
+
+// enter records the start of an array or object with l members, checking the
+// depth and element limits.
+func (d *decodeState) enter(l int) {
+	d.tick()
+	d.depth++
+	if d.MaxDepth > 0 && d.depth > d.MaxDepth {
+		d.error("exceeded max depth of %d, position: %d", d.MaxDepth, d.off)
+	}
+	d.elements += l
+	if d.MaxElements > 0 && d.elements > d.MaxElements {
+		d.error("exceeded max elements of %d, position: %d", d.MaxElements, d.off)
+	}
+}
+
+func (d *decodeState) leave() {
+	d.depth--
+}
+
+func (d *decodeState) recover(err *error) {
+	if r := recover(); r != nil {
+		if e, ok := r.(serializeErr); ok {
+			*err = e.error
+		} else {
+			panic(r)
+		}
+	}
+}
+
+func (d *decodeState) isEOF() bool {
+	return len(d.data) <= d.off
+}
+
+func (d *decodeState) skipEq(str string) {
+	bs := []byte(str)
+	l := len(bs)
+	end := d.off + l
+	got := d.data[d.off:]
+	for i := 0; i < l; i++ {
+		if len(got) <= i {
+			d.eofError(", want: %s, position: %d", str, d.off)
+			return
+		}
+		if bs[i] != got[i] && !(d.Lenient && equalFold(bs[i], got[i])) {
+			d.error("unexpected token %s, position: %d", []byte{got[i]}, end)
+			return
+		}
+	}
+	d.off = end
+}
+
+// readNumber reads the number or scalar at d.off, ending with delim, which
+// may not be longer than decoding.MaxNumberLen bytes.
+func (d *decodeState) readNumber(delim byte) []byte {
+	rest := d.data[d.off:]
+	if len(rest) > decoding.MaxNumberLen {
+		rest = rest[:decoding.MaxNumberLen+1]
+	}
+	i := bytes.IndexByte(rest, delim)
+	switch {
+	case i < 0 && len(rest) > decoding.MaxNumberLen:
+		d.error("number longer than %d bytes, position: %d", decoding.MaxNumberLen, d.off)
+	case i < 0:
+		d.eofError(", want: %s, from position: %d", []byte{delim}, d.off)
+		return nil
+	}
+	end := d.off + i
+	data := d.data[d.off:end]
+	d.off = end + 1
+
+	return data
+}
+
+// equalFold reports whether the ASCII letters a and b differ only in case.
+func equalFold(a, b byte) bool {
+	return a|0x20 == b|0x20 && 'a' <= a|0x20 && a|0x20 <= 'z'
+}
+
+// readValue reads the value at d.off. Arrays and objects are read with an
+// explicit stack of frames rather than by recursion, so that deeply nested
+// data cannot exhaust the goroutine stack.
+func (d *decodeState) readValue() (v *Value) {
+	base := len(d.stack)
+	if d.recovering {
+		if d.stopped {
+			return nil
+		}
+		defer d.salvage(base, len(d.path), d.depth, &v)
+	}
+	for {
+		// read a scalar, or the start of an array or object
+		if len(d.stack) > base && d.Lazy {
+			v = d.readLazyElem()
+		} else {
+			off := d.off
+			if v = d.readValueToken(); v != nil {
+				d.valueEnd(off, v)
+			}
+		}
+		// add it to the innermost array or object, closing the complete ones
+		for len(d.stack) > base {
+			f := &d.stack[len(d.stack)-1]
+			if v != nil {
+				d.addMember(f, v)
+			}
+			if d.nextMember(f) {
+				break
+			}
+			v = d.closeFrame()
+		}
+		if len(d.stack) == base {
+			return v
+		}
+	}
+}
+
+// valueEnd finishes reading the value v that started at off.
+func (d *decodeState) valueEnd(off int, v *Value) {
+	if d.offsets != nil {
+		d.offsets[v] = off
+	}
+	d.skipSemicolons()
+}
+
+// skipSemicolons skips doubled semicolons written by buggy serializers in
+// lenient mode.
+func (d *decodeState) skipSemicolons() {
+	if d.Lenient {
+		for !d.isEOF() && d.data[d.off] == ';' {
+			d.off++
+		}
+	}
+}
+
+// peekToken returns the type token at d.off, folded to the right case in
+// lenient mode, failing at the end of the data.
+func (d *decodeState) peekToken() byte {
+	if d.isEOF() {
+		d.eofError(" in read value type, position: %d", d.off)
+		return 0
+	}
+	c := d.data[d.off]
+	if d.Lenient {
+		c = decoding.FoldToken(c)
+	}
+	return c
+}
+
+// readValueToken reads the scalar at d.off, or pushes a frame for the array
+// or object at d.off and returns nil.
+func (d *decodeState) readValueToken() *Value {
+	switch c := d.peekToken(); c {
+	case 'N':
+		return d.readNil()
+	case 'b':
+		return d.readBool()
+	case 'i':
+		return d.readInt()
+	case 's', 'S':
+		return d.readString()
+	case 'd':
+		return d.readFloat()
+	case 'a':
+		d.openArray()
+		return nil
+	case 'O':
+		d.openObject()
+		return nil
+	case 'C':
+		start := d.off
+		v := d.readCustomObject()
+		if d.SPLAsArrays {
+			v = d.splDecode(v)
+		}
+		return d.opaque(start, v)
+	default:
+		d.error("unexpected token %s at position: %d", []byte{d.data[d.off]}, d.off)
+		return nil
+	}
+}
+
+// A lexeme is a token being read by readParts, part by part as the grammar
+// shared with decoding.Scanner describes it.
+type lexeme struct {
+	token  byte
+	start  int             // offset of the token
+	parts  []decoding.Part // parts left to read
+	n      int             // the last length or count read
+	scalar []byte          // contents of a bool, int or float
+	bodies [2][]byte       // string contents, class name and C: object data
+	nb     int             // number of bodies read
+}
+
+// startToken starts reading the token at d.off, failing if it is not a
+// type token.
+func (d *decodeState) startToken() lexeme {
+	start := d.off
+	c := d.peekToken()
+	parts := decoding.Parts(c)
+	if parts == nil {
+		d.error("unexpected token %s at position: %d", []byte{d.data[d.off]}, d.off)
+	}
+	d.off++
+	return lexeme{token: c, start: start, parts: parts}
+}
+
+// readToken reads the token at d.off.
+func (d *decodeState) readToken() lexeme {
+	l := d.startToken()
+	d.readParts(&l, -1)
+	return l
+}
+
+// readParts reads the parts of l until it holds nb bodies, or all of them
+// if nb is negative.
+func (d *decodeState) readParts(l *lexeme, nb int) {
+	for len(l.parts) > 0 && l.nb != nb {
+		p := l.parts[0]
+		l.parts = l.parts[1:]
+		switch p.Kind {
+		case decoding.PartLit:
+			d.skipEq(p.Lit)
+		case decoding.PartScalar:
+			l.scalar = d.readNumber(';')
+		case decoding.PartLen:
+			l.n = d.readIntBody(':')
+			if d.recovering && l.token == 's' {
+				l.n = d.salvageStrLen(l.n)
+			}
+		case decoding.PartCount:
+			l.n = d.readCount(minMemberSize)
+		case decoding.PartCustomLen:
+			l.n = d.readIntBody(':')
+		case decoding.PartBody:
+			l.bodies[l.nb] = d.readBody(l)
+			l.nb++
+		case decoding.PartEscaped:
+			l.bodies[l.nb] = d.readEscapedBody(l.n)
+			l.nb++
+		}
+	}
+}
+
+func (d *decodeState) readNil() *Value {
+	d.readToken()
+	return d.newNull()
+}
+
+func (d *decodeState) readBool() *Value {
+	l := d.readToken()
+	b, ok := decoding.ParseBool(l.scalar)
+	if !ok {
+		d.error("cannot convert `%s` to bool", l.scalar)
+		return nil
+	}
+	return d.newBool(b)
+}
+
+func (d *decodeState) readInt() *Value {
+	bs := d.readToken().scalar
+	var v *Value
+	i, err := decoding.ParseInt(bs)
+	switch {
+	case err == nil:
+		v = d.newInt(int(i))
+		if d.Int32 && (i < math.MinInt32 || i > math.MaxInt32) {
+			v = d.arena.Float(float64(i))
+		}
+	case errors.Is(err, strconv.ErrRange) && !d.StrictInts:
+		// PHP itself turns integers that overflow into floats.
+		f, _ := strconv.ParseFloat(string(bs), 64)
+		v = d.arena.Float(f)
+	default:
+		d.error("cannot convert `%s` to int: %v", bs, err)
+		return nil
+	}
+	if d.KeepLexemes {
+		v = v.WithLexeme(string(bs))
+	}
+	return v
+}
+
+func (d *decodeState) readIntBody(delim byte) int {
+	bs := d.readNumber(delim)
+	i, err := decoding.ParseLength(bs)
+	if err != nil {
+		d.error("cannot convert `%s` to int: %v", bs, err)
+		return 0
+	}
+	return i
+}
+
+func (d *decodeState) readFloat() *Value {
+	bs := d.readToken().scalar
+	f, err := decoding.ParseFloat(bs)
+	if err != nil {
+		d.error("cannot convert `%s` to float: %v", bs, err)
+		return nil
+	}
+	if d.KeepLexemes {
+		return d.arena.Float(f).WithLexeme(string(bs))
+	}
+	return d.arena.Float(f)
+}
+
+func (d *decodeState) readString() *Value {
+	bs := d.readStringBytes()
+	if d.StringBytes {
+		return d.newBytes(bs)
+	}
+	return d.newString(string(bs))
+}
+
+// readStringBytes reads an s: or S: string. The contents of an s: string
+// are not copied from d.data.
+func (d *decodeState) readStringBytes() []byte {
+	off := d.off
+	if c := d.peekToken(); c != 's' && c != 'S' {
+		d.error("unexpected token %s, position: %d", []byte{d.data[d.off]}, d.off)
+	}
+	s := d.readToken().bodies[0]
+	d.checkUTF8(s, off)
+	return s
+}
+
+// readBody reads the string body or C: object data of l.n bytes.
+func (d *decodeState) readBody(l *lexeme) []byte {
+	custom := l.token == 'C' && l.nb == 1
+	if l.n < 0 && custom {
+		d.error("invalid custom object length %d, position: %d", l.n, d.off)
+	}
+	if l.n < 0 {
+		d.error("invalid string length %d, position: %d", l.n, d.off)
+	}
+	if len(d.data)-d.off < l.n {
+		if custom {
+			d.eofError(" in custom object data, from: %d, length: %d", d.off, l.n)
+		}
+		d.eofError(" in string body, from: %d, length: %d", d.off, l.n)
+		return nil
+	}
+	end := d.off + l.n
+	bs := d.data[d.off:end:end]
+	d.off = end
+	return bs
+}
+
+// readEscapedBody reads the body of an S: string of length bytes, in which
+// a backslash followed by two hex digits stands for one byte.
+func (d *decodeState) readEscapedBody(length int) []byte {
+	if length < 0 {
+		d.error("invalid string length %d, position: %d", length, d.off)
+	}
+	if len(d.data)-d.off < length {
+		d.eofError(" in string body, from: %d, length: %d", d.off, length)
+		return nil
+	}
+	bs := make([]byte, 0, length)
+	for len(bs) < length {
+		if d.isEOF() {
+			d.eofError(" in string body, position: %d", d.off)
+			return nil
+		}
+		c := d.data[d.off]
+		if c == '\\' {
+			if len(d.data)-d.off < 3 {
+				d.eofError(" in string escape, position: %d", d.off)
+				return nil
+			}
+			x, ok := decoding.Unhex(d.data[d.off+1 : d.off+3])
+			if !ok {
+				d.error("invalid string escape %q, position: %d", d.data[d.off:d.off+3], d.off)
+			}
+			c = x
+			d.off += 3
+		} else {
+			d.off++
+		}
+		bs = append(bs, c)
+	}
+	return bs
+}
+
+// minMemberSize is the minimum serialized size of an array element or an
+// object field, such as "i:0;N;".
+const minMemberSize = 6
+
+// maxPrealloc is the largest number of array elements or object fields
+// allocated before they are read.
+const maxPrealloc = 1024
+
+func preallocSize(l int) int {
+	if l > maxPrealloc {
+		return maxPrealloc
+	}
+	return l
+}
+
+// readCount reads the member count of an array or object and checks that
+// the rest of the data can hold that many members of at least size bytes,
+// so that a forged count cannot make the decoder do excessive work.
+func (d *decodeState) readCount(size int) int {
+	start := d.off
+	l := d.readIntBody(':')
+	if l < 0 {
+		d.error("invalid count %d, position: %d", l, start)
+	}
+	if rest := len(d.data) - d.off; l > rest/size && !d.recovering {
+		// in recovery mode, the closing brace ends the members instead
+		d.eofError(" in reading %d members from %d bytes, position: %d", l, rest, start)
+	}
+	return l
+}
+
+// A frame is an array or object being read by readValue.
+type frame struct {
+	start   int // offset of the array or object
+	l, i    int // declared and read numbers of members
+	pathLen int // length of d.path outside the members
+
+	object  bool
+	name    string // class name of an object
+	asArray bool   // the object is decoded as an array
+	allowed bool   // the class of the object is allowed
+
+	elems  []*ArrayElement
+	index  map[interface{}]int // key -> position in elems, for large arrays
+	fields []*ObjField
+
+	// the member being read
+	key      *Value
+	keyStart int
+	mangled  string
+	field    string
+	vis      Visibility
+	class    string // declaring class of a private field of a parent class
+}
+
+func (d *decodeState) openArray() {
+	t := d.readToken()
+	l := t.n
+	d.enter(l)
+	d.stack = append(d.stack, frame{
+		start:   t.start,
+		l:       l,
+		pathLen: len(d.path),
+		elems:   make([]*ArrayElement, 0, preallocSize(l)),
+	})
+}
+
+func (d *decodeState) openObject() {
+	t := d.readToken()
+	name, l := string(t.bodies[0]), t.n
+	d.enter(l)
+
+	f := frame{start: t.start, l: l, pathLen: len(d.path), object: true, name: name}
+	f.asArray = d.ObjectsAsArrays || d.StdClassAsArray && name == "stdClass"
+	f.allowed = f.asArray || d.allowClass(name)
+	if f.asArray {
+		f.elems = make([]*ArrayElement, 0, preallocSize(l+1))
+		if d.ClassKey != "" {
+			f.elems = append(f.elems, d.arena.Element(d.newString(d.ClassKey), d.newString(name)))
+		}
+	} else {
+		f.fields = make([]*ObjField, 0, preallocSize(l))
+	}
+	d.stack = append(d.stack, f)
+}
+
+// nextMember reads the key or property name of the next member of f and
+// reports whether there is one.
+func (d *decodeState) nextMember(f *frame) bool {
+	if !d.more(f.i, f.l) {
+		return false
+	}
+	if !f.object {
+		f.keyStart = d.off
+		f.key = d.readKey()
+		d.path = append(d.path, pathStep{key: f.key})
+		return true
+	}
+	mangled := d.readPropertyName()
+	name, vis, class := DemangleName(mangled)
+	if vis == VisibilityPublic && strings.HasPrefix(mangled, "\x00") {
+		d.error("invalid field name: %s", mangled)
+	}
+	if class == f.name {
+		class = ""
+	}
+	f.mangled, f.field, f.vis, f.class = mangled, name, vis, class
+	d.path = append(d.path, pathStep{name: name})
+	return true
+}
+
+// addMember adds the value v of the member of f being read.
+func (d *decodeState) addMember(f *frame, v *Value) {
+	d.path = d.path[:len(d.path)-1]
+	f.i++
+	switch {
+	case !f.object:
+		if j := findKey(f.elems, &f.index, f.key); j >= 0 {
+			if d.StrictKeys {
+				d.warn(f.keyStart, "duplicate array key %v, position: %d", f.key.Interface(), f.keyStart)
+			}
+			// like PHP, the last value wins at the first position
+			f.elems[j].Value = v
+			return
+		}
+		f.elems = append(f.elems, d.arena.Element(f.key, v))
+	case f.asArray:
+		// like PHP's (array) cast, keep non-public names mangled and turn
+		// integer-like names into int keys
+		if d.ClassKey != "" && f.mangled == d.ClassKey {
+			f.elems[0].Value = v // a property of the same name wins
+			return
+		}
+		f.elems = append(f.elems, d.arena.Element(d.propertyKey(f.mangled), v))
+	default:
+		field := d.arena.Field(f.field, v, f.vis)
+		field.Class = f.class
+		f.fields = append(f.fields, field)
+	}
+}
+
+// closeFrame pops the innermost frame and returns its array or object,
+// which is left partial if d is stopped.
+func (d *decodeState) closeFrame() *Value {
+	f := d.stack[len(d.stack)-1]
+	d.stack[len(d.stack)-1] = frame{}
+	d.stack = d.stack[:len(d.stack)-1]
+	d.leave()
+
+	var v *Value
+	switch {
+	case !f.object || f.asArray:
+		v = d.arena.Array(f.elems...)
+	case !f.allowed:
+		// like PHP, keep the object as an incomplete class recording its name
+		v = d.arena.Object(IncompleteClass, append([]*ObjField{d.incompleteClassMarker(f.name)}, f.fields...)...)
+	default:
+		v = d.arena.Object(f.name, f.fields...)
+	}
+	if d.stopped {
+		return v
+	}
+	d.skipEq("}")
+	if f.object {
+		if d.SPLAsArrays {
+			v = d.splDecode(v)
+		}
+		v = d.opaque(f.start, v)
+	}
+	d.valueEnd(f.start, v)
+	return v
+}
+
+// findKey returns the position of the key k in ls, or -1 if it is not in
+// ls. Small arrays are scanned; for larger ones, *index is built and kept up
+// to date with the element about to be appended when k is not found.
+func findKey(ls []*ArrayElement, index *map[interface{}]int, k *Value) int {
+	const scanLimit = 8
+	key := k.Interface()
+	if *index == nil {
+		if len(ls) < scanLimit {
+			for j, e := range ls {
+				if e.Index.Interface() == key {
+					return j
+				}
+			}
+			return -1
+		}
+		*index = make(map[interface{}]int, len(ls)+1)
+		for j, e := range ls {
+			(*index)[e.Index.Interface()] = j
+		}
+	}
+	if j, ok := (*index)[key]; ok {
+		return j
+	}
+	(*index)[key] = len(ls)
+	return -1
+}
+
+// readKey reads an array key, which must be an int or a string unless
+// castKeys is set.
+func (d *decodeState) readKey() *Value {
+	off := d.off
+	switch d.peekToken() {
+	case 'a':
+		d.error("invalid array key type: %v", TypeArray)
+	case 'O', 'C':
+		d.error("invalid array key type: %v", TypeObject)
+	}
+	v := d.readValueToken()
+	d.valueEnd(off, v)
+	switch v.Type() {
+	case TypeString:
+		if i, ok := decoding.NumericKey(v.String(), d.Int32); ok && !d.VerbatimKeys {
+			return d.newInt(int(i))
+		}
+		if d.StringBytes {
+			// keys are compared and hashed as Go strings
+			return d.newString(v.String())
+		}
+		return v
+	case TypeInt:
+		return v
+	case TypeBool, TypeFloat, TypeNull:
+		if d.CastKeys {
+			return d.castKey(off, v)
+		}
+		fallthrough
+	default:
+		d.error("invalid array key type: %v", v.Type())
+		return nil
+	}
+}
+
+// castKey returns the key PHP stores the bool, float or null key v read at
+// off as: bools and floats are cast to int and null to "".
+func (d *decodeState) castKey(off int, v *Value) *Value {
+	switch v.Type() {
+	case TypeBool:
+		if v.Bool() {
+			return d.newInt(1)
+		}
+		return d.newInt(0)
+	case TypeFloat:
+		i, ok := decoding.FloatToInt(v.Float())
+		if !ok || d.Int32 && (i < math.MinInt32 || i > math.MaxInt32) {
+			d.error("float array key %v out of the int range, position: %d", v.Float(), off)
+		}
+		return d.newInt(int(i))
+	}
+	return d.newString("")
+}
+
+// allowClass reports whether objects of the class name may be decoded as
+// they are under the allowedClasses option. Objects of other classes are
+// decoded as incomplete class objects, or fail if rejectClasses is set.
+func (d *decodeState) allowClass(name string) bool {
+	if d.AllowedClasses == nil || d.AllowedClasses[strings.ToLower(name)] {
+		return true
+	}
+	if d.RejectClasses {
+		d.error("class %s is not allowed, position: %d", name, d.off)
+	}
+	return false
+}
+
+// incompleteClassMarker returns the first field of an incomplete class object
+// of the class name.
+func (d *decodeState) incompleteClassMarker(name string) *ObjField {
+	return d.arena.Field(IncompleteClassNameField, d.arena.String(name), VisibilityPublic)
+}
+
+// readCustomObject reads a C: object written by a class implementing the
+// Serializable interface, keeping its data as it is.
+func (d *decodeState) readCustomObject() *Value {
+	t := d.startToken()
+	d.readParts(&t, 1)
+	name := string(t.bodies[0])
+	allowed := d.allowClass(name)
+	d.readParts(&t, -1)
+	v := d.arena.CustomObject(name, append([]byte{}, t.bodies[1]...))
+	if !allowed {
+		// keep the data, which only the class can interpret
+		obj := v.Object()
+		obj.Name, obj.Fields = IncompleteClass, []*ObjField{d.incompleteClassMarker(name)}
+	}
+	return v
+}
+
+// readPropertyName reads the name of an object field. Like PHP, it accepts
+// integer names, which objects such as SplFixedArray are written with.
+func (d *decodeState) readPropertyName() string {
+	if !d.isEOF() && d.data[d.off] == 'i' {
+		bs := d.readToken().scalar
+		i, err := strconv.ParseInt(string(bs), 10, 64)
+		if err != nil {
+			d.error("invalid field name: %s", bs)
+		}
+		return strconv.FormatInt(i, 10)
+	}
+	return string(d.readStringBytes())
+}
+
+// propertyKey returns the array key of the property name s.
+func (d *decodeState) propertyKey(s string) *Value {
+	if i, err := strconv.Atoi(s); err == nil && strconv.Itoa(i) == s {
+		return d.newInt(i)
+	}
+	return d.newString(s)
+}
+
+// tick counts a step of decoding and checks d.ctx every
+// decoding.ContextCheckInterval steps.
+func (d *decodeState) tick() {
+	if d.ctx == nil {
+		return
+	}
+	d.ticks++
+	if d.ticks%decoding.ContextCheckInterval == 0 {
+		if err := d.ctx.Err(); err != nil {
+			panic(serializeErr{fmt.Errorf("php serialize: %w, position: %d", err, d.off)})
+		}
+	}
+}
+
+// checkUTF8 reports an error if the ValidUTF8 option is set and the string
+// s read at position off is not valid UTF-8.
+func (d *decodeState) checkUTF8(s []byte, off int) {
+	if d.ValidUTF8 && !utf8.Valid(s) {
+		d.warn(off, "invalid UTF-8 in string at position %d", off)
+	}
+}
+
+// opaque returns a closure v read from d.data[start:d.off] as a Value that
+// holds a copy of those bytes, and any other v as it is.
+func (d *decodeState) opaque(start int, v *Value) *Value {
+	if v.Type() != TypeObject || !decoding.ClosureClasses[strings.ToLower(v.Object().Name)] {
+		return v
+	}
+	data := append([]byte(nil), d.data[start:d.off]...)
+	return Raw(data, func([]byte) (*Value, error) {
+		return v, nil
+	})
+}
@@ -0,0 +1,150 @@
+package php
+
+import "strconv"
+
+// DiffKind is the kind of a Difference.
+type DiffKind uint
+
+// DiffKinds
+const (
+	// DiffChanged reports a value replaced by a different one. Values of
+	// different types, objects of different classes, objects serialized by
+	// Serializable and fields of different visibility are reported as
+	// changed as a whole.
+	DiffChanged DiffKind = iota
+	// DiffAdded reports an array element or object field only in the second
+	// value.
+	DiffAdded
+	// DiffRemoved reports an array element or object field only in the first
+	// value.
+	DiffRemoved
+	// DiffReordered reports an array or object whose common keys or fields
+	// are in a different order.
+	DiffReordered
+)
+
+var diffKindNames = []string{
+	DiffChanged:   "changed",
+	DiffAdded:     "added",
+	DiffRemoved:   "removed",
+	DiffReordered: "reordered",
+}
+
+func (k DiffKind) String() string {
+	if int(k) < len(diffKindNames) {
+		return diffKindNames[k]
+	}
+	return "DiffKind" + strconv.Itoa(int(k))
+}
+
+// Difference is a difference found by Diff.
+type Difference struct {
+	// Path locates the value from the root, formatted like Path.String,
+	// such as `["users"][0]->name`. The root has the empty path.
+	Path string
+	Kind DiffKind
+	// Old and New are the values in the first and second value, nil for
+	// added and removed values respectively.
+	Old, New *Value
+}
+
+// Diff returns the differences between a and b, in the order of their paths
+// in a followed by the paths only in b. Values are compared as by Equal;
+// Diff returns no differences exactly when Equal(a, b) is true.
+func Diff(a, b *Value) []Difference {
+	var ds []Difference
+	diff(&ds, "", a, b)
+	return ds
+}
+
+type diffEntry struct {
+	key   string
+	value *Value
+	vis   Visibility
+}
+
+func diff(ds *[]Difference, path string, a, b *Value) {
+	if Equal(a, b) {
+		return
+	}
+	if a.IsNil() || b.IsNil() || a.Type() != b.Type() {
+		*ds = append(*ds, Difference{Path: path, Kind: DiffChanged, Old: a, New: b})
+		return
+	}
+	var x, y []diffEntry
+	switch a.Type() {
+	case TypeArray:
+		x, y = arrayEntries(a.Array()), arrayEntries(b.Array())
+	case TypeObject:
+		if a.Object().Name != b.Object().Name || a.Object().Serialized != nil || b.Object().Serialized != nil {
+			*ds = append(*ds, Difference{Path: path, Kind: DiffChanged, Old: a, New: b})
+			return
+		}
+		x, y = fieldEntries(a.Object().Fields), fieldEntries(b.Object().Fields)
+	default:
+		*ds = append(*ds, Difference{Path: path, Kind: DiffChanged, Old: a, New: b})
+		return
+	}
+
+	pos := make(map[string]int, len(y))
+	for i, e := range y {
+		pos[e.key] = i
+	}
+	last, reordered, common := -1, false, 0
+	for _, e := range x {
+		i, ok := pos[e.key]
+		if !ok {
+			continue
+		}
+		if i < last {
+			reordered = true
+		}
+		last = i
+		common++
+	}
+	if reordered {
+		*ds = append(*ds, Difference{Path: path, Kind: DiffReordered, Old: a, New: b})
+	}
+
+	for _, e := range x {
+		i, ok := pos[e.key]
+		if !ok {
+			*ds = append(*ds, Difference{Path: path + e.key, Kind: DiffRemoved, Old: e.value})
+			continue
+		}
+		f := y[i]
+		if e.vis != f.vis {
+			*ds = append(*ds, Difference{Path: path + e.key, Kind: DiffChanged, Old: e.value, New: f.value})
+			continue
+		}
+		diff(ds, path+e.key, e.value, f.value)
+	}
+	if common == len(y) {
+		return
+	}
+	inX := make(map[string]bool, len(x))
+	for _, e := range x {
+		inX[e.key] = true
+	}
+	for _, f := range y {
+		if !inX[f.key] {
+			*ds = append(*ds, Difference{Path: path + f.key, Kind: DiffAdded, New: f.value})
+		}
+	}
+}
+
+func arrayEntries(arr []*ArrayElement) []diffEntry {
+	es := make([]diffEntry, len(arr))
+	for i, e := range arr {
+		es[i] = diffEntry{key: keyPath(e.Index), value: e.Value}
+	}
+	return es
+}
+
+func fieldEntries(fields []*ObjField) []diffEntry {
+	es := make([]diffEntry, len(fields))
+	for i, f := range fields {
+		es[i] = diffEntry{key: fieldPath(f.Name), value: f.Value, vis: f.Visibility}
+	}
+	return es
+}
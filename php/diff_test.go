@@ -0,0 +1,83 @@
+package php_test
+
+import (
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestDiff(t *testing.T) {
+	type diff struct {
+		path string
+		kind php.DiffKind
+	}
+	cases := []struct {
+		a, b *php.Value
+		want []diff
+	}{
+		{a: php.Int(1), b: php.Int(1)},
+		{a: php.NaN(), b: php.NaN()},
+		{a: php.Int(1), b: php.Float(1), want: []diff{{"", php.DiffChanged}}},
+		{a: php.Null(), b: php.String(""), want: []diff{{"", php.DiffChanged}}},
+		{
+			a: php.Assoc(map[string]*php.Value{"a": php.Int(1), "b": php.Int(2), "c": php.List(php.Int(1))}),
+			b: php.Assoc(map[string]*php.Value{"a": php.Int(1), "c": php.List(php.Int(2), php.Int(3)), "d": php.Int(4)}),
+			want: []diff{
+				{`["b"]`, php.DiffRemoved},
+				{`["c"][0]`, php.DiffChanged},
+				{`["c"][1]`, php.DiffAdded},
+				{`["d"]`, php.DiffAdded},
+			},
+		},
+		{
+			a:    php.Array(php.Element(php.Int(1), php.Null()), php.Element(php.String("1"), php.Null())),
+			b:    php.Array(php.Element(php.String("1"), php.Null())),
+			want: []diff{{"[1]", php.DiffRemoved}},
+		},
+		{
+			a:    php.Array(php.Element(php.Int(1), php.Null()), php.Element(php.Int(0), php.Null())),
+			b:    php.List(php.Null(), php.Bool(true)),
+			want: []diff{{"", php.DiffReordered}, {"[1]", php.DiffChanged}},
+		},
+		{
+			a: php.Object("User",
+				php.PubField("name", php.String("bob")),
+				php.PubField("age", php.Int(1)),
+				php.PubField("tags", php.List()),
+			),
+			b: php.Object("User",
+				php.PubField("name", php.String("ann")),
+				php.ProtectedField("age", php.Int(1)),
+				php.PubField("tags", php.List()),
+			),
+			want: []diff{{"->name", php.DiffChanged}, {"->age", php.DiffChanged}},
+		},
+		{
+			a:    php.List(php.Object("A")),
+			b:    php.List(php.Object("B")),
+			want: []diff{{"[0]", php.DiffChanged}},
+		},
+	}
+	for i, tc := range cases {
+		ds := php.Diff(tc.a, tc.b)
+		got := make([]diff, len(ds))
+		for j, d := range ds {
+			got[j] = diff{d.Path, d.Kind}
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("#%d: Diff(...) == %v, want: %v", i, got, tc.want)
+			continue
+		}
+		for j := range got {
+			if got[j] != tc.want[j] {
+				t.Errorf("#%d: Diff(...) == %v, want: %v", i, got, tc.want)
+				break
+			}
+		}
+	}
+
+	ds := php.Diff(php.List(php.Int(1)), php.List(php.Int(2)))
+	if len(ds) != 1 || ds[0].Old.Int() != 1 || ds[0].New.Int() != 2 {
+		t.Errorf("Diff([1], [2]) == %+v, want: changed 1 to 2", ds)
+	}
+}
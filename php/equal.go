@@ -0,0 +1,71 @@
+package php
+
+import "math"
+
+// Equal reports whether a and b are semantically equal PHP values.
+//
+// Unlike reflect.DeepEqual, NaN is equal to NaN, array keys are compared by
+// value rather than by pointer, and nil is equal to a null Value. Arrays are
+// equal if they hold the same keys with equal values in the same order, and
+// objects are equal if their class names and fields, including visibility,
+// are equal in the same order, or their Serialized data are equal.
+func Equal(a, b *Value) bool {
+	// pairs of values left to compare, kept in an explicit stack rather than
+	// by recursion so that deeply nested values cannot exhaust the goroutine
+	// stack
+	stack := [][2]*Value{{a, b}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		var ok bool
+		if stack, ok = equalShallow(p[0], p[1], stack); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// equalShallow reports whether a and b are equal but for the members of
+// arrays and objects, which it pushes on stack in pairs to be compared.
+func equalShallow(a, b *Value, stack [][2]*Value) ([][2]*Value, bool) {
+	if a.IsNil() || b.IsNil() {
+		return stack, a.IsNil() && b.IsNil()
+	}
+	if a.t != b.t {
+		return stack, false
+	}
+	switch a.t {
+	case TypeString:
+		return stack, a.String() == b.String()
+	case TypeFloat:
+		x, y := a.Float(), b.Float()
+		return stack, x == y || math.IsNaN(x) && math.IsNaN(y)
+	case TypeArray:
+		x, y := a.Array(), b.Array()
+		if len(x) != len(y) {
+			return stack, false
+		}
+		for i := range x {
+			stack = append(stack, [2]*Value{x[i].Index, y[i].Index}, [2]*Value{x[i].Value, y[i].Value})
+		}
+		return stack, true
+	case TypeObject:
+		x, y := a.Object(), b.Object()
+		if x.Name != y.Name || len(x.Fields) != len(y.Fields) {
+			return stack, false
+		}
+		if x.Serialized != nil || y.Serialized != nil {
+			return stack, x.Serialized != nil && y.Serialized != nil && string(x.Serialized) == string(y.Serialized)
+		}
+		for i, f := range x.Fields {
+			g := y.Fields[i]
+			if f.MangledName(x.Name) != g.MangledName(y.Name) {
+				return stack, false
+			}
+			stack = append(stack, [2]*Value{f.Value, g.Value})
+		}
+		return stack, true
+	default:
+		return stack, a.i == b.i
+	}
+}
@@ -0,0 +1,45 @@
+package php_test
+
+import (
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestEqual(t *testing.T) {
+	cases := []struct {
+		a, b *php.Value
+		want bool
+	}{
+		{a: nil, b: php.Null(), want: true},
+		{a: php.NaN(), b: php.NaN(), want: true},
+		{a: php.Int(1), b: php.Int(1), want: true},
+		{a: php.Int(1), b: php.Float(1), want: false},
+		{a: php.String("a"), b: php.String("b"), want: false},
+		{
+			a:    php.Array(php.Element(php.String("k"), php.NaN())),
+			b:    php.Array(php.Element(php.String("k"), php.NaN())),
+			want: true,
+		},
+		{
+			a:    php.Array(php.Element(php.Int(0), php.Int(1)), php.Element(php.Int(1), php.Int(2))),
+			b:    php.Array(php.Element(php.Int(1), php.Int(2)), php.Element(php.Int(0), php.Int(1))),
+			want: false,
+		},
+		{
+			a:    php.Object("Foo", php.PubField("a", php.Int(1))),
+			b:    php.Object("Foo", php.PubField("a", php.Int(1))),
+			want: true,
+		},
+		{
+			a:    php.Object("Foo", php.PubField("a", php.Int(1))),
+			b:    php.Object("Foo", php.PrivField("a", php.Int(1))),
+			want: false,
+		},
+	}
+	for i, tc := range cases {
+		if got := php.Equal(tc.a, tc.b); got != tc.want {
+			t.Errorf("#%d: Equal(%#v, %#v) == %v, want: %v", i, tc.a, tc.b, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package php
+
+// The range of ints decoded to shared Values with the intern option.
+const (
+	minInternedInt = -128
+	maxInternedInt = 1023
+)
+
+// Values shared by all values decoded with the intern option.
+var (
+	internedNull  = Null()
+	internedFalse = Bool(false)
+	internedTrue  = Bool(true)
+	internedEmpty = String("")
+	internedInts  = func() []*Value {
+		vs := make([]*Value, maxInternedInt-minInternedInt+1)
+		for i := range vs {
+			vs[i] = Int(i + minInternedInt)
+		}
+		return vs
+	}()
+)
+
+func (d *decodeState) newNull() *Value {
+	if d.Intern {
+		return internedNull
+	}
+	return d.arena.Null()
+}
+
+func (d *decodeState) newBool(b bool) *Value {
+	switch {
+	case !d.Intern:
+		return d.arena.Bool(b)
+	case b:
+		return internedTrue
+	}
+	return internedFalse
+}
+
+func (d *decodeState) newInt(i int) *Value {
+	if d.Intern && minInternedInt <= i && i <= maxInternedInt {
+		return internedInts[i-minInternedInt]
+	}
+	return d.arena.Int(i)
+}
+
+func (d *decodeState) newString(s string) *Value {
+	if d.Intern && s == "" {
+		return internedEmpty
+	}
+	return d.arena.String(s)
+}
+
+func (d *decodeState) newBytes(bs []byte) *Value {
+	if d.Intern && len(bs) == 0 {
+		return internedEmpty
+	}
+	return d.arena.Bytes(bs)
+}
@@ -0,0 +1,217 @@
+package php
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// ClassKey is the JSON object key that holds the class name of an object
+// Value.
+const ClassKey = "__class"
+
+// MarshalJSON implements json.Marshaler.
+//
+// null, bool, int, float and string Values become the corresponding JSON
+// values, except that NaN and infinities are written as the strings "NAN",
+// "INF" and "-INF". Arrays whose keys are 0, 1, 2, ... in order become JSON
+// arrays, other arrays become JSON objects with their keys in order, and
+// objects become JSON objects whose first key is ClassKey holding the class
+// name, followed by the fields. Field visibility is not preserved.
+func (v *Value) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, v *Value) error {
+	if v.IsNil() {
+		buf.WriteString("null")
+		return nil
+	}
+	switch v.t {
+	case TypeBool:
+		buf.WriteString(strconv.FormatBool(v.Bool()))
+	case TypeInt:
+		buf.WriteString(strconv.FormatInt(v.Int(), 10))
+	case TypeFloat:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			buf.WriteString(`"NAN"`)
+		case math.IsInf(f, 1):
+			buf.WriteString(`"INF"`)
+		case math.IsInf(f, -1):
+			buf.WriteString(`"-INF"`)
+		default:
+			bs, err := json.Marshal(f)
+			if err != nil {
+				return err
+			}
+			buf.Write(bs)
+		}
+	case TypeString:
+		writeJSONString(buf, v.String())
+	case TypeArray:
+		arr := v.Array()
+		if v.IsList() {
+			buf.WriteByte('[')
+			for i, e := range arr {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				if err := writeJSON(buf, e.Value); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte(']')
+			return nil
+		}
+		buf.WriteByte('{')
+		for i, e := range arr {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if e.Index.t == TypeInt {
+				writeJSONString(buf, strconv.FormatInt(e.Index.Int(), 10))
+			} else {
+				writeJSONString(buf, e.Index.String())
+			}
+			buf.WriteByte(':')
+			if err := writeJSON(buf, e.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case TypeObject:
+		obj := v.Object()
+		buf.WriteString(`{"` + ClassKey + `":`)
+		writeJSONString(buf, obj.Name)
+		for _, f := range obj.Fields {
+			buf.WriteByte(',')
+			writeJSONString(buf, f.Name)
+			buf.WriteByte(':')
+			if err := writeJSON(buf, f.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("php: cannot marshal %v Value to JSON", v.t)
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	bs, _ := json.Marshal(s)
+	buf.Write(bs)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reading the representation
+// written by MarshalJSON.
+//
+// JSON numbers without a fraction or exponent that fit in int64 become int
+// Values, other numbers become floats. JSON objects having ClassKey become
+// objects with public fields, other JSON objects become arrays in which keys
+// that are decimal integers are converted to int keys, as PHP does.
+// The strings "NAN", "INF" and "-INF" are kept as strings.
+func (v *Value) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	nv, err := readJSON(dec)
+	if err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("php: invalid data after top-level JSON value")
+	}
+	*v = *nv
+	return nil
+}
+
+func readJSON(dec *json.Decoder) (*Value, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case nil:
+		return Null(), nil
+	case bool:
+		return Bool(tok), nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(tok), 10, 64); err == nil {
+			return Int(int(i)), nil
+		}
+		f, err := tok.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return Float(f), nil
+	case string:
+		return String(tok), nil
+	case json.Delim:
+		if tok == '[' {
+			var ls []*ArrayElement
+			for i := 0; dec.More(); i++ {
+				e, err := readJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				ls = append(ls, Element(Int(i), e))
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return Array(ls...), nil
+		}
+		return readJSONObject(dec)
+	}
+	return nil, fmt.Errorf("php: unexpected JSON token %v", tok)
+}
+
+func readJSONObject(dec *json.Decoder) (*Value, error) {
+	var (
+		ls       []*ArrayElement
+		class    string
+		hasClass bool
+	)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := tok.(string)
+		val, err := readJSON(dec)
+		if err != nil {
+			return nil, err
+		}
+		if key == ClassKey && !hasClass && val.t == TypeString {
+			class = val.String()
+			hasClass = true
+			continue
+		}
+		ls = append(ls, Element(Key(key), val))
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	if !hasClass {
+		return Array(ls...), nil
+	}
+	fields := make([]*ObjField, len(ls))
+	for i, e := range ls {
+		name := e.Index.String()
+		if e.Index.t == TypeInt {
+			name = strconv.FormatInt(e.Index.Int(), 10)
+		}
+		fields[i] = PubField(name, e.Value)
+	}
+	return Object(class, fields...), nil
+}
@@ -0,0 +1,61 @@
+package php_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestValueJSON(t *testing.T) {
+	cases := []struct {
+		val  *php.Value
+		json string
+	}{
+		{val: php.Null(), json: `null`},
+		{val: php.Bool(true), json: `true`},
+		{val: php.Int(42), json: `42`},
+		{val: php.Float(1.5), json: `1.5`},
+		{val: php.String("a\"b"), json: `"a\"b"`},
+		{val: php.Append(php.Array(), php.Int(1), php.String("x")), json: `[1,"x"]`},
+		{
+			val: php.Array(
+				php.Element(php.String("b"), php.Null()),
+				php.Element(php.Int(3), php.Bool(false)),
+			),
+			json: `{"b":null,"3":false}`,
+		},
+		{
+			val:  php.Object("Foo", php.PubField("a", php.Int(1)), php.PubField("b", php.Array())),
+			json: `{"__class":"Foo","a":1,"b":[]}`,
+		},
+	}
+	for i, tc := range cases {
+		got, err := json.Marshal(tc.val)
+		if err != nil {
+			t.Fatalf("#%d: json.Marshal(...) returns error: %v", i, err)
+		}
+		if string(got) != tc.json {
+			t.Errorf("#%d: json.Marshal(...) == %s, want: %s", i, got, tc.json)
+		}
+
+		v := new(php.Value)
+		if err := json.Unmarshal([]byte(tc.json), v); err != nil {
+			t.Fatalf("#%d: json.Unmarshal(%s) returns error: %v", i, tc.json, err)
+		}
+		if !reflect.DeepEqual(v, tc.val) {
+			t.Errorf("#%d: json.Unmarshal(%s) == %#v, want: %#v", i, tc.json, v, tc.val)
+		}
+	}
+}
+
+func TestValueMarshalJSONNaN(t *testing.T) {
+	got, err := json.Marshal([]*php.Value{php.NaN(), php.Inf(1), php.Inf(-1)})
+	if err != nil {
+		t.Fatalf("json.Marshal(...) returns error: %v", err)
+	}
+	if want := `["NAN","INF","-INF"]`; string(got) != want {
+		t.Errorf("json.Marshal(...) == %s, want: %s", got, want)
+	}
+}
@@ -0,0 +1,44 @@
+package php
+
+import (
+	"fmt"
+
+	"github.com/kamiaka/go-phpserialize/internal/decoding"
+)
+
+// readLazyElem reads the value of an array element or object field in lazy
+// mode, deferring its decoding.
+func (d *decodeState) readLazyElem() *Value {
+	start := d.off
+	d.skipValue()
+	opts := d.Options
+	return Raw(d.data[start:d.off], func(data []byte) (*Value, error) {
+		d := newDecodeState(data, opts)
+		d.ownsData = true
+		return d.unmarshal()
+	})
+}
+
+// skipValue advances past the value at d.off, checking only its structure.
+// Scalars are validated when the value is decoded. Like readValue, it keeps
+// track of nested arrays and objects without recursion.
+func (d *decodeState) skipValue() {
+	start := d.off
+	s := decoding.Scanner{
+		Options:  d.Options,
+		Ctx:      d.ctx,
+		Base:     d.off,
+		Depth:    d.depth,
+		Elements: d.elements,
+	}
+	n, done, err := s.Scan(d.data[d.off:])
+	if err != nil {
+		panic(serializeErr{fmt.Errorf("%w%s", err, d.pathSuffix())})
+	}
+	d.off += n
+	d.elements = s.Elements
+	if !done {
+		d.eofError(" in value, from position: %d", start)
+	}
+	d.skipSemicolons()
+}
@@ -0,0 +1,83 @@
+package php
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// OrderedMap is a map of int or string keys to arbitrary Go values that
+// remembers insertion order. phpserialize.Marshal encodes it as a PHP array
+// with the keys in that order, whereas Go maps are encoded with sorted keys.
+//
+// The zero value is an empty map ready to use.
+type OrderedMap struct {
+	keys   []interface{}
+	values map[interface{}]interface{}
+}
+
+// NewOrderedMap returns an empty OrderedMap.
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{}
+}
+
+// orderedKey normalizes key to int64 or string so that int and int64 keys of
+// equal value are the same key, as in PHP.
+func orderedKey(key interface{}) interface{} {
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	case reflect.String:
+		return v.String()
+	default:
+		panic(fmt.Sprintf("php: invalid OrderedMap key type %T", key))
+	}
+}
+
+// Set sets the value of key. A new key is appended at the end; an existing
+// key keeps its position.
+// It panics if key is not of an integer or string kind.
+func (m *OrderedMap) Set(key, value interface{}) *OrderedMap {
+	k := orderedKey(key)
+	if m.values == nil {
+		m.values = make(map[interface{}]interface{})
+	}
+	if _, ok := m.values[k]; !ok {
+		m.keys = append(m.keys, k)
+	}
+	m.values[k] = value
+	return m
+}
+
+// Get returns the value of key and whether it exists.
+func (m *OrderedMap) Get(key interface{}) (interface{}, bool) {
+	v, ok := m.values[orderedKey(key)]
+	return v, ok
+}
+
+// Delete removes key from m.
+func (m *OrderedMap) Delete(key interface{}) {
+	k := orderedKey(key)
+	if _, ok := m.values[k]; !ok {
+		return
+	}
+	delete(m.values, k)
+	for i, mk := range m.keys {
+		if mk == k {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys returns m's keys in insertion order. Int keys are returned as int64.
+func (m *OrderedMap) Keys() []interface{} {
+	return append([]interface{}(nil), m.keys...)
+}
+
+// Len returns the number of keys in m.
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
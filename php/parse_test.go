@@ -0,0 +1,83 @@
+package php_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		data string
+		want *php.Value
+	}{
+		{data: `N;`, want: php.Null()},
+		{data: `b:1;`, want: php.Bool(true)},
+		{data: `i:-42;`, want: php.Int(-42)},
+		{data: `i:+7;`, want: php.Int(7)},
+		{data: `i:9223372036854775808;`, want: php.Float(9223372036854775808)},
+		{data: `d:1.5;`, want: php.Float(1.5)},
+		{data: `d:NAN;`, want: php.NaN()},
+		{data: `d:-INF;`, want: php.Inf(-1)},
+		{data: `s:4:"a";b";`, want: php.String(`a";b`)},
+		{data: `S:3:"a\62c";`, want: php.String("abc")},
+		{
+			data: `a:3:{i:0;s:1:"a";s:1:"1";i:2;i:0;s:1:"c";}`,
+			want: php.Array(php.Element(php.Int(0), php.String("c")), php.Element(php.Int(1), php.Int(2))),
+		},
+		{
+			data: "O:3:\"Foo\":2:{s:1:\"a\";i:1;s:4:\"\x00*\x00b\";a:0:{}}",
+			want: php.Object("Foo", php.PubField("a", php.Int(1)), php.ProtectedField("b", php.Array())),
+		},
+		{data: `C:3:"Foo":5:{a;b}c}`, want: php.CustomObject("Foo", []byte("a;b}c"))},
+	}
+	for i, tc := range cases {
+		v, err := php.Parse([]byte(tc.data))
+		if err != nil {
+			t.Errorf("#%d: Parse(%q) returns error: %v", i, tc.data, err)
+			continue
+		}
+		if !php.Equal(v, tc.want) {
+			t.Errorf("#%d: Parse(%q) == %#v, want: %#v", i, tc.data, v, tc.want)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	cases := []string{
+		``,
+		`x`,
+		`N`,
+		`b:2;`,
+		`i:x;`,
+		`d:x;`,
+		`s:5:"abc";`,
+		`s:-1:"";`,
+		`a:1:{N;i:1;}`,
+		`a:1000000:{}`,
+		`a:1:{i:0;i:1;`,
+		"O:3:\"Foo\":1:{s:2:\"\x00a\";N;}",
+		`i:1;i:2;`,
+	}
+	for i, data := range cases {
+		if _, err := php.Parse([]byte(data)); err == nil {
+			t.Errorf("#%d: Parse(%q) returns no error", i, data)
+		}
+	}
+}
+
+func TestParseDeep(t *testing.T) {
+	const depth = 100000
+	data := strings.Repeat("a:1:{i:0;", depth) + "N;" + strings.Repeat("}", depth)
+	v, err := php.Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse(...) returns error: %v", err)
+	}
+	for i := 0; i < depth; i++ {
+		v = v.Array()[0].Value
+	}
+	if v.Type() != php.TypeNull {
+		t.Errorf("Parse(...) holds %v at depth %d, want: null", v.Type(), depth)
+	}
+}
@@ -0,0 +1,485 @@
+package php
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Query is a compiled JSONPath-like expression that selects values from a
+// tree of Values. The syntax is:
+//
+//	$                 the root value; it may be omitted, as in items[0]
+//	.name, ['name']   the array element with the key name, or the field name
+//	[3], [-1]         the array element with the int key 3 or -1
+//	['a','b'], [0,1]  the elements or fields with any of the keys
+//	.*, [*]           every array element or object field
+//	..                recursive descent: applies the following selector to
+//	                  the value and to every value in it, as in $..items
+//	[?(filter)]       every array element or object field matching filter
+//
+// A filter is made of paths starting with @ (the element or field being
+// tested) or $ (the root), string, number, true, false and null literals,
+// the comparison operators ==, !=, <, <=, > and >=, and !, && and || with
+// parentheses. A path on its own tests that the value exists. Values are
+// compared like PHP 8's <=> and == operators, and comparisons with paths that
+// do not exist are false, except for !=.
+//
+//	$..items[?(@.sku == "X" && @.qty > 1)].price
+//
+// Keys are matched like At matches them. Unlike in JSONPath, integer
+// indexes are keys rather than positions, as in PHP.
+type Query struct {
+	expr  string
+	steps []queryStep
+}
+
+type queryStep struct {
+	recursive bool
+	wildcard  bool
+	names     []string
+	filter    queryExpr
+}
+
+// CompileQuery parses a query expression. See Query for the syntax.
+func CompileQuery(expr string) (q *Query, err error) {
+	p := &queryParser{s: expr}
+	defer func() {
+		if r := recover(); r != nil {
+			if pe, ok := r.(parseError); ok {
+				q, err = nil, pe.error
+			} else {
+				panic(r)
+			}
+		}
+	}()
+	p.skipSpace()
+	var steps []queryStep
+	if p.peek() == '$' {
+		p.off++
+	} else if isNameByte(p.peek()) {
+		steps = append(steps, queryStep{})
+		p.dotted(&steps[0])
+	}
+	q = &Query{expr: expr, steps: append(steps, p.path()...)}
+	p.skipSpace()
+	if p.off != len(p.s) {
+		p.error("unexpected %q", p.s[p.off])
+	}
+	return q, nil
+}
+
+// MustCompileQuery is like CompileQuery but panics if the expression cannot
+// be parsed. It simplifies the initialization of global variables holding
+// queries.
+func MustCompileQuery(expr string) *Query {
+	q, err := CompileQuery(expr)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// Select compiles the query expression and returns the values it selects
+// from v. See Query for the syntax.
+func Select(v *Value, expr string) ([]*Value, error) {
+	q, err := CompileQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	return q.Select(v), nil
+}
+
+// String returns the source text of the query.
+func (q *Query) String() string {
+	return q.expr
+}
+
+// Select returns the values q selects from v in document order. The same
+// value may appear more than once when recursive descent reaches it from
+// several places.
+func (q *Query) Select(v *Value) []*Value {
+	return selectSteps(q.steps, v, v)
+}
+
+// First returns the first value q selects from v, or a missing Value if
+// there is none.
+func (q *Query) First(v *Value) *Value {
+	if vs := q.Select(v); len(vs) > 0 {
+		return vs[0]
+	}
+	return Missing()
+}
+
+func selectSteps(steps []queryStep, v, root *Value) []*Value {
+	vs := []*Value{v}
+	for _, s := range steps {
+		var next []*Value
+		for _, v := range vs {
+			if s.recursive {
+				Walk(v, func(_ Path, v *Value) error {
+					next = s.apply(next, v, root)
+					return nil
+				})
+			} else {
+				next = s.apply(next, v, root)
+			}
+		}
+		vs = next
+	}
+	return vs
+}
+
+// apply appends the values s selects from v to vs.
+func (s *queryStep) apply(vs []*Value, v, root *Value) []*Value {
+	if v.IsNil() {
+		return vs
+	}
+	switch {
+	case s.names != nil:
+		if t := v.Type(); t != TypeArray && t != TypeObject {
+			return vs
+		}
+		for _, name := range s.names {
+			if x := v.At(name); x.Exists() {
+				vs = append(vs, x)
+			}
+		}
+	default:
+		members(v, func(m *Value) {
+			if s.wildcard || s.filter.test(m, root) {
+				vs = append(vs, m)
+			}
+		})
+	}
+	return vs
+}
+
+func members(v *Value, fn func(*Value)) {
+	switch v.Type() {
+	case TypeArray:
+		for _, e := range v.Array() {
+			fn(e.Value)
+		}
+	case TypeObject:
+		for _, f := range v.Object().Fields {
+			fn(f.Value)
+		}
+	}
+}
+
+// queryExpr is a node of a filter expression.
+type queryExpr interface {
+	// eval returns the value of the expression for the current value @,
+	// or a missing Value.
+	eval(cur, root *Value) *Value
+	test(cur, root *Value) bool
+}
+
+type (
+	pathExpr struct {
+		root  bool
+		steps []queryStep
+	}
+	literalExpr struct{ v *Value }
+	notExpr     struct{ x queryExpr }
+	logicalExpr struct {
+		and  bool
+		x, y queryExpr
+	}
+	compareExpr struct {
+		op   string
+		x, y queryExpr
+	}
+)
+
+func (e *pathExpr) eval(cur, root *Value) *Value {
+	v := cur
+	if e.root {
+		v = root
+	}
+	if vs := selectSteps(e.steps, v, root); len(vs) > 0 {
+		return vs[0]
+	}
+	return Missing()
+}
+
+func (e *pathExpr) test(cur, root *Value) bool {
+	return e.eval(cur, root).Exists()
+}
+
+func (e *literalExpr) eval(*Value, *Value) *Value { return e.v }
+func (e *literalExpr) test(*Value, *Value) bool   { return truthy(e.v) }
+
+func (e *notExpr) eval(cur, root *Value) *Value { return Bool(e.test(cur, root)) }
+func (e *notExpr) test(cur, root *Value) bool   { return !e.x.test(cur, root) }
+
+func (e *logicalExpr) eval(cur, root *Value) *Value { return Bool(e.test(cur, root)) }
+
+func (e *logicalExpr) test(cur, root *Value) bool {
+	if e.and {
+		return e.x.test(cur, root) && e.y.test(cur, root)
+	}
+	return e.x.test(cur, root) || e.y.test(cur, root)
+}
+
+func (e *compareExpr) eval(cur, root *Value) *Value { return Bool(e.test(cur, root)) }
+
+func (e *compareExpr) test(cur, root *Value) bool {
+	x, y := e.x.eval(cur, root), e.y.eval(cur, root)
+	if !x.Exists() || !y.Exists() {
+		return e.op == "!="
+	}
+	c := Compare(x, y)
+	switch e.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		// uncomparable values compare as 1 both ways
+		return c > 0 && Compare(y, x) < 0
+	default:
+		return c >= 0 && Compare(y, x) <= 0
+	}
+}
+
+type parseError struct{ error }
+
+type queryParser struct {
+	s   string
+	off int
+}
+
+func (p *queryParser) error(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	panic(parseError{fmt.Errorf("php: invalid query %q: %s at offset %d", p.s, msg, p.off)})
+}
+
+func (p *queryParser) peek() byte {
+	if p.off < len(p.s) {
+		return p.s[p.off]
+	}
+	return 0
+}
+
+func (p *queryParser) skipSpace() {
+	for p.off < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.off]) >= 0 {
+		p.off++
+	}
+}
+
+func (p *queryParser) consume(s string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.s[p.off:], s) {
+		p.off += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expect(s string) {
+	if !p.consume(s) {
+		if p.off == len(p.s) {
+			p.error("missing %q", s)
+		}
+		p.error("expected %q, found %q", s, p.s[p.off])
+	}
+}
+
+// path parses the steps of a path up to the first character that does not
+// continue it.
+func (p *queryParser) path() []queryStep {
+	var steps []queryStep
+	for {
+		switch {
+		case strings.HasPrefix(p.s[p.off:], ".."):
+			p.off += 2
+			s := queryStep{recursive: true}
+			if p.peek() == '[' {
+				p.off++
+				p.brackets(&s)
+			} else {
+				p.dotted(&s)
+			}
+			steps = append(steps, s)
+		case p.peek() == '.':
+			p.off++
+			var s queryStep
+			p.dotted(&s)
+			steps = append(steps, s)
+		case p.peek() == '[':
+			p.off++
+			var s queryStep
+			p.brackets(&s)
+			steps = append(steps, s)
+		default:
+			return steps
+		}
+	}
+}
+
+// dotted parses the selector after a dot.
+func (p *queryParser) dotted(s *queryStep) {
+	if p.peek() == '*' {
+		p.off++
+		s.wildcard = true
+		return
+	}
+	start := p.off
+	for p.off < len(p.s) && isNameByte(p.s[p.off]) {
+		p.off++
+	}
+	if p.off == start {
+		p.error("missing name")
+	}
+	s.names = []string{p.s[start:p.off]}
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c == '\\' || c >= 0x80 ||
+		'0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// brackets parses the selector after an opening bracket up to and including
+// the closing bracket.
+func (p *queryParser) brackets(s *queryStep) {
+	switch {
+	case p.consume("*"):
+		s.wildcard = true
+	case p.consume("?"):
+		p.expect("(")
+		s.filter = p.or()
+		p.expect(")")
+	default:
+		s.names = []string{}
+		for {
+			p.skipSpace()
+			switch c := p.peek(); {
+			case c == '\'' || c == '"':
+				s.names = append(s.names, p.quoted())
+			case c == '-' || '0' <= c && c <= '9':
+				start := p.off
+				p.off++
+				for '0' <= p.peek() && p.peek() <= '9' {
+					p.off++
+				}
+				i, err := strconv.ParseInt(p.s[start:p.off], 10, 64)
+				if err != nil {
+					p.off = start
+					p.error("invalid index")
+				}
+				s.names = append(s.names, strconv.FormatInt(i, 10))
+			default:
+				p.error("expected key")
+			}
+			if !p.consume(",") {
+				break
+			}
+		}
+	}
+	p.expect("]")
+}
+
+// quoted parses a string literal in single or double quotes, in which a
+// backslash escapes the following character.
+func (p *queryParser) quoted() string {
+	q := p.s[p.off]
+	p.off++
+	var b strings.Builder
+	for {
+		if p.off == len(p.s) {
+			p.error("unterminated string")
+		}
+		c := p.s[p.off]
+		p.off++
+		switch c {
+		case q:
+			return b.String()
+		case '\\':
+			if p.off == len(p.s) {
+				p.error("unterminated string")
+			}
+			c = p.s[p.off]
+			p.off++
+		}
+		b.WriteByte(c)
+	}
+}
+
+func (p *queryParser) or() queryExpr {
+	x := p.and()
+	for p.consume("||") {
+		x = &logicalExpr{x: x, y: p.and()}
+	}
+	return x
+}
+
+func (p *queryParser) and() queryExpr {
+	x := p.unary()
+	for p.consume("&&") {
+		x = &logicalExpr{and: true, x: x, y: p.unary()}
+	}
+	return x
+}
+
+func (p *queryParser) unary() queryExpr {
+	if p.consume("!") {
+		return &notExpr{p.unary()}
+	}
+	if p.consume("(") {
+		x := p.or()
+		p.expect(")")
+		return x
+	}
+	x := p.operand()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			return &compareExpr{op: op, x: x, y: p.operand()}
+		}
+	}
+	return x
+}
+
+func (p *queryParser) operand() queryExpr {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.off++
+		return &pathExpr{root: c == '$', steps: p.path()}
+	case c == '\'' || c == '"':
+		return &literalExpr{String(p.quoted())}
+	case c == '-' || c == '.' || '0' <= c && c <= '9':
+		start := p.off
+		for p.off < len(p.s) && strings.IndexByte("+-.0123456789eE", p.s[p.off]) >= 0 {
+			p.off++
+		}
+		lit := p.s[start:p.off]
+		if i, err := strconv.ParseInt(lit, 10, 64); err == nil {
+			return &literalExpr{&Value{t: TypeInt, i: i}}
+		}
+		f, err := strconv.ParseFloat(lit, 64)
+		if err != nil {
+			p.off = start
+			p.error("invalid number")
+		}
+		return &literalExpr{Float(f)}
+	}
+	for _, lit := range []struct {
+		s string
+		v *Value
+	}{{"true", Bool(true)}, {"false", Bool(false)}, {"null", Null()}} {
+		if p.consume(lit.s) {
+			return &literalExpr{lit.v}
+		}
+	}
+	if p.off == len(p.s) {
+		p.error("missing operand")
+	}
+	p.error("unexpected %q", p.s[p.off])
+	return nil
+}
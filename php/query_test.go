@@ -0,0 +1,95 @@
+package php_test
+
+import (
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestSelect(t *testing.T) {
+	item := func(sku string, qty int, price float64) *php.Value {
+		return php.Assoc(map[string]*php.Value{
+			"sku":   php.String(sku),
+			"qty":   php.Int(qty),
+			"price": php.Float(price),
+		})
+	}
+	v := php.Array(
+		php.Element(php.String("orders"), php.List(
+			php.Object("Order",
+				php.PubField("id", php.Int(1)),
+				php.ProtectedField("items", php.List(item("X", 2, 1.5), item("Y", 1, 3))),
+			),
+			php.Object("Order",
+				php.PubField("id", php.Int(2)),
+				php.ProtectedField("items", php.List(item("X", 1, 2.5))),
+			),
+		)),
+		php.Element(php.String("limit"), php.Int(1)),
+	)
+
+	tests := []struct {
+		expr string
+		want []*php.Value
+	}{
+		{`$`, []*php.Value{v}},
+		{`$.limit`, []*php.Value{php.Int(1)}},
+		{`limit`, []*php.Value{php.Int(1)}},
+		{`orders[0].id`, []*php.Value{php.Int(1)}},
+		{`$['orders'][1].id`, []*php.Value{php.Int(2)}},
+		{`$.orders[0,1].id`, []*php.Value{php.Int(1), php.Int(2)}},
+		{`$.orders[-1]`, nil},
+		{`$.orders.*.id`, []*php.Value{php.Int(1), php.Int(2)}},
+		{`$.orders[*].items[0].sku`, []*php.Value{php.String("X"), php.String("X")}},
+		{`$..sku`, []*php.Value{php.String("X"), php.String("Y"), php.String("X")}},
+		{`$..items[?(@.sku == "X")].price`, []*php.Value{php.Float(1.5), php.Float(2.5)}},
+		{`$..items[?(@.sku == 'X' && @.qty > $.limit)].price`, []*php.Value{php.Float(1.5)}},
+		{`$..items[?(@.price >= 3 || !@.qty)].sku`, []*php.Value{php.String("Y")}},
+		{`$..items[?(@.qty == "1")].sku`, []*php.Value{php.String("Y"), php.String("X")}},
+		{`$.orders[?(@.none != 1)].id`, []*php.Value{php.Int(1), php.Int(2)}},
+		{`$.orders[?(@.none)].id`, nil},
+		{`$.orders[?((@.id < 2))]..price`, []*php.Value{php.Float(1.5), php.Float(3)}},
+	}
+	for i, tt := range tests {
+		got, err := php.Select(v, tt.expr)
+		if err != nil {
+			t.Errorf("#%d: Select(v, %q) returns error: %v", i, tt.expr, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("#%d: Select(v, %q) == %v, want: %v", i, tt.expr, got, tt.want)
+			continue
+		}
+		for j := range got {
+			if !php.Equal(got[j], tt.want[j]) {
+				t.Errorf("#%d: Select(v, %q) == %v, want: %v", i, tt.expr, got, tt.want)
+				break
+			}
+		}
+	}
+
+	q := php.MustCompileQuery(`$.orders[1].id`)
+	if got := q.First(v); got.IntOr(0) != 2 {
+		t.Errorf("First(v) == %v, want: 2", got)
+	}
+	if got := q.First(php.Null()); got.Exists() {
+		t.Errorf("First(Null()) == %v, want: Missing()", got)
+	}
+}
+
+func TestCompileQueryError(t *testing.T) {
+	for i, expr := range []string{
+		`$.`,
+		`$[`,
+		`$['a`,
+		`$[a]`,
+		`$.a b`,
+		`$[?(@.a ==)]`,
+		`$[?(@.a == 1]`,
+		`$[?(@.a == 1.2.3)]`,
+	} {
+		if _, err := php.CompileQuery(expr); err == nil {
+			t.Errorf("#%d: CompileQuery(%q) returns no error", i, expr)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package php
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// RawValue holds the serialized bytes of a Value whose decoding is deferred
+// until the Value is first accessed.
+type RawValue struct {
+	data   []byte
+	parse  func([]byte) (*Value, error)
+	once   sync.Once
+	loaded uint32
+	err    error
+}
+
+// Raw returns a Value that holds the serialized bytes data and is decoded by
+// parse when any of its methods is first called, e.g. by
+// phpserialize.Unmarshal. Until then, encoders write data as it is.
+// If parse fails, the Value behaves like a missing Value and Load returns
+// the error.
+func Raw(data []byte, parse func([]byte) (*Value, error)) *Value {
+	return &Value{
+		raw: &RawValue{
+			data:  data,
+			parse: parse,
+		},
+	}
+}
+
+// Bytes returns the serialized bytes of r. The caller must not modify them.
+func (r *RawValue) Bytes() []byte {
+	return r.data
+}
+
+// Raw returns the serialized form of v if v was created by Raw and has not
+// been decoded yet, or nil otherwise. Raw does not decode v.
+func (v *Value) Raw() *RawValue {
+	if v == nil || v.raw == nil || atomic.LoadUint32(&v.raw.loaded) == 1 {
+		return nil
+	}
+	return v.raw
+}
+
+// Load decodes v if it was created by Raw and returns the decoding error, if
+// any. It is safe to call Load on any Value.
+func (v *Value) Load() error {
+	if v == nil || v.raw == nil {
+		return nil
+	}
+	r := v.raw
+	r.once.Do(func() {
+		pv, err := r.parse(r.data)
+		if err != nil {
+			r.err = err
+		} else {
+			v.t, v.i, v.lex = pv.t, pv.i, pv.lex
+		}
+		atomic.StoreUint32(&r.loaded, 1)
+	})
+	return r.err
+}
+
+// load decodes v if needed, ignoring the error, which leaves v invalid.
+func (v *Value) load() {
+	v.Load()
+}
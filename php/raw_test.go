@@ -0,0 +1,45 @@
+package php_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestRaw(t *testing.T) {
+	calls := 0
+	v := php.Raw([]byte("42"), func(data []byte) (*php.Value, error) {
+		calls++
+		return php.Int(42), nil
+	})
+	if r := v.Raw(); r == nil || string(r.Bytes()) != "42" {
+		t.Fatalf("Raw() == %v, want: raw bytes 42", r)
+	}
+	if calls != 0 {
+		t.Errorf("Raw(...) decodes before access")
+	}
+	if got := v.Int(); got != 42 {
+		t.Errorf("Int() == %d, want: 42", got)
+	}
+	if got := v.IntOr(0); got != 42 {
+		t.Errorf("IntOr(0) == %d, want: 42", got)
+	}
+	if calls != 1 {
+		t.Errorf("Raw(...) decodes %d times, want: 1", calls)
+	}
+	if v.Raw() != nil {
+		t.Errorf("Raw() returns raw bytes after decoding")
+	}
+
+	errBad := errors.New("bad")
+	bad := php.Raw([]byte("?"), func([]byte) (*php.Value, error) {
+		return nil, errBad
+	})
+	if bad.Exists() {
+		t.Errorf("Exists() == true for a value failing to decode")
+	}
+	if err := bad.Load(); err != errBad {
+		t.Errorf("Load() returns %v, want: %v", err, errBad)
+	}
+}
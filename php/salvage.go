@@ -0,0 +1,103 @@
+package php
+
+import (
+	"bytes"
+
+	"github.com/kamiaka/go-phpserialize/internal/decoding"
+)
+
+// warn reports a problem found at off that recovery mode decodes past: it
+// is recorded in recovery mode and fails decoding otherwise.
+func (d *decodeState) warn(off int, format string, args ...interface{}) {
+	if !d.recovering {
+		d.error(format, args...)
+	}
+	d.problems = append(d.problems, decoding.Problem{
+		Offset: off,
+		Path:   d.formatPath(),
+		Err:    d.errorf(format, args...),
+	})
+}
+
+// salvage is deferred by readValue in recovery mode to record the error
+// that stopped decoding a value and end decoding without failing. The value
+// being read is dropped, and the arrays and objects above it, from the top
+// of the frame stack down to base, are closed with the members read so far
+// and stored in *v. pathLen and depth restore the state of d at the start
+// of the value.
+func (d *decodeState) salvage(base, pathLen, depth int, v **Value) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	e, ok := r.(serializeErr)
+	if !ok {
+		panic(r)
+	}
+	d.problems = append(d.problems, decoding.Problem{
+		Offset: d.off,
+		Path:   d.formatPath(),
+		Err:    e.error,
+	})
+	d.stopped = true
+
+	var partial *Value
+	for len(d.stack) > base {
+		f := &d.stack[len(d.stack)-1]
+		if partial != nil {
+			d.path = d.path[:f.pathLen+1]
+			d.addMember(f, partial)
+		}
+		d.path = d.path[:f.pathLen]
+		partial = d.closeFrame()
+	}
+	d.path = d.path[:pathLen]
+	d.depth = depth
+	*v = partial
+}
+
+// salvageStrLen returns the length of the s: string body at d.off, which
+// is l unless the body does not end after l bytes: like Repair, the body
+// then ends at the next `";`.
+func (d *decodeState) salvageStrLen(l int) int {
+	start := d.off + 1 // after the opening quote
+	if start > len(d.data) {
+		return l
+	}
+	if l >= 0 && l < len(d.data)-start-1 && d.data[start+l] == '"' && d.data[start+l+1] == ';' {
+		return l
+	}
+	n := bytes.Index(d.data[start:], []byte(`";`))
+	if n < 0 {
+		return l
+	}
+	d.warn(d.off, "string length %d does not match its %d bytes, position: %d", l, n, d.off)
+	return n
+}
+
+// more reports whether the i-th member of an array or object of l members
+// follows. In recovery mode, the closing brace rather than l tells where
+// the members end.
+func (d *decodeState) more(i, l int) bool {
+	d.tick()
+	if !d.recovering {
+		return i < l
+	}
+	if d.stopped {
+		return false
+	}
+	if d.isEOF() {
+		d.warn(d.off, "unexpected end of data after %d of %d members", i, l)
+		d.stopped = true
+		return false
+	}
+	closing := d.data[d.off] == '}'
+	switch {
+	case i < l && closing:
+		d.warn(d.off, "found %d members instead of %d, position: %d", i, l, d.off)
+		return false
+	case i == l && !closing:
+		d.warn(d.off, "found more than %d members, position: %d", l, d.off)
+	}
+	return !closing
+}
@@ -0,0 +1,159 @@
+package php
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/kamiaka/go-phpserialize/internal/phpfloat"
+)
+
+// Serialize returns the PHP serialized bytes of v, as Marshal of the
+// phpserialize package does with its default options, without going through
+// its reflection-based encoder.
+func (v *Value) Serialize() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeSerialized(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo implements io.WriterTo by writing the PHP serialized bytes of v
+// to w.
+func (v *Value) WriteTo(w io.Writer) (int64, error) {
+	bs, err := v.Serialize()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(bs)
+	if err == nil && n < len(bs) {
+		err = io.ErrShortWrite
+	}
+	return int64(n), err
+}
+
+// A serializeStep is a value left to write by writeSerialized, or the end
+// of an array or object.
+type serializeStep struct {
+	v   *Value
+	end bool
+}
+
+// writeSerialized writes v to buf. Arrays and objects are written with an
+// explicit stack of the values left to write rather than by recursion, so
+// that deeply nested values cannot exhaust the goroutine stack.
+func writeSerialized(buf *bytes.Buffer, v *Value) error {
+	stack := []serializeStep{{v: v}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if s.end {
+			buf.WriteByte('}')
+			continue
+		}
+		var err error
+		if stack, err = writeSerializedValue(buf, s.v, stack); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSerializedValue writes the scalar v, or the start of the array or
+// object v, to buf, and returns stack with the members of v and its end
+// pushed in reverse order.
+func writeSerializedValue(buf *bytes.Buffer, v *Value, stack []serializeStep) ([]serializeStep, error) {
+	if r := v.Raw(); r != nil {
+		buf.Write(r.Bytes())
+		return stack, nil
+	}
+	if v.IsNil() {
+		buf.WriteString("N;")
+		return stack, nil
+	}
+	switch v.t {
+	case TypeBool:
+		if v.Bool() {
+			buf.WriteString("b:1;")
+		} else {
+			buf.WriteString("b:0;")
+		}
+	case TypeInt:
+		buf.WriteString("i:")
+		if v.lex != "" {
+			buf.WriteString(v.lex)
+		} else {
+			buf.WriteString(strconv.FormatInt(v.Int(), 10))
+		}
+		buf.WriteByte(';')
+	case TypeFloat:
+		buf.WriteString("d:")
+		switch f := v.Float(); {
+		case v.lex != "":
+			buf.WriteString(v.lex)
+		case math.IsNaN(f):
+			buf.WriteString("NAN")
+		case math.IsInf(f, 1):
+			buf.WriteString("INF")
+		case math.IsInf(f, -1):
+			buf.WriteString("-INF")
+		default:
+			buf.WriteString(phpfloat.Format(f, -1))
+		}
+		buf.WriteByte(';')
+	case TypeString:
+		if v.IsBytes() {
+			b := v.Bytes()
+			buf.WriteString("s:" + strconv.Itoa(len(b)) + `:"`)
+			buf.Write(b)
+			buf.WriteString(`";`)
+		} else {
+			writeSerializedString(buf, v.String())
+		}
+	case TypeArray:
+		arr := v.Array()
+		buf.WriteString("a:" + strconv.Itoa(len(arr)) + ":{")
+		stack = append(stack, serializeStep{end: true})
+		for i := len(arr) - 1; i >= 0; i-- {
+			key := arr[i].Index
+			if key.Type() == TypeString {
+				key = Key(key.String())
+			}
+			stack = append(stack, serializeStep{v: arr[i].Value}, serializeStep{v: key})
+		}
+	case TypeObject:
+		obj := v.Object()
+		if obj.Serialized != nil {
+			name := obj.Name
+			if n, ok := obj.IncompleteClassName(); ok {
+				name = n
+			}
+			buf.WriteString("C:" + strconv.Itoa(len(name)) + `:"` + name + `":` + strconv.Itoa(len(obj.Serialized)) + ":{")
+			buf.Write(obj.Serialized)
+			buf.WriteByte('}')
+			return stack, nil
+		}
+		name, fields := obj.Name, obj.Fields
+		if n, ok := obj.IncompleteClassName(); ok {
+			name, fields = n, fields[1:]
+		}
+		buf.WriteString("O:" + strconv.Itoa(len(name)) + `:"` + name + `":` + strconv.Itoa(len(fields)) + ":{")
+		stack = append(stack, serializeStep{end: true})
+		for i := len(fields) - 1; i >= 0; i-- {
+			f := fields[i]
+			stack = append(stack, serializeStep{v: f.Value}, serializeStep{v: String(f.MangledName(name))})
+		}
+	default:
+		return stack, fmt.Errorf("php: cannot serialize Value of type %v", v.t)
+	}
+	return stack, nil
+}
+
+func writeSerializedString(buf *bytes.Buffer, s string) {
+	buf.WriteString("s:" + strconv.Itoa(len(s)) + `:"`)
+	buf.WriteString(s)
+	buf.WriteString(`";`)
+}
@@ -0,0 +1,85 @@
+package php_test
+
+import (
+	"bytes"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestSerialize(t *testing.T) {
+	cases := []struct {
+		v    *php.Value
+		want string
+	}{
+		{v: php.Null(), want: `N;`},
+		{v: php.Bool(true), want: `b:1;`},
+		{v: php.Int(-5), want: `i:-5;`},
+		{v: php.Int(5).WithLexeme("+05"), want: `i:+05;`},
+		{v: php.Float(0.1), want: `d:0.1;`},
+		{v: php.Float(1e25), want: `d:1.0E+25;`},
+		{v: php.Inf(-1), want: `d:-INF;`},
+		{v: php.String("héllo"), want: `s:6:"héllo";`},
+		{v: php.Bytes([]byte("\x1f\x8b\x00\xff")), want: "s:4:\"\x1f\x8b\x00\xff\";"},
+		{
+			v:    php.Array(php.Element(php.String("5"), php.Int(1)), php.Element(php.String("05"), php.Int(2))),
+			want: `a:2:{i:5;i:1;s:2:"05";i:2;}`,
+		},
+		{
+			v:    php.Object("Foo", php.PubField("a", php.Int(1)), php.ProtectedField("b", php.Null()), php.PrivField("c", php.Null())),
+			want: "O:3:\"Foo\":3:{s:1:\"a\";i:1;s:4:\"\x00*\x00b\";N;s:6:\"\x00Foo\x00c\";N;}",
+		},
+		{v: php.CustomObject("Foo", []byte("x;y")), want: `C:3:"Foo":3:{x;y}`},
+		{
+			v: php.Object(php.IncompleteClass,
+				php.PubField(php.IncompleteClassNameField, php.String("Bar")),
+				php.PubField("x", php.Int(1)),
+			),
+			want: `O:3:"Bar":1:{s:1:"x";i:1;}`,
+		},
+		{v: php.Raw([]byte(`i:7;`), nil), want: `i:7;`},
+	}
+	for i, tc := range cases {
+		got, err := tc.v.Serialize()
+		if err != nil {
+			t.Errorf("#%d: Serialize() returns error: %v", i, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("#%d: Serialize() == %q, want: %q", i, got, tc.want)
+		}
+		var buf bytes.Buffer
+		n, err := tc.v.WriteTo(&buf)
+		if err != nil || n != int64(len(tc.want)) || buf.String() != tc.want {
+			t.Errorf("#%d: WriteTo(...) == %d, %v and writes %q, want: %d, nil and %q", i, n, err, buf.String(), len(tc.want), tc.want)
+		}
+	}
+	if _, err := php.Missing().Serialize(); err == nil {
+		t.Errorf("Missing().Serialize() returns no error")
+	}
+}
+
+func TestDeepValue(t *testing.T) {
+	// the helpers must not recurse per level: this stack cannot hold a
+	// frame per level
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+	const depth = 100000
+	v := deepList(depth)
+	bs, err := v.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize() returns error: %v", err)
+	}
+	if want := strings.Repeat("a:1:{i:0;", depth) + "N;" + strings.Repeat("}", depth); string(bs) != want {
+		t.Errorf("Serialize() returns %d bytes, want: %d", len(bs), len(want))
+	}
+	c := v.Clone()
+	if !php.Equal(c, v) {
+		t.Errorf("Equal(Clone(), v) == false, want: true")
+	}
+	if php.Equal(c, deepList(depth-1)) {
+		t.Errorf("Equal(Clone(), shallower) == true, want: false")
+	}
+}
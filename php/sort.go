@@ -0,0 +1,237 @@
+package php
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kamiaka/go-phpserialize/internal/phpfloat"
+)
+
+// SortKeys returns the array PHP value v with its elements sorted by key,
+// like PHP's ksort. v must be an array.
+func SortKeys(v *Value) *Value {
+	return SortFunc(v, func(a, b *ArrayElement) int {
+		return Compare(a.Index, b.Index)
+	})
+}
+
+// SortValues returns the array PHP value v with its values sorted and keyed
+// 0, 1, 2, ..., like PHP's sort. v must be an array.
+func SortValues(v *Value) *Value {
+	sorted := SortFunc(v, func(a, b *ArrayElement) int {
+		return Compare(a.Value, b.Value)
+	}).Array()
+	vs := make([]*Value, len(sorted))
+	for i, e := range sorted {
+		vs[i] = e.Value
+	}
+	return List(vs...)
+}
+
+// SortFunc returns the array PHP value v with its elements sorted by cmp,
+// which returns a negative number, zero or a positive number when a comes
+// before, along with or after b, like PHP's uksort and uasort. The sort is
+// stable, as in PHP 8. v must be an array.
+func SortFunc(v *Value, cmp func(a, b *ArrayElement) int) *Value {
+	ls := append([]*ArrayElement(nil), v.Array()...)
+	sort.SliceStable(ls, func(i, j int) bool {
+		return cmp(ls[i], ls[j]) < 0
+	})
+	return Array(ls...)
+}
+
+// Compare compares a and b like PHP 8's <=> operator and returns -1, 0 or 1.
+// Values that PHP cannot order, such as arrays with different keys, compare
+// as 1.
+func Compare(a, b *Value) int {
+	ta, tb := compareType(a), compareType(b)
+	switch {
+	case ta == TypeNull && tb == TypeString:
+		return compareStrings("", b.String())
+	case ta == TypeString && tb == TypeNull:
+		return compareStrings(a.String(), "")
+	case ta == TypeNull || ta == TypeBool || tb == TypeNull || tb == TypeBool:
+		return compareBools(truthy(a), truthy(b))
+	case isNumber(ta) && isNumber(tb):
+		return compareNumbers(a, b)
+	case isNumber(ta) && tb == TypeString:
+		if f, ok := numericString(b.String()); ok {
+			return compareNumbers(a, Float(f))
+		}
+		return compareStrings(numberString(a), b.String())
+	case ta == TypeString && isNumber(tb):
+		if f, ok := numericString(a.String()); ok {
+			return compareNumbers(Float(f), b)
+		}
+		return compareStrings(a.String(), numberString(b))
+	case ta == TypeString && tb == TypeString:
+		x, okx := numericString(a.String())
+		y, oky := numericString(b.String())
+		if okx && oky {
+			return compareFloats(x, y)
+		}
+		return compareStrings(a.String(), b.String())
+	case ta == TypeArray && tb == TypeArray:
+		return compareArrays(a.Array(), b.Array())
+	case ta == TypeArray:
+		return 1
+	case tb == TypeArray:
+		return -1
+	case ta == TypeObject && tb == TypeObject:
+		x, y := a.Object(), b.Object()
+		if x.Name != y.Name {
+			return 1
+		}
+		return compareArrays(fieldElements(x), fieldElements(y))
+	case ta == TypeObject:
+		return 1
+	default:
+		return -1
+	}
+}
+
+func compareType(v *Value) Type {
+	if v.IsNil() {
+		return TypeNull
+	}
+	return v.Type()
+}
+
+func isNumber(t Type) bool {
+	return t == TypeInt || t == TypeFloat
+}
+
+// truthy converts v to bool like PHP's (bool) cast.
+func truthy(v *Value) bool {
+	switch compareType(v) {
+	case TypeBool:
+		return v.Bool()
+	case TypeInt:
+		return v.Int() != 0
+	case TypeFloat:
+		return v.Float() != 0
+	case TypeString:
+		s := v.String()
+		return s != "" && s != "0"
+	case TypeArray:
+		return len(v.Array()) > 0
+	case TypeObject:
+		return true
+	}
+	return false
+}
+
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case b:
+		return -1
+	}
+	return 1
+}
+
+func compareNumbers(a, b *Value) int {
+	if a.Type() == TypeInt && b.Type() == TypeInt {
+		switch x, y := a.Int(), b.Int(); {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return compareFloats(toFloat(a), toFloat(b))
+}
+
+func toFloat(v *Value) float64 {
+	if v.Type() == TypeInt {
+		return float64(v.Int())
+	}
+	return v.Float()
+}
+
+func compareFloats(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	case x == y:
+		return 0
+	}
+	// NaN is neither smaller nor equal
+	return 1
+}
+
+func compareStrings(a, b string) int {
+	return strings.Compare(a, b)
+}
+
+// numberString formats the int or float v like PHP's string conversion.
+func numberString(v *Value) string {
+	if v.Type() == TypeInt {
+		return strconv.FormatInt(v.Int(), 10)
+	}
+	f := v.Float()
+	switch {
+	case math.IsNaN(f):
+		return "NAN"
+	case math.IsInf(f, 1):
+		return "INF"
+	case math.IsInf(f, -1):
+		return "-INF"
+	}
+	return phpfloat.Format(f, 14)
+}
+
+var numericPattern = regexp.MustCompile(`^[ \t\n\r\v\f]*[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?[ \t\n\r\v\f]*$`)
+
+// numericString returns the number s holds if s is a numeric string in
+// PHP 8's sense, which allows surrounding whitespace.
+func numericString(s string) (float64, bool) {
+	if !numericPattern.MatchString(s) {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.Trim(s, " \t\n\r\v\f"), 64)
+	return f, err == nil || math.IsInf(f, 0)
+}
+
+// compareArrays compares arrays like PHP: the one with fewer elements is
+// smaller, and otherwise the values are compared in the order of a, which
+// fails if b lacks a key of a.
+func compareArrays(a, b []*ArrayElement) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	for _, e := range a {
+		var f *ArrayElement
+		for _, g := range b {
+			if Equal(e.Index, g.Index) {
+				f = g
+				break
+			}
+		}
+		if f == nil {
+			return 1
+		}
+		if c := Compare(e.Value, f.Value); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func fieldElements(o *Obj) []*ArrayElement {
+	es := make([]*ArrayElement, len(o.Fields))
+	for i, f := range o.Fields {
+		es[i] = Element(String(f.MangledName(o.Name)), f.Value)
+	}
+	return es
+}
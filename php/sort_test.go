@@ -0,0 +1,78 @@
+package php_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		a, b *php.Value
+		want int
+	}{
+		{php.Int(1), php.Int(2), -1},
+		{php.Int(2), php.Float(1.5), 1},
+		{php.String("10"), php.String("9"), 1},
+		{php.String("abc"), php.String("abd"), -1},
+		{php.String("1e1"), php.String("10"), 0},
+		{php.Int(0), php.String("a"), -1},
+		{php.Int(10), php.String(" 10 "), 0},
+		{php.Null(), php.Bool(false), 0},
+		{php.Null(), php.String("a"), -1},
+		{php.Bool(true), php.String("a"), 0},
+		{php.Float(math.NaN()), php.Float(math.NaN()), 1},
+		{php.List(php.Int(1)), php.List(php.Int(1), php.Int(2)), -1},
+		{php.List(php.Int(3)), php.List(php.Int(2)), 1},
+		{php.List(php.Int(1)), php.Int(100), 1},
+		{php.Object("A"), php.Object("B"), 1},
+	}
+	for i, c := range cases {
+		if got := php.Compare(c.a, c.b); got != c.want {
+			t.Errorf("#%d: Compare(%v, %v) == %d, want: %d", i, c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	v := php.Array(
+		php.Element(php.String("b"), php.Int(3)),
+		php.Element(php.Int(10), php.Int(1)),
+		php.Element(php.String("a"), php.Int(2)),
+		php.Element(php.Int(2), php.Int(1)),
+	)
+
+	got := php.SortKeys(v)
+	want := php.Array(
+		php.Element(php.Int(2), php.Int(1)),
+		php.Element(php.Int(10), php.Int(1)),
+		php.Element(php.String("a"), php.Int(2)),
+		php.Element(php.String("b"), php.Int(3)),
+	)
+	if !php.Equal(got, want) {
+		t.Errorf("SortKeys(...) == %v, want: %v", got, want)
+	}
+
+	got = php.SortValues(v)
+	want = php.List(php.Int(1), php.Int(1), php.Int(2), php.Int(3))
+	if !php.Equal(got, want) {
+		t.Errorf("SortValues(...) == %v, want: %v", got, want)
+	}
+
+	got = php.SortFunc(v, func(a, b *php.ArrayElement) int {
+		return php.Compare(b.Value, a.Value)
+	})
+	want = php.Array(
+		php.Element(php.String("b"), php.Int(3)),
+		php.Element(php.String("a"), php.Int(2)),
+		php.Element(php.Int(10), php.Int(1)),
+		php.Element(php.Int(2), php.Int(1)),
+	)
+	if !php.Equal(got, want) {
+		t.Errorf("SortFunc(...) == %v, want: %v", got, want)
+	}
+	if v.Array()[0].Index.String() != "b" {
+		t.Errorf("sorting modified its argument")
+	}
+}
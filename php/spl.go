@@ -0,0 +1,169 @@
+package php
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kamiaka/go-phpserialize/internal/decoding"
+)
+
+type splKind uint
+
+const (
+	splArrayObject splKind = iota
+	splFixedArray
+	splObjectStorage
+	splList
+)
+
+// splClasses maps the lower-cased names of the SPL containers splArray
+// interprets to their kinds.
+var splClasses = map[string]splKind{
+	"arrayobject":            splArrayObject,
+	"arrayiterator":          splArrayObject,
+	"recursivearrayiterator": splArrayObject,
+	"splfixedarray":          splFixedArray,
+	"splobjectstorage":       splObjectStorage,
+	"spldoublylinkedlist":    splList,
+	"splqueue":               splList,
+	"splstack":               splList,
+}
+
+// splDecode replaces the SPL container v by its contents for the
+// SPLAsArrays option.
+func (d *decodeState) splDecode(v *Value) *Value {
+	v, err := splArray(v, d.Options)
+	if err != nil {
+		panic(serializeErr{err})
+	}
+	return v
+}
+
+// splArray returns the contents of the SPL container v as an array, as
+// phpserialize.SPLAsArray documents, decoding C: data with opts.
+func splArray(v *Value, opts decoding.Options) (*Value, error) {
+	if v.IsNil() || v.Type() != TypeObject {
+		return v, nil
+	}
+	obj := v.Object()
+	kind, ok := splClasses[strings.ToLower(obj.Name)]
+	if !ok {
+		return v, nil
+	}
+	if obj.Serialized != nil {
+		return splCustom(kind, obj.Serialized, opts)
+	}
+
+	field := func(name string) *Value {
+		if f := obj.Field(name); f != nil {
+			return f.Value
+		}
+		return nil
+	}
+	switch kind {
+	case splArrayObject:
+		if s := field("1"); s != nil {
+			return splStorage(s), nil
+		}
+	case splFixedArray:
+		var es []*Value
+		for _, f := range obj.Fields {
+			if k := Key(f.Name); k.Type() == TypeInt && f.Visibility == VisibilityPublic {
+				es = append(es, f.Value)
+			}
+		}
+		return List(es...), nil
+	case splObjectStorage:
+		if s := field("0"); s != nil && s.Type() == TypeArray && len(s.Array())%2 == 0 {
+			arr := s.Array()
+			es := make([]*Value, 0, len(arr)/2)
+			for i := 0; i < len(arr); i += 2 {
+				es = append(es, splStorageEntry(arr[i].Value, arr[i+1].Value))
+			}
+			return List(es...), nil
+		}
+	case splList:
+		if s := field("1"); s != nil && s.Type() == TypeArray {
+			arr := s.Array()
+			es := make([]*Value, len(arr))
+			for i, e := range arr {
+				es[i] = e.Value
+			}
+			return List(es...), nil
+		}
+	}
+	return nil, fmt.Errorf("php serialize: invalid %s data", obj.Name)
+}
+
+// splCustom interprets the data of an SPL container written as a C: object.
+func splCustom(kind splKind, data []byte, opts decoding.Options) (v *Value, err error) {
+	opts.Lenient = false // semicolons separate the members
+	d := newDecodeState(data, opts)
+	defer d.recover(&err)
+
+	switch kind {
+	case splArrayObject:
+		// x:i:flags;storage;m:members
+		d.skipEq("x:")
+		d.readValue()
+		v = splStorage(d.readValue())
+		d.skipEq(";m:")
+		d.readValue()
+	case splObjectStorage:
+		// x:i:count;object,data;...;m:members
+		d.skipEq("x:")
+		n := d.readValue()
+		if n.Type() != TypeInt || n.Int() < 0 || n.Int() > int64(len(data)) {
+			d.error("invalid SplObjectStorage count")
+		}
+		es := make([]*Value, 0, preallocSize(int(n.Int())))
+		for i := int64(0); i < n.Int(); i++ {
+			obj, inf := d.readValue(), Null()
+			if !d.isEOF() && d.data[d.off] == ',' {
+				d.off++
+				inf = d.readValue()
+			}
+			d.skipEq(";")
+			es = append(es, splStorageEntry(obj, inf))
+		}
+		d.skipEq("m:")
+		d.readValue()
+		v = List(es...)
+	case splList:
+		// i:flags;:element:element...
+		d.readValue()
+		var es []*Value
+		for !d.isEOF() {
+			d.skipEq(":")
+			es = append(es, d.readValue())
+		}
+		v = List(es...)
+	default:
+		d.error("unexpected SplFixedArray data")
+	}
+	if !d.isEOF() {
+		d.error("unexpected token: %s, position: %d", []byte{d.data[d.off]}, d.off)
+	}
+	return v, nil
+}
+
+// splStorage returns the storage of an ArrayObject as an array, converting
+// an object like PHP's (array) cast.
+func splStorage(s *Value) *Value {
+	if s.IsNil() || s.Type() != TypeObject {
+		return s
+	}
+	obj := s.Object()
+	es := make([]*ArrayElement, len(obj.Fields))
+	for i, f := range obj.Fields {
+		es[i] = Element(Key(f.MangledName(obj.Name)), f.Value)
+	}
+	return Array(es...)
+}
+
+func splStorageEntry(obj, inf *Value) *Value {
+	return Array(
+		Element(String("obj"), obj),
+		Element(String("inf"), inf),
+	)
+}
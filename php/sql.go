@@ -0,0 +1,45 @@
+package php
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/kamiaka/go-phpserialize/internal/decoding"
+)
+
+// Value implements driver.Valuer by serializing v, so that Values can be
+// stored in columns holding PHP serialized data, such as WordPress's
+// wp_options.option_value. A nil v is stored as SQL NULL.
+func (v *Value) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return v.Serialize()
+}
+
+// Scan implements sql.Scanner by decoding the PHP serialized data of a
+// column into v with the decoder of Parse and of the phpserialize package,
+// which reads nested arrays and objects without recursion and rejects
+// counts and lengths the data cannot hold. The decoded Value does not share
+// memory with src, which the driver may reuse. SQL NULL scans as a null
+// Value.
+func (v *Value) Scan(src interface{}) error {
+	var data []byte
+	switch src := src.(type) {
+	case nil:
+		*v = *Null()
+		return nil
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return fmt.Errorf("php: cannot scan %T into a Value", src)
+	}
+	nv, err := newDecodeState(data, decoding.Options{}).unmarshal()
+	if err != nil {
+		return err
+	}
+	*v = *nv
+	return nil
+}
@@ -0,0 +1,69 @@
+package php_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+var (
+	_ driver.Valuer = (*php.Value)(nil)
+	_ sql.Scanner   = (*php.Value)(nil)
+)
+
+func TestValueSQL(t *testing.T) {
+	v := php.Array(php.Element(php.String("siteurl"), php.String("http://example.org")))
+	dv, err := v.Value()
+	if err != nil {
+		t.Fatalf("Value() returns error: %v", err)
+	}
+	want := `a:1:{s:7:"siteurl";s:18:"http://example.org";}`
+	if bs, ok := dv.([]byte); !ok || string(bs) != want {
+		t.Errorf("Value() == %#v, want: %s", dv, want)
+	}
+	if dv, err := (*php.Value)(nil).Value(); dv != nil || err != nil {
+		t.Errorf("Value() of nil == %#v, %v, want: nil, nil", dv, err)
+	}
+
+	cases := []struct {
+		src        interface{}
+		want       *php.Value
+		wantsError bool
+	}{
+		{src: []byte(want), want: v},
+		{src: want, want: v},
+		{src: nil, want: php.Null()},
+		{src: `S:3:"a\62c";`, want: php.String("abc")},
+		{src: strings.Repeat("a:1:{i:0;", 100000) + "N;" + strings.Repeat("}", 100000), want: deepList(100000)},
+		{src: []byte(`a:1:{`), wantsError: true},
+		{src: []byte(`a:1000000:{}`), wantsError: true},
+		{src: int64(1), wantsError: true},
+	}
+	for i, tc := range cases {
+		var got php.Value
+		err := got.Scan(tc.src)
+		if tc.wantsError {
+			if err == nil {
+				t.Errorf("#%d: Scan(%#v) wants error but no error occurred", i, tc.src)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: Scan(%#v) returns error: %v", i, tc.src, err)
+		} else if !php.Equal(&got, tc.want) {
+			t.Errorf("#%d: Scan(%#v) == %v, want: %v", i, tc.src, &got, tc.want)
+		}
+	}
+}
+
+// deepList returns n nested lists holding null.
+func deepList(n int) *php.Value {
+	v := php.Null()
+	for i := 0; i < n; i++ {
+		v = php.List(v)
+	}
+	return v
+}
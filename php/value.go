@@ -2,12 +2,17 @@ package php
 
 import (
 	"math"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // Value represents PHP value
 type Value struct {
-	t Type
-	i interface{}
+	t   Type
+	i   interface{}
+	lex string    // original text of a decoded int or float, if preserved
+	raw *RawValue // serialized form of a lazily decoded value
 }
 
 // A ValueError occurs when a method is invoked on a Value that does not support it.
@@ -32,11 +37,13 @@ func valueError(method string, t Type) {
 
 // Type returns PHP value type.
 func (v *Value) Type() Type {
+	v.load()
 	return v.t
 }
 
 // Bool returns v's underlying value.
 func (v *Value) Bool() bool {
+	v.load()
 	uv, ok := v.i.(bool)
 	if !ok {
 		valueError("php.Value.Bool", v.t)
@@ -46,6 +53,7 @@ func (v *Value) Bool() bool {
 
 // Int returns v's underlying value.
 func (v *Value) Int() int64 {
+	v.load()
 	uv, ok := v.i.(int64)
 	if !ok {
 		valueError("php.Value.Int", v.t)
@@ -55,6 +63,7 @@ func (v *Value) Int() int64 {
 
 // Float returns v's underlying value.
 func (v *Value) Float() float64 {
+	v.load()
 	uv, ok := v.i.(float64)
 	if !ok {
 		valueError("php.Value.Float", v.t)
@@ -67,15 +76,47 @@ func (v *Value) Float() float64 {
 // Unlike the other getters, it does not panic if v's value is not String.
 // Instead, it returns as string of the form "<T Value>" where T is v's type.
 func (v *Value) String() string {
-	uv, ok := v.i.(string)
-	if !ok {
-		return "<" + v.Type().String() + " value>"
+	v.load()
+	switch uv := v.i.(type) {
+	case string:
+		return uv
+	case []byte:
+		return string(uv)
 	}
-	return uv
+	return "<" + v.Type().String() + " value>"
+}
+
+// Bytes returns the contents of the string v. If v holds a byte slice, as
+// created by the Bytes function or decoded with the
+// phpserialize.WithStringBytes option, Bytes returns it without copying and
+// it must not be modified.
+// It panics if v's type is not string.
+func (v *Value) Bytes() []byte {
+	v.load()
+	switch uv := v.i.(type) {
+	case []byte:
+		return uv
+	case string:
+		return []byte(uv)
+	}
+	valueError("php.Value.Bytes", v.t)
+	return nil
+}
+
+// IsBytes reports whether v is a string Value holding a byte slice, as
+// created by the Bytes function, rather than a Go string.
+func (v *Value) IsBytes() bool {
+	v.load()
+	if v == nil {
+		return false
+	}
+	_, ok := v.i.([]byte)
+	return ok
 }
 
 // Array returns v's underlying value.
 func (v *Value) Array() []*ArrayElement {
+	v.load()
 	uv, ok := v.i.([]*ArrayElement)
 	if !ok {
 		valueError("php.Value.Array", v.t)
@@ -94,11 +135,78 @@ func (v *Value) Keys() []*Value {
 	return keys
 }
 
+// Len returns the number of v's elements.
+// It panics if v's type is not array.
+func (v *Value) Len() int {
+	return len(v.Array())
+}
+
+// Has reports whether v has an element with key, which is converted by Key
+// if it is a string, as PHP would store it.
+// It panics if v's type is not array or key is not of an integer or string
+// kind.
+func (v *Value) Has(key interface{}) bool {
+	var index *Value
+	switch k := orderedKey(key).(type) {
+	case int64:
+		index = &Value{t: TypeInt, i: k}
+	case string:
+		index = Key(k)
+	}
+	return v.element(index) != nil
+}
+
+// Values returns v's element values in order, discarding the keys, like
+// PHP's array_values. It is the same as ToSlice.
+// It panics if v's type is not array.
+func (v *Value) Values() []*Value {
+	return v.ToSlice()
+}
+
 // Index returns v's element, returns nil if not found.
+// Keys are compared by value, so that Index(Int(3)) finds the element with
+// the key 3; a string index is converted by Key, as PHP would store it.
+// A nil index finds no element.
 //  It panics if v's type is not array.
 func (v *Value) Index(index *Value) *Value {
+	if index != nil && index.Type() == TypeString {
+		index = Key(index.String())
+	}
+	if e := v.element(index); e != nil {
+		return e.Value
+	}
+	return nil
+}
+
+// element returns v's element with the key index, comparing string keys by
+// their bytes, so that keys held as []byte match too.
+func (v *Value) element(index *Value) *ArrayElement {
+	arr := v.Array()
+	if index == nil {
+		return nil
+	}
+	for _, e := range arr {
+		switch {
+		case e.Index == index:
+			return e
+		case e.Index.t != index.t:
+		case index.t == TypeString:
+			if e.Index.String() == index.String() {
+				return e
+			}
+		case e.Index.i == index.i:
+			return e
+		}
+	}
+	return nil
+}
+
+// IndexByInt returns v's element with the int key i, returns nil if not
+// found.
+// It panics if v's type is not array.
+func (v *Value) IndexByInt(i int) *Value {
 	for _, e := range v.Array() {
-		if e.Index == index {
+		if e.Index.t == TypeInt && e.Index.i == int64(i) {
 			return e.Value
 		}
 	}
@@ -108,15 +216,58 @@ func (v *Value) Index(index *Value) *Value {
 // IndexByName returns found v's element by index name, returns nil if not found.
 func (v *Value) IndexByName(name string) *Value {
 	for _, e := range v.Array() {
-		if e.Index.Interface() == name {
+		if e.Index.t == TypeString && e.Index.String() == name {
 			return e.Value
 		}
 	}
 	return nil
 }
 
+// IsList reports whether v is an array whose keys are the ints 0, 1, 2, ...
+// in order, like a PHP list. An empty array is a list.
+func (v *Value) IsList() bool {
+	v.load()
+	if v == nil || v.t != TypeArray {
+		return false
+	}
+	for i, e := range v.Array() {
+		if e.Index.t != TypeInt || e.Index.Int() != int64(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// ToSlice returns v's element values in order, discarding the keys.
+// It panics if v's type is not array.
+func (v *Value) ToSlice() []*Value {
+	a := v.Array()
+	ls := make([]*Value, len(a))
+	for i, e := range a {
+		ls[i] = e.Value
+	}
+	return ls
+}
+
+// ToMap returns v's elements keyed by their string form; int keys are
+// formatted in decimal.
+// It panics if v's type is not array.
+func (v *Value) ToMap() map[string]*Value {
+	a := v.Array()
+	m := make(map[string]*Value, len(a))
+	for _, e := range a {
+		if e.Index.t == TypeInt {
+			m[strconv.FormatInt(e.Index.Int(), 10)] = e.Value
+		} else {
+			m[e.Index.String()] = e.Value
+		}
+	}
+	return m
+}
+
 // Object returns v's underlying value.
 func (v *Value) Object() *Obj {
+	v.load()
 	uv, ok := v.i.(*Obj)
 	if !ok {
 		valueError("php.Value.Object", v.t)
@@ -124,16 +275,192 @@ func (v *Value) Object() *Obj {
 	return uv
 }
 
+// TryBool returns v's underlying value, or a *ValueError if v is not a bool
+// Value.
+func (v *Value) TryBool() (bool, error) {
+	v.load()
+	if v != nil {
+		if uv, ok := v.i.(bool); ok {
+			return uv, nil
+		}
+	}
+	return false, tryError("php.Value.TryBool", v)
+}
+
+// TryInt returns v's underlying value, or a *ValueError if v is not an int
+// Value.
+func (v *Value) TryInt() (int64, error) {
+	v.load()
+	if v != nil {
+		if uv, ok := v.i.(int64); ok {
+			return uv, nil
+		}
+	}
+	return 0, tryError("php.Value.TryInt", v)
+}
+
+// TryFloat returns v's underlying value, or a *ValueError if v is not a float
+// Value.
+func (v *Value) TryFloat() (float64, error) {
+	v.load()
+	if v != nil {
+		if uv, ok := v.i.(float64); ok {
+			return uv, nil
+		}
+	}
+	return 0, tryError("php.Value.TryFloat", v)
+}
+
+// TryString returns v's underlying value, or a *ValueError if v is not a
+// string Value.
+func (v *Value) TryString() (string, error) {
+	v.load()
+	if v != nil {
+		switch uv := v.i.(type) {
+		case string:
+			return uv, nil
+		case []byte:
+			return string(uv), nil
+		}
+	}
+	return "", tryError("php.Value.TryString", v)
+}
+
+// TryArray returns v's underlying value, or a *ValueError if v is not an
+// array Value.
+func (v *Value) TryArray() ([]*ArrayElement, error) {
+	v.load()
+	if v != nil {
+		if uv, ok := v.i.([]*ArrayElement); ok {
+			return uv, nil
+		}
+	}
+	return nil, tryError("php.Value.TryArray", v)
+}
+
+// TryObject returns v's underlying value, or a *ValueError if v is not an
+// object Value.
+func (v *Value) TryObject() (*Obj, error) {
+	v.load()
+	if v != nil {
+		if uv, ok := v.i.(*Obj); ok {
+			return uv, nil
+		}
+	}
+	return nil, tryError("php.Value.TryObject", v)
+}
+
+func tryError(method string, v *Value) error {
+	e := &ValueError{Method: method}
+	if v != nil {
+		e.Type = v.t
+	}
+	return e
+}
+
 // IsNil reports whether it's argument v is nil (PHP null)
 func (v *Value) IsNil() bool {
+	v.load()
 	return v == nil || v.t == TypeNull
 }
 
+// Lexeme returns the original text of the int or float v as it appeared in
+// the decoded data, or "" if it was not preserved.
+func (v *Value) Lexeme() string {
+	v.load()
+	return v.lex
+}
+
+// WithLexeme returns a copy of the int or float v that remembers s as its
+// original text, which encoders write instead of formatting the number, so
+// that re-encoding reproduces the input byte for byte. s must be the exact
+// text between "i:" or "d:" and ";" in the serialized data.
+// It panics if v's type is neither int nor float.
+func (v *Value) WithLexeme(s string) *Value {
+	v.load()
+	if v.t != TypeInt && v.t != TypeFloat {
+		valueError("php.Value.WithLexeme", v.t)
+	}
+	c := *v
+	c.lex = s
+	return &c
+}
+
 // Interface returns v's current value as an interface{}.
 func (v *Value) Interface() interface{} {
+	v.load()
+	if b, ok := v.i.([]byte); ok {
+		return string(b)
+	}
 	return v.i
 }
 
+// Clone returns a deep copy of v that shares no memory with v.
+// Cloning nil returns nil.
+func (v *Value) Clone() *Value {
+	var c *Value
+	// values left to copy and where to store their copies, kept in an
+	// explicit stack rather than by recursion so that deeply nested values
+	// cannot exhaust the goroutine stack
+	stack := []cloneStep{{v, &c}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		*s.dst, stack = s.src.cloneShallow(stack)
+	}
+	return c
+}
+
+// A cloneStep is a value left to copy by Clone and where to store its copy.
+type cloneStep struct {
+	src *Value
+	dst **Value
+}
+
+// cloneShallow returns a copy of v whose array elements and object fields
+// are left to copy, pushing them on stack.
+func (v *Value) cloneShallow(stack []cloneStep) (*Value, []cloneStep) {
+	v.load()
+	if v == nil {
+		return nil, stack
+	}
+	switch v.t {
+	case TypeArray:
+		arr := v.Array()
+		var ls []*ArrayElement
+		if arr != nil {
+			ls = make([]*ArrayElement, len(arr))
+			for i, e := range arr {
+				ls[i] = &ArrayElement{}
+				stack = append(stack, cloneStep{e.Index, &ls[i].Index}, cloneStep{e.Value, &ls[i].Value})
+			}
+		}
+		return Array(ls...), stack
+	case TypeObject:
+		obj := v.Object()
+		var fs []*ObjField
+		if obj.Fields != nil {
+			fs = make([]*ObjField, len(obj.Fields))
+			for i, f := range obj.Fields {
+				c := *f
+				fs[i] = &c
+				stack = append(stack, cloneStep{f.Value, &c.Value})
+			}
+		}
+		c := Object(obj.Name, fs...)
+		if obj.Serialized != nil {
+			c.Object().Serialized = append([]byte(nil), obj.Serialized...)
+		}
+		return c, stack
+	default:
+		c := *v
+		if b, ok := c.i.([]byte); ok {
+			c.i = append([]byte(nil), b...)
+		}
+		return &c, stack
+	}
+}
+
 // ArrayElement represents Array member.
 //   array index must be int or string PHP value.
 type ArrayElement struct {
@@ -145,6 +472,11 @@ type ArrayElement struct {
 type Obj struct {
 	Name   string
 	Fields []*ObjField
+	// Serialized holds the data of an object written by a class
+	// implementing PHP's Serializable interface, as a C: token, in a format
+	// of the class's own. Such objects have no Fields. It is nil for other
+	// objects.
+	Serialized []byte
 }
 
 // ObjField represents Array or Object member
@@ -152,6 +484,10 @@ type ObjField struct {
 	Name       string
 	Visibility Visibility
 	Value      *Value
+	// Class is the class declaring a private field when it is not the
+	// object's class, such as a parent class, whose field may share its
+	// name with one of the object's class. It is empty otherwise.
+	Class string
 }
 
 // Visibility for PHP class member
@@ -164,6 +500,141 @@ const (
 	VisibilityPrivate
 )
 
+// Field returns o's field named name, returns nil if not found.
+// name may also be given in its serialized (mangled) form, such as "*name"
+// for a protected field or "\x00Class\x00name" for a private one, in which
+// case the field's visibility, and the declaring class of a private field,
+// must match as well.
+func (o *Obj) Field(name string) *ObjField {
+	n, vis, _ := DemangleName(name)
+	for _, f := range o.Fields {
+		if f.Name == n && (vis == VisibilityPublic || f.Visibility == vis && (vis != VisibilityPrivate || f.MangledName(o.Name) == name)) {
+			return f
+		}
+	}
+	return nil
+}
+
+// IsAnonymous reports whether o is an instance of an anonymous class, whose
+// name PHP forms like "class@anonymous\x00/path/to/file.php:3$0".
+func (o *Obj) IsAnonymous() bool {
+	return strings.Contains(o.Name, "@anonymous\x00") || strings.HasSuffix(o.Name, "@anonymous")
+}
+
+// Incomplete classes
+const (
+	// IncompleteClass is the class of objects whose class is unavailable,
+	// such as objects of classes not allowed when decoding.
+	IncompleteClass = "__PHP_Incomplete_Class"
+	// IncompleteClassNameField is the property of an incomplete class object
+	// holding the original class name. It comes before the other fields.
+	IncompleteClassNameField = "__PHP_Incomplete_Class_Name"
+)
+
+// IncompleteClassName returns the original class name of o if o is an
+// incomplete class object, and whether it is. An incomplete class object
+// read from a C: token keeps its data in Serialized.
+func (o *Obj) IncompleteClassName() (string, bool) {
+	if o.Name != IncompleteClass || len(o.Fields) == 0 {
+		return "", false
+	}
+	f := o.Fields[0]
+	if f.Name != IncompleteClassNameField || f.Visibility != VisibilityPublic {
+		return "", false
+	}
+	name, err := f.Value.TryString()
+	return name, err == nil
+}
+
+// SetField sets the field name with visibility vis to v, replacing the value
+// of an existing field with the same name and visibility, or appending a new
+// field otherwise.
+func (o *Obj) SetField(name string, v *Value, vis Visibility) {
+	for i, f := range o.Fields {
+		if f.Name == name && f.Visibility == vis {
+			o.Fields[i] = Field(name, v, vis)
+			return
+		}
+	}
+	o.Fields = append(o.Fields, Field(name, v, vis))
+}
+
+// RemoveField removes the field that Field(name) returns and reports whether
+// there was one.
+func (o *Obj) RemoveField(name string) bool {
+	f := o.Field(name)
+	if f == nil {
+		return false
+	}
+	for i, of := range o.Fields {
+		if of == f {
+			o.Fields = append(o.Fields[:i:i], o.Fields[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// RenameClass changes o's class name to name. Private fields are mangled with
+// the new name when o is serialized.
+func (o *Obj) RenameClass(name string) {
+	o.Name = name
+}
+
+// PublicFields returns o's public fields.
+func (o *Obj) PublicFields() []*ObjField {
+	var fs []*ObjField
+	for _, f := range o.Fields {
+		if f.Visibility == VisibilityPublic {
+			fs = append(fs, f)
+		}
+	}
+	return fs
+}
+
+// MangledName returns f's property name as it is serialized in an object of
+// class class. A private field is mangled with f.Class if it is set.
+func (f *ObjField) MangledName(class string) string {
+	if f.Class != "" {
+		class = f.Class
+	}
+	return MangleName(class, f.Name, f.Visibility)
+}
+
+// MangleName returns the serialized property name of name with visibility
+// vis declared in class class.
+func MangleName(class, name string, vis Visibility) string {
+	switch vis {
+	case VisibilityProtected:
+		return "\x00*\x00" + name
+	case VisibilityPrivate:
+		return "\x00" + class + "\x00" + name
+	default: // public
+		return name
+	}
+}
+
+// DemangleName splits a serialized property name into the bare name, its
+// visibility and, for a private property, the class declaring it.
+// Besides PHP's "\x00*\x00name", the form "*name" written by earlier
+// versions of this package is accepted for protected properties.
+func DemangleName(s string) (name string, vis Visibility, class string) {
+	if strings.HasPrefix(s, "\x00*\x00") {
+		return s[3:], VisibilityProtected, ""
+	}
+	if strings.HasPrefix(s, "*") {
+		return s[1:], VisibilityProtected, ""
+	}
+	if strings.HasPrefix(s, "\x00") {
+		// the class name of an anonymous class contains NUL bytes, but the
+		// property name cannot
+		if i := strings.LastIndexByte(s, '\x00'); i > 0 {
+			return s[i+1:], VisibilityPrivate, s[1:i]
+		}
+	}
+	return s, VisibilityPublic, ""
+}
+
 // Null returns null PHP Value
 func Null() *Value {
 	return &Value{
@@ -214,6 +685,16 @@ func String(v string) *Value {
 	}
 }
 
+// Bytes returns string PHP Value holding the binary data v, such as a gzip
+// blob or an image, without converting it to a Go string. v is not copied
+// and must not be modified afterwards. Use String or Key for array keys.
+func Bytes(v []byte) *Value {
+	return &Value{
+		t: TypeString,
+		i: v,
+	}
+}
+
 // Array returns array PHP Value.
 func Array(v ...*ArrayElement) *Value {
 	return &Value{
@@ -222,6 +703,30 @@ func Array(v ...*ArrayElement) *Value {
 	}
 }
 
+// List returns array PHP Value of vs with the keys 0, 1, 2, ...
+func List(vs ...*Value) *Value {
+	ls := make([]*ArrayElement, len(vs))
+	for i, v := range vs {
+		ls[i] = Element(Int(i), v)
+	}
+	return Array(ls...)
+}
+
+// Assoc returns array PHP Value of m with the keys in sorted order. Keys are
+// converted by Key, as PHP would store them.
+func Assoc(m map[string]*Value) *Value {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ls := make([]*ArrayElement, len(keys))
+	for i, k := range keys {
+		ls[i] = Element(Key(k), m[k])
+	}
+	return Array(ls...)
+}
+
 // Append appends the values es to an array PHP value v.
 //   v's value must be array PHP value.
 func Append(v *Value, es ...*Value) *Value {
@@ -239,6 +744,65 @@ func Append(v *Value, es ...*Value) *Value {
 	return Array(ls...)
 }
 
+// AppendKV returns the array PHP value v with the value stored under key,
+// like PHP's $v[$key] = $value: an existing key keeps its position and gets
+// the new value, and a new key is appended. String keys are converted by Key.
+// v must be an array and key an int or a string.
+func AppendKV(v *Value, key, value *Value) *Value {
+	if key.Type() == TypeString {
+		key = Key(key.String())
+	} else if key.Type() != TypeInt {
+		valueError("php.AppendKV", key.Type())
+	}
+	arr := v.Array()
+	ls := make([]*ArrayElement, len(arr), len(arr)+1)
+	copy(ls, arr)
+	for i, e := range ls {
+		if Equal(e.Index, key) {
+			ls[i] = Element(e.Index, value)
+			return Array(ls...)
+		}
+	}
+	return Array(append(ls, Element(key, value))...)
+}
+
+// Merge returns the array PHP values a and b merged like PHP's array_merge:
+// the int keys of both are renumbered from 0, and the values of string keys
+// in b replace those of a, keeping their positions in a. Both must be
+// arrays.
+func Merge(a, b *Value) *Value {
+	x, y := a.Array(), b.Array()
+	ls := make([]*ArrayElement, 0, len(x)+len(y))
+	pos := map[string]int{}
+	next := 0
+	for _, arr := range [][]*ArrayElement{x, y} {
+		for _, e := range arr {
+			if e.Index.Type() == TypeInt {
+				ls = append(ls, Element(Int(next), e.Value))
+				next++
+				continue
+			}
+			if i, ok := pos[e.Index.String()]; ok {
+				ls[i] = Element(e.Index, e.Value)
+				continue
+			}
+			pos[e.Index.String()] = len(ls)
+			ls = append(ls, Element(e.Index, e.Value))
+		}
+	}
+	return Array(ls...)
+}
+
+// Key returns the array key PHP stores for the string key s: an int key if s
+// is a decimal integer in canonical form, such as "5" or "-1" but not "05"
+// or "+5", or s itself otherwise.
+func Key(s string) *Value {
+	if i, err := strconv.ParseInt(s, 10, strconv.IntSize); err == nil && strconv.FormatInt(i, 10) == s {
+		return Int(int(i))
+	}
+	return String(s)
+}
+
 // Element returns element of array PHP Value.
 func Element(index, value *Value) *ArrayElement {
 	return &ArrayElement{
@@ -258,6 +822,42 @@ func Object(name string, fields ...*ObjField) *Value {
 	}
 }
 
+// StdClass returns a stdClass object PHP Value, the class of PHP's
+// (object) casts and of objects decoded by json_decode.
+func StdClass(fields ...*ObjField) *Value {
+	return Object("stdClass", fields...)
+}
+
+// StdClassMap returns a stdClass object PHP Value with the public fields of
+// m in sorted order.
+func StdClassMap(m map[string]*Value) *Value {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := make([]*ObjField, len(names))
+	for i, name := range names {
+		fields[i] = PubField(name, m[name])
+	}
+	return StdClass(fields...)
+}
+
+// CustomObject returns an object PHP Value of class name serialized by the
+// Serializable interface as data.
+func CustomObject(name string, data []byte) *Value {
+	if data == nil {
+		data = []byte{}
+	}
+	return &Value{
+		t: TypeObject,
+		i: &Obj{
+			Name:       name,
+			Serialized: data,
+		},
+	}
+}
+
 // Field returns PHP object field.
 func Field(name string, v *Value, vis Visibility) *ObjField {
 	return &ObjField{
@@ -274,10 +874,10 @@ func PubField(name string, v *Value) *ObjField {
 
 // PrivField returns PHP object private field.
 func PrivField(name string, v *Value) *ObjField {
-	return Field(name, v, VisibilityPublic)
+	return Field(name, v, VisibilityPrivate)
 }
 
 // ProtectedField returns PHP object protected field.
 func ProtectedField(name string, v *Value) *ObjField {
-	return Field(name, v, VisibilityPublic)
+	return Field(name, v, VisibilityProtected)
 }
@@ -0,0 +1,355 @@
+package php_test
+
+import (
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestObjField(t *testing.T) {
+	obj := php.Object(
+		"Foo",
+		php.PubField("a", php.Int(1)),
+		php.ProtectedField("b", php.Int(2)),
+		php.PrivField("c", php.Int(3)),
+		&php.ObjField{Name: "c", Visibility: php.VisibilityPrivate, Value: php.Int(4), Class: "Bar"},
+	).Object()
+
+	cases := []struct {
+		name string
+		want *php.ObjField
+	}{
+		{name: "a", want: obj.Fields[0]},
+		{name: "b", want: obj.Fields[1]},
+		{name: "*b", want: obj.Fields[1]},
+		{name: "\x00*\x00b", want: obj.Fields[1]},
+		{name: "\x00Foo\x00c", want: obj.Fields[2]},
+		{name: "*a", want: nil},
+		{name: "\x00Foo\x00b", want: nil},
+		{name: "\x00Bar\x00c", want: obj.Fields[3]},
+		{name: "\x00Baz\x00c", want: nil},
+		{name: "d", want: nil},
+	}
+	for i, tc := range cases {
+		if got := obj.Field(tc.name); got != tc.want {
+			t.Errorf("#%d: Field(%q) == %#v, want: %#v", i, tc.name, got, tc.want)
+		}
+	}
+
+	if got := obj.PublicFields(); len(got) != 1 || got[0] != obj.Fields[0] {
+		t.Errorf("PublicFields() == %#v, want: [%#v]", got, obj.Fields[0])
+	}
+
+	for i, f := range obj.Fields {
+		mangled := f.MangledName(obj.Name)
+		name, vis, class := php.DemangleName(mangled)
+		if name != f.Name || vis != f.Visibility || vis == php.VisibilityPrivate && class != "Foo" && class != f.Class {
+			t.Errorf("#%d: DemangleName(%q) == %q, %v, %q, want the field %#v", i, mangled, name, vis, class, f)
+		}
+	}
+	if got := obj.Fields[3].MangledName("Foo"); got != "\x00Bar\x00c" {
+		t.Errorf("MangledName() of a parent's private field == %q, want: %q", got, "\x00Bar\x00c")
+	}
+}
+
+func TestFieldVisibility(t *testing.T) {
+	cases := []struct {
+		f    *php.ObjField
+		want php.Visibility
+	}{
+		{f: php.PubField("a", php.Null()), want: php.VisibilityPublic},
+		{f: php.ProtectedField("a", php.Null()), want: php.VisibilityProtected},
+		{f: php.PrivField("a", php.Null()), want: php.VisibilityPrivate},
+	}
+	for i, tc := range cases {
+		if tc.f.Visibility != tc.want {
+			t.Errorf("#%d: Visibility == %v, want: %v", i, tc.f.Visibility, tc.want)
+		}
+	}
+
+	// the visibility decides the serialized name
+	v := php.Object("Foo", php.ProtectedField("b", php.Int(1)), php.PrivField("c", php.Int(2)))
+	want := `O:3:"Foo":2:{s:4:"` + "\x00*\x00" + `b";i:1;s:6:"` + "\x00Foo\x00" + `c";i:2;}`
+	if got, err := v.Serialize(); err != nil || string(got) != want {
+		t.Errorf("Serialize() == %q, %v, want: %q", got, err, want)
+	}
+}
+
+func TestFieldVisibilityRoundTrip(t *testing.T) {
+	// fields built with PrivField and ProtectedField match the fields
+	// parsed from their serialized names, and not public fields
+	v := php.Object("Foo", php.PubField("a", php.Int(0)), php.ProtectedField("b", php.Int(1)), php.PrivField("c", php.Int(2)))
+	bs, err := v.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize() returns error: %v", err)
+	}
+	got, err := php.Parse(bs)
+	if err != nil {
+		t.Fatalf("Parse(%q) returns error: %v", bs, err)
+	}
+	if !php.Equal(v, got) {
+		t.Errorf("Parse(%q) == %#v, want: %#v", bs, got, v)
+	}
+	pub := php.Object("Foo", php.PubField("a", php.Int(0)), php.PubField("b", php.Int(1)), php.PubField("c", php.Int(2)))
+	if php.Equal(pub, got) {
+		t.Errorf("Parse(%q) equals an object of public fields", bs)
+	}
+}
+
+func TestTryGetters(t *testing.T) {
+	if got, err := php.Int(42).TryInt(); err != nil || got != 42 {
+		t.Errorf("Int(42).TryInt() == %v, %v, want: 42, <nil>", got, err)
+	}
+	if got, err := php.String("a").TryString(); err != nil || got != "a" {
+		t.Errorf(`String("a").TryString() == %q, %v, want: "a", <nil>`, got, err)
+	}
+
+	_, err := php.String("42").TryInt()
+	want := &php.ValueError{Method: "php.Value.TryInt", Type: php.TypeString}
+	if e, ok := err.(*php.ValueError); !ok || *e != *want {
+		t.Errorf(`String("42").TryInt() returns error: %#v, want: %#v`, err, want)
+	}
+
+	var nilValue *php.Value
+	for _, try := range []func() error{
+		func() error { _, err := nilValue.TryBool(); return err },
+		func() error { _, err := php.Null().TryFloat(); return err },
+		func() error { _, err := php.Int(1).TryArray(); return err },
+		func() error { _, err := php.Array().TryObject(); return err },
+	} {
+		if try() == nil {
+			t.Error("Try getter wants error but no error occurred")
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	v := php.Array(
+		php.Element(php.String("a"), php.Object("Foo", php.PrivField("x", php.Int(1)))),
+		php.Element(php.Int(0), php.Append(php.Array(), php.NaN())),
+	)
+	c := v.Clone()
+	if !php.Equal(v, c) {
+		t.Fatalf("Clone() == %#v, want: %#v", c, v)
+	}
+
+	c.Array()[0].Value.Object().Fields[0].Value = php.Int(2)
+	c.Array()[1].Index = php.Int(5)
+	if got := v.At("a").At("x").IntOr(0); got != 1 {
+		t.Errorf("original field changed to %d by modifying clone", got)
+	}
+	if got := v.Array()[1].Index.Int(); got != 0 {
+		t.Errorf("original key changed to %d by modifying clone", got)
+	}
+
+	custom := php.CustomObject("Foo", []byte("x"))
+	c = custom.Clone()
+	if got, err := c.Serialize(); err != nil || string(got) != `C:3:"Foo":1:{x}` {
+		t.Errorf("Clone().Serialize() == %s, %v, want: %s", got, err, `C:3:"Foo":1:{x}`)
+	}
+	c.Object().Serialized[0] = 'y'
+	if got := string(custom.Object().Serialized); got != "x" {
+		t.Errorf("original Serialized changed to %q by modifying clone", got)
+	}
+
+	var nilValue *php.Value
+	if got := nilValue.Clone(); got != nil {
+		t.Errorf("nil.Clone() == %#v, want: nil", got)
+	}
+}
+
+func TestIsList(t *testing.T) {
+	list := php.Append(php.Array(), php.Int(1), php.String("a"))
+	assoc := php.Array(
+		php.Element(php.String("a"), php.Int(1)),
+		php.Element(php.Int(7), php.Int(2)),
+	)
+
+	if !list.IsList() || !php.Array().IsList() {
+		t.Error("IsList() == false for list arrays, want: true")
+	}
+	if assoc.IsList() || php.Int(0).IsList() {
+		t.Error("IsList() == true for non-list values, want: false")
+	}
+
+	if got := list.ToSlice(); len(got) != 2 || got[1] != list.Array()[1].Value {
+		t.Errorf("ToSlice() == %#v", got)
+	}
+	m := assoc.ToMap()
+	if len(m) != 2 || m["a"].Int() != 1 || m["7"].Int() != 2 {
+		t.Errorf("ToMap() == %#v", m)
+	}
+}
+
+func TestListAndAssoc(t *testing.T) {
+	got := php.List(php.Int(1), php.String("a"))
+	want := php.Array(
+		php.Element(php.Int(0), php.Int(1)),
+		php.Element(php.Int(1), php.String("a")),
+	)
+	if !php.Equal(got, want) {
+		t.Errorf("List(...) == %#v, want: %#v", got, want)
+	}
+
+	got = php.Assoc(map[string]*php.Value{
+		"b": php.Int(2),
+		"a": php.Int(1),
+		"7": php.Null(),
+	})
+	want = php.Array(
+		php.Element(php.Int(7), php.Null()),
+		php.Element(php.String("a"), php.Int(1)),
+		php.Element(php.String("b"), php.Int(2)),
+	)
+	if !php.Equal(got, want) {
+		t.Errorf("Assoc(...) == %#v, want: %#v", got, want)
+	}
+}
+
+func TestObjMutation(t *testing.T) {
+	v := php.Object("User",
+		php.PubField("name", php.String("bob")),
+		php.PrivField("password", php.String("secret")),
+	)
+	obj := v.Object()
+	obj.SetField("name", php.String("alice"), php.VisibilityPublic)
+	obj.SetField("role", php.String("admin"), php.VisibilityProtected)
+	if !obj.RemoveField("password") {
+		t.Errorf(`RemoveField("password") == false, want: true`)
+	}
+	if obj.RemoveField("password") {
+		t.Errorf(`RemoveField("password") == true for a removed field`)
+	}
+	obj.RenameClass(`App\User`)
+
+	want := php.Object(`App\User`,
+		php.PubField("name", php.String("alice")),
+		php.ProtectedField("role", php.String("admin")),
+	)
+	if !php.Equal(v, want) {
+		t.Errorf("mutated object == %#v, want: %#v", v, want)
+	}
+}
+
+func TestStdClass(t *testing.T) {
+	got := php.StdClass(php.PubField("a", php.Int(1)))
+	want := php.Object("stdClass", php.PubField("a", php.Int(1)))
+	if !php.Equal(got, want) {
+		t.Errorf("StdClass(...) == %#v, want: %#v", got, want)
+	}
+
+	got = php.StdClassMap(map[string]*php.Value{
+		"b": php.Int(2),
+		"7": php.Null(),
+	})
+	want = php.Object("stdClass", php.PubField("7", php.Null()), php.PubField("b", php.Int(2)))
+	if !php.Equal(got, want) {
+		t.Errorf("StdClassMap(...) == %#v, want: %#v", got, want)
+	}
+	if bs, err := got.Serialize(); err != nil || string(bs) != `O:8:"stdClass":2:{s:1:"7";N;s:1:"b";i:2;}` {
+		t.Errorf("StdClassMap(...).Serialize() == %s, %v", bs, err)
+	}
+}
+
+func TestAppendKV(t *testing.T) {
+	v := php.Array(php.Element(php.Int(3), php.String("a")), php.Element(php.String("k"), php.Int(1)))
+	got := php.AppendKV(v, php.String("k"), php.Int(2))
+	got = php.AppendKV(got, php.String("7"), php.Null())
+	got = php.Append(got, php.Bool(true))
+	want := php.Array(
+		php.Element(php.Int(3), php.String("a")),
+		php.Element(php.String("k"), php.Int(2)),
+		php.Element(php.Int(7), php.Null()),
+		php.Element(php.Int(8), php.Bool(true)),
+	)
+	if !php.Equal(got, want) {
+		t.Errorf("AppendKV(...) == %#v, want: %#v", got, want)
+	}
+	if v.IndexByName("k").Int() != 1 {
+		t.Errorf("AppendKV(...) modified its argument")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := php.Array(
+		php.Element(php.String("x"), php.Int(1)),
+		php.Element(php.Int(5), php.String("a")),
+		php.Element(php.String("y"), php.Int(2)),
+	)
+	b := php.Array(
+		php.Element(php.Int(9), php.String("b")),
+		php.Element(php.String("x"), php.Int(3)),
+		php.Element(php.String("z"), php.Int(4)),
+	)
+	want := php.Array(
+		php.Element(php.String("x"), php.Int(3)),
+		php.Element(php.Int(0), php.String("a")),
+		php.Element(php.String("y"), php.Int(2)),
+		php.Element(php.Int(1), php.String("b")),
+		php.Element(php.String("z"), php.Int(4)),
+	)
+	if got := php.Merge(a, b); !php.Equal(got, want) {
+		t.Errorf("Merge(...) == %#v, want: %#v", got, want)
+	}
+}
+
+func TestArrayQueries(t *testing.T) {
+	v := php.Array(
+		php.Element(php.Int(5), php.String("a")),
+		php.Element(php.String("k"), php.Null()),
+		php.Element(php.Bytes([]byte("b")), php.Int(1)),
+	)
+	if n := v.Len(); n != 3 {
+		t.Errorf("Len() == %d, want: 3", n)
+	}
+	for _, c := range []struct {
+		key  interface{}
+		want bool
+	}{
+		{5, true},
+		{int64(5), true},
+		{"5", true},
+		{"05", false},
+		{"k", true},
+		{"x", false},
+		{6, false},
+		{"b", true},
+	} {
+		if got := v.Has(c.key); got != c.want {
+			t.Errorf("Has(%#v) == %v, want: %v", c.key, got, c.want)
+		}
+	}
+	if vs := v.Values(); len(vs) != 3 || vs[0].String() != "a" || !vs[1].IsNil() {
+		t.Errorf("Values() == %v", vs)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	v := php.Array(
+		php.Element(php.Int(3), php.String("a")),
+		php.Element(php.String("k"), php.String("b")),
+		php.Element(php.Bytes([]byte("x")), php.String("c")),
+	)
+	cases := []struct {
+		got  *php.Value
+		want *php.Value
+	}{
+		{v.Index(php.Int(3)), php.String("a")},
+		{v.Index(php.String("3")), php.String("a")},
+		{v.Index(php.String("k")), php.String("b")},
+		{v.Index(v.Keys()[1]), php.String("b")},
+		{v.Index(php.Int(4)), nil},
+		{v.Index(nil), nil},
+		{v.Index(php.String("x")), php.String("c")},
+		{v.Index(php.Bytes([]byte("k"))), php.String("b")},
+		{v.IndexByName("x"), php.String("c")},
+		{v.IndexByName("3"), nil},
+		{v.IndexByInt(3), php.String("a")},
+		{v.IndexByInt(0), nil},
+	}
+	for i, c := range cases {
+		if c.got != c.want && !php.Equal(c.got, c.want) || (c.got == nil) != (c.want == nil) {
+			t.Errorf("#%d: got %v, want: %v", i, c.got, c.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package php
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// A PathStep is a step from an array to one of its elements or from an
+// object to one of its fields.
+type PathStep struct {
+	Key   *Value    // array key, nil for object fields
+	Field *ObjField // object field, nil for array elements
+}
+
+// Path locates a value from the root of a tree of Values. The root has the
+// empty path.
+type Path []PathStep
+
+// String formats p like `["users"][0]->name`: array keys are written in
+// brackets, with string keys quoted, and object fields after "->".
+func (p Path) String() string {
+	var b strings.Builder
+	for _, s := range p {
+		if s.Field != nil {
+			b.WriteString(fieldPath(s.Field.Name))
+		} else {
+			b.WriteString(keyPath(s.Key))
+		}
+	}
+	return b.String()
+}
+
+func keyPath(key *Value) string {
+	if key.Type() == TypeInt {
+		return "[" + strconv.FormatInt(key.Int(), 10) + "]"
+	}
+	return "[" + strconv.Quote(key.String()) + "]"
+}
+
+func fieldPath(name string) string {
+	return "->" + name
+}
+
+// SkipValue is used as a return value from the function passed to Walk to
+// skip the members of the array or object it was called with. It is not
+// returned as an error by Walk.
+var SkipValue = errors.New("skip this value")
+
+// Walk calls fn for v and every value in it, depth-first with the members of
+// arrays and objects in order after the value itself. Array keys are not
+// visited. If fn returns SkipValue, the members of the value are skipped;
+// any other error stops the walk and is returned by Walk.
+//
+// The path passed to fn is only valid during the call; copy it to keep it.
+func Walk(v *Value, fn func(path Path, v *Value) error) error {
+	err := walk(nil, v, fn)
+	if err == SkipValue {
+		return nil
+	}
+	return err
+}
+
+func walk(path Path, v *Value, fn func(path Path, v *Value) error) error {
+	if err := fn(path, v); err != nil {
+		return err
+	}
+	if v.IsNil() {
+		return nil
+	}
+	switch v.Type() {
+	case TypeArray:
+		for _, e := range v.Array() {
+			if err := walk(append(path, PathStep{Key: e.Index}), e.Value, fn); err != nil && err != SkipValue {
+				return err
+			}
+		}
+	case TypeObject:
+		for _, f := range v.Object().Fields {
+			if err := walk(append(path, PathStep{Field: f}), f.Value, fn); err != nil && err != SkipValue {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Transform returns v with values replaced by fn, without modifying v. fn is
+// called for v and, unless it replaces it, for every value in it,
+// depth-first like Walk. It returns the Value to use instead, or the Value
+// it was called with to keep it and go on with its members. Arrays and
+// objects with replaced members are copied; other Values are shared with v.
+// An error returned by fn stops the transformation and is returned by
+// Transform.
+//
+// The path passed to fn is only valid during the call; copy it to keep it.
+func Transform(v *Value, fn func(path Path, v *Value) (*Value, error)) (*Value, error) {
+	return transform(nil, v, fn)
+}
+
+func transform(path Path, v *Value, fn func(path Path, v *Value) (*Value, error)) (*Value, error) {
+	nv, err := fn(path, v)
+	if err != nil || nv != v || v.IsNil() {
+		return nv, err
+	}
+	switch v.Type() {
+	case TypeArray:
+		arr := v.Array()
+		var ls []*ArrayElement
+		for i, e := range arr {
+			x, err := transform(append(path, PathStep{Key: e.Index}), e.Value, fn)
+			if err != nil {
+				return nil, err
+			}
+			if x != e.Value && ls == nil {
+				ls = append(make([]*ArrayElement, 0, len(arr)), arr...)
+			}
+			if ls != nil && x != ls[i].Value {
+				ls[i] = Element(e.Index, x)
+			}
+		}
+		if ls != nil {
+			return Array(ls...), nil
+		}
+	case TypeObject:
+		obj := v.Object()
+		var fields []*ObjField
+		for i, f := range obj.Fields {
+			x, err := transform(append(path, PathStep{Field: f}), f.Value, fn)
+			if err != nil {
+				return nil, err
+			}
+			if x != f.Value && fields == nil {
+				fields = append(make([]*ObjField, 0, len(obj.Fields)), obj.Fields...)
+			}
+			if fields != nil && x != fields[i].Value {
+				c := *f
+				c.Value = x
+				fields[i] = &c
+			}
+		}
+		if fields != nil {
+			return Object(obj.Name, fields...), nil
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,109 @@
+package php_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestWalk(t *testing.T) {
+	v := php.Array(
+		php.Element(php.String("users"), php.List(
+			php.Object("User", php.PubField("name", php.String("a")), php.PrivField("password", php.String("x"))),
+		)),
+		php.Element(php.Int(7), php.Null()),
+	)
+	var got []string
+	err := php.Walk(v, func(path php.Path, v *php.Value) error {
+		got = append(got, path.String())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk(...) returns error: %v", err)
+	}
+	want := []string{``, `["users"]`, `["users"][0]`, `["users"][0]->name`, `["users"][0]->password`, `[7]`}
+	if len(got) != len(want) {
+		t.Fatalf("Walk(...) visits %q, want: %q", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("Walk(...) visits %q, want: %q", got, want)
+			break
+		}
+	}
+
+	n := 0
+	err = php.Walk(v, func(path php.Path, v *php.Value) error {
+		n++
+		if len(path) == 1 {
+			return php.SkipValue
+		}
+		return nil
+	})
+	if err != nil || n != 3 {
+		t.Errorf("Walk(...) with SkipValue visits %d values and returns %v, want: 3, nil", n, err)
+	}
+
+	stop := errors.New("stop")
+	err = php.Walk(v, func(path php.Path, v *php.Value) error {
+		if len(path) == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("Walk(...) returns %v, want: %v", err, stop)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	unchanged := php.List(php.Int(1))
+	v := php.Array(
+		php.Element(php.String("home"), php.String("http://old.example/")),
+		php.Element(php.String("widget"), php.Object("W",
+			php.PubField("url", php.String("http://old.example/a")),
+			php.ProtectedField("n", php.Int(2)),
+		)),
+		php.Element(php.String("other"), unchanged),
+		php.Element(php.String("secret"), php.List(php.String("http://old.example/b"))),
+	)
+	orig := v.Clone()
+
+	got, err := php.Transform(v, func(path php.Path, v *php.Value) (*php.Value, error) {
+		if path.String() == `["secret"]` {
+			return php.Null(), nil
+		}
+		if !v.IsNil() && v.Type() == php.TypeString {
+			return php.String(strings.ReplaceAll(v.String(), "old.example", "new.example")), nil
+		}
+		return v, nil
+	})
+	if err != nil {
+		t.Fatalf("Transform(...) returns error: %v", err)
+	}
+	want := php.Array(
+		php.Element(php.String("home"), php.String("http://new.example/")),
+		php.Element(php.String("widget"), php.Object("W",
+			php.PubField("url", php.String("http://new.example/a")),
+			php.ProtectedField("n", php.Int(2)),
+		)),
+		php.Element(php.String("other"), php.List(php.Int(1))),
+		php.Element(php.String("secret"), php.Null()),
+	)
+	if !php.Equal(got, want) {
+		t.Errorf("Transform(...) == %v, want: %v", got, want)
+	}
+	if got.IndexByName("other") != unchanged {
+		t.Errorf("Transform(...) copied an unchanged array")
+	}
+	if !php.Equal(v, orig) {
+		t.Errorf("Transform(...) modified its argument")
+	}
+
+	stop := errors.New("stop")
+	if _, err := php.Transform(v, func(php.Path, *php.Value) (*php.Value, error) { return nil, stop }); err != stop {
+		t.Errorf("Transform(...) returns %v, want: %v", err, stop)
+	}
+}
@@ -0,0 +1,99 @@
+// Package phptest provides test assertions on PHP serialized data and
+// php.Values that report their differences by path, such as
+// `["users"][0]->name: changed from s:3:"bob"; to s:5:"alice";`.
+package phptest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// Equal reports an error on t and returns false unless want and got are the
+// same PHP value. Each of them may be PHP serialized data as a string or
+// []byte, a *php.Value, or any other Go value, which is encoded with opts
+// before the comparison. Strings and byte slices are always taken as
+// serialized data; to compare a PHP string, pass php.String(s).
+func Equal(t testing.TB, want, got interface{}, opts ...phpserialize.Option) bool {
+	t.Helper()
+	w, err := value(want, opts)
+	if err != nil {
+		t.Errorf("phptest: want: %v", err)
+		return false
+	}
+	g, err := value(got, opts)
+	if err != nil {
+		t.Errorf("phptest: got: %v", err)
+		return false
+	}
+	return DiffValues(t, w, g)
+}
+
+// DiffValues reports an error on t listing the differences between the
+// wanted value a and the value b, one per line with its path, and returns
+// false if there are any. It returns true if php.Equal(a, b) is true.
+func DiffValues(t testing.TB, a, b *php.Value) bool {
+	t.Helper()
+	ds := php.Diff(a, b)
+	if len(ds) == 0 {
+		return true
+	}
+	var sb strings.Builder
+	sb.WriteString("PHP values differ:")
+	for _, d := range ds {
+		sb.WriteString("\n\t")
+		sb.WriteString(Describe(d))
+	}
+	t.Error(sb.String())
+	return false
+}
+
+// Describe formats the difference d as a line such as
+// `["id"]: changed from i:1; to i:2;`, writing values as serialized data.
+func Describe(d php.Difference) string {
+	path := d.Path
+	if path == "" {
+		path = "(root)"
+	}
+	switch d.Kind {
+	case php.DiffAdded:
+		return fmt.Sprintf("%s: unexpected %s", path, format(d.New))
+	case php.DiffRemoved:
+		return fmt.Sprintf("%s: missing %s", path, format(d.Old))
+	case php.DiffReordered:
+		return fmt.Sprintf("%s: members reordered from %s to %s", path, format(d.Old), format(d.New))
+	}
+	return fmt.Sprintf("%s: changed from %s to %s", path, format(d.Old), format(d.New))
+}
+
+// value converts an argument of Equal to a Value.
+func value(x interface{}, opts []phpserialize.Option) (*php.Value, error) {
+	switch x := x.(type) {
+	case *php.Value:
+		return x, nil
+	case string:
+		return phpserialize.UnmarshalWithOptions([]byte(x), opts...)
+	case []byte:
+		return phpserialize.UnmarshalWithOptions(x, opts...)
+	}
+	data, err := phpserialize.MarshalWithOptions(x, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return phpserialize.UnmarshalWithOptions(data, opts...)
+}
+
+// format returns the serialized data of v for messages.
+func format(v *php.Value) string {
+	if v == nil {
+		return "nothing"
+	}
+	data, err := v.Serialize()
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,82 @@
+package phptest_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+	"github.com/kamiaka/go-phpserialize/phptest"
+)
+
+// recorder is a testing.TB that records the errors reported to it.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Error(args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestEqual(t *testing.T) {
+	type user struct {
+		ID   int    `php:"id"`
+		Name string `php:"name"`
+	}
+	cases := []struct {
+		want, got interface{}
+		lines     []string
+	}{
+		{want: `a:1:{i:0;s:1:"a";}`, got: []byte(`a:1:{i:0;s:1:"a";}`)},
+		{want: `a:1:{i:0;s:1:"a";}`, got: []string{"a"}},
+		{want: php.List(php.Int(1)), got: `a:1:{i:0;i:1;}`},
+		{
+			want:  `O:4:"user":2:{s:2:"id";i:1;s:4:"name";s:3:"bob";}`,
+			got:   user{ID: 2, Name: "bob"},
+			lines: []string{`->id: changed from i:1; to i:2;`},
+		},
+		{
+			want:  `a:2:{s:1:"a";i:1;s:1:"b";i:2;}`,
+			got:   map[string]int{"a": 1, "c": 3},
+			lines: []string{`["b"]: missing i:2;`, `["c"]: unexpected i:3;`},
+		},
+		{want: `i:1;`, got: `s:1:"1";`, lines: []string{`(root): changed from i:1; to s:1:"1";`}},
+		{want: `a:1:{`, got: `i:1;`, lines: []string{"phptest: want: "}},
+	}
+	for i, tc := range cases {
+		r := &recorder{TB: t}
+		ok := phptest.Equal(r, tc.want, tc.got)
+		if ok != (len(tc.lines) == 0) {
+			t.Errorf("#%d: Equal(...) == %v with errors %q", i, ok, r.errors)
+		}
+		msg := strings.Join(r.errors, "\n")
+		for _, l := range tc.lines {
+			if !strings.Contains(msg, l) {
+				t.Errorf("#%d: Equal(...) reports %q, want it to contain %q", i, msg, l)
+			}
+		}
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	r := &recorder{TB: t}
+	a := php.Array(php.Element(php.String("users"), php.List(php.Object("User", php.PubField("name", php.String("bob"))))))
+	b := php.Array(php.Element(php.String("users"), php.List(php.Object("User", php.PubField("name", php.String("alice"))))))
+	if phptest.DiffValues(r, a, b) {
+		t.Errorf("DiffValues(...) == true for different values")
+	}
+	want := "PHP values differ:\n\t" + `["users"][0]->name: changed from s:3:"bob"; to s:5:"alice";`
+	if len(r.errors) != 1 || r.errors[0] != want {
+		t.Errorf("DiffValues(...) reports %q, want: %q", r.errors, want)
+	}
+	if !phptest.DiffValues(t, a, a) {
+		t.Errorf("DiffValues(...) == false for equal values")
+	}
+}
@@ -0,0 +1,59 @@
+package phpserialize
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// RawMessage is a raw PHP serialized value. It implements Marshaler and
+// can be used to embed pre-serialized values, e.g. from a database, into a
+// larger structure without decoding and re-encoding them, or to keep part of
+// a decoded value serialized.
+type RawMessage []byte
+
+// MarshalPHPSerialize returns m, or N; if m is nil.
+func (m RawMessage) MarshalPHPSerialize() ([]byte, error) {
+	if m == nil {
+		return sNil, nil
+	}
+	return m, nil
+}
+
+var (
+	marshalerType  = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	rawMessageType = reflect.TypeOf(RawMessage(nil))
+)
+
+// Valid reports whether data is a single valid PHP serialized value.
+func Valid(data []byte) bool {
+	_, err := Unmarshal(data)
+	return err == nil
+}
+
+// MarshalerError is returned when a Marshaler, an encoding.TextMarshaler or a
+// registered encoder fails, or when a Marshaler returns invalid data with
+// validation enabled.
+type MarshalerError struct {
+	Type reflect.Type
+	Err  error
+}
+
+func (e *MarshalerError) Error() string {
+	return "PHP serialize: error marshaling type " + e.Type.String() + ": " + e.Err.Error()
+}
+
+func (e *MarshalerError) Unwrap() error {
+	return e.Err
+}
+
+// writeMarshaler writes the output of m.
+func (e *encodeState) writeMarshaler(m Marshaler) {
+	bs, err := m.MarshalPHPSerialize()
+	if err == nil && e.validate && !Valid(bs) {
+		err = fmt.Errorf("invalid serialized value %q", bs)
+	}
+	if err != nil {
+		raiseError(&MarshalerError{reflect.TypeOf(m), err})
+	}
+	e.Write(bs)
+}
@@ -0,0 +1,65 @@
+package phpserialize
+
+import (
+	"github.com/kamiaka/go-phpserialize/internal/decoding"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// A DecodeError describes a problem found in damaged data by
+// UnmarshalRecover.
+type DecodeError struct {
+	Offset int64  // offset in the data where the problem was found
+	Path   string // path of the value being read, like "orders[3].price"
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// UnmarshalRecover decodes data like UnmarshalWithOptions but salvages what
+// it can of damaged data instead of failing. It returns the Value decoded so
+// far along with the problems found, in the order they were found, or nil
+// errors if data is valid.
+//
+// Strings whose length does not match their contents are cut at the next
+// `";`, and arrays and objects are read up to their closing brace whatever
+// their count says. Repeated keys with WithStrictKeys and invalid UTF-8 with
+// WithValidUTF8 are recorded and kept as decoded. Other problems end
+// decoding: the value being read is dropped and the arrays and objects
+// holding it keep the members read before it. The returned Value is nil only
+// if nothing could be decoded.
+func UnmarshalRecover(data []byte, opts ...Option) (*php.Value, []*DecodeError) {
+	o := newOptions(opts)
+	o.lazy = false // damaged values cannot be decoded later
+	o.allErrors = false
+	r := phpDecoder.Decode(&decoding.Request{
+		Data:    data,
+		Options: o.decodeOptions(),
+		Recover: true,
+	})
+	errs := decodeErrors(r.Problems)
+	if r.Err != nil {
+		// limits checked before reading any value
+		errs = append(errs, &DecodeError{Err: r.Err})
+	}
+	return r.Value, errs
+}
+
+// decodeErrors returns the DecodeErrors of the problems found by the
+// decoder, or nil if there are none.
+func decodeErrors(problems []decoding.Problem) []*DecodeError {
+	var errs []*DecodeError
+	for _, p := range problems {
+		errs = append(errs, &DecodeError{
+			Offset: int64(p.Offset),
+			Path:   p.Path,
+			Err:    p.Err,
+		})
+	}
+	return errs
+}
@@ -0,0 +1,128 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestUnmarshalRecover(t *testing.T) {
+	cases := []struct {
+		data    string
+		want    *php.Value
+		offsets []int64
+		paths   []string
+	}{
+		{
+			data: `a:1:{s:1:"a";i:1;}`,
+			want: php.Array(php.Element(php.String("a"), php.Int(1))),
+		},
+		{
+			data:    `a:2:{i:0;s:5:"abc";i:1;b:1;}`,
+			want:    php.List(php.String("abc"), php.Bool(true)),
+			offsets: []int64{13},
+			paths:   []string{"[0]"},
+		},
+		{
+			data:    `a:3:{i:0;i:1;i:1;i:2;}`,
+			want:    php.List(php.Int(1), php.Int(2)),
+			offsets: []int64{21},
+			paths:   []string{""},
+		},
+		{
+			data:    `a:1:{i:0;i:1;i:1;i:2;}`,
+			want:    php.List(php.Int(1), php.Int(2)),
+			offsets: []int64{13},
+			paths:   []string{""},
+		},
+		{
+			data:    `a:2:{s:1:"a";a:2:{i:0;i:1;i:1;x:2;}s:1:"b";i:3;}`,
+			want:    php.Array(php.Element(php.String("a"), php.List(php.Int(1)))),
+			offsets: []int64{30},
+			paths:   []string{"a[1]"},
+		},
+		{
+			data:    `O:3:"Foo":2:{s:1:"a";i:1;s:1:"b";i:`,
+			want:    php.Object("Foo", php.Field("a", php.Int(1), php.VisibilityPublic)),
+			offsets: []int64{35},
+			paths:   []string{"b"},
+		},
+		{
+			data:    `a:2:{i:0;i:1;`,
+			want:    php.List(php.Int(1)),
+			offsets: []int64{13},
+			paths:   []string{""},
+		},
+		{
+			data:    `i:1;i:2;`,
+			want:    php.Int(1),
+			offsets: []int64{4},
+			paths:   []string{""},
+		},
+		{
+			data:    `a:2:{i:0;s:1:"a";i:1;s:9223372036854775806:"abc";}`,
+			want:    php.List(php.String("a"), php.String("abc")),
+			offsets: []int64{43},
+			paths:   []string{"[1]"},
+		},
+		{
+			data:    `x:1;`,
+			offsets: []int64{0},
+			paths:   []string{""},
+		},
+	}
+	for i, tc := range cases {
+		got, errs := phpserialize.UnmarshalRecover([]byte(tc.data))
+		if tc.want == nil {
+			if got != nil {
+				t.Errorf("#%d: UnmarshalRecover(%q) = %v, want nil", i, tc.data, got)
+			}
+		} else if !php.Equal(got, tc.want) {
+			t.Errorf("#%d: UnmarshalRecover(%q) = %v, want %v", i, tc.data, got, tc.want)
+		}
+		if len(errs) != len(tc.offsets) {
+			t.Errorf("#%d: UnmarshalRecover(%q) returns errors %v, want %d", i, tc.data, errs, len(tc.offsets))
+			continue
+		}
+		for j, err := range errs {
+			if err.Offset != tc.offsets[j] || err.Path != tc.paths[j] {
+				t.Errorf("#%d: error %d at %d, %q (%v), want %d, %q", i, j, err.Offset, err.Path, err, tc.offsets[j], tc.paths[j])
+			}
+		}
+	}
+}
+
+func TestUnmarshalWithAllErrors(t *testing.T) {
+	data := "a:3:{i:0;s:5:\"abc\";i:0;s:1:\"\xff\";i:1;b:1;}"
+	_, err := phpserialize.UnmarshalWithOptions([]byte(data), phpserialize.WithStrictKeys(), phpserialize.WithValidUTF8())
+	if err == nil || strings.Contains(err.Error(), "\n") {
+		t.Fatalf("UnmarshalWithOptions(%q) returns error %v, want a single error", data, err)
+	}
+
+	v, err := phpserialize.UnmarshalWithOptions([]byte(data), phpserialize.WithStrictKeys(), phpserialize.WithValidUTF8(), phpserialize.WithAllErrors())
+	if v != nil {
+		t.Errorf("UnmarshalWithOptions(%q) = %v, want nil", data, v)
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("UnmarshalWithOptions(%q) returns error %v, want joined errors", data, err)
+	}
+	want := []int64{13, 23, 19}
+	errs := joined.Unwrap()
+	if len(errs) != len(want) {
+		t.Fatalf("UnmarshalWithOptions(%q) returns errors %v, want %d", data, errs, len(want))
+	}
+	for i, e := range errs {
+		var de *phpserialize.DecodeError
+		if !errors.As(e, &de) || de.Offset != want[i] {
+			t.Errorf("#%d: error %v, want a DecodeError at %d", i, e, want[i])
+		}
+	}
+
+	if _, err := phpserialize.UnmarshalWithOptions([]byte(`a:1:{i:0;i:1;}`), phpserialize.WithAllErrors()); err != nil {
+		t.Errorf("UnmarshalWithOptions returns error: %v", err)
+	}
+}
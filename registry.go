@@ -0,0 +1,137 @@
+package phpserialize
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+var classRegistry sync.Map // map[string]reflect.Type
+
+// RegisterName records the concrete Go type of value under the PHP class name
+// name. When an object of that class is decoded into an interface value, a
+// new value of the registered type is created and the object is decoded into
+// it. value may be a struct or a pointer to a struct; the interface is
+// assigned a value of the same type as value.
+//
+// RegisterName panics if name is already registered for a different type.
+func RegisterName(name string, value interface{}) {
+	t := reflect.TypeOf(value)
+	if t == nil {
+		panic("php serialize: RegisterName with nil value")
+	}
+	if old, loaded := classRegistry.LoadOrStore(name, t); loaded && old != t {
+		panic(fmt.Sprintf("php serialize: registering duplicate types for %q: %v != %v", name, old, t))
+	}
+}
+
+// Register records the concrete Go type of value like RegisterName, under
+// the PHP class name the type is encoded as: the result of its PHPClassName
+// method if it implements ClassNamer, else its Go type name.
+func Register(value interface{}) {
+	t := reflect.TypeOf(value)
+	if t == nil {
+		panic("php serialize: Register with nil value")
+	}
+	var name string
+	if cn, ok := value.(ClassNamer); ok {
+		name = cn.PHPClassName()
+	} else if t.Kind() == reflect.Ptr {
+		name = t.Elem().Name()
+	} else {
+		name = t.Name()
+	}
+	if name == "" {
+		panic(fmt.Sprintf("php serialize: Register with unnamed type %v", t))
+	}
+	RegisterName(name, value)
+}
+
+// registeredType returns the Go type registered for the PHP class name.
+func registeredType(name string) (reflect.Type, bool) {
+	t, ok := classRegistry.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return t.(reflect.Type), true
+}
+
+var (
+	typeEncoders    sync.Map // map[reflect.Type]func(reflect.Value) (*php.Value, error)
+	hasTypeEncoders int32    // set once an encoder is registered
+)
+
+// RegisterEncoder registers fn to encode values of type T, such as
+// third-party types like decimal or UUID types that cannot implement
+// Marshaler. The returned php.Value is encoded in place of the value.
+// Registered encoders take precedence over Marshaler and
+// encoding.TextMarshaler implementations. Registering an encoder for a type
+// again replaces it.
+func RegisterEncoder[T any](fn func(T) (*php.Value, error)) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	typeEncoders.Store(t, func(v reflect.Value) (*php.Value, error) {
+		return fn(v.Interface().(T))
+	})
+	atomic.StoreInt32(&hasTypeEncoders, 1)
+}
+
+// typeEncoder returns the encoder registered for t.
+func typeEncoder(t reflect.Type) (func(reflect.Value) (*php.Value, error), bool) {
+	if atomic.LoadInt32(&hasTypeEncoders) == 0 {
+		return nil, false
+	}
+	fn, ok := typeEncoders.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return fn.(func(reflect.Value) (*php.Value, error)), true
+}
+
+var (
+	typeDecoders  sync.Map // map[reflect.Type]func(*php.Value) (reflect.Value, error)
+	classDecoders sync.Map // map[string]func(*php.Value) (interface{}, error)
+)
+
+// RegisterDecoder registers fn to decode values into Go values of type T,
+// such as money or UUID types, when UnmarshalInto and related functions
+// store a decoded value in a T. Registering a decoder for a type again
+// replaces it.
+func RegisterDecoder[T any](fn func(*php.Value) (T, error)) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	typeDecoders.Store(t, func(v *php.Value) (reflect.Value, error) {
+		x, err := fn(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(&x).Elem(), nil
+	})
+}
+
+// RegisterClassDecoder registers fn to decode objects of the PHP class name
+// stored in interface values, taking precedence over RegisterName. The
+// result of fn must be assignable to the interface. Registering a decoder for
+// a class again replaces it.
+func RegisterClassDecoder(name string, fn func(*php.Value) (interface{}, error)) {
+	classDecoders.Store(name, fn)
+}
+
+// typeDecoder returns the decoder registered for t.
+func typeDecoder(t reflect.Type) (func(*php.Value) (reflect.Value, error), bool) {
+	fn, ok := typeDecoders.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return fn.(func(*php.Value) (reflect.Value, error)), true
+}
+
+// classDecoder returns the decoder registered for the PHP class name.
+func classDecoder(name string) (func(*php.Value) (interface{}, error), bool) {
+	fn, ok := classDecoders.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return fn.(func(*php.Value) (interface{}, error)), true
+}
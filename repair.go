@@ -0,0 +1,68 @@
+package phpserialize
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Repair fixes the lengths of the strings in the PHP serialized data that no
+// longer match their contents, as happens after a search and replace or a
+// character set conversion on the serialized text. Like the fixer scripts
+// commonly used with PHP, a string whose declared length does not end at a
+// closing `";` is taken to end at the next `";`, so Repair cannot fix strings
+// that contain `";` themselves. Strings with correct lengths are kept as they
+// are. Repair does not check that the result is valid; use Valid for that.
+func Repair(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		if data[i] != 's' || i > 0 && strings.IndexByte(";{}", data[i-1]) < 0 {
+			out = append(out, data[i])
+			i++
+			continue
+		}
+		n, start, ok := repairStrHeader(data, i)
+		if !ok {
+			out = append(out, data[i])
+			i++
+			continue
+		}
+		end := start + n
+		if n < 0 || n > len(data)-start-2 || data[end] != '"' || data[end+1] != ';' {
+			k := bytes.Index(data[start:], []byte(`";`))
+			if k < 0 {
+				return nil, errors.New("php serialize: cannot repair unterminated string at offset " + strconv.Itoa(i))
+			}
+			end = start + k
+		}
+		out = append(out, "s:"...)
+		out = strconv.AppendInt(out, int64(end-start), 10)
+		out = append(out, `:"`...)
+		out = append(out, data[start:end]...)
+		out = append(out, `";`...)
+		i = end + 2
+	}
+	return out, nil
+}
+
+// repairStrHeader parses the `s:N:"` header at data[i:], returning N and the
+// offset of the string contents.
+func repairStrHeader(data []byte, i int) (n, start int, ok bool) {
+	j := i + 2
+	if j >= len(data) || data[i+1] != ':' {
+		return 0, 0, false
+	}
+	k := j
+	for k < len(data) && '0' <= data[k] && data[k] <= '9' {
+		k++
+	}
+	if k == j || k+2 > len(data) || data[k] != ':' || data[k+1] != '"' {
+		return 0, 0, false
+	}
+	n, err := strconv.Atoi(string(data[j:k]))
+	if err != nil {
+		n = -1
+	}
+	return n, k + 2, true
+}
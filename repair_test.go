@@ -0,0 +1,50 @@
+package phpserialize_test
+
+import (
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+)
+
+func TestRepair(t *testing.T) {
+	cases := []struct {
+		data       string
+		want       string
+		wantsError bool
+	}{
+		{data: `s:3:"abc";`, want: `s:3:"abc";`},
+		{data: `s:3:"héllo";`, want: `s:6:"héllo";`},
+		{data: `a:2:{i:0;s:5:"a";i:1;b:1;}`, want: `a:2:{i:0;s:1:"a";i:1;b:1;}`},
+		{data: `s:4:"a";b";`, want: `s:4:"a";b";`},
+		{
+			data: `a:2:{s:4:"site";s:18:"http://example.org";s:4:"urls";a:1:{i:0;s:22:"https://example.org/x";}}`,
+			want: `a:2:{s:4:"site";s:18:"http://example.org";s:4:"urls";a:1:{i:0;s:21:"https://example.org/x";}}`,
+		},
+		{
+			data: `O:3:"Foo":1:{s:4:"path";s:1:"/var/www";}`,
+			want: `O:3:"Foo":1:{s:4:"path";s:8:"/var/www";}`,
+		},
+		{data: `s:3:"abc`, wantsError: true},
+		{data: `s:9223372036854775807:"abc";`, want: `s:3:"abc";`},
+		{data: `s:9223372036854775806:"abc";`, want: `s:3:"abc";`},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.Repair([]byte(tc.data))
+		if err != nil {
+			if !tc.wantsError {
+				t.Errorf("#%d: Repair(%q) returns error: %v", i, tc.data, err)
+			}
+			continue
+		}
+		if tc.wantsError {
+			t.Errorf("#%d: Repair(%q) wants error but no error occurred", i, tc.data)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("#%d: Repair(%q) == %q, want: %q", i, tc.data, got, tc.want)
+		}
+		if !phpserialize.Valid(got) {
+			t.Errorf("#%d: Repair(%q) == %q, which is not valid", i, tc.data, got)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package phpserialize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// UnmarshalSession parses session data in the format of PHP's default "php"
+// session serializer, as stored by native session handlers, in which every
+// session variable is written as its name, a '|' and its serialized value.
+// The variables are returned as an array Value keyed by their names.
+func UnmarshalSession(data []byte, opts ...Option) (*php.Value, error) {
+	var es []*php.ArrayElement
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '|')
+		if i < 0 {
+			return nil, errors.New("php serialize: session variable without '|'")
+		}
+		name := string(data[:i])
+		v, rest, err := UnmarshalPartial(data[i+1:], opts...)
+		if err != nil {
+			return nil, fmt.Errorf("php serialize: session variable %q: %w", name, err)
+		}
+		es = append(es, php.Element(php.String(name), v))
+		data = rest
+	}
+	return php.Array(es...), nil
+}
+
+// MarshalSession returns the session data of the session variables held by
+// the array v in the format of PHP's "php" session serializer. Names may not
+// contain '|' or '!'.
+func MarshalSession(v *php.Value, opts ...Option) ([]byte, error) {
+	arr, err := v.TryArray()
+	if err != nil {
+		return nil, err
+	}
+	var buf []byte
+	for _, e := range arr {
+		name := e.Index.Interface()
+		s := fmt.Sprint(name)
+		if strings.ContainsAny(s, "|!") {
+			return nil, fmt.Errorf("php serialize: invalid session variable name %q", s)
+		}
+		bs, err := MarshalWithOptions(e.Value, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("php serialize: session variable %q: %w", s, err)
+		}
+		buf = append(buf, s...)
+		buf = append(buf, '|')
+		buf = append(buf, bs...)
+	}
+	return buf, nil
+}
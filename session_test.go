@@ -0,0 +1,40 @@
+package phpserialize_test
+
+import (
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestSession(t *testing.T) {
+	data := `user|a:1:{s:2:"id";i:5;}count|i:3;empty|s:0:"";`
+	want := php.Array(
+		php.Element(php.String("user"), php.Assoc(map[string]*php.Value{"id": php.Int(5)})),
+		php.Element(php.String("count"), php.Int(3)),
+		php.Element(php.String("empty"), php.String("")),
+	)
+	v, err := phpserialize.UnmarshalSession([]byte(data))
+	if err != nil {
+		t.Fatalf("UnmarshalSession(%q) returns error: %v", data, err)
+	}
+	if !php.Equal(v, want) {
+		t.Errorf("UnmarshalSession(%q) == %#v, want: %#v", data, v, want)
+	}
+	bs, err := phpserialize.MarshalSession(v)
+	if err != nil {
+		t.Fatalf("MarshalSession(...) returns error: %v", err)
+	}
+	if string(bs) != data {
+		t.Errorf("MarshalSession(...) == %q, want: %q", bs, data)
+	}
+
+	for _, data := range []string{`user`, `user|i:1`, `a|i:1;b`} {
+		if _, err := phpserialize.UnmarshalSession([]byte(data)); err == nil {
+			t.Errorf("UnmarshalSession(%q) returns no error", data)
+		}
+	}
+	if _, err := phpserialize.MarshalSession(php.Assoc(map[string]*php.Value{"a|b": php.Null()})); err == nil {
+		t.Errorf("MarshalSession(invalid name) returns no error")
+	}
+}
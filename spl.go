@@ -0,0 +1,17 @@
+package phpserialize
+
+import "github.com/kamiaka/go-phpserialize/php"
+
+// SPLAsArray returns the contents of v as an ordinary array Value if v is an
+// ArrayObject, ArrayIterator, RecursiveArrayIterator, SplFixedArray,
+// SplObjectStorage, SplDoublyLinkedList, SplQueue or SplStack object, and v
+// itself otherwise. Both the C: form written before PHP 7.4 and the O: form
+// of later versions are understood.
+//
+// ArrayObjects and iterators give their storage, SplFixedArrays and lists
+// give their elements as a list, and SplObjectStorages give a list of arrays
+// holding each object under "obj" and its data under "inf", as var_dump
+// shows them. Flags and other properties are dropped.
+func SPLAsArray(v *php.Value) (*php.Value, error) {
+	return phpDecoder.SPLAsArray(v)
+}
@@ -0,0 +1,94 @@
+package phpserialize_test
+
+import (
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestSPLAsArray(t *testing.T) {
+	std := php.Object("stdClass")
+	cases := []struct {
+		data    string
+		want    *php.Value
+		wantErr bool
+	}{
+		{
+			data: `C:11:"ArrayObject":33:{x:i:0;a:1:{s:1:"a";i:1;};m:a:0:{}}`,
+			want: php.Array(php.Element(php.String("a"), php.Int(1))),
+		},
+		{
+			data: `O:11:"ArrayObject":4:{i:0;i:0;i:1;a:1:{s:1:"a";i:1;}i:2;a:0:{}i:3;N;}`,
+			want: php.Array(php.Element(php.String("a"), php.Int(1))),
+		},
+		{
+			data: `C:13:"ArrayIterator":44:{x:i:0;O:3:"Foo":1:{s:4:"` + "\x00*\x00" + `b";i:2;};m:a:0:{}}`,
+			want: php.Array(php.Element(php.String("\x00*\x00b"), php.Int(2))),
+		},
+		{
+			data: `O:13:"SplFixedArray":2:{i:0;s:1:"a";i:1;N;}`,
+			want: php.List(php.String("a"), php.Null()),
+		},
+		{
+			data: `C:16:"SplObjectStorage":39:{x:i:1;O:8:"stdClass":0:{},i:7;;m:a:0:{}}`,
+			want: php.List(php.Array(php.Element(php.String("obj"), std), php.Element(php.String("inf"), php.Int(7)))),
+		},
+		{
+			data: `O:16:"SplObjectStorage":2:{i:0;a:2:{i:0;O:8:"stdClass":0:{}i:1;N;}i:1;a:0:{}}`,
+			want: php.List(php.Array(php.Element(php.String("obj"), std), php.Element(php.String("inf"), php.Null()))),
+		},
+		{
+			data: `C:8:"SplQueue":18:{i:4;:s:1:"a";:i:1;}`,
+			want: php.List(php.String("a"), php.Int(1)),
+		},
+		{
+			data: `O:8:"SplStack":3:{i:0;i:6;i:1;a:1:{i:0;b:1;}i:2;a:0:{}}`,
+			want: php.List(php.Bool(true)),
+		},
+		{
+			data: `O:3:"Foo":0:{}`,
+			want: php.Object("Foo"),
+		},
+		{
+			data:    `C:11:"ArrayObject":5:{x:i:0}`,
+			wantErr: true,
+		},
+		{
+			data:    `O:11:"ArrayObject":0:{}`,
+			wantErr: true,
+		},
+	}
+	for i, c := range cases {
+		v, err := phpserialize.Unmarshal([]byte(c.data))
+		if err != nil {
+			t.Fatalf("#%d: Unmarshal(%q) returns error: %v", i, c.data, err)
+		}
+		got, err := phpserialize.SPLAsArray(v)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("#%d: SPLAsArray(%q) returns no error", i, c.data)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: SPLAsArray(%q) returns error: %v", i, c.data, err)
+			continue
+		}
+		if !php.Equal(got, c.want) {
+			t.Errorf("#%d: SPLAsArray(%q) == %v, want: %v", i, c.data, got, c.want)
+		}
+	}
+}
+
+func TestUnmarshalWithSPLAsArrays(t *testing.T) {
+	data := []byte(`a:1:{s:5:"queue";C:8:"SplQueue":50:{i:4;:C:11:"ArrayObject":21:{x:i:0;a:0:{};m:a:0:{}}}}`)
+	got, err := phpserialize.UnmarshalWithOptions(data, phpserialize.WithSPLAsArrays())
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions(%q) returns error: %v", data, err)
+	}
+	want := php.Array(php.Element(php.String("queue"), php.List(php.Array())))
+	if !php.Equal(got, want) {
+		t.Errorf("UnmarshalWithOptions(%q) == %v, want: %v", data, got, want)
+	}
+}
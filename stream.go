@@ -1,27 +1,302 @@
 package phpserialize
 
-import "io"
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/kamiaka/go-phpserialize/internal/decoding"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// A Decoder reads and decodes PHP serialized values from an input stream.
+type Decoder struct {
+	opts  options
+	r     io.Reader
+	buf   []byte
+	scanp int   // start of unread data in buf
+	done  int64 // bytes of the input discarded from buf
+	last  int   // length of the last decoded value, which ends at scanp
+	err   error
+	hash  hash.Hash
+
+	scan    *decoding.Scanner // scan of the value at scanp, nil between values
+	scanned int               // bytes after scanp passed to scan
+}
+
+// NewDecoder returns a new decoder that reads from r and applies opts to
+// every decoded value.
+//
+// The decoder introduces its own buffering and may read data from r beyond
+// the PHP serialized values requested.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	return &Decoder{
+		opts: newOptions(opts),
+		r:    r,
+	}
+}
+
+// SetHash sets h to be fed the bytes of every value successfully decoded
+// afterwards, so a digest of the stream can be computed in the same pass.
+// In lenient mode, the semicolons doubled after the last value are fed to h
+// too, so that the digest covers all the bytes read. Buffered bytes that
+// have not been decoded yet are not written to h.
+// Passing nil disables hashing.
+func (dec *Decoder) SetHash(h hash.Hash) {
+	dec.hash = h
+}
+
+// Decode reads the next PHP serialized value from its input and returns it.
+// At the end of the input stream, Decode returns io.EOF.
+func (dec *Decoder) Decode() (*php.Value, error) {
+	return dec.decode(nil)
+}
+
+// decode implements Decode and DecodeContext. ctx may be nil.
+func (dec *Decoder) decode(ctx context.Context) (*php.Value, error) {
+	return dec.next(ctx)
+}
+
+// next reads the next value from the input, reading more of the input until
+// the value is complete. The bytes read are scanned as they arrive, keeping
+// the state of the scan between reads, and the value is decoded once it is
+// complete, so that a large value is not parsed again from its first byte
+// after every read. ctx may be nil.
+func (dec *Decoder) next(ctx context.Context) (*php.Value, error) {
+	defer dec.resetScan()
+	for {
+		if dec.scan == nil {
+			o := dec.opts.decodeOptions()
+			o.MaxBytes = 0 // checked below while the value is incomplete
+			dec.scan = &decoding.Scanner{Options: o, Ctx: ctx}
+		}
+		_, done, err := dec.scan.Scan(dec.buf[dec.scanp+dec.scanned:])
+		if done || err != nil {
+			return dec.decodeScanned(ctx, err)
+		}
+		dec.scanned = len(dec.buf) - dec.scanp
+		if max := dec.opts.maxBytes; max > 0 && len(dec.buf)-dec.scanp > max {
+			return nil, tooLargeError("value size exceeds limit of %d bytes", max)
+		}
+		if max := dec.opts.maxInputBytes; max > 0 && dec.done+int64(len(dec.buf)) > max {
+			return nil, tooLargeError("input exceeds limit of %d bytes", max)
+		}
+		if dec.err != nil {
+			if dec.err == io.EOF {
+				if rest := dec.buf[dec.scanp:]; dec.opts.lenient && len(bytes.Trim(rest, ";")) == 0 {
+					// semicolons doubled after the last value, which are
+					// hashed like those before a value
+					if dec.hash != nil {
+						dec.hash.Write(rest)
+					}
+					dec.scanp = len(dec.buf)
+				}
+				if dec.scanp == len(dec.buf) {
+					return nil, io.EOF
+				}
+				if lr, ok := dec.r.(*io.LimitedReader); ok && lr.N <= 0 {
+					return nil, tooLargeError("value cut off by the read limit")
+				}
+				// the decoder reports where the value is cut off
+				return dec.decodeScanned(ctx, nil)
+			}
+			return nil, dec.err
+		}
+		if ctx != nil {
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf("php serialize: %w", err)
+			}
+		}
+		dec.refill()
+	}
+}
+
+// decodeScanned decodes the value at scanp once the scan has found its end
+// or failed with scanErr. The decoder checks what the scan does not, and
+// reports errors at the first malformed byte the same way as Unmarshal; the
+// error of the scan is returned only when the decoder finds none.
+func (dec *Decoder) decodeScanned(ctx context.Context, scanErr error) (*php.Value, error) {
+	r := phpDecoder.Decode(&decoding.Request{
+		Data:    dec.buf[dec.scanp:],
+		Options: dec.opts.decodeOptions(),
+		Mode:    decoding.Prefix,
+		Ctx:     ctx,
+	})
+	if scanErr != nil && (r.Err == nil || errors.Is(r.Err, io.ErrUnexpectedEOF)) {
+		return nil, scanErr
+	}
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if dec.hash != nil {
+		dec.hash.Write(dec.buf[dec.scanp : dec.scanp+r.N])
+	}
+	if max := dec.opts.maxInputBytes; max > 0 && dec.InputOffset()+int64(r.N) > max {
+		return nil, tooLargeError("input exceeds limit of %d bytes", max)
+	}
+	dec.scanp += r.N
+	dec.last = r.N
+	return r.Value, nil
+}
+
+// resetScan discards the state of the scan of the value at scanp, which
+// starts over at the next call to next.
+func (dec *Decoder) resetScan() {
+	dec.scan, dec.scanned = nil, 0
+}
+
+// InputOffset returns the input stream byte offset of the current decoder
+// position, which is the end of the last decoded value. Positions in the
+// errors returned by Decode and DecodeInto count from the start of the value
+// being decoded, that is, from the offset InputOffset returns before the
+// call.
+func (dec *Decoder) InputOffset() int64 {
+	return dec.done + int64(dec.scanp)
+}
+
+// Buffered returns a reader of the data remaining in the Decoder's buffer,
+// which has been read from the input but not decoded yet. The reader is
+// valid until the next call to Decode or DecodeInto. To go on reading a
+// stream after the last value with another parser, read the buffered data
+// before the rest of the input, as with io.MultiReader(dec.Buffered(), r).
+func (dec *Decoder) Buffered() io.Reader {
+	return bytes.NewReader(dec.buf[dec.scanp:])
+}
+
+// refill reads more data into the buffer, discarding bytes already decoded.
+func (dec *Decoder) refill() {
+	if dec.scanp > 0 {
+		if dec.opts.stringBytes {
+			// decoded strings share the buffer, so keep it as it is
+			dec.buf = append(make([]byte, 0, cap(dec.buf)), dec.buf[dec.scanp:]...)
+		} else {
+			n := copy(dec.buf, dec.buf[dec.scanp:])
+			dec.buf = dec.buf[:n]
+		}
+		dec.done += int64(dec.scanp)
+		dec.scanp = 0
+	}
+
+	const minRead = 512
+	if cap(dec.buf)-len(dec.buf) < minRead {
+		buf := make([]byte, len(dec.buf), 2*cap(dec.buf)+minRead)
+		copy(buf, dec.buf)
+		dec.buf = buf
+	}
+
+	p := dec.buf[len(dec.buf):cap(dec.buf)]
+	if max := dec.opts.maxInputBytes; max > 0 {
+		// read one byte beyond the limit at most to tell it is exceeded
+		if rest := max + 1 - dec.done - int64(len(dec.buf)); rest < int64(len(p)) {
+			p = p[:rest]
+		}
+	}
+	n, err := dec.r.Read(p)
+	dec.buf = dec.buf[:len(dec.buf)+n]
+	dec.err = err
+}
 
 // An Encoder writes PHP serialize values to an output stream.
 type Encoder struct {
-	w io.Writer
+	opts   options
+	w      io.Writer
+	hash   hash.Hash
+	bw     *bufio.Writer // nil unless created by NewBufferedEncoder
+	closed bool
+}
+
+// SetHash sets h to be fed the bytes of every value written by Encode
+// afterwards, so a digest of the stream can be computed in the same pass.
+// Passing nil disables hashing.
+func (enc *Encoder) SetHash(h hash.Hash) {
+	enc.hash = h
 }
 
 // Encode writes the PHP serialized value to the stream.
 func (enc *Encoder) Encode(i interface{}) error {
-	e := newEncodeState()
-	err := e.marshal(i)
-	if err != nil {
-		return err
+	return enc.EncodeAll(i)
+}
+
+// EncodeAll writes the PHP serialized values one after another to the
+// stream, with a single write to the underlying writer. Nothing is written
+// if any of the values cannot be encoded.
+func (enc *Encoder) EncodeAll(values ...interface{}) error {
+	if enc.closed {
+		return errEncoderClosed
+	}
+	e := newEncodeState(enc.opts)
+	defer freeEncodeState(e)
+	for _, i := range values {
+		if err := e.marshal(i); err != nil {
+			return err
+		}
 	}
 
-	_, err = enc.w.Write(e.Bytes())
+	w := enc.w
+	if enc.bw != nil {
+		w = enc.bw
+	}
+	bs := e.Bytes()
+	n, err := writeAll(w, bs)
+	if enc.hash != nil {
+		enc.hash.Write(bs[:n])
+	}
 	return err
 }
 
-// NewEncoder returns a new encoder.
-func NewEncoder(w io.Writer) *Encoder {
+// Flush writes any buffered data to the underlying writer of an Encoder
+// created by NewBufferedEncoder. It does nothing for other encoders, which
+// write every value as it is encoded.
+func (enc *Encoder) Flush() error {
+	if enc.bw == nil {
+		return nil
+	}
+	return enc.bw.Flush()
+}
+
+// Close flushes the encoder and makes Encode fail afterwards. It does not
+// close the underlying writer, which stays owned by the caller.
+func (enc *Encoder) Close() error {
+	if enc.closed {
+		return errEncoderClosed
+	}
+	enc.closed = true
+	return enc.Flush()
+}
+
+var errEncoderClosed = errors.New("php serialize: encoder is closed")
+
+// writeAll writes bs to w in a single call like io.Writer.Write, but also
+// reports a short write from a writer that does not return an error for it,
+// so that truncated output is never reported as success.
+func writeAll(w io.Writer, bs []byte) (int, error) {
+	n, err := w.Write(bs)
+	if err == nil && n < len(bs) {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+// NewEncoder returns a new encoder that writes to w and applies opts to
+// every encoded value. The Set methods change the options afterwards.
+func NewEncoder(w io.Writer, opts ...Option) *Encoder {
 	return &Encoder{
-		w: w,
+		opts: newOptions(opts),
+		w:    w,
 	}
 }
+
+// NewBufferedEncoder returns a new encoder like NewEncoder that collects the
+// encoded values in a buffer of size bytes, or a default size if size is not
+// positive, and writes them to w only when the buffer is full, so that a
+// continuous feed of small values takes few writes. Flush writes the values
+// buffered so far, and Flush or Close must be called to write the rest.
+func NewBufferedEncoder(w io.Writer, size int, opts ...Option) *Encoder {
+	enc := NewEncoder(w, opts...)
+	enc.bw = bufio.NewWriterSize(w, size)
+	return enc
+}
@@ -0,0 +1,403 @@
+package phpserialize_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestDecoder(t *testing.T) {
+	data := `i:1;s:3:"abc";a:1:{i:0;b:1;}N;`
+	want := []*php.Value{
+		php.Int(1),
+		php.String("abc"),
+		php.Append(php.Array(), php.Bool(true)),
+		php.Null(),
+	}
+
+	dec := phpserialize.NewDecoder(iotest.OneByteReader(strings.NewReader(data)))
+	for i, w := range want {
+		got, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("#%d: Decode() returns error: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("#%d: Decode() == %#v, want: %#v", i, got, w)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("Decode() at end of input returns error: %v, want: %v", err, io.EOF)
+	}
+}
+
+func TestDecoderInputOffset(t *testing.T) {
+	data := `i:1;s:3:"abc";a:1:{i:0;b:1;}N;`
+	want := []int64{4, 14, 28, 30}
+
+	dec := phpserialize.NewDecoder(iotest.OneByteReader(strings.NewReader(data)))
+	if off := dec.InputOffset(); off != 0 {
+		t.Errorf("InputOffset() == %d before Decode, want: 0", off)
+	}
+	for i, w := range want {
+		if _, err := dec.Decode(); err != nil {
+			t.Fatalf("#%d: Decode() returns error: %v", i, err)
+		}
+		if off := dec.InputOffset(); off != w {
+			t.Errorf("#%d: InputOffset() == %d, want: %d", i, off, w)
+		}
+	}
+}
+
+func TestDecoderBuffered(t *testing.T) {
+	r := strings.NewReader("a:1:{i:0;s:4:\"body\";}\nraw body data")
+	dec := phpserialize.NewDecoder(r)
+	if _, err := dec.Decode(); err != nil {
+		t.Fatalf("Decode() returns error: %v", err)
+	}
+	rest, err := io.ReadAll(io.MultiReader(dec.Buffered(), r))
+	if err != nil {
+		t.Fatalf("ReadAll returns error: %v", err)
+	}
+	if want := "\nraw body data"; string(rest) != want {
+		t.Errorf("rest of input == %q, want: %q", rest, want)
+	}
+}
+
+func TestDecoderMaxInputBytes(t *testing.T) {
+	data := `i:1;s:3:"abc";i:2;`
+	dec := phpserialize.NewDecoder(iotest.OneByteReader(strings.NewReader(data)), phpserialize.WithMaxInputBytes(14))
+	for i := 0; i < 2; i++ {
+		if _, err := dec.Decode(); err != nil {
+			t.Fatalf("#%d: Decode() returns error: %v", i, err)
+		}
+	}
+	if _, err := dec.Decode(); !errors.Is(err, phpserialize.ErrTooLarge) {
+		t.Errorf("Decode() beyond the limit returns error: %v, want: %v", err, phpserialize.ErrTooLarge)
+	}
+
+	dec = phpserialize.NewDecoder(&io.LimitedReader{R: strings.NewReader(data), N: 16})
+	if _, err := dec.Decode(); err != nil {
+		t.Fatalf("Decode() returns error: %v", err)
+	}
+	if _, err := dec.Decode(); err != nil {
+		t.Fatalf("Decode() returns error: %v", err)
+	}
+	if _, err := dec.Decode(); !errors.Is(err, phpserialize.ErrTooLarge) {
+		t.Errorf("Decode() beyond the LimitedReader returns error: %v, want: %v", err, phpserialize.ErrTooLarge)
+	}
+
+	dec = phpserialize.NewDecoder(strings.NewReader(data), phpserialize.WithMaxInputBytes(int64(len(data))))
+	for i := 0; i < 3; i++ {
+		if _, err := dec.Decode(); err != nil {
+			t.Fatalf("#%d: Decode() within the limit returns error: %v", i, err)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("Decode() at end of input returns error: %v, want: %v", err, io.EOF)
+	}
+}
+
+func TestDecoderUnexpectedEOF(t *testing.T) {
+	dec := phpserialize.NewDecoder(strings.NewReader(`i:1;s:3:"ab`))
+	if _, err := dec.Decode(); err != nil {
+		t.Fatalf("Decode() returns error: %v", err)
+	}
+	if _, err := dec.Decode(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Decode() returns error: %v, want: %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestDecoderOneByteReads(t *testing.T) {
+	cases := []struct {
+		data string
+		opts []phpserialize.Option
+	}{
+		{data: `s:0:"";S:5:"a\62c\0ad";C:3:"Foo":0:{}C:3:"Foo":3:{abc}`},
+		{data: `O:3:"Foo":2:{s:1:"a";a:0:{}s:1:"b";a:1:{i:0;d:-1.5E+20;}}b:0;i:-7;`},
+		{data: `a:1:{s:1:"x";a:1:{i:0;a:1:{i:0;N;}}}O:8:"stdClass":0:{}`, opts: []phpserialize.Option{phpserialize.WithLazy()}},
+		{data: `a:2:{i:0;i:1;;i:1;I:2;;};;n;s:1:";";;`, opts: []phpserialize.Option{phpserialize.WithLenient()}},
+	}
+	for i, tc := range cases {
+		whole := phpserialize.NewDecoder(strings.NewReader(tc.data), tc.opts...)
+		dec := phpserialize.NewDecoder(iotest.OneByteReader(strings.NewReader(tc.data)), tc.opts...)
+		for j := 0; ; j++ {
+			want, wantErr := whole.Decode()
+			got, err := dec.Decode()
+			if err != wantErr {
+				t.Fatalf("#%d.%d: Decode() returns error: %v, want: %v", i, j, err, wantErr)
+			}
+			if err != nil {
+				break
+			}
+			if !php.Equal(got, want) {
+				t.Errorf("#%d.%d: Decode() == %v, want: %v", i, j, got, want)
+			}
+		}
+	}
+}
+
+func TestDecoderOneByteReadsErrors(t *testing.T) {
+	cases := []string{
+		`a:1:{i:0;i:1;i:1;i:2;}`,
+		`a:1:{i:0;x:1;}`,
+		`a:1:{i:0;i:x;}`,
+		`s:-1:"";`,
+		`S:2:"\6x";`,
+		`C:3:"Foo":2:{abc}`,
+		`a:1:{d:1.5;i:0;}`,
+	}
+	for i, data := range cases {
+		_, want := phpserialize.NewDecoder(strings.NewReader(data)).Decode()
+		_, err := phpserialize.NewDecoder(iotest.OneByteReader(strings.NewReader(data))).Decode()
+		if err == nil || want == nil || err.Error() != want.Error() {
+			t.Errorf("#%d: Decode() of %q returns error: %v, want: %v", i, data, err, want)
+		}
+	}
+}
+
+// chunkReader reads n bytes at most at a time.
+type chunkReader struct {
+	r io.Reader
+	n int
+}
+
+func (r chunkReader) Read(p []byte) (int, error) {
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	return r.r.Read(p)
+}
+
+func TestDecoderLargeValue(t *testing.T) {
+	// a value read in many small pieces is scanned once, not parsed again
+	// from its first byte after every read
+	const n = 1 << 18
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "a:%d:{", n)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, `i:%d;s:20:"%020d";`, i, i)
+	}
+	buf.WriteString("}")
+
+	dec := phpserialize.NewDecoder(chunkReader{bytes.NewReader(buf.Bytes()), 4096})
+	start := time.Now()
+	v, err := dec.Decode()
+	if err != nil {
+		t.Fatalf("Decode() returns error: %v", err)
+	}
+	if got := len(v.Array()); got != n {
+		t.Errorf("Decode() returns %d elements, want: %d", got, n)
+	}
+	if d := time.Since(start); d > 20*time.Second {
+		t.Errorf("Decode() of %d bytes in 4 KB reads takes %v", buf.Len(), d)
+	}
+}
+
+func TestDecoderSetHash(t *testing.T) {
+	data := `i:1;s:3:"abc";`
+	h := sha256.New()
+	dec := phpserialize.NewDecoder(strings.NewReader(data))
+	dec.SetHash(h)
+	for {
+		if _, err := dec.Decode(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Decode() returns error: %v", err)
+		}
+	}
+
+	want := sha256.Sum256([]byte(data))
+	if got := h.Sum(nil); !bytes.Equal(got, want[:]) {
+		t.Errorf("hash == %x, want: %x", got, want)
+	}
+
+	// doubled semicolons are hashed, after the last value too
+	data = `i:1;;s:3:"abc";;;`
+	h.Reset()
+	dec = phpserialize.NewDecoder(iotest.OneByteReader(strings.NewReader(data)), phpserialize.WithLenient())
+	dec.SetHash(h)
+	for {
+		if _, err := dec.Decode(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Decode() returns error: %v", err)
+		}
+	}
+	want = sha256.Sum256([]byte(data))
+	if got := h.Sum(nil); !bytes.Equal(got, want[:]) {
+		t.Errorf("lenient hash == %x, want: %x", got, want)
+	}
+}
+
+func TestEncoderSetHash(t *testing.T) {
+	var buf bytes.Buffer
+	h := sha256.New()
+	enc := phpserialize.NewEncoder(&buf)
+	enc.SetHash(h)
+	for _, v := range []interface{}{1, "abc", []int{1, 2}} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("Encode(%#v) returns error: %v", v, err)
+		}
+	}
+
+	want := sha256.Sum256(buf.Bytes())
+	if got := h.Sum(nil); !bytes.Equal(got, want[:]) {
+		t.Errorf("hash == %x, want: %x", got, want)
+	}
+}
+
+// shortWriter accepts at most n bytes per call without returning an error,
+// breaking the io.Writer contract like some network wrappers do.
+type shortWriter struct{ n int }
+
+func (w shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return w.n, nil
+	}
+	return len(p), nil
+}
+
+func TestWriteErrors(t *testing.T) {
+	errWrite := errors.New("write failed")
+	v := php.List(php.Int(1), php.String("abc"))
+	cases := []struct {
+		w    io.Writer
+		want error
+	}{
+		{shortWriter{n: 3}, io.ErrShortWrite},
+		{errorWriter{errWrite}, errWrite},
+	}
+	for i, tc := range cases {
+		if err := phpserialize.NewEncoder(tc.w).Encode([]int{1, 2}); !errors.Is(err, tc.want) {
+			t.Errorf("#%d: Encode(...) returns error: %v, want: %v", i, err, tc.want)
+		}
+		if err := phpserialize.Dump(tc.w, v); !errors.Is(err, tc.want) {
+			t.Errorf("#%d: Dump(...) returns error: %v, want: %v", i, err, tc.want)
+		}
+		if err := phpserialize.VarExport(tc.w, v); !errors.Is(err, tc.want) {
+			t.Errorf("#%d: VarExport(...) returns error: %v, want: %v", i, err, tc.want)
+		}
+		if _, err := v.WriteTo(tc.w); !errors.Is(err, tc.want) {
+			t.Errorf("#%d: WriteTo(...) returns error: %v, want: %v", i, err, tc.want)
+		}
+	}
+}
+
+type errorWriter struct{ err error }
+
+func (w errorWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+// countingWriter counts the calls to Write and records whether it is closed.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+	closed bool
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func (w *countingWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestNewBufferedEncoder(t *testing.T) {
+	w := &countingWriter{}
+	enc := phpserialize.NewBufferedEncoder(w, 0)
+	for i := 0; i < 100; i++ {
+		if err := enc.Encode(i); err != nil {
+			t.Fatalf("Encode(%d) returns error: %v", i, err)
+		}
+	}
+	if w.Len() != 0 {
+		t.Fatalf("Encode(...) writes %q before Flush, want nothing", w.String())
+	}
+	if err := enc.EncodeAll("a", true, nil); err != nil {
+		t.Fatalf("EncodeAll(...) returns error: %v", err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("Flush() returns error: %v", err)
+	}
+	if w.writes != 1 {
+		t.Errorf("Flush() writes %d times, want: 1", w.writes)
+	}
+	if got := w.String(); !strings.HasPrefix(got, "i:0;i:1;") || !strings.HasSuffix(got, `i:99;s:1:"a";b:1;N;`) {
+		t.Errorf("Flush() writes %q, want: i:0; to i:99; followed by %q", got, `s:1:"a";b:1;N;`)
+	}
+
+	n := w.Len()
+	if err := enc.Encode(1); err != nil {
+		t.Fatalf("Encode(1) returns error: %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() returns error: %v", err)
+	}
+	if got := w.String()[n:]; got != "i:1;" || w.closed {
+		t.Errorf("Close() writes %q and closes: %v, want: %q and false", got, w.closed, "i:1;")
+	}
+	if err := enc.Encode(2); err == nil {
+		t.Errorf("Encode(2) after Close returns no error")
+	}
+}
+
+func TestEncoderEncodeAll(t *testing.T) {
+	w := &countingWriter{}
+	enc := phpserialize.NewEncoder(w)
+	if err := enc.EncodeAll(1, "a"); err != nil {
+		t.Fatalf("EncodeAll(...) returns error: %v", err)
+	}
+	if err := enc.EncodeAll(2, make(chan int)); err == nil {
+		t.Errorf("EncodeAll(2, chan) returns no error")
+	}
+	if got, want := w.String(), `i:1;s:1:"a";`; got != want || w.writes != 1 {
+		t.Errorf("EncodeAll(...) writes %q in %d calls, want: %q in 1", got, w.writes, want)
+	}
+}
+
+func TestNewEncoderOptions(t *testing.T) {
+	type userProfile struct {
+		UserName string
+		Score    float64
+	}
+	var buf bytes.Buffer
+	enc := phpserialize.NewEncoder(&buf,
+		phpserialize.WithFieldNameMapper(phpserialize.SnakeCase),
+		phpserialize.WithClassNameMapper(func(t reflect.Type) string { return `App\` + t.Name() }),
+		phpserialize.WithFloatPrecision(17),
+	)
+	if err := enc.Encode(userProfile{UserName: "bob", Score: 0.1}); err != nil {
+		t.Fatalf("Encode(...) returns error: %v", err)
+	}
+	want := `O:15:"App\userProfile":2:{s:9:"user_name";s:3:"bob";s:5:"score";d:0.10000000000000001;}`
+	if got := buf.String(); got != want {
+		t.Errorf("Encode(...) writes %s\nwant: %s", got, want)
+	}
+}
+
+func TestDecoderDecodeIntoWithJSONTags(t *testing.T) {
+	var v struct {
+		UserID int `json:"user_id"`
+	}
+	dec := phpserialize.NewDecoder(strings.NewReader(`a:1:{s:7:"user_id";i:7;}`), phpserialize.WithJSONTags())
+	if err := dec.DecodeInto(&v); err != nil {
+		t.Fatalf("DecodeInto(...) returns error: %v", err)
+	}
+	if v.UserID != 7 {
+		t.Errorf("DecodeInto(...) sets UserID to %d, want: 7", v.UserID)
+	}
+}
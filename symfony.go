@@ -0,0 +1,78 @@
+package phpserialize
+
+import (
+	"time"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// Session variables used by Symfony's native session storage.
+const (
+	SymfonyAttributesKey = "_sf2_attributes"
+	SymfonyFlashesKey    = "_symfony_flashes"
+	SymfonyMetaKey       = "_sf2_meta"
+)
+
+// SymfonySession holds the bags of a Symfony session as stored by its native
+// session storage.
+type SymfonySession struct {
+	Attributes *php.Value // array of the attribute bag
+	Flashes    *php.Value // array of the flash bag
+	Meta       SymfonyMetadata
+}
+
+// SymfonyMetadata holds the contents of Symfony's session MetadataBag.
+type SymfonyMetadata struct {
+	Created  time.Time
+	Updated  time.Time
+	Lifetime int // cookie lifetime in seconds
+}
+
+// UnmarshalSymfonySession parses session data written by Symfony's native
+// session storage. Missing bags are returned as empty arrays.
+func UnmarshalSymfonySession(data []byte) (*SymfonySession, error) {
+	v, err := UnmarshalSession(data)
+	if err != nil {
+		return nil, err
+	}
+	s := &SymfonySession{
+		Attributes: v.IndexByName(SymfonyAttributesKey),
+		Flashes:    v.IndexByName(SymfonyFlashesKey),
+	}
+	if s.Attributes == nil {
+		s.Attributes = php.Array()
+	}
+	if s.Flashes == nil {
+		s.Flashes = php.Array()
+	}
+	if meta := v.IndexByName(SymfonyMetaKey); meta != nil {
+		s.Meta = SymfonyMetadata{
+			Created:  time.Unix(meta.At("c").IntOr(0), 0),
+			Updated:  time.Unix(meta.At("u").IntOr(0), 0),
+			Lifetime: int(meta.At("l").IntOr(0)),
+		}
+	}
+	return s, nil
+}
+
+// MarshalSymfonySession returns the session data of s in the form written by
+// Symfony's native session storage.
+func MarshalSymfonySession(s *SymfonySession) ([]byte, error) {
+	attrs, flashes := s.Attributes, s.Flashes
+	if attrs == nil {
+		attrs = php.Array()
+	}
+	if flashes == nil {
+		flashes = php.Array()
+	}
+	meta := php.Array(
+		php.Element(php.String("u"), php.Int(int(s.Meta.Updated.Unix()))),
+		php.Element(php.String("c"), php.Int(int(s.Meta.Created.Unix()))),
+		php.Element(php.String("l"), php.Int(s.Meta.Lifetime)),
+	)
+	return MarshalSession(php.Array(
+		php.Element(php.String(SymfonyAttributesKey), attrs),
+		php.Element(php.String(SymfonyFlashesKey), flashes),
+		php.Element(php.String(SymfonyMetaKey), meta),
+	))
+}
@@ -0,0 +1,40 @@
+package phpserialize_test
+
+import (
+	"testing"
+	"time"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestSymfonySession(t *testing.T) {
+	data := `_sf2_attributes|a:1:{s:4:"user";s:3:"bob";}` +
+		`_symfony_flashes|a:0:{}` +
+		`_sf2_meta|a:3:{s:1:"u";i:1700000100;s:1:"c";i:1700000000;s:1:"l";i:0;}`
+	s, err := phpserialize.UnmarshalSymfonySession([]byte(data))
+	if err != nil {
+		t.Fatalf("UnmarshalSymfonySession(%q) returns error: %v", data, err)
+	}
+	if got := s.Attributes.At("user").StringOr(""); got != "bob" {
+		t.Errorf(`Attributes.At("user") == %q, want: "bob"`, got)
+	}
+	if !s.Meta.Created.Equal(time.Unix(1700000000, 0)) || !s.Meta.Updated.Equal(time.Unix(1700000100, 0)) {
+		t.Errorf("Meta == %+v, want created 1700000000 and updated 1700000100", s.Meta)
+	}
+	bs, err := phpserialize.MarshalSymfonySession(s)
+	if err != nil {
+		t.Fatalf("MarshalSymfonySession(...) returns error: %v", err)
+	}
+	if string(bs) != data {
+		t.Errorf("MarshalSymfonySession(...) == %q, want: %q", bs, data)
+	}
+
+	s, err = phpserialize.UnmarshalSymfonySession(nil)
+	if err != nil {
+		t.Fatalf("UnmarshalSymfonySession(nil) returns error: %v", err)
+	}
+	if !php.Equal(s.Attributes, php.Array()) || !php.Equal(s.Flashes, php.Array()) {
+		t.Errorf("UnmarshalSymfonySession(nil) == %+v, want empty bags", s)
+	}
+}
@@ -0,0 +1,156 @@
+package phpserialize
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// TimeEncoding selects how time.Time values are encoded.
+type TimeEncoding uint
+
+// Time encodings
+const (
+	// TimeReflect encodes time.Time like any other struct.
+	TimeReflect TimeEncoding = iota
+	// TimeUnix encodes time.Time as an int of seconds since the Unix epoch.
+	TimeUnix
+	// TimeString encodes time.Time as a string formatted with the time
+	// layout, time.RFC3339 by default.
+	TimeString
+	// TimeDateTime encodes time.Time as a PHP DateTime object.
+	TimeDateTime
+	// TimeDateTimeImmutable encodes time.Time as a PHP DateTimeImmutable
+	// object.
+	TimeDateTimeImmutable
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// phpDateLayout is the layout of the date property of PHP DateTime objects.
+const phpDateLayout = "2006-01-02 15:04:05.000000"
+
+func (e *encodeState) writeTime(t time.Time) {
+	switch e.timeEncoding {
+	case TimeUnix:
+		e.writeInt(t.Unix())
+	case TimeString:
+		layout := e.timeLayout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		e.writeString(t.Format(layout))
+	case TimeDateTime, TimeDateTimeImmutable:
+		class := "DateTime"
+		if e.timeEncoding == TimeDateTimeImmutable {
+			class = "DateTimeImmutable"
+		}
+		tzType, tz := phpTimezone(t)
+		e.writeObjectHeader(class, 3)
+		e.writeString("date")
+		e.writeString(t.Format(phpDateLayout))
+		e.writeString("timezone_type")
+		e.writeInt(int64(tzType))
+		e.writeString("timezone")
+		e.writeString(tz)
+		e.writeEnd()
+	}
+}
+
+// phpTimezone returns the PHP timezone_type and timezone of t: type 3 with
+// the identifier for UTC and IANA locations, type 1 with the UTC offset
+// otherwise.
+func phpTimezone(t time.Time) (tzType int, tz string) {
+	if name := t.Location().String(); name == "UTC" || strings.Contains(name, "/") {
+		return 3, name
+	}
+	_, offset := t.Zone()
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return 1, fmt.Sprintf("%c%02d:%02d", sign, offset/3600, offset%3600/60)
+}
+
+// DecodeTime converts the PHP value v into a time.Time. It accepts
+// DateTime, DateTimeImmutable and subclasses such as Carbon, i.e. any object
+// with date, timezone_type and timezone properties, as well as int Unix
+// timestamps and strings formatted as RFC 3339.
+func DecodeTime(v *php.Value) (time.Time, error) {
+	switch v.Type() {
+	case php.TypeInt:
+		return time.Unix(v.Int(), 0), nil
+	case php.TypeString:
+		return time.Parse(time.RFC3339Nano, v.String())
+	case php.TypeObject:
+		return decodeDateTime(v.Object())
+	default:
+		return time.Time{}, fmt.Errorf("php serialize: cannot convert %v to time.Time", v.Type())
+	}
+}
+
+func decodeDateTime(obj *php.Obj) (time.Time, error) {
+	prop := func(name string) *php.Value {
+		if f := obj.Field(name); f != nil {
+			return f.Value
+		}
+		return nil
+	}
+	date, err := prop("date").TryString()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("php serialize: %s object has no date string", obj.Name)
+	}
+	tzType, err := prop("timezone_type").TryInt()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("php serialize: %s object has no timezone_type", obj.Name)
+	}
+	tz, err := prop("timezone").TryString()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("php serialize: %s object has no timezone", obj.Name)
+	}
+
+	var loc *time.Location
+	switch tzType {
+	case 1:
+		t, err := time.Parse("-07:00", tz)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("php serialize: invalid timezone offset %q: %v", tz, err)
+		}
+		_, offset := t.Zone()
+		loc = time.FixedZone("", offset)
+	case 2:
+		if offset, ok := zoneAbbreviations[strings.ToUpper(tz)]; ok {
+			loc = time.FixedZone(tz, offset)
+			break
+		}
+		fallthrough
+	case 3:
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("php serialize: unknown timezone %q: %v", tz, err)
+		}
+	default:
+		return time.Time{}, fmt.Errorf("php serialize: invalid timezone_type %d", tzType)
+	}
+	return time.ParseInLocation(phpDateLayout, date, loc)
+}
+
+// zoneAbbreviations holds the UTC offsets in seconds of common timezone
+// abbreviations, which PHP writes with timezone_type 2, like "EST" for
+// new DateTime("2021-01-02 03:04:05 EST"). Ambiguous ones, such as "IST",
+// are left out.
+var zoneAbbreviations = map[string]int{
+	"UTC": 0, "UT": 0, "GMT": 0, "Z": 0,
+	"WET": 0, "WEST": 1 * 3600, "BST": 1 * 3600,
+	"CET": 1 * 3600, "CEST": 2 * 3600, "EET": 2 * 3600, "EEST": 3 * 3600, "MSK": 3 * 3600,
+	"EST": -5 * 3600, "EDT": -4 * 3600, "CST": -6 * 3600, "CDT": -5 * 3600,
+	"MST": -7 * 3600, "MDT": -6 * 3600, "PST": -8 * 3600, "PDT": -7 * 3600,
+	"AKST": -9 * 3600, "AKDT": -8 * 3600, "HST": -10 * 3600,
+	"JST": 9 * 3600, "KST": 9 * 3600, "HKT": 8 * 3600, "AWST": 8 * 3600,
+	"ACST": 9*3600 + 1800, "ACDT": 10*3600 + 1800, "AEST": 10 * 3600, "AEDT": 11 * 3600,
+	"NZST": 12 * 3600, "NZDT": 13 * 3600,
+}
@@ -0,0 +1,51 @@
+package phpserialize
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+var jsonRawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// isJSONKind reports whether a value of type t can hold JSON text for the
+// json struct tag option.
+func isJSONKind(t reflect.Type) bool {
+	return t.Kind() == reflect.String || t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+// writeJSON writes the JSON text held by the string or byte slice v as the
+// equivalent PHP value, as php.Value.UnmarshalJSON converts it. Empty text
+// is written as null.
+func (e *encodeState) writeJSON(v reflect.Value) {
+	var data []byte
+	if v.Kind() == reflect.String {
+		data = []byte(v.String())
+	} else {
+		data = v.Bytes()
+	}
+	if len(data) == 0 {
+		e.writeNil()
+		return
+	}
+	var pv php.Value
+	if err := pv.UnmarshalJSON(data); err != nil {
+		raiseError(&MarshalerError{v.Type(), err})
+	}
+	e.writePHPValue(&pv)
+}
+
+// assignJSON stores src as JSON text, as php.Value.MarshalJSON writes it, in
+// the string or byte slice v.
+func (a *assignState) assignJSON(src *php.Value, v reflect.Value) {
+	data, err := src.MarshalJSON()
+	if err != nil {
+		raiseError(err)
+	}
+	if v.Kind() == reflect.String {
+		v.SetString(string(data))
+	} else {
+		v.SetBytes(data)
+	}
+}
@@ -0,0 +1,54 @@
+package phpserialize_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+)
+
+func TestJSONTranscoding(t *testing.T) {
+	type Event struct {
+		Name    string          `php:"name"`
+		Payload json.RawMessage `php:"payload"`
+		Meta    string          `php:"meta,json"`
+		Empty   []byte          `php:"empty,json"`
+	}
+	v := Event{
+		Name:    "signup",
+		Payload: json.RawMessage(`{"id":7,"tags":["a","b"]}`),
+		Meta:    `{"ok":true}`,
+	}
+	want := `O:5:"Event":4:{s:4:"name";s:6:"signup";s:7:"payload";a:2:{s:2:"id";i:7;s:4:"tags";a:2:{i:0;s:1:"a";i:1;s:1:"b";}}s:4:"meta";a:1:{s:2:"ok";b:1;}s:5:"empty";N;}`
+
+	got, err := phpserialize.MarshalWithOptions(v, phpserialize.WithJSONTranscoding())
+	if err != nil {
+		t.Fatalf("MarshalWithOptions(%+v) returns error: %v", v, err)
+	}
+	if string(got) != want {
+		t.Errorf("MarshalWithOptions(%+v) == %s\nwant: %s", v, got, want)
+	}
+
+	back, err := phpserialize.Decode[Event](got, phpserialize.WithJSONTranscoding())
+	if err != nil {
+		t.Fatalf("Decode(%s) returns error: %v", got, err)
+	}
+	if string(back.Payload) != string(v.Payload) || back.Meta != v.Meta || string(back.Empty) != "null" {
+		t.Errorf("Decode(%s) == %+v, want: %+v", got, back, v)
+	}
+
+	// without the option, only the tagged fields are transcoded
+	got, err = phpserialize.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal(%+v) returns error: %v", v, err)
+	}
+	want = `O:5:"Event":4:{s:4:"name";s:6:"signup";s:7:"payload";s:25:"{"id":7,"tags":["a","b"]}";s:4:"meta";a:1:{s:2:"ok";b:1;}s:5:"empty";N;}`
+	if string(got) != want {
+		t.Errorf("Marshal(%+v) == %s\nwant: %s", v, got, want)
+	}
+
+	v.Meta = `{`
+	if _, err := phpserialize.Marshal(v); err == nil {
+		t.Errorf("Marshal(invalid JSON) returns no error")
+	}
+}
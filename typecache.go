@@ -0,0 +1,406 @@
+package phpserialize
+
+import (
+	"reflect"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// typeInfo holds what the encoder needs to know about a Go type that does
+// not depend on the options or on the value, computed once per type.
+type typeInfo struct {
+	marshaler        bool // implements Marshaler
+	ptrMarshaler     bool // *T implements Marshaler
+	textMarshaler    bool // implements encoding.TextMarshaler
+	ptrTextMarshaler bool // *T implements encoding.TextMarshaler
+	classNamer       bool // implements ClassNamer
+	ptrClassNamer    bool // *T implements ClassNamer
+}
+
+var typeInfos sync.Map // map[reflect.Type]*typeInfo
+
+// cachedTypeInfo returns the typeInfo of t.
+func cachedTypeInfo(t reflect.Type) *typeInfo {
+	if ti, ok := typeInfos.Load(t); ok {
+		return ti.(*typeInfo)
+	}
+	ti := &typeInfo{
+		marshaler:     t.Implements(marshalerType),
+		textMarshaler: t.Implements(textMarshalerType),
+		classNamer:    t.Implements(classNamerType),
+	}
+	if t.Kind() != reflect.Ptr {
+		pt := reflect.PtrTo(t)
+		ti.ptrMarshaler = pt.Implements(marshalerType)
+		ti.ptrTextMarshaler = pt.Implements(textMarshalerType)
+		ti.ptrClassNamer = pt.Implements(classNamerType)
+	}
+	v, _ := typeInfos.LoadOrStore(t, ti)
+	return v.(*typeInfo)
+}
+
+// funcID returns the identity of fn: the address of its closure, which
+// differs between closures of the same code. A cache key holding it keeps
+// fn alive, so the address is not reused by another func.
+func funcID(fn func(string) string) unsafe.Pointer {
+	if fn == nil {
+		return nil
+	}
+	return *(*unsafe.Pointer)(unsafe.Pointer(&fn))
+}
+
+// fieldCacheKey is the key of the fields of a struct type in fieldCache:
+// the type and the options typeFields depends on.
+type fieldCacheKey struct {
+	t               reflect.Type
+	jsonTags        bool
+	promoteEmbedded bool
+	fieldNameMapper unsafe.Pointer // funcID of the mapper
+}
+
+func newFieldCacheKey(t reflect.Type, o *options) fieldCacheKey {
+	return fieldCacheKey{t, o.jsonTags, o.promoteEmbedded, funcID(o.fieldNameMapper)}
+}
+
+// fieldCache holds the results of typeFields.
+var fieldCache sync.Map // map[fieldCacheKey][]field
+
+// cachedTypeFields returns typeFields(t, o), computed once per struct type
+// and options. Field name mappers are told apart by identity, so a mapper
+// should be created once rather than for every call. The returned slice is
+// shared and must not be modified.
+func cachedTypeFields(t reflect.Type, o *options) []field {
+	k := newFieldCacheKey(t, o)
+	if fs, ok := fieldCache.Load(k); ok {
+		return fs.([]field)
+	}
+	fs, _ := fieldCache.LoadOrStore(k, typeFields(t, o))
+	return fs.([]field)
+}
+
+// An encoderFunc writes the value v of the type it was compiled for.
+type encoderFunc func(e *encodeState, v reflect.Value)
+
+// encoderCacheKey is the key of a compiled encoder in encoderCache: the type
+// and the options that choose how values of the type are written. The other
+// options are read by the encoders as they write.
+type encoderCacheKey struct {
+	fields          fieldCacheKey
+	jsonTranscoding bool
+	textMarshalers  bool
+	timeReflect     bool
+}
+
+// encoderCache holds the encoders compiled by newTypeEncoder.
+var encoderCache sync.Map // map[encoderCacheKey]encoderFunc
+
+// cachedTypeEncoder returns the encoder of values of type t with o, compiled
+// once per type and options.
+func cachedTypeEncoder(t reflect.Type, o *options) encoderFunc {
+	k := encoderCacheKey{
+		fields:          newFieldCacheKey(t, o),
+		jsonTranscoding: o.jsonTranscoding,
+		textMarshalers:  o.textMarshalers,
+		timeReflect:     o.timeEncoding == TimeReflect,
+	}
+	if f, ok := encoderCache.Load(k); ok {
+		return f.(encoderFunc)
+	}
+
+	// as in encoding/json, an indirect func stands in for the encoder while
+	// it is compiled, so that recursive types find it
+	var (
+		wg sync.WaitGroup
+		f  encoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := encoderCache.LoadOrStore(k, encoderFunc(func(e *encodeState, v reflect.Value) {
+		wg.Wait()
+		f(e, v)
+	}))
+	if loaded {
+		return fi.(encoderFunc)
+	}
+	f = newTypeEncoder(t, o)
+	wg.Done()
+	encoderCache.Store(k, f)
+	return f
+}
+
+// newTypeEncoder compiles the encoder of values of type t with o. The rules
+// of encodeState.writeReflectValue that depend only on the type and on the
+// options in encoderCacheKey are applied here once; those that depend on
+// the value, such as whether it can be used as an interface, are left to
+// the returned encoder.
+func newTypeEncoder(t reflect.Type, o *options) encoderFunc {
+	if t == phpValueType || t == phpValueType.Elem() {
+		return phpValueEncoder
+	}
+	if o.jsonTranscoding && t == jsonRawMessageType {
+		return jsonEncoder
+	}
+	var enc encoderFunc
+	if t.Kind() == reflect.Ptr {
+		elem := newValueEncoder(t.Elem(), o)
+		enc = func(e *encodeState, v reflect.Value) {
+			elem(e, v.Elem())
+		}
+	} else {
+		enc = newValueEncoder(t, o)
+	}
+	if cachedTypeInfo(t).marshaler {
+		next := enc
+		enc = func(e *encodeState, v reflect.Value) {
+			if !v.CanInterface() {
+				next(e, v)
+				return
+			}
+			e.writeMarshaler(v.Interface().(Marshaler))
+		}
+	}
+	// registered encoders may be added after the encoder is compiled
+	next := enc
+	enc = func(e *encodeState, v reflect.Value) {
+		if e.writeRegistered(v) || v.Kind() == reflect.Ptr && e.writeRegistered(v.Elem()) {
+			return
+		}
+		next(e, v)
+	}
+	if t.Kind() == reflect.Ptr {
+		next := enc
+		enc = func(e *encodeState, v reflect.Value) {
+			if v.IsNil() {
+				e.writeNil()
+				return
+			}
+			next(e, v)
+		}
+	}
+	return enc
+}
+
+// newValueEncoder compiles the encoder of the values of type t that are not
+// pointers, or that pointers of the type newTypeEncoder compiles for point
+// to.
+func newValueEncoder(t reflect.Type, o *options) encoderFunc {
+	enc := newKindEncoder(t, o)
+	ti := cachedTypeInfo(t)
+	if o.textMarshalers && (ti.textMarshaler || ti.ptrTextMarshaler) {
+		next := enc
+		enc = func(e *encodeState, v reflect.Value) {
+			m, ok := textMarshaler(v)
+			if !ok {
+				next(e, v)
+				return
+			}
+			bs, err := m.MarshalText()
+			if err != nil {
+				raiseError(&MarshalerError{v.Type(), err})
+			}
+			e.writeString(string(bs))
+		}
+	}
+	if t == timeType && o.timeEncoding != TimeReflect {
+		next := enc
+		enc = func(e *encodeState, v reflect.Value) {
+			if !v.CanInterface() {
+				next(e, v)
+				return
+			}
+			e.writeTime(v.Interface().(time.Time))
+		}
+	}
+	if t == orderedMapType {
+		enc = (*encodeState).writeOrderedMap
+	}
+	if ti.ptrMarshaler {
+		next := enc
+		enc = func(e *encodeState, v reflect.Value) {
+			if !v.CanInterface() {
+				next(e, v)
+				return
+			}
+			if !v.CanAddr() {
+				// map values and fields of structs passed by value are not
+				// addressable; use a copy for the pointer receiver
+				c := reflect.New(v.Type()).Elem()
+				c.Set(v)
+				v = c
+			}
+			e.writeMarshaler(v.Addr().Interface().(Marshaler))
+		}
+	}
+	return enc
+}
+
+// newKindEncoder compiles the encoder of the values of type t by its kind.
+func newKindEncoder(t reflect.Type, o *options) encoderFunc {
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(e *encodeState, v reflect.Value) { e.writeBool(v.Bool()) }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(e *encodeState, v reflect.Value) { e.writeInt(v.Int()) }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(e *encodeState, v reflect.Value) { e.writeUint(v.Uint()) }
+	case reflect.Float32, reflect.Float64:
+		return func(e *encodeState, v reflect.Value) { e.writeFloat(v.Float()) }
+	case reflect.Complex64, reflect.Complex128:
+		return (*encodeState).writeComplex
+	case reflect.String:
+		return func(e *encodeState, v reflect.Value) { e.writeString(v.String()) }
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// PHP strings are byte strings
+			return func(e *encodeState, v reflect.Value) {
+				if e.nilSliceAsNull && v.IsNil() {
+					e.writeNil()
+					return
+				}
+				e.writeBytes(v.Bytes())
+			}
+		}
+		array := newArrayEncoder(t, o)
+		return func(e *encodeState, v reflect.Value) {
+			if e.nilSliceAsNull && v.IsNil() {
+				e.writeNil()
+				return
+			}
+			array(e, v)
+		}
+	case reflect.Array:
+		return newArrayEncoder(t, o)
+	case reflect.Map:
+		return newMapEncoder(t, o)
+	case reflect.Struct:
+		return newStructEncoder(t, o)
+	case reflect.Interface:
+		return func(e *encodeState, v reflect.Value) { e.writeReflectValue(v.Elem()) }
+	default:
+		return func(e *encodeState, v reflect.Value) { raiseError(&UnsupportedTypeError{t}) }
+	}
+}
+
+func phpValueEncoder(e *encodeState, v reflect.Value) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		e.writeNil()
+		return
+	}
+	pv, _ := phpValue(v)
+	e.writePHPValue(pv)
+}
+
+func jsonEncoder(e *encodeState, v reflect.Value) {
+	e.writeJSON(v)
+}
+
+// newArrayEncoder compiles the encoder of the slice or array type t.
+func newArrayEncoder(t reflect.Type, o *options) encoderFunc {
+	elem := cachedTypeEncoder(t.Elem(), o)
+	return func(e *encodeState, v reflect.Value) {
+		l := v.Len()
+		e.writeArrayHeader(l)
+		for i := 0; i < l; i++ {
+			e.writeInt(int64(i))
+			e.spill()
+			elem(e, v.Index(i))
+		}
+		e.writeEnd()
+	}
+}
+
+// newMapEncoder compiles the encoder of the map type t.
+func newMapEncoder(t reflect.Type, o *options) encoderFunc {
+	elem := cachedTypeEncoder(t.Elem(), o)
+	return func(e *encodeState, v reflect.Value) {
+		if e.nilSliceAsNull && v.IsNil() {
+			e.writeNil()
+			return
+		}
+		keys := v.MapKeys()
+		e.sortMapKeys(keys)
+		e.writeArrayHeader(len(keys))
+		var seen map[string]bool
+		for _, k := range keys {
+			e.writeUniqueMapKey(k, &seen)
+			e.spill()
+			elem(e, v.MapIndex(k))
+		}
+		e.writeEnd()
+	}
+}
+
+// structField is a field of a struct type with its compiled encoder.
+type structField struct {
+	field
+	visibility php.Visibility // of the property
+	enc        encoderFunc
+}
+
+// newStructEncoder compiles the encoder of the struct type t.
+func newStructEncoder(t reflect.Type, o *options) encoderFunc {
+	var fields []structField
+	for _, f := range cachedTypeFields(t, o) {
+		sf := structField{field: f, visibility: php.VisibilityPublic}
+		switch {
+		case f.hasVisibility:
+			sf.visibility = f.visibility
+		case f.private:
+			sf.visibility = php.VisibilityPrivate
+		}
+		if f.json {
+			sf.enc = jsonEncoder
+		} else {
+			sf.enc = cachedTypeEncoder(t.FieldByIndex(f.index).Type, o)
+		}
+		fields = append(fields, sf)
+	}
+
+	type property struct {
+		name string
+		v    reflect.Value
+		enc  encoderFunc
+	}
+	return func(e *encodeState, v reflect.Value) {
+		var name string
+		if !e.structsAsArrays {
+			name = e.className(v)
+		}
+
+		props := make([]property, 0, len(fields))
+		for _, f := range fields {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || f.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			n := f.name
+			if f.visibility != php.VisibilityPublic && !e.structsAsArrays {
+				class := name
+				if f.owner != nil {
+					ov, _ := fieldByIndex(v, f.owner)
+					class = e.className(reflect.Indirect(ov))
+				}
+				n = php.MangleName(class, n, f.visibility)
+			}
+			props = append(props, property{n, fv, f.enc})
+		}
+
+		if e.structsAsArrays {
+			e.writeArrayHeader(len(props))
+		} else {
+			e.writeObjectHeader(name, len(props))
+		}
+		for _, p := range props {
+			if e.structsAsArrays {
+				e.writeStringKey(p.name)
+			} else {
+				e.writeString(p.name)
+			}
+			e.spill()
+			p.enc(e, p.v)
+		}
+		e.writeEnd()
+	}
+}
@@ -0,0 +1,595 @@
+package phpserialize
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/kamiaka/go-phpserialize/internal/decoding"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// UnmarshalInto parses the PHP serialized data and stores the result in the
+// value pointed to by v.
+//
+// Objects and arrays are decoded into structs by matching property names
+// with exported field names, or the names given by php struct tags. When the
+// target is an interface and the object class has been registered with
+// Register or RegisterName, a value of the registered type is created and
+// decoded into. Otherwise an empty interface receives bool, int64, float64,
+// string, []interface{} for list arrays, map[interface{}]interface{} for
+// other arrays and map[string]interface{} for objects. time.Time values are
+// decoded with DecodeTime.
+//
+// Decoders registered with RegisterDecoder and RegisterClassDecoder take
+// precedence over these rules.
+func UnmarshalInto(data []byte, v interface{}) error {
+	return unmarshalData(data, v, options{})
+}
+
+// Decode parses the PHP serialized data into a new value of type T, following
+// the rules of UnmarshalInto. The options apply to parsing and to storing the
+// result, as for a Decoder.
+func Decode[T any](data []byte, opts ...Option) (T, error) {
+	var v T
+	err := unmarshalData(data, &v, newOptions(opts))
+	return v, err
+}
+
+// UnmarshalValue stores the decoded PHP value src in the value pointed to by
+// v, following the rules of UnmarshalInto.
+func UnmarshalValue(src *php.Value, v interface{}) error {
+	return unmarshalValue(src, v, options{})
+}
+
+// unmarshalData parses data and stores the result in the value pointed to by
+// v, locating the value of an UnmarshalTypeError in data.
+func unmarshalData(data []byte, v interface{}, opts options) error {
+	pv, err := unmarshal(nil, data, opts)
+	if err != nil {
+		return err
+	}
+	err = unmarshalValue(pv, v, opts)
+	if e, ok := err.(*UnmarshalTypeError); ok {
+		e.Offset = valueOffset(data, opts, e.steps)
+	}
+	return err
+}
+
+// assignState holds the options applying to storing decoded values in Go
+// values.
+type assignState struct {
+	options
+	path []pathStep // from the root to the value being stored
+}
+
+// pathStep is a step from an array or object to one of its members.
+type pathStep struct {
+	key  *php.Value // array key, nil for object fields
+	name string     // field name
+	pos  int        // position among the members
+}
+
+func (a *assignState) enter(key *php.Value, name string, pos int) {
+	a.path = append(a.path, pathStep{key: key, name: name, pos: pos})
+}
+
+func (a *assignState) leave() {
+	a.path = a.path[:len(a.path)-1]
+}
+
+// formatPath formats path like "orders[3].items[0].price".
+func formatPath(path []pathStep) string {
+	var b strings.Builder
+	for _, p := range path {
+		switch {
+		case p.key != nil && p.key.Type() == php.TypeInt:
+			b.WriteString("[" + strconv.FormatInt(p.key.Int(), 10) + "]")
+		case p.key != nil:
+			p.name = p.key.String()
+			fallthrough
+		default:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(p.name)
+		}
+	}
+	return b.String()
+}
+
+// valueOffset returns the offset in data of the value reached from the root by
+// the steps, or of the innermost value on the way whose offset is known.
+func valueOffset(data []byte, opts options, steps []pathStep) int64 {
+	opts.intern = false // offsets are recorded by Value
+	r := phpDecoder.Decode(&decoding.Request{
+		Data:    data,
+		Options: opts.decodeOptions(),
+		Offsets: true,
+	})
+	if r.Err != nil {
+		return -1
+	}
+	v := r.Value
+	off := int64(r.Offsets[v])
+	for _, p := range steps {
+		switch v.Type() {
+		case php.TypeArray:
+			arr := v.Array()
+			if p.pos >= len(arr) {
+				return off
+			}
+			v = arr[p.pos].Value
+		case php.TypeObject:
+			fields := v.Object().Fields
+			if p.pos >= len(fields) {
+				return off
+			}
+			v = fields[p.pos].Value
+		default:
+			return off
+		}
+		if o, ok := r.Offsets[v]; ok {
+			off = int64(o)
+		}
+	}
+	return off
+}
+
+func unmarshalValue(src *php.Value, v interface{}, opts options) (err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+
+	a := &assignState{options: opts}
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(serializeErr); ok {
+				err = e.error
+			} else {
+				panic(r)
+			}
+			if _, ok := err.(*UnmarshalTypeError); !ok && len(a.path) > 0 {
+				err = fmt.Errorf("%w, path: %s", err, formatPath(a.path))
+			}
+		}
+	}()
+	a.assignValue(src, rv.Elem())
+	return nil
+}
+
+// DecodeInto reads the next PHP serialized value from its input and stores it
+// in the value pointed to by v, following the rules of UnmarshalInto.
+func (dec *Decoder) DecodeInto(v interface{}) error {
+	pv, err := dec.Decode()
+	if err != nil {
+		return err
+	}
+	err = unmarshalValue(pv, v, dec.opts)
+	if e, ok := err.(*UnmarshalTypeError); ok {
+		e.Offset = valueOffset(dec.buf[dec.scanp-dec.last:dec.scanp], dec.opts, e.steps)
+	}
+	return err
+}
+
+// An InvalidUnmarshalError describes an invalid argument passed to
+// UnmarshalInto or UnmarshalValue. (The argument must be a non-nil pointer.)
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "php serialize: Unmarshal(nil)"
+	}
+	if e.Type.Kind() != reflect.Ptr {
+		return "php serialize: Unmarshal(non-pointer " + e.Type.String() + ")"
+	}
+	return "php serialize: Unmarshal(nil " + e.Type.String() + ")"
+}
+
+var phpValueType = reflect.TypeOf((*php.Value)(nil))
+
+// An UnmarshalTypeError describes a PHP value that was not appropriate for a
+// Go value of a specific type.
+type UnmarshalTypeError struct {
+	Value string       // description of the PHP value, such as "string" or "int 300"
+	Type  reflect.Type // type of the Go value it could not be assigned to
+	// Offset is the offset of the value in the data, counted from the start
+	// of the value for a Decoder, or -1 when the data is not known as for
+	// UnmarshalValue.
+	Offset int64
+	// Path locates the value from the root, such as "orders[3].price":
+	// string keys and property names are joined with dots and integer keys
+	// written in brackets. The root has the empty path.
+	Path string
+
+	steps []pathStep
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	if e.Path == "" {
+		return "php serialize: cannot unmarshal " + e.Value + " into Go value of type " + e.Type.String()
+	}
+	return "php serialize: cannot unmarshal " + e.Value + " at " + e.Path + " into Go value of type " + e.Type.String()
+}
+
+func (a *assignState) typeError(value string, t reflect.Type) {
+	raiseError(&UnmarshalTypeError{
+		Value:  value,
+		Type:   t,
+		Offset: -1,
+		Path:   formatPath(a.path),
+		steps:  append([]pathStep(nil), a.path...),
+	})
+}
+
+func (a *assignState) assignError(src *php.Value, t reflect.Type) {
+	a.typeError(src.Type().String(), t)
+}
+
+func (a *assignState) assignValue(src *php.Value, v reflect.Value) {
+	if v.Type() == phpValueType {
+		v.Set(reflect.ValueOf(src))
+		return
+	}
+	if fn, ok := typeDecoder(v.Type()); ok {
+		x, err := fn(src)
+		if err != nil {
+			raiseError(err)
+		}
+		v.Set(x)
+		return
+	}
+	if v.Type() == rawMessageType {
+		bs, err := Marshal(src)
+		if err != nil {
+			raiseError(err)
+		}
+		v.SetBytes(bs)
+		return
+	}
+	if a.jsonTranscoding && v.Type() == jsonRawMessageType {
+		a.assignJSON(src, v)
+		return
+	}
+	if v.Type() == timeType && !src.IsNil() {
+		t, err := DecodeTime(src)
+		if err != nil {
+			raiseError(err)
+		}
+		v.Set(reflect.ValueOf(t))
+		return
+	}
+	if src.IsNil() {
+		switch v.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		a.assignValue(src, v.Elem())
+	case reflect.Interface:
+		a.assignInterface(src, v)
+	case reflect.Bool:
+		if src.Type() != php.TypeBool {
+			if b, ok := weakBool(src); ok && a.weakTypes {
+				v.SetBool(b)
+				return
+			}
+			a.assignError(src, v.Type())
+		}
+		v.SetBool(src.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := weakInt(src)
+		if !ok || src.Type() != php.TypeInt && !a.weakTypes {
+			a.assignError(src, v.Type())
+		}
+		if v.OverflowInt(i) {
+			a.typeError("int "+strconv.FormatInt(i, 10), v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := weakInt(src)
+		if !ok || src.Type() != php.TypeInt && !a.weakTypes {
+			a.assignError(src, v.Type())
+		}
+		if i < 0 || v.OverflowUint(uint64(i)) {
+			a.typeError("int "+strconv.FormatInt(i, 10), v.Type())
+		}
+		v.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		switch src.Type() {
+		case php.TypeFloat:
+			v.SetFloat(src.Float())
+		case php.TypeInt:
+			v.SetFloat(float64(src.Int()))
+		default:
+			f, ok := weakFloat(src)
+			if !ok || !a.weakTypes {
+				a.assignError(src, v.Type())
+			}
+			v.SetFloat(f)
+		}
+	case reflect.Complex64, reflect.Complex128:
+		a.assignComplex(src, v)
+	case reflect.String:
+		if src.Type() != php.TypeString {
+			if s, ok := weakString(src); ok && a.weakTypes {
+				v.SetString(s)
+				return
+			}
+			a.assignError(src, v.Type())
+		}
+		v.SetString(src.String())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && src.Type() == php.TypeString {
+			v.SetBytes([]byte(src.String()))
+			return
+		}
+		if src.Type() != php.TypeArray {
+			a.assignError(src, v.Type())
+		}
+		arr := src.Array()
+		s := reflect.MakeSlice(v.Type(), len(arr), len(arr))
+		for i, e := range arr {
+			a.enter(e.Index, "", i)
+			a.assignValue(e.Value, s.Index(i))
+			a.leave()
+		}
+		v.Set(s)
+	case reflect.Array:
+		if src.Type() != php.TypeArray {
+			a.assignError(src, v.Type())
+		}
+		arr := src.Array()
+		for i := 0; i < v.Len(); i++ {
+			if i < len(arr) {
+				a.enter(arr[i].Index, "", i)
+				a.assignValue(arr[i].Value, v.Index(i))
+				a.leave()
+			} else {
+				v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+			}
+		}
+	case reflect.Map:
+		a.assignMap(src, v)
+	case reflect.Struct:
+		a.assignStruct(src, v)
+	default:
+		raiseError(&UnsupportedTypeError{v.Type()})
+	}
+}
+
+func (a *assignState) assignInterface(src *php.Value, v reflect.Value) {
+	if src.Type() == php.TypeObject {
+		if x, ok := decodeClass(src); ok {
+			xv := reflect.ValueOf(x)
+			if x == nil {
+				xv = reflect.Zero(v.Type())
+			} else if !xv.Type().AssignableTo(v.Type()) {
+				raiseError(fmt.Errorf("php serialize: decoded %v of class %s does not implement %v", xv.Type(), src.Object().Name, v.Type()))
+			}
+			v.Set(xv)
+			return
+		}
+		if t, ok := registeredType(src.Object().Name); ok {
+			var nv reflect.Value
+			if t.Kind() == reflect.Ptr {
+				nv = reflect.New(t.Elem())
+				a.assignValue(src, nv.Elem())
+			} else {
+				nv = reflect.New(t).Elem()
+				a.assignValue(src, nv)
+			}
+			if !nv.Type().AssignableTo(v.Type()) {
+				raiseError(fmt.Errorf("php serialize: registered type %v for class %s does not implement %v", t, src.Object().Name, v.Type()))
+			}
+			v.Set(nv)
+			return
+		}
+	}
+	if v.NumMethod() != 0 {
+		a.assignError(src, v.Type())
+	}
+	v.Set(reflect.ValueOf(interfaceValue(src)))
+}
+
+// decodeClass decodes the object src with the decoder registered for its
+// class, and reports whether there is one.
+func decodeClass(src *php.Value) (interface{}, bool) {
+	fn, ok := classDecoder(src.Object().Name)
+	if !ok {
+		return nil, false
+	}
+	x, err := fn(src)
+	if err != nil {
+		raiseError(err)
+	}
+	return x, true
+}
+
+// interfaceValue returns the natural Go representation of src.
+func interfaceValue(src *php.Value) interface{} {
+	if src.IsNil() {
+		return nil
+	}
+	switch src.Type() {
+	case php.TypeArray:
+		arr := src.Array()
+		if src.IsList() {
+			ls := make([]interface{}, len(arr))
+			for i, e := range arr {
+				ls[i] = interfaceValue(e.Value)
+			}
+			return ls
+		}
+		m := make(map[interface{}]interface{}, len(arr))
+		for _, e := range arr {
+			m[e.Index.Interface()] = interfaceValue(e.Value)
+		}
+		return m
+	case php.TypeObject:
+		if x, ok := decodeClass(src); ok {
+			return x
+		}
+		obj := src.Object()
+		m := make(map[string]interface{}, len(obj.Fields))
+		for _, f := range obj.Fields {
+			m[f.Name] = interfaceValue(f.Value)
+		}
+		return m
+	default:
+		return src.Interface()
+	}
+}
+
+func (a *assignState) assignMap(src *php.Value, v reflect.Value) {
+	t := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+	switch src.Type() {
+	case php.TypeArray:
+		for i, e := range src.Array() {
+			a.enter(e.Index, "", i)
+			a.assignMapEntry(v, e.Index, e.Value)
+			a.leave()
+		}
+	case php.TypeObject:
+		for i, f := range src.Object().Fields {
+			a.enter(nil, f.Name, i)
+			a.assignMapEntry(v, php.String(f.Name), f.Value)
+			a.leave()
+		}
+	default:
+		a.assignError(src, t)
+	}
+}
+
+func (a *assignState) assignMapEntry(m reflect.Value, key, val *php.Value) {
+	t := m.Type()
+	k := reflect.New(t.Key()).Elem()
+	switch k.Kind() {
+	case reflect.String:
+		if key.Type() == php.TypeInt {
+			k.SetString(strconv.FormatInt(key.Int(), 10))
+		} else {
+			k.SetString(key.String())
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if key.Type() == php.TypeString {
+			i, err := strconv.ParseInt(key.String(), 10, 64)
+			if err != nil {
+				raiseError(fmt.Errorf("php serialize: cannot unmarshal array key %q into Go value of type %v", key.String(), t.Key()))
+			}
+			key = php.Int(int(i))
+		}
+		a.assignValue(key, k)
+	case reflect.Interface:
+		a.assignValue(key, k)
+	default:
+		raiseError(&UnsupportedMapKeyTypeError{t.Key()})
+	}
+	e := reflect.New(t.Elem()).Elem()
+	a.assignValue(val, e)
+	m.SetMapIndex(k, e)
+}
+
+// fieldByIndexAlloc returns the nested field of v at index, allocating the
+// nil embedded struct pointers on the way as encoding/json does.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					raiseError(fmt.Errorf("php serialize: cannot set embedded pointer to unexported struct: %v", v.Type().Elem()))
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func (a *assignState) assignStruct(src *php.Value, v reflect.Value) {
+	t := v.Type()
+	fields := map[string]field{} // by name, or by visibilityKey for fields with a visibility
+	var folded map[string]field  // by lower-cased key, first field wins
+	if a.caseInsensitive {
+		folded = map[string]field{}
+	}
+	for _, f := range cachedTypeFields(t, &a.options) {
+		key := f.name
+		if f.hasVisibility {
+			key = visibilityKey(key, f.visibility)
+		}
+		fields[key] = f
+		if _, ok := folded[strings.ToLower(key)]; folded != nil && !ok {
+			folded[strings.ToLower(key)] = f
+		}
+	}
+	lookup := func(key string) (field, bool) {
+		f, ok := fields[key]
+		if !ok && folded != nil {
+			f, ok = folded[strings.ToLower(key)]
+		}
+		return f, ok
+	}
+	set := func(name string, vis php.Visibility, val *php.Value) {
+		f, ok := lookup(visibilityKey(name, vis))
+		if !ok {
+			f, ok = lookup(name)
+		}
+		if !ok {
+			if a.disallowUnknownFields {
+				raiseError(fmt.Errorf("php serialize: unknown property %q for Go value of type %v", name, t))
+			}
+			return
+		}
+		if f.private {
+			return
+		}
+		if f.json {
+			a.assignJSON(val, fieldByIndexAlloc(v, f.index))
+			return
+		}
+		a.assignValue(val, fieldByIndexAlloc(v, f.index))
+	}
+	switch src.Type() {
+	case php.TypeObject:
+		for i, f := range src.Object().Fields {
+			a.enter(nil, f.Name, i)
+			set(f.Name, f.Visibility, f.Value)
+			a.leave()
+		}
+	case php.TypeArray:
+		for i, e := range src.Array() {
+			a.enter(e.Index, "", i)
+			if e.Index.Type() == php.TypeString {
+				// keys of objects cast to arrays keep the mangled names
+				name, vis := e.Index.String(), php.VisibilityPublic
+				if strings.HasPrefix(name, "\x00") {
+					name, vis, _ = php.DemangleName(name)
+				}
+				set(name, vis, e.Value)
+			} else if a.disallowUnknownFields {
+				raiseError(fmt.Errorf("php serialize: unknown key %d for Go value of type %v", e.Index.Int(), t))
+			}
+			a.leave()
+		}
+	default:
+		a.assignError(src, t)
+	}
+}
@@ -0,0 +1,583 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+type event interface {
+	EventName() string
+}
+
+type userCreated struct {
+	ID   int
+	Name string
+}
+
+func (e *userCreated) EventName() string { return "created" }
+
+type userDeleted struct {
+	ID int
+}
+
+func (e userDeleted) EventName() string { return "deleted" }
+
+type taggedUser struct {
+	ID      int    `php:"id"`
+	Ignored string `php:"-"`
+}
+
+type envelope struct {
+	Source string
+	Event  event
+	Meta   interface{}
+}
+
+func init() {
+	phpserialize.RegisterName(`App\Events\UserCreated`, &userCreated{})
+	phpserialize.RegisterName(`App\Events\UserDeleted`, userDeleted{})
+}
+
+func TestUnmarshalInto(t *testing.T) {
+	cases := []struct {
+		data       string
+		ptr        interface{}
+		want       interface{}
+		wantsError bool
+	}{
+		{data: `i:42;`, ptr: new(int), want: 42},
+		{data: `i:300;`, ptr: new(int8), wantsError: true},
+		{data: `i:42;`, ptr: new(float64), want: 42.0},
+		{data: `s:3:"abc";`, ptr: new(string), want: "abc"},
+		{data: `s:3:"abc";`, ptr: new(int), wantsError: true},
+		{data: `s:3:"abc";`, ptr: new([]byte), want: []byte("abc")},
+		{data: `N;`, ptr: new(*int), want: (*int)(nil)},
+		{data: `a:2:{i:0;i:1;i:1;i:2;}`, ptr: new([]int), want: []int{1, 2}},
+		{
+			data: `a:2:{s:1:"a";i:1;i:5;i:2;}`,
+			ptr:  new(map[string]int),
+			want: map[string]int{"a": 1, "5": 2},
+		},
+		{
+			data: `a:2:{i:0;s:1:"a";i:1;a:1:{s:1:"k";b:1;}}`,
+			ptr:  new(interface{}),
+			want: []interface{}{"a", map[interface{}]interface{}{"k": true}},
+		},
+		{
+			data: `O:8:"stdClass":2:{s:6:"Source";s:3:"web";s:5:"Event";` +
+				`O:22:"App\Events\UserCreated":2:{s:2:"ID";i:1;s:4:"Name";s:3:"Bob";}}`,
+			ptr: new(envelope),
+			want: envelope{
+				Source: "web",
+				Event:  &userCreated{ID: 1, Name: "Bob"},
+			},
+		},
+		{
+			data: `O:8:"stdClass":2:{s:5:"Event";O:22:"App\Events\UserDeleted":1:{s:2:"ID";i:2;}` +
+				`s:4:"Meta";O:3:"Foo":1:{s:1:"a";i:1;}}`,
+			ptr: new(envelope),
+			want: envelope{
+				Event: userDeleted{ID: 2},
+				Meta:  map[string]interface{}{"a": int64(1)},
+			},
+		},
+		{
+			data: `a:3:{s:2:"id";i:7;s:2:"ID";i:8;s:7:"Ignored";s:1:"x";}`,
+			ptr:  new(taggedUser),
+			want: taggedUser{ID: 7},
+		},
+		{
+			data:       `O:8:"stdClass":1:{s:5:"Event";O:3:"Foo":0:{}}`,
+			ptr:        new(envelope),
+			wantsError: true,
+		},
+	}
+	for i, tc := range cases {
+		err := phpserialize.UnmarshalInto([]byte(tc.data), tc.ptr)
+		if err != nil {
+			if !tc.wantsError {
+				t.Errorf("#%d: UnmarshalInto(%q) returns error: %v", i, tc.data, err)
+			}
+			continue
+		}
+		if tc.wantsError {
+			t.Errorf("#%d: UnmarshalInto(%q) wants error but no error occurred", i, tc.data)
+			continue
+		}
+		if got := reflect.ValueOf(tc.ptr).Elem().Interface(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("#%d: UnmarshalInto(%q) == %#v, want: %#v", i, tc.data, got, tc.want)
+		}
+	}
+}
+
+type userRenamed struct {
+	ID   int
+	Name string
+}
+
+func (e *userRenamed) EventName() string    { return "renamed" }
+func (e *userRenamed) PHPClassName() string { return `App\Events\UserRenamed` }
+
+type userBanned struct {
+	ID int
+}
+
+func (e userBanned) EventName() string { return "banned" }
+
+func TestRegister(t *testing.T) {
+	phpserialize.Register(&userRenamed{})
+	phpserialize.Register(userBanned{})
+
+	cases := []struct {
+		data string
+		want event
+	}{
+		{
+			data: `O:8:"stdClass":1:{s:5:"Event";O:22:"App\Events\UserRenamed":1:{s:4:"Name";s:3:"Ann";}}`,
+			want: &userRenamed{Name: "Ann"},
+		},
+		{
+			data: `O:8:"stdClass":1:{s:5:"Event";O:10:"userBanned":1:{s:2:"ID";i:3;}}`,
+			want: userBanned{ID: 3},
+		},
+	}
+	for i, tc := range cases {
+		var v envelope
+		if err := phpserialize.UnmarshalInto([]byte(tc.data), &v); err != nil {
+			t.Errorf("#%d: UnmarshalInto(%q) returns error: %v", i, tc.data, err)
+			continue
+		}
+		if !reflect.DeepEqual(v.Event, tc.want) {
+			t.Errorf("#%d: UnmarshalInto(%q) sets Event to %#v, want: %#v", i, tc.data, v.Event, tc.want)
+		}
+	}
+}
+
+func TestUnmarshalIntoInvalid(t *testing.T) {
+	var i int
+	if err := phpserialize.UnmarshalInto([]byte(`i:1;`), i); err == nil {
+		t.Error("UnmarshalInto(non-pointer) wants error but no error occurred")
+	}
+}
+
+type PromotedInner struct {
+	X int
+}
+
+type promotedOuter struct {
+	*PromotedInner
+	Y int
+}
+
+type promotedUnexported struct {
+	*promotedInner
+	Y int
+}
+
+type promotedInner struct {
+	X int
+}
+
+func TestDecodePromotedEmbeddedPointer(t *testing.T) {
+	data := []byte(`O:5:"Outer":2:{s:1:"X";i:1;s:1:"Y";i:2;}`)
+	got, err := phpserialize.Decode[promotedOuter](data, phpserialize.WithPromoteEmbedded())
+	if err != nil {
+		t.Fatalf("Decode(%s) returns error: %v", data, err)
+	}
+	if got.PromotedInner == nil || got.X != 1 || got.Y != 2 {
+		t.Errorf("Decode(%s) == %+v, want the nil embedded pointer allocated", data, got)
+	}
+
+	if _, err := phpserialize.Decode[promotedUnexported](data, phpserialize.WithPromoteEmbedded()); err == nil {
+		t.Errorf("Decode(%s) into a nil pointer to an unexported embedded struct wants error but no error occurred", data)
+	}
+}
+
+func TestUnmarshalIntoTime(t *testing.T) {
+	cases := []struct {
+		data string
+		want time.Time
+	}{
+		{
+			data: `O:8:"DateTime":3:{s:4:"date";s:26:"2021-01-02 03:04:05.000006";s:13:"timezone_type";i:3;s:8:"timezone";s:3:"UTC";}`,
+			want: time.Date(2021, 1, 2, 3, 4, 5, 6000, time.UTC),
+		},
+		{
+			data: `O:13:"Carbon\Carbon":3:{s:4:"date";s:26:"2021-01-02 03:04:05.000000";s:13:"timezone_type";i:1;s:8:"timezone";s:6:"+09:00";}`,
+			want: time.Date(2021, 1, 1, 18, 4, 5, 0, time.UTC),
+		},
+		{
+			data: `O:8:"DateTime":3:{s:4:"date";s:26:"2021-01-02 03:04:05.000000";s:13:"timezone_type";i:2;s:8:"timezone";s:3:"EST";}`,
+			want: time.Date(2021, 1, 2, 8, 4, 5, 0, time.UTC),
+		},
+		{
+			data: `O:8:"DateTime":3:{s:4:"date";s:26:"2021-07-02 03:04:05.000000";s:13:"timezone_type";i:2;s:8:"timezone";s:4:"CEST";}`,
+			want: time.Date(2021, 7, 2, 1, 4, 5, 0, time.UTC),
+		},
+		{data: `i:1609556645;`, want: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{data: `s:20:"2021-01-02T03:04:05Z";`, want: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}
+	for i, tc := range cases {
+		var got time.Time
+		if err := phpserialize.UnmarshalInto([]byte(tc.data), &got); err != nil {
+			t.Fatalf("#%d: UnmarshalInto(...) returns error: %v", i, err)
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("#%d: UnmarshalInto(...) == %v, want: %v", i, got, tc.want)
+		}
+	}
+
+	var got time.Time
+	data := `O:8:"DateTime":1:{s:4:"date";s:3:"bad";}`
+	if err := phpserialize.UnmarshalInto([]byte(data), &got); err == nil {
+		t.Error("UnmarshalInto(...) wants error but no error occurred")
+	}
+}
+
+func TestUnmarshalIntoRawMessage(t *testing.T) {
+	var v struct {
+		A string
+		B phpserialize.RawMessage
+	}
+	data := []byte(`a:2:{s:1:"A";s:1:"x";s:1:"B";a:1:{i:0;d:0.5;}}`)
+	if err := phpserialize.UnmarshalInto(data, &v); err != nil {
+		t.Fatalf("UnmarshalInto(...) returns error: %v", err)
+	}
+	if want := `a:1:{i:0;d:0.5;}`; string(v.B) != want {
+		t.Errorf("UnmarshalInto(...) sets B to %s, want: %s", v.B, want)
+	}
+}
+
+type cents int64
+
+type point struct {
+	X, Y int64
+}
+
+func TestRegisterDecoder(t *testing.T) {
+	phpserialize.RegisterDecoder(func(v *php.Value) (cents, error) {
+		s, err := v.TryString()
+		if err != nil {
+			return 0, err
+		}
+		var whole, frac int64
+		if _, err := fmt.Sscanf(s, "%d.%d", &whole, &frac); err != nil {
+			return 0, err
+		}
+		return cents(whole*100 + frac), nil
+	})
+	phpserialize.RegisterClassDecoder(`Geo\Point`, func(v *php.Value) (interface{}, error) {
+		return point{X: v.At("x").IntOr(0), Y: v.At("y").IntOr(0)}, nil
+	})
+
+	var v struct {
+		Price  cents
+		Prices []cents
+		Where  interface{}
+		All    []interface{}
+	}
+	data := []byte(`a:4:{s:5:"Price";s:5:"12.34";s:6:"Prices";a:1:{i:0;s:4:"0.05";}` +
+		`s:5:"Where";O:9:"Geo\Point":2:{s:1:"x";i:1;s:1:"y";i:2;}` +
+		`s:3:"All";a:1:{i:0;O:9:"Geo\Point":0:{}}}`)
+	if err := phpserialize.UnmarshalInto(data, &v); err != nil {
+		t.Fatalf("UnmarshalInto(...) returns error: %v", err)
+	}
+	if v.Price != 1234 || len(v.Prices) != 1 || v.Prices[0] != 5 {
+		t.Errorf("UnmarshalInto(...) sets prices %v, %v, want: 1234, [5]", v.Price, v.Prices)
+	}
+	if v.Where != (point{1, 2}) {
+		t.Errorf("UnmarshalInto(...) sets Where to %#v, want: %#v", v.Where, point{1, 2})
+	}
+	if len(v.All) != 1 || v.All[0] != (point{}) {
+		t.Errorf("UnmarshalInto(...) sets All to %#v, want: [%#v]", v.All, point{})
+	}
+
+	if err := phpserialize.UnmarshalInto([]byte(`s:1:"x";`), new(cents)); err == nil {
+		t.Errorf("UnmarshalInto(invalid cents) returns no error")
+	}
+}
+
+type panicking int
+
+func TestRegisterDecoderPanic(t *testing.T) {
+	phpserialize.RegisterDecoder(func(v *php.Value) (panicking, error) {
+		var a []int
+		return panicking(a[v.IntOr(0)]), nil
+	})
+	defer func() {
+		if _, ok := recover().(runtime.Error); !ok {
+			t.Errorf("UnmarshalInto(...) does not pass on the panic of the decoder")
+		}
+	}()
+	phpserialize.UnmarshalInto([]byte(`i:1;`), new(panicking))
+}
+
+func TestDecode(t *testing.T) {
+	u, err := phpserialize.Decode[taggedUser]([]byte(`a:1:{s:2:"id";i:7;}`))
+	if err != nil {
+		t.Fatalf("Decode[taggedUser](...) returns error: %v", err)
+	}
+	if u != (taggedUser{ID: 7}) {
+		t.Errorf("Decode[taggedUser](...) = %#v, want: %#v", u, taggedUser{ID: 7})
+	}
+
+	m, err := phpserialize.Decode[map[string]int]([]byte(`a:1:{s:1:"a";i:1;}`), phpserialize.WithMaxDepth(1))
+	if err != nil {
+		t.Fatalf("Decode[map[string]int](...) returns error: %v", err)
+	}
+	if !reflect.DeepEqual(m, map[string]int{"a": 1}) {
+		t.Errorf("Decode[map[string]int](...) = %v, want: %v", m, map[string]int{"a": 1})
+	}
+
+	if _, err := phpserialize.Decode[int]([]byte(`s:1:"a";`)); err == nil {
+		t.Errorf("Decode[int](string) returns no error")
+	}
+	if _, err := phpserialize.Decode[int]([]byte(`i:1`)); err == nil {
+		t.Errorf("Decode[int](truncated) returns no error")
+	}
+}
+
+func TestDecodeDisallowUnknownFields(t *testing.T) {
+	type user struct {
+		ID   int `php:"id"`
+		Name string
+		note string
+	}
+	cases := []struct {
+		data       string
+		wantsError bool
+	}{
+		{data: `a:2:{s:2:"id";i:1;s:4:"Name";s:1:"a";}`},
+		{data: `O:4:"User":2:{s:2:"id";i:1;s:4:"note";s:1:"x";}`},
+		{data: `a:1:{s:3:"age";i:1;}`, wantsError: true},
+		{data: `a:1:{i:0;i:1;}`, wantsError: true},
+		{data: `O:4:"User":1:{s:2:"ID";i:1;}`, wantsError: true},
+	}
+	for i, tc := range cases {
+		_, err := phpserialize.Decode[user]([]byte(tc.data), phpserialize.WithDisallowUnknownFields())
+		if err != nil {
+			if !tc.wantsError {
+				t.Errorf("#%d: Decode(%q) returns error: %v", i, tc.data, err)
+			}
+			continue
+		}
+		if tc.wantsError {
+			t.Errorf("#%d: Decode(%q) wants error but no error occurred", i, tc.data)
+		}
+		if _, err := phpserialize.Decode[user]([]byte(tc.data)); err != nil {
+			t.Errorf("#%d: Decode(%q) without option returns error: %v", i, tc.data, err)
+		}
+	}
+}
+
+func TestDecodeCaseInsensitiveFields(t *testing.T) {
+	type user struct {
+		UserID int
+		Userid int `php:"userid"`
+		Name   string
+	}
+	data := []byte(`a:3:{s:6:"userId";i:1;s:6:"userid";i:2;s:4:"NAME";s:1:"a";}`)
+	u, err := phpserialize.Decode[user](data, phpserialize.WithCaseInsensitiveFields(), phpserialize.WithDisallowUnknownFields())
+	if err != nil {
+		t.Fatalf("Decode(%q) returns error: %v", data, err)
+	}
+	if want := (user{UserID: 1, Userid: 2, Name: "a"}); u != want {
+		t.Errorf("Decode(%q) == %+v, want: %+v", data, u, want)
+	}
+
+	u, err = phpserialize.Decode[user](data)
+	if err != nil {
+		t.Fatalf("Decode(%q) returns error: %v", data, err)
+	}
+	if want := (user{Userid: 2}); u != want {
+		t.Errorf("Decode(%q) without option == %+v, want: %+v", data, u, want)
+	}
+}
+
+func TestDecodeWeakTypes(t *testing.T) {
+	type record struct {
+		ID     int
+		Count  uint8
+		Active bool
+		Score  float64
+		Label  string
+	}
+	cases := []struct {
+		data    string
+		want    record
+		wantErr bool
+	}{
+		{
+			data: `a:5:{s:2:"ID";s:2:"42";s:5:"Count";d:3.9;s:6:"Active";i:1;s:5:"Score";s:4:"2.5x";s:5:"Label";d:1.5;}`,
+			want: record{ID: 42, Count: 3, Active: true, Score: 2.5, Label: "1.5"},
+		},
+		{
+			data: `a:4:{s:2:"ID";s:3:"abc";s:6:"Active";s:1:"0";s:5:"Score";b:1;s:5:"Label";b:1;}`,
+			want: record{Score: 1, Label: "1"},
+		},
+		{
+			data: `a:1:{s:2:"ID";s:5:" 1e3 ";}`,
+			want: record{ID: 1000},
+		},
+		{
+			data:    `a:1:{s:5:"Count";s:3:"300";}`,
+			wantErr: true,
+		},
+		{
+			data:    `a:1:{s:5:"Count";i:-1;}`,
+			wantErr: true,
+		},
+		{
+			data:    `a:1:{s:2:"ID";d:1.0E+30;}`,
+			wantErr: true,
+		},
+		{
+			data:    `a:1:{s:2:"ID";a:0:{}}`,
+			wantErr: true,
+		},
+	}
+	for i, c := range cases {
+		got, err := phpserialize.Decode[record]([]byte(c.data), phpserialize.WithWeakTypes())
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("#%d: Decode(%q) == %+v, want error", i, c.data, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: Decode(%q) returns error: %v", i, c.data, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("#%d: Decode(%q) == %+v, want: %+v", i, c.data, got, c.want)
+		}
+	}
+
+	data := []byte(`a:1:{s:2:"ID";s:2:"42";}`)
+	if _, err := phpserialize.Decode[record](data); err == nil {
+		t.Errorf("Decode(%q) without option returns no error", data)
+	}
+}
+
+func TestUnmarshalTypeError(t *testing.T) {
+	type item struct {
+		Price int `php:"price"`
+	}
+	type order struct {
+		Items []item `php:"items"`
+	}
+	type doc struct {
+		Orders map[string]order `php:"orders"`
+		Count  uint8            `php:"count"`
+	}
+	cases := []struct {
+		data   string
+		value  string
+		typ    reflect.Type
+		offset int64
+		path   string
+	}{
+		{
+			data:   `s:1:"a";`,
+			value:  "string",
+			typ:    reflect.TypeOf(doc{}),
+			offset: 0,
+			path:   "",
+		},
+		{
+			data:   `a:1:{s:6:"orders";a:1:{s:1:"x";a:1:{s:5:"items";a:2:{i:0;a:0:{}i:1;a:1:{s:5:"price";s:3:"1.5";}}}}}`,
+			value:  "string",
+			typ:    reflect.TypeOf(0),
+			offset: 84,
+			path:   "orders.x.items[1].price",
+		},
+		{
+			data:   `O:8:"stdClass":1:{s:5:"count";i:300;}`,
+			value:  "int 300",
+			typ:    reflect.TypeOf(uint8(0)),
+			offset: 30,
+			path:   "count",
+		},
+	}
+	for i, c := range cases {
+		_, err := phpserialize.Decode[doc]([]byte(c.data))
+		var e *phpserialize.UnmarshalTypeError
+		if !errors.As(err, &e) {
+			t.Errorf("#%d: Decode(%q) returns error %v, want UnmarshalTypeError", i, c.data, err)
+			continue
+		}
+		if e.Value != c.value || e.Type != c.typ || e.Offset != c.offset || e.Path != c.path {
+			t.Errorf("#%d: Decode(%q) returns %+v, want: Value %q, Type %v, Offset %d, Path %q", i, c.data, e, c.value, c.typ, c.offset, c.path)
+		}
+	}
+
+	err := phpserialize.UnmarshalValue(php.String("a"), new(int))
+	var e *phpserialize.UnmarshalTypeError
+	if !errors.As(err, &e) || e.Offset != -1 {
+		t.Errorf("UnmarshalValue returns error %v, want UnmarshalTypeError with Offset -1", err)
+	}
+}
+
+func TestDecodeErrorPath(t *testing.T) {
+	type item struct {
+		Price int `php:"price"`
+	}
+	data := []byte(`a:1:{s:5:"items";a:1:{i:0;a:1:{s:5:"extra";i:1;}}}`)
+	_, err := phpserialize.Decode[map[string][]item](data, phpserialize.WithDisallowUnknownFields())
+	if want := `php serialize: unknown property "extra" for Go value of type phpserialize_test.item, path: items[0].extra`; err == nil || err.Error() != want {
+		t.Errorf("Decode(%q) returns error %v, want: %s", data, err, want)
+	}
+}
+
+func TestUnmarshalVisibilityTags(t *testing.T) {
+	type Account struct {
+		Name   string `php:"name"`
+		Secret string `php:"secret,private"`
+		Token  string `php:"token,protected"`
+		Public string `php:"token,public"`
+	}
+	v := Account{Name: "bob", Secret: "s", Token: "t", Public: "p"}
+	data := []byte("O:7:\"Account\":4:{s:4:\"name\";s:3:\"bob\";s:15:\"\x00Account\x00secret\";s:1:\"s\";s:8:\"\x00*\x00token\";s:1:\"t\";s:5:\"token\";s:1:\"p\";}")
+	var got Account
+	if err := phpserialize.UnmarshalInto(data, &got); err != nil {
+		t.Fatalf("UnmarshalInto(%q) returns error: %v", data, err)
+	}
+	if got != v {
+		t.Errorf("UnmarshalInto(%q) == %+v, want: %+v", data, got, v)
+	}
+
+	cases := []struct {
+		data string
+		want Account
+	}{
+		{
+			// visibilities that do not match the tags are ignored
+			data: "O:7:\"Account\":2:{s:6:\"secret\";s:1:\"s\";s:4:\"name\";s:1:\"n\";}",
+			want: Account{Name: "n"},
+		},
+		{
+			data: "O:1:\"X\":1:{s:7:\"\x00*\x00name\";s:1:\"n\";}",
+			want: Account{Name: "n"},
+		},
+		{
+			// an object cast to an array
+			data: "a:2:{s:9:\"\x00X\x00secret\";s:1:\"s\";s:8:\"\x00*\x00token\";s:1:\"t\";}",
+			want: Account{Secret: "s", Token: "t"},
+		},
+	}
+	for i, c := range cases {
+		var got Account
+		if err := phpserialize.UnmarshalInto([]byte(c.data), &got); err != nil {
+			t.Errorf("#%d: UnmarshalInto(%q) returns error: %v", i, c.data, err)
+		} else if got != c.want {
+			t.Errorf("#%d: UnmarshalInto(%q) == %+v, want: %+v", i, c.data, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package phpserialize
+
+import "unicode/utf8"
+
+// invalidUTF8 returns an error locating the first string in the serialized
+// data that is not valid UTF-8, or nil if there is none. escaped tells
+// whether strings may be written as S: tokens.
+func invalidUTF8(data []byte, escaped bool) error {
+	if !escaped && utf8.Valid(data) {
+		// the rest of the serialized form is ASCII
+		return nil
+	}
+	_, err := unmarshal(nil, data, options{validUTF8: true})
+	return err
+}
@@ -0,0 +1,56 @@
+package phpserialize_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+)
+
+func TestUnmarshalValidUTF8(t *testing.T) {
+	cases := []struct {
+		data string
+		want string // error substring, "" for success
+	}{
+		{data: `s:6:"héllo";`},
+		{data: "a:1:{s:4:\"name\";s:2:\"\xc3\x28\";}", want: "invalid UTF-8 in string at position 16, path: name"},
+		{data: "a:1:{s:1:\"\xff\";i:1;}", want: "invalid UTF-8 in string at position 5"},
+		{data: "O:3:\"Foo\":1:{s:1:\"a\";a:1:{i:0;S:1:\"\\ff\";}}", want: "path: a[0]"},
+	}
+	for i, c := range cases {
+		_, err := phpserialize.UnmarshalWithOptions([]byte(c.data), phpserialize.WithValidUTF8())
+		switch {
+		case c.want == "" && err != nil:
+			t.Errorf("#%d: UnmarshalWithOptions(%q) returns error: %v", i, c.data, err)
+		case c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)):
+			t.Errorf("#%d: UnmarshalWithOptions(%q) returns error: %v, want: %q", i, c.data, err, c.want)
+		}
+		if _, err := phpserialize.Unmarshal([]byte(c.data)); err != nil {
+			t.Errorf("#%d: Unmarshal(%q) returns error: %v", i, c.data, err)
+		}
+	}
+}
+
+func TestMarshalValidUTF8(t *testing.T) {
+	v := map[string]interface{}{
+		"ok":   "日本語",
+		"list": []interface{}{1, []byte{0xff}},
+	}
+	_, err := phpserialize.MarshalWithOptions(v, phpserialize.WithValidUTF8())
+	if err == nil || !strings.Contains(err.Error(), "invalid UTF-8 in string at position 33, path: list[1]") {
+		t.Errorf("MarshalWithOptions(...) returns error: %v", err)
+	}
+	if _, err := phpserialize.MarshalWithOptions(v, phpserialize.WithValidUTF8(), phpserialize.WithEscapedStrings()); err == nil {
+		t.Errorf("MarshalWithOptions(..., WithEscapedStrings()) returns no error")
+	}
+	if _, err := phpserialize.MarshalWithOptions(map[string]string{"a": "é"}, phpserialize.WithValidUTF8()); err != nil {
+		t.Errorf("MarshalWithOptions(valid) returns error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	enc := phpserialize.NewEncoder(&buf, phpserialize.WithValidUTF8())
+	if err := enc.Encode("\xff"); err == nil {
+		t.Errorf("Encode(invalid) returns no error")
+	}
+}
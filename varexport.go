@@ -0,0 +1,122 @@
+package phpserialize
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kamiaka/go-phpserialize/internal/phpfloat"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// VarExport writes v to w as PHP source code in the form of PHP's
+// var_export, so that evaluating it in PHP yields the value again. Objects
+// are written as calls to their class's __set_state method, or as
+// (object) array(...) casts for stdClass, with all fields regardless of
+// visibility.
+func VarExport(w io.Writer, v *php.Value) error {
+	var buf bytes.Buffer
+	varExport(&buf, v, 1)
+	_, err := writeAll(w, buf.Bytes())
+	return err
+}
+
+// varExport follows php_var_export_ex, including its indentation levels.
+func varExport(buf *bytes.Buffer, v *php.Value, level int) {
+	if v.IsNil() {
+		buf.WriteString("NULL")
+		return
+	}
+	switch v.Type() {
+	case php.TypeBool:
+		buf.WriteString(strconv.FormatBool(v.Bool()))
+	case php.TypeInt:
+		if i := v.Int(); i == math.MinInt64 {
+			buf.WriteString("-9223372036854775807-1")
+		} else {
+			buf.WriteString(strconv.FormatInt(i, 10))
+		}
+	case php.TypeFloat:
+		buf.WriteString(exportFloat(v.Float()))
+	case php.TypeString:
+		buf.WriteByte('\'')
+		buf.WriteString(strings.ReplaceAll(exportEscape(v.String()), "\x00", `' . "\0" . '`))
+		buf.WriteByte('\'')
+	case php.TypeArray:
+		if level > 1 {
+			buf.WriteByte('\n')
+			buf.WriteString(strings.Repeat(" ", level-1))
+		}
+		buf.WriteString("array (\n")
+		for _, e := range v.Array() {
+			buf.WriteString(strings.Repeat(" ", level+1))
+			if e.Index.Type() == php.TypeInt {
+				buf.WriteString(strconv.FormatInt(e.Index.Int(), 10))
+			} else {
+				buf.WriteString("'" + exportEscape(e.Index.String()) + "'")
+			}
+			buf.WriteString(" => ")
+			varExport(buf, e.Value, level+2)
+			buf.WriteString(",\n")
+		}
+		if level > 1 {
+			buf.WriteString(strings.Repeat(" ", level-1))
+		}
+		buf.WriteByte(')')
+	case php.TypeObject:
+		obj := v.Object()
+		if level > 1 {
+			buf.WriteByte('\n')
+			buf.WriteString(strings.Repeat(" ", level-1))
+		}
+		std := strings.EqualFold(obj.Name, "stdClass")
+		if std {
+			buf.WriteString("(object) array(\n")
+		} else {
+			buf.WriteString(`\` + obj.Name + "::__set_state(array(\n")
+		}
+		for _, f := range obj.Fields {
+			buf.WriteString(strings.Repeat(" ", level+2))
+			buf.WriteString("'" + exportEscape(f.Name) + "' => ")
+			varExport(buf, f.Value, level+2)
+			buf.WriteString(",\n")
+		}
+		if level > 1 {
+			buf.WriteString(strings.Repeat(" ", level-1))
+		}
+		if std {
+			buf.WriteByte(')')
+		} else {
+			buf.WriteString("))")
+		}
+	default:
+		buf.WriteString("NULL")
+	}
+}
+
+var exportEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// exportEscape escapes s for a single-quoted PHP string literal.
+func exportEscape(s string) string {
+	return exportEscaper.Replace(s)
+}
+
+// exportFloat formats f as var_export does, keeping a fraction on integral
+// values so that they read back as floats.
+func exportFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NAN"
+	case math.IsInf(f, 1):
+		return "INF"
+	case math.IsInf(f, -1):
+		return "-INF"
+	}
+	s := phpfloat.Format(f, -1)
+	if !strings.ContainsAny(s, ".E") {
+		s += ".0"
+	}
+	return s
+}
@@ -0,0 +1,68 @@
+package phpserialize_test
+
+import (
+	"bytes"
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestVarExport(t *testing.T) {
+	cases := []struct {
+		v    *php.Value
+		want string
+	}{
+		{v: php.Null(), want: `NULL`},
+		{v: php.Bool(false), want: `false`},
+		{v: php.Int(-3), want: `-3`},
+		{v: php.Float(1), want: `1.0`},
+		{v: php.Float(0.1), want: `0.1`},
+		{v: php.Float(1e25), want: `1.0E+25`},
+		{v: php.Inf(-1), want: `-INF`},
+		{v: php.String(`it's a \ "x"`), want: `'it\'s a \\ "x"'`},
+		{v: php.String("a\x00b"), want: `'a' . "\0" . 'b'`},
+		{v: php.Array(), want: "array (\n)"},
+		{
+			v: php.Array(
+				php.Element(php.Int(0), php.Int(1)),
+				php.Element(php.String("a'b"), php.List(php.Bool(true))),
+			),
+			want: "array (\n" +
+				"  0 => 1,\n" +
+				"  'a\\'b' => \n" +
+				"  array (\n" +
+				"    0 => true,\n" +
+				"  ),\n" +
+				")",
+		},
+		{
+			v: php.Object(`App\User`,
+				php.PubField("name", php.String("bob")),
+				php.ProtectedField("tags", php.List(php.String("x"))),
+				php.PrivField("meta", php.Object("stdClass", php.PubField("a", php.Null()))),
+			),
+			want: "\\App\\User::__set_state(array(\n" +
+				"   'name' => 'bob',\n" +
+				"   'tags' => \n" +
+				"  array (\n" +
+				"    0 => 'x',\n" +
+				"  ),\n" +
+				"   'meta' => \n" +
+				"  (object) array(\n" +
+				"     'a' => NULL,\n" +
+				"  ),\n" +
+				"))",
+		},
+	}
+	for i, tc := range cases {
+		var buf bytes.Buffer
+		if err := phpserialize.VarExport(&buf, tc.v); err != nil {
+			t.Errorf("#%d: VarExport(...) returns error: %v", i, err)
+			continue
+		}
+		if got := buf.String(); got != tc.want {
+			t.Errorf("#%d: VarExport(...) writes:\n%s\nwant:\n%s", i, got, tc.want)
+		}
+	}
+}
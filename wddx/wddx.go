@@ -0,0 +1,339 @@
+// Package wddx converts between php.Values and WDDX packets as written and
+// read by PHP's wddx extension.
+package wddx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// ClassNameVar is the name of the struct variable that holds the class name
+// of a serialized object.
+const ClassNameVar = "php_class_name"
+
+// Marshal returns the WDDX packet of v. Like PHP, it writes arrays with the
+// keys 0, 1, 2, ... in order as WDDX arrays, other arrays as structs, and
+// objects as structs holding their class name in ClassNameVar followed by
+// their fields, whose visibility is lost. NaN and infinities cannot be
+// represented.
+func Marshal(v *php.Value) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("<wddxPacket version='1.0'><header/><data>")
+	if err := writeValue(&buf, v); err != nil {
+		return nil, err
+	}
+	buf.WriteString("</data></wddxPacket>")
+	return buf.Bytes(), nil
+}
+
+func writeValue(buf *bytes.Buffer, v *php.Value) error {
+	if v.IsNil() {
+		buf.WriteString("<null/>")
+		return nil
+	}
+	switch v.Type() {
+	case php.TypeBool:
+		buf.WriteString("<boolean value='" + strconv.FormatBool(v.Bool()) + "'/>")
+	case php.TypeInt:
+		buf.WriteString("<number>" + strconv.FormatInt(v.Int(), 10) + "</number>")
+	case php.TypeFloat:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("wddx: unsupported float value: %v", f)
+		}
+		buf.WriteString("<number>" + strconv.FormatFloat(f, 'g', -1, 64) + "</number>")
+	case php.TypeString:
+		buf.WriteString("<string>")
+		writeString(buf, v.String())
+		buf.WriteString("</string>")
+	case php.TypeArray:
+		arr := v.Array()
+		if v.IsList() {
+			buf.WriteString("<array length='" + strconv.Itoa(len(arr)) + "'>")
+			for _, e := range arr {
+				if err := writeValue(buf, e.Value); err != nil {
+					return err
+				}
+			}
+			buf.WriteString("</array>")
+			return nil
+		}
+		buf.WriteString("<struct>")
+		for _, e := range arr {
+			name := e.Index.String()
+			if e.Index.Type() == php.TypeInt {
+				name = strconv.FormatInt(e.Index.Int(), 10)
+			}
+			if err := writeVar(buf, name, e.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("</struct>")
+	case php.TypeObject:
+		obj := v.Object()
+		if obj.Serialized != nil {
+			return fmt.Errorf("wddx: objects serialized by Serializable are not supported")
+		}
+		name, fields := obj.Name, obj.Fields
+		if n, ok := obj.IncompleteClassName(); ok {
+			name, fields = n, fields[1:]
+		}
+		buf.WriteString("<struct>")
+		writeVar(buf, ClassNameVar, php.String(name))
+		for _, f := range fields {
+			if err := writeVar(buf, f.Name, f.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("</struct>")
+	default:
+		return fmt.Errorf("wddx: invalid value type: %v", v.Type())
+	}
+	return nil
+}
+
+func writeVar(buf *bytes.Buffer, name string, v *php.Value) error {
+	buf.WriteString("<var name='")
+	xml.EscapeText(buf, []byte(name))
+	buf.WriteString("'>")
+	if err := writeValue(buf, v); err != nil {
+		return err
+	}
+	buf.WriteString("</var>")
+	return nil
+}
+
+// writeString writes s escaped, with control characters written as char
+// elements.
+func writeString(buf *bytes.Buffer, s string) {
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 {
+			xml.EscapeText(buf, []byte(s[start:i]))
+			fmt.Fprintf(buf, "<char code='%02X'/>", c)
+			start = i + 1
+		}
+	}
+	xml.EscapeText(buf, []byte(s[start:]))
+}
+
+// Unmarshal parses the WDDX packet data and returns the value it holds.
+// Structs holding ClassNameVar decode as objects with public fields, other
+// structs as arrays whose keys are cast like PHP's. Binary values decode as
+// strings of their bytes and dateTime values as strings of their text;
+// recordsets are not supported.
+func Unmarshal(data []byte) (*php.Value, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		se, err := nextStart(dec)
+		if err != nil {
+			return nil, err
+		}
+		switch se.Name.Local {
+		case "wddxPacket":
+			continue
+		case "header":
+			if err := dec.Skip(); err != nil {
+				return nil, err
+			}
+			continue
+		case "data":
+			se, err := nextStart(dec)
+			if err != nil {
+				return nil, err
+			}
+			return readValue(dec, se)
+		default:
+			return nil, fmt.Errorf("wddx: unexpected element <%s>", se.Name.Local)
+		}
+	}
+}
+
+// nextStart returns the next start element, skipping other tokens, or an
+// error if an end element or the end of the data comes first.
+func nextStart(dec *xml.Decoder) (xml.StartElement, error) {
+	for {
+		t, err := dec.Token()
+		if err == io.EOF {
+			return xml.StartElement{}, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			return t, nil
+		case xml.EndElement:
+			return xml.StartElement{}, fmt.Errorf("wddx: unexpected </%s>", t.Name.Local)
+		}
+	}
+}
+
+// readChildren calls fn with every child element of the element being read,
+// up to its end element.
+func readChildren(dec *xml.Decoder, fn func(xml.StartElement) error) error {
+	for {
+		t, err := dec.Token()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			if err := fn(t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// readText returns the text of the element being read, in which char
+// elements stand for the characters with their codes.
+func readText(dec *xml.Decoder) (string, error) {
+	var b strings.Builder
+	for {
+		t, err := dec.Token()
+		if err == io.EOF {
+			return "", io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := t.(type) {
+		case xml.CharData:
+			b.Write(t)
+		case xml.StartElement:
+			if t.Name.Local != "char" {
+				return "", fmt.Errorf("wddx: unexpected element <%s> in text", t.Name.Local)
+			}
+			c, err := strconv.ParseUint(attr(t, "code"), 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("wddx: invalid char code %q", attr(t, "code"))
+			}
+			b.WriteByte(byte(c))
+			if err := dec.Skip(); err != nil {
+				return "", err
+			}
+		case xml.EndElement:
+			return b.String(), nil
+		}
+	}
+}
+
+func attr(se xml.StartElement, name string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func readValue(dec *xml.Decoder, se xml.StartElement) (*php.Value, error) {
+	switch se.Name.Local {
+	case "null":
+		return php.Null(), dec.Skip()
+	case "boolean":
+		b, err := strconv.ParseBool(attr(se, "value"))
+		if err != nil {
+			return nil, fmt.Errorf("wddx: invalid boolean %q", attr(se, "value"))
+		}
+		return php.Bool(b), dec.Skip()
+	case "number":
+		s, err := readText(dec)
+		if err != nil {
+			return nil, err
+		}
+		s = strings.TrimSpace(s)
+		if i, err := strconv.ParseInt(s, 10, strconv.IntSize); err == nil {
+			return php.Int(int(i)), nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("wddx: invalid number %q", s)
+		}
+		return php.Float(f), nil
+	case "string", "dateTime":
+		s, err := readText(dec)
+		if err != nil {
+			return nil, err
+		}
+		return php.String(s), nil
+	case "binary":
+		s, err := readText(dec)
+		if err != nil {
+			return nil, err
+		}
+		bs, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("wddx: invalid binary: %w", err)
+		}
+		return php.String(string(bs)), nil
+	case "array":
+		var vs []*php.Value
+		err := readChildren(dec, func(se xml.StartElement) error {
+			v, err := readValue(dec, se)
+			vs = append(vs, v)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return php.List(vs...), nil
+	case "struct":
+		return readStruct(dec)
+	default:
+		return nil, fmt.Errorf("wddx: unsupported element <%s>", se.Name.Local)
+	}
+}
+
+func readStruct(dec *xml.Decoder) (*php.Value, error) {
+	var (
+		names []string
+		es    []*php.ArrayElement
+	)
+	err := readChildren(dec, func(se xml.StartElement) error {
+		if se.Name.Local != "var" {
+			return fmt.Errorf("wddx: unexpected element <%s> in struct", se.Name.Local)
+		}
+		vse, err := nextStart(dec)
+		if err != nil {
+			return err
+		}
+		v, err := readValue(dec, vse)
+		if err != nil {
+			return err
+		}
+		names = append(names, attr(se, "name"))
+		es = append(es, php.Element(php.Key(attr(se, "name")), v))
+		return dec.Skip()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(es) == 0 || names[0] != ClassNameVar {
+		return php.Array(es...), nil
+	}
+	name, err := es[0].Value.TryString()
+	if err != nil {
+		return nil, errors.New("wddx: class name is not a string")
+	}
+	fields := make([]*php.ObjField, len(es)-1)
+	for i, e := range es[1:] {
+		fields[i] = php.PubField(names[i+1], e.Value)
+	}
+	return php.Object(name, fields...), nil
+}
@@ -0,0 +1,92 @@
+package wddx_test
+
+import (
+	"testing"
+
+	"github.com/kamiaka/go-phpserialize/php"
+	"github.com/kamiaka/go-phpserialize/wddx"
+)
+
+const (
+	head = "<wddxPacket version='1.0'><header/><data>"
+	tail = "</data></wddxPacket>"
+)
+
+func TestMarshal(t *testing.T) {
+	cases := []struct {
+		v    *php.Value
+		want string
+	}{
+		{v: php.Null(), want: `<null/>`},
+		{v: php.Bool(true), want: `<boolean value='true'/>`},
+		{v: php.Int(-5), want: `<number>-5</number>`},
+		{v: php.Float(1.5), want: `<number>1.5</number>`},
+		{v: php.String("a<b>&\n"), want: `<string>a&lt;b&gt;&amp;<char code='0A'/></string>`},
+		{v: php.List(php.Int(1), php.String("x")), want: `<array length='2'><number>1</number><string>x</string></array>`},
+		{
+			v:    php.Array(php.Element(php.String("a"), php.Null()), php.Element(php.Int(5), php.Bool(false))),
+			want: `<struct><var name='a'><null/></var><var name='5'><boolean value='false'/></var></struct>`,
+		},
+		{
+			v:    php.Object("Foo", php.PubField("a", php.Int(1)), php.PubField("1", php.Int(2))),
+			want: `<struct><var name='php_class_name'><string>Foo</string></var><var name='a'><number>1</number></var><var name='1'><number>2</number></var></struct>`,
+		},
+	}
+	for i, tc := range cases {
+		got, err := wddx.Marshal(tc.v)
+		if err != nil {
+			t.Errorf("#%d: Marshal(...) returns error: %v", i, err)
+			continue
+		}
+		if string(got) != head+tc.want+tail {
+			t.Errorf("#%d: Marshal(...) == %q, want: %q", i, got, head+tc.want+tail)
+		}
+		v, err := wddx.Unmarshal(got)
+		if err != nil {
+			t.Errorf("#%d: Unmarshal(%q) returns error: %v", i, got, err)
+			continue
+		}
+		if !php.Equal(v, tc.v) {
+			t.Errorf("#%d: Unmarshal(%q) == %#v, want: %#v", i, got, v, tc.v)
+		}
+	}
+	if _, err := wddx.Marshal(php.NaN()); err == nil {
+		t.Errorf("Marshal(NaN()) returns no error")
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	data := `<?xml version="1.0"?>
+<wddxPacket version="1.0">
+  <header><comment>test</comment></header>
+  <data>
+    <struct>
+      <var name="bin"><binary length="3">YWJj</binary></var>
+      <var name="when"><dateTime>2002-06-30T18:15:31</dateTime></var>
+    </struct>
+  </data>
+</wddxPacket>`
+	want := php.Assoc(map[string]*php.Value{
+		"bin":  php.String("abc"),
+		"when": php.String("2002-06-30T18:15:31"),
+	})
+	v, err := wddx.Unmarshal([]byte(data))
+	if err != nil {
+		t.Fatalf("Unmarshal(...) returns error: %v", err)
+	}
+	if !php.Equal(v, want) {
+		t.Errorf("Unmarshal(...) == %#v, want: %#v", v, want)
+	}
+
+	for _, data := range []string{
+		``,
+		head + `<number>x</number>` + tail,
+		head + `<recordset/>` + tail,
+		head + `<struct><number>1</number></struct>` + tail,
+		head + `<array length='1'><null/>`,
+	} {
+		if _, err := wddx.Unmarshal([]byte(data)); err == nil {
+			t.Errorf("Unmarshal(%q) returns no error", data)
+		}
+	}
+}
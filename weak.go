@@ -0,0 +1,96 @@
+package phpserialize
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+
+	"github.com/kamiaka/go-phpserialize/internal/decoding"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// The functions below convert scalar Values like PHP's casts for the
+// WithWeakTypes option. They report false for arrays and objects.
+
+// weakBool converts src like PHP's (bool) cast.
+func weakBool(src *php.Value) (bool, bool) {
+	switch src.Type() {
+	case php.TypeBool:
+		return src.Bool(), true
+	case php.TypeInt:
+		return src.Int() != 0, true
+	case php.TypeFloat:
+		return src.Float() != 0, true
+	case php.TypeString:
+		s := src.String()
+		return s != "" && s != "0", true
+	}
+	return false, false
+}
+
+// weakInt converts src like PHP's (int) cast, except that it reports false
+// for floats that do not fit in an int64 rather than wrapping them.
+func weakInt(src *php.Value) (int64, bool) {
+	switch src.Type() {
+	case php.TypeBool:
+		if src.Bool() {
+			return 1, true
+		}
+		return 0, true
+	case php.TypeInt:
+		return src.Int(), true
+	case php.TypeFloat:
+		return decoding.FloatToInt(src.Float())
+	case php.TypeString:
+		s := trimNumeric(numericPrefix.FindString(src.String()))
+		if s == "" {
+			return 0, true
+		}
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err == nil || errors.Is(err, strconv.ErrRange) {
+			// PHP saturates integer strings that overflow
+			return i, true
+		}
+		f, _ := strconv.ParseFloat(s, 64)
+		return decoding.FloatToInt(f)
+	}
+	return 0, false
+}
+
+// weakFloat converts src like PHP's (float) cast.
+func weakFloat(src *php.Value) (float64, bool) {
+	switch src.Type() {
+	case php.TypeBool:
+		if src.Bool() {
+			return 1, true
+		}
+		return 0, true
+	case php.TypeInt:
+		return float64(src.Int()), true
+	case php.TypeFloat:
+		return src.Float(), true
+	case php.TypeString:
+		f, _ := strconv.ParseFloat(trimNumeric(numericPrefix.FindString(src.String())), 64)
+		return f, true
+	}
+	return 0, false
+}
+
+// weakString converts src like PHP's (string) cast.
+func weakString(src *php.Value) (string, bool) {
+	switch src.Type() {
+	case php.TypeBool:
+		return phpBoolString(src.Bool()), true
+	case php.TypeInt:
+		return strconv.FormatInt(src.Int(), 10), true
+	case php.TypeFloat:
+		return phpFloatString(src.Float()), true
+	case php.TypeString:
+		return src.String(), true
+	}
+	return "", false
+}
+
+// numericPrefix matches the leading numeric part of a string that PHP's
+// casts convert, such as "42" in "42 apples".
+var numericPrefix = regexp.MustCompile(`^[ \t\n\r\v\f]*[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?`)
@@ -0,0 +1,155 @@
+package phpserialize
+
+import (
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kamiaka/go-phpserialize/internal/phpfloat"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+// phpTrimSet is the set of characters removed by PHP's trim.
+const phpTrimSet = " \t\n\r\x00\x0B"
+
+var (
+	wpSizedToken     = regexp.MustCompile(`^[saOE]:[0-9]+:`)
+	wpScalarToken    = regexp.MustCompile(`^[bid]:[0-9.E+-]+;`)
+	wpScalarTokenEnd = regexp.MustCompile(`^[bid]:[0-9.E+-]+;$`)
+)
+
+// IsSerialized reports whether data looks like PHP serialized data, using the
+// same heuristics as WordPress's is_serialized, which only checks the type
+// token and the shape of the value rather than parsing it. When strict is
+// false, data only needs to end with the value's first ';' or '}' somewhere.
+func IsSerialized(data string, strict bool) bool {
+	data = strings.Trim(data, phpTrimSet)
+	if data == "N;" {
+		return true
+	}
+	if len(data) < 4 || data[1] != ':' {
+		return false
+	}
+	if strict {
+		if last := data[len(data)-1]; last != ';' && last != '}' {
+			return false
+		}
+	} else {
+		semicolon := strings.IndexByte(data, ';')
+		brace := strings.IndexByte(data, '}')
+		if semicolon == -1 && brace == -1 {
+			return false
+		}
+		if semicolon != -1 && semicolon < 3 || brace != -1 && brace < 4 {
+			return false
+		}
+	}
+	switch data[0] {
+	case 's':
+		if strict {
+			if data[len(data)-2] != '"' {
+				return false
+			}
+		} else if !strings.Contains(data, `"`) {
+			return false
+		}
+		return wpSizedToken.MatchString(data)
+	case 'a', 'O', 'E':
+		return wpSizedToken.MatchString(data)
+	case 'b', 'i', 'd':
+		if strict {
+			return wpScalarTokenEnd.MatchString(data)
+		}
+		return wpScalarToken.MatchString(data)
+	}
+	return false
+}
+
+// MaybeUnserialize decodes data if IsSerialized(data, true) reports it as
+// serialized, like WordPress's maybe_unserialize, and returns it as a string
+// Value otherwise.
+func MaybeUnserialize(data string) (*php.Value, error) {
+	if !IsSerialized(data, true) {
+		return php.String(data), nil
+	}
+	return Unmarshal([]byte(strings.Trim(data, phpTrimSet)))
+}
+
+// MaybeSerialize returns the string WordPress's maybe_serialize stores for v:
+// arrays, slices, maps, structs and array or object php.Values are
+// serialized, as are strings that IsSerialized(s, false) reports as
+// serialized, so that they are serialized twice; other strings are returned
+// as they are and other scalars are converted the way PHP converts them to
+// strings.
+func MaybeSerialize(v interface{}) (string, error) {
+	if s, ok := wpScalarString(v); ok {
+		if !IsSerialized(s, false) {
+			return s, nil
+		}
+		v = s
+	}
+	bs, err := Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+// wpScalarString returns v converted to a string as PHP does, if v is not an
+// array or an object.
+func wpScalarString(v interface{}) (string, bool) {
+	if pv, ok := v.(*php.Value); ok {
+		if pv.IsNil() {
+			return "", true
+		}
+		switch pv.Type() {
+		case php.TypeBool:
+			return phpBoolString(pv.Bool()), true
+		case php.TypeInt:
+			return strconv.FormatInt(pv.Int(), 10), true
+		case php.TypeFloat:
+			return phpFloatString(pv.Float()), true
+		case php.TypeString:
+			return pv.String(), true
+		}
+		return "", false
+	}
+	if b, ok := v.([]byte); ok {
+		return string(b), true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return "", true
+	case reflect.Bool:
+		return phpBoolString(rv.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return phpFloatString(rv.Float()), true
+	case reflect.String:
+		return rv.String(), true
+	}
+	return "", false
+}
+
+// phpBoolString formats b as PHP's string conversion does.
+func phpBoolString(b bool) string {
+	if b {
+		return "1"
+	}
+	return ""
+}
+
+// phpFloatString formats f as PHP's string conversion does with the default
+// precision setting of 14.
+func phpFloatString(f float64) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return dumpFloat(f)
+	}
+	return phpfloat.Format(f, 14)
+}
@@ -0,0 +1,94 @@
+package phpserialize_test
+
+import (
+	"testing"
+
+	phpserialize "github.com/kamiaka/go-phpserialize"
+	"github.com/kamiaka/go-phpserialize/php"
+)
+
+func TestIsSerialized(t *testing.T) {
+	cases := []struct {
+		data   string
+		strict bool
+		want   bool
+	}{
+		{data: `N;`, strict: true, want: true},
+		{data: " a:0:{}\n", strict: true, want: true},
+		{data: `s:3:"abc";`, strict: true, want: true},
+		{data: `s:30:"abc";`, strict: true, want: true},
+		{data: `i:5;`, strict: true, want: true},
+		{data: `d:0.5;`, strict: true, want: true},
+		{data: `b:1;`, strict: true, want: true},
+		{data: `O:8:"stdClass":0:{}`, strict: true, want: true},
+		{data: `i:5;x`, strict: true, want: false},
+		{data: `i:5;x`, strict: false, want: true},
+		{data: `s:3:"abc";x`, strict: false, want: true},
+		{data: `s:3:abc;`, strict: true, want: false},
+		{data: `x:5;`, strict: true, want: false},
+		{data: `i:;`, strict: true, want: false},
+		{data: `hello`, strict: false, want: false},
+		{data: `a:1`, strict: false, want: false},
+	}
+	for i, tc := range cases {
+		if got := phpserialize.IsSerialized(tc.data, tc.strict); got != tc.want {
+			t.Errorf("#%d: IsSerialized(%q, %v) == %v, want: %v", i, tc.data, tc.strict, got, tc.want)
+		}
+	}
+}
+
+func TestMaybeUnserialize(t *testing.T) {
+	cases := []struct {
+		data       string
+		want       *php.Value
+		wantsError bool
+	}{
+		{data: `hello`, want: php.String("hello")},
+		{data: "a:1:{i:0;b:1;}\n", want: php.List(php.Bool(true))},
+		{data: `s:9:"abc";`, wantsError: true},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.MaybeUnserialize(tc.data)
+		if err != nil {
+			if !tc.wantsError {
+				t.Errorf("#%d: MaybeUnserialize(%q) returns error: %v", i, tc.data, err)
+			}
+			continue
+		}
+		if tc.wantsError {
+			t.Errorf("#%d: MaybeUnserialize(%q) wants error but no error occurred", i, tc.data)
+			continue
+		}
+		if !php.Equal(got, tc.want) {
+			t.Errorf("#%d: MaybeUnserialize(%q) == %#v, want: %#v", i, tc.data, got, tc.want)
+		}
+	}
+}
+
+func TestMaybeSerialize(t *testing.T) {
+	cases := []struct {
+		v    interface{}
+		want string
+	}{
+		{v: "hello", want: "hello"},
+		{v: `i:1;`, want: `s:4:"i:1;";`},
+		{v: 42, want: "42"},
+		{v: true, want: "1"},
+		{v: false, want: ""},
+		{v: nil, want: ""},
+		{v: 0.1 + 0.2, want: "0.3"},
+		{v: []int{1}, want: `a:1:{i:0;i:1;}`},
+		{v: php.Int(7), want: "7"},
+		{v: php.List(php.Null()), want: `a:1:{i:0;N;}`},
+	}
+	for i, tc := range cases {
+		got, err := phpserialize.MaybeSerialize(tc.v)
+		if err != nil {
+			t.Errorf("#%d: MaybeSerialize(%#v) returns error: %v", i, tc.v, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("#%d: MaybeSerialize(%#v) == %q, want: %q", i, tc.v, got, tc.want)
+		}
+	}
+}